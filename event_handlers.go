@@ -2,16 +2,21 @@ package main
 
 import (
 	"encoding/json"
+	"io"
+	"math/rand"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/atedja/go-vector"
+	log "github.com/sirupsen/logrus"
+
 	"GoGoGadgetGravity/guis"
 	"GoGoGadgetGravity/physics"
 	"GoGoGadgetGravity/state"
 )
 
-// SaveStateEvent saves the current simulation state to file.
+// SaveStateEvent saves the current simulation state to file, gzipped if the file name ends in .gz (see state.Compress).
 // It is triggered by the GUI after it provides a file picker to the user (the selected file path is passed to this
 // function).
 func SaveStateEvent(file string) {
@@ -23,13 +28,17 @@ func SaveStateEvent(file string) {
 		if err == nil {
 			_, err = f.Seek(0, 0)
 		}
-		// Create a json encoder that uses the file as its output
-		enc := json.NewEncoder(f)
+		// Create a json encoder that uses the file as its output (gzipped if the file name ends in .gz)
+		w := state.Compress(f, file)
+		enc := json.NewEncoder(w)
 		enc.SetIndent("", "\t")
 		// Encode (output to file)
 		if err == nil {
 			err = enc.Encode(State)
 		}
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
 		if err == nil {
 			err = f.Sync()
 		}
@@ -44,33 +53,19 @@ func SaveStateEvent(file string) {
 	}
 }
 
-// LoadStateEvent loads the simulation state saved in a file.
+// LoadStateEvent loads the simulation state saved in a file (which may be gzipped; see state.Decompress).
 // It is triggered by the GUI after it provides a file picker to the user (the selected file path is passed to this
 // function).
 func LoadStateEvent(file string) {
 	f, err := os.OpenFile(file, os.O_RDONLY, 0755)
 	if err == nil {
 		defer f.Close()
-		// Create a state.Data struct and decode the json data from the file into it
-		var data *state.Data
-		err = json.NewDecoder(f).Decode(&data)
+		err = LoadStateFromReader(f)
 		if err == nil {
-			// The values of State are assigned the values we just read
-			*State = *data
-			// Since State.PhysicsEngine is a pointer, the values from the file aren't populated to the engine; set the
-			// engine data to the values from file
-			physics.Engine = *data.PhysicsEngine
-			// Reset the State.PhysicsEngine to point to the physics.Engine (was it wiped by *Sate = *data?)
-			// Todo: check if this is necessary
-			State.PhysicsEngine = &physics.Engine
-
-			// Calculate the proxies etc.
-			physics.InitializeParticles()
-			physics.SaveInitialParticleStates()
-
 			// Tell the GUI to set control values and redraw the scene
 			initialValues := guis.GUIInitializationData{
-				// Important to use State instead of data because particle initialization has been done on State now
+				// Important to use State instead of the data read because particle initialization has been done on
+				// State now
 				Data: State,
 				// Not used by LoadState
 				WinMinWidth: 0,
@@ -79,11 +74,6 @@ func LoadStateEvent(file string) {
 			}
 			GUI.LoadState(initialValues)
 
-			// Individual particle TrackHistory and particle HistorySize (and the history slice) are not stored in file.
-			// Restore these settings (and initialize history slice) using the global State settings as read from file.
-			HistoryTrailChangedEvent(data.HistoryTrail)
-			HistoryTrailLengthChangedEvent(data.HistoryLength)
-
 			GUI.SetStatusText("Settings and "+strconv.Itoa(len(State.PhysicsEngine.Particles))+
 				" particles loaded from file: "+file, 0)
 		} else {
@@ -94,14 +84,209 @@ func LoadStateEvent(file string) {
 	}
 }
 
-// EnvironmentSizeChangedEvent updates the physics.Engine.EnvironmentSize and, if the simulation is currently paused,
-// generates new particles randomly within that environment.
+// SaveSettingsEvent saves the current settings (see state.Settings), without the particles, to file, so that they may be
+// shared or applied to other simulations (see LoadSettingsEvent).
+// It is triggered by the GUI after it provides a file picker to the user (the selected file path is passed to this
+// function).
+func SaveSettingsEvent(file string) {
+	f, err := os.Create(file)
+	if err != nil {
+		GUI.SetStatusText("Saving settings to file failed. Error: "+err.Error(), 0)
+		return
+	}
+	defer f.Close()
+	w := state.Compress(f, file)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(State.Settings())
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		GUI.SetStatusText("Saving settings failed. Error: "+err.Error(), 0)
+		return
+	}
+	GUI.SetStatusText("Current settings saved to file: "+file, 0)
+}
+
+// LoadSettingsEvent applies the settings saved in a file (see SaveSettingsEvent) to the current simulation, keeping the
+// current particles (see LoadSettingsFromReader). The GUI updates its controls and redraws the particles.
+// It is triggered by the GUI after it provides a file picker to the user (the selected file path is passed to this
+// function).
+func LoadSettingsEvent(file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		GUI.SetStatusText("Loading settings from file failed. Error: "+err.Error(), 0)
+		return
+	}
+	defer f.Close()
+	if err = LoadSettingsFromReader(f); err != nil {
+		GUI.SetStatusText("Loading settings from file failed. Error: "+err.Error(), 0)
+		return
+	}
+	GUI.LoadState(guis.GUIInitializationData{Data: State})
+	GUI.SetStatusText("Settings loaded from file: "+file, 0)
+}
+
+// ResetSettingsEvent restores the physics.Engine settings to their defaults (see physics.EngineData.ResetToDefaults),
+// keeping the current particles (though they're recolored, in case the color scheme changed, and any negative far
+// charges are clamped to 0), and updates the GUI controls to match.
+// It is triggered by the GUI.
+func ResetSettingsEvent() {
+	History.Record(State, "")
+	physics.LockParticles()
+	State.PhysicsEngine.ResetToDefaults()
+	physics.ClampFarCharges()
+	physics.RecolorParticles()
+	physics.UnlockParticles()
+	GUI.LoadState(guis.GUIInitializationData{Data: State})
+	GUI.SetStatusText("Physics settings reset to defaults", 0)
+}
+
+// LoadSettingsFromReader applies the settings (see state.Settings) from the (json) data read from r to the current
+// State and physics.Engine, keeping the current particles. Settings not in the data (such as from older files) keep
+// their current values. The data may also be a full saved state, in which case only its settings are applied. It
+// doesn't update the GUI (see LoadSettingsEvent). The particles lock is held while the settings are applied (see
+// physics.LockParticles), so it mustn't be held by the caller.
+// If the data can't be decoded, an error is returned and the current State is left unchanged (and nothing is recorded
+// in the History). Otherwise, the current State is recorded before the settings are applied, so loading them can be
+// undone.
+func LoadSettingsFromReader(r io.Reader) error {
+	settings := State.Settings()
+	if err := state.DecodeSettings(r, settings); err != nil {
+		return err
+	}
+	History.Record(State, "")
+
+	physics.LockParticles()
+	defer physics.UnlockParticles()
+	State.ApplySettings(settings)
+	if err := State.PhysicsEngine.Validate(); err != nil {
+		log.Warnln("Loaded settings: " + err.Error())
+	}
+	// The environment size may have changed, and the particles' colors may depend on the settings
+	if moved := physics.ConfineParticles(); moved > 0 {
+		log.Infoln("Loaded settings: moved " + strconv.Itoa(moved) + " particles inside the environment")
+	}
+	// The settings may not allow the particles' far charges (see physics.EngineData.SignedFarCharge)
+	if clamped := physics.ClampFarCharges(); clamped > 0 {
+		log.Infoln("Loaded settings: clamped the far charges of " + strconv.Itoa(clamped) + " particles")
+	}
+	physics.RecolorParticles()
+	return nil
+}
+
+// LoadPresetEvent replaces the settings and particles with those of the named built-in scenario (see physics.Presets).
+// Like loading from file, the particles' initial states are saved and their position histories restarted, and the GUI
+// updates its controls and redraws the particles.
+// It is triggered by the GUI.
+func LoadPresetEvent(name string) {
+	preset := physics.FindPreset(name)
+	if preset == nil {
+		GUI.SetStatusText("Unknown preset: "+name, 0)
+		return
+	}
+	History.Record(State, "")
+	physics.LockParticles()
+	preset.Load()
+	// Keep the particle count control in line with the scenario
+	State.NumberOfParticles = len(State.PhysicsEngine.Particles)
+	physics.UnlockParticles()
+
+	GUI.LoadState(guis.GUIInitializationData{Data: State})
+	GUI.SetStatusText("Preset "+name+" loaded ("+strconv.Itoa(State.NumberOfParticles)+" particles)", 0)
+}
+
+// LoadStateFromReader loads the simulation state from the (json) data read from r, replacing the current State and
+// physics.Engine values and particles. It doesn't update the GUI (see LoadStateEvent). The particles lock is held while
+// they're replaced (see physics.LockParticles), so it mustn't be held by the caller.
+// If the data can't be decoded, an error is returned and the current State is left unchanged.
+func LoadStateFromReader(r io.Reader) error {
+	// Create a state.Data struct and decode the json data into it (upgrading data saved in older formats). The engine
+	// data is initialized first, so that any values not in the data keep their defaults.
+	data := &state.Data{PhysicsEngine: &physics.EngineData{}, InitialSpeed: initialSpeed, HistoryStride: 1,
+		DrawRadiusScale: 1, MinDrawRadius: 1, WallColor: state.DefaultWallColor,
+		PauseSpeedThreshold: initialPauseSpeedThreshold, StepsPerFrame: 1}
+	data.PhysicsEngine.Initialize()
+	if err := state.Decode(r, data); err != nil {
+		return err
+	}
+
+	physics.LockParticles()
+	defer physics.UnlockParticles()
+	// The values of State are assigned the values we just read
+	*State = *data
+	// Since State.PhysicsEngine is a pointer, the values read aren't populated to the engine; set the engine data to
+	// the values read
+	physics.Engine = *data.PhysicsEngine
+	// Reset the State.PhysicsEngine to point to the physics.Engine (*State = *data pointed it at data's engine)
+	State.PhysicsEngine = &physics.Engine
+	// Saved states may have been edited by hand (or created by other tools), so they may contain invalid settings
+	if err := State.PhysicsEngine.Validate(); err != nil {
+		log.Warnln("Loaded state: " + err.Error())
+	}
+
+	// Calculate the proxies etc.
+	physics.InitializeParticles()
+	// Particles outside the saved environment (e.g. if its size was edited) are moved inside it (once their radii are
+	// calculated)
+	if moved := physics.ConfineParticles(); moved > 0 {
+		log.Warnln("Loaded state: moved " + strconv.Itoa(moved) + " particles outside the environment inside it")
+	}
+	physics.SaveInitialParticleStates()
+	// The loaded particles start a new genealogy
+	physics.ClearGenealogy()
+
+	// Individual particle position histories are restored from the data. Apply the history settings as read to the
+	// particles (older files don't include the individual particle settings), and to any particles added later.
+	physics.SetHistoryPrefill(State.HistoryPrefill)
+	physics.SetParticleHistory(State.HistoryTrail, State.HistoryLength, State.HistoryStride)
+
+	return nil
+}
+
+// EnvironmentWidthChangedEvent updates the physics.Engine.EnvironmentWidth and, if the simulation is currently paused,
+// generates new particles randomly within that environment. Otherwise, any particles left outside the (shrunken)
+// environment are moved back inside it (see confineParticles).
+// It is triggered by the GUI.
+func EnvironmentWidthChangedEvent(value int) {
+	History.Record(State, "EnvironmentWidth")
+	State.PhysicsEngine.EnvironmentWidth = value
+	if isPaused() {
+		GenerateParticles()
+		GUI.UpdateView()
+	} else {
+		confineParticles()
+	}
+}
+
+// EnvironmentHeightChangedEvent updates the physics.Engine.EnvironmentHeight and, if the simulation is currently
+// paused, generates new particles randomly within that environment. Otherwise, any particles left outside the
+// (shrunken) environment are moved back inside it (see confineParticles).
 // It is triggered by the GUI.
-func EnvironmentSizeChangedEvent(value int) {
-	State.PhysicsEngine.EnvironmentSize = value
-	if paused {
+func EnvironmentHeightChangedEvent(value int) {
+	History.Record(State, "EnvironmentHeight")
+	State.PhysicsEngine.EnvironmentHeight = value
+	if isPaused() {
 		GenerateParticles()
-		GUI.UpdateView(State.PhysicsEngine.Particles)
+		GUI.UpdateView()
+	} else {
+		confineParticles()
+	}
+}
+
+// confineParticles moves any particles outside the environment (after it shrinks) back inside it, clamping them to the
+// edges with their outward velocities reflected, or wrapping them around (see physics.ConfineParticles), and tells the
+// user how many were moved.
+func confineParticles() {
+	physics.LockParticles()
+	moved := physics.ConfineParticles()
+	physics.UnlockParticles()
+	if moved > 0 {
+		GUI.SetStatusText("Moved "+strconv.Itoa(moved)+" particles inside the resized environment", 1500)
 	}
 }
 
@@ -109,10 +294,11 @@ func EnvironmentSizeChangedEvent(value int) {
 // particles.
 // It is triggered by the GUI.
 func NumParticlesChangedEvent(value int) {
+	History.Record(State, "NumberOfParticles")
 	State.NumberOfParticles = value
-	if paused {
+	if isPaused() {
 		GenerateParticles()
-		GUI.DrawParticles(State.PhysicsEngine.Particles)
+		GUI.DrawParticles()
 	}
 }
 
@@ -120,71 +306,443 @@ func NumParticlesChangedEvent(value int) {
 // generates those particles.
 // It is triggered by the GUI.
 func AverageMassChangedEvent(value int) {
+	History.Record(State, "AverageMass")
 	State.AverageMass = value
-	if paused {
+	if isPaused() {
+		GenerateParticles()
+		GUI.DrawParticles()
+	}
+}
+
+// InitialVelocityModeChangedEvent updates the way the velocities of generated particles are initialized (see
+// state.InitialVelocityMode), and if the simulation is paused generates those particles.
+// It is triggered by the GUI.
+func InitialVelocityModeChangedEvent(value int) {
+	History.Record(State, "InitialVelocityMode")
+	State.InitialVelocityMode = state.InitialVelocityMode(value)
+	if isPaused() {
 		GenerateParticles()
-		GUI.DrawParticles(State.PhysicsEngine.Particles)
+		GUI.DrawParticles()
+	}
+}
+
+// InitialSpeedChangedEvent updates the scale of the initial velocities of generated particles, and if the simulation
+// is paused generates those particles.
+// It is triggered by the GUI.
+func InitialSpeedChangedEvent(value float64) {
+	History.Record(State, "InitialSpeed")
+	State.InitialSpeed = value
+	if isPaused() {
+		GenerateParticles()
+		GUI.DrawParticles()
+	}
+}
+
+// GenerationRegionChangedEvent sets the region generated particles are placed within to the circle centered on (x, y)
+// with the provided radius (replacing any other State.GenerationRegions), or to the whole environment if radius is 0,
+// and if the simulation is paused generates those particles.
+// It is triggered by the GUI.
+func GenerationRegionChangedEvent(x, y, radius float64) {
+	History.Record(State, "GenerationRegions")
+	if radius > 0 {
+		State.GenerationRegions = []state.GenerationRegion{{Shape: state.RegionCircle, X: x, Y: y, Radius: radius}}
+	} else {
+		State.GenerationRegions = nil
+	}
+	if isPaused() {
+		GenerateParticles()
+		GUI.DrawParticles()
+	}
+}
+
+// ChargeDistributionChangedEvent updates the distribution the close charges of generated particles are drawn from (see
+// state.ChargeDistribution), and if the simulation is paused generates those particles.
+// It is triggered by the GUI.
+func ChargeDistributionChangedEvent(value int) {
+	History.Record(State, "ChargeDistribution")
+	State.ChargeDistribution = state.ChargeDistribution(value)
+	if isPaused() {
+		GenerateParticles()
+		GUI.DrawParticles()
 	}
 }
 
 // RegenParticlesEvent generates new random particles.
 // It is triggered by GUI.
 func RegenParticlesEvent() {
+	History.Record(State, "")
 	GenerateParticles()
-	GUI.DrawParticles(State.PhysicsEngine.Particles)
+	GUI.DrawParticles()
 }
 
 // GravityStrengthChangedEvent updates the physics.Engine.GravityStrength.
 // It is triggered by the GUI.
 func GravityStrengthChangedEvent(value float64) {
+	History.Record(State, "GravityStrength")
 	State.PhysicsEngine.GravityStrength = value
+	validateEngineSettings()
 }
 
 // CloseChargeStrengthChangedEvent updates the physics.Engine.CloseChargeStrength.
 // It is triggered by the GUI.
 func CloseChargeStrengthChangedEvent(value float64) {
+	History.Record(State, "CloseChargeStrength")
 	State.PhysicsEngine.CloseChargeStrength = value
+	validateEngineSettings()
 }
 
 // FarChargeStrengthChangedEvent updates the physics.Engine.FarChargeStrength.
 // It is triggered by the GUI.
 func FarChargeStrengthChangedEvent(value float64) {
+	History.Record(State, "FarChargeStrength")
 	State.PhysicsEngine.FarChargeStrength = value
+	validateEngineSettings()
+}
+
+// FarChargeExponentChangedEvent updates the physics.Engine.FarChargeExponent.
+// It is triggered by the GUI.
+func FarChargeExponentChangedEvent(value float64) {
+	History.Record(State, "FarChargeExponent")
+	State.PhysicsEngine.FarChargeExponent = value
+	validateEngineSettings()
+}
+
+// FarChargeCutoffChangedEvent updates the physics.Engine.FarChargeCutoff.
+// It is triggered by the GUI.
+func FarChargeCutoffChangedEvent(value float64) {
+	History.Record(State, "FarChargeCutoff")
+	State.PhysicsEngine.FarChargeCutoff = value
+}
+
+// GravityOnlyChangedEvent updates physics.Engine.GravityOnly.
+// It is triggered by the GUI.
+func GravityOnlyChangedEvent(checked bool) {
+	History.Record(State, "GravityOnly")
+	State.PhysicsEngine.GravityOnly = checked
+}
+
+// EnableGravityChangedEvent updates physics.Engine.EnableGravity.
+// It is triggered by the GUI.
+func EnableGravityChangedEvent(checked bool) {
+	History.Record(State, "EnableGravity")
+	State.PhysicsEngine.EnableGravity = checked
+}
+
+// EnableCloseChargeChangedEvent updates physics.Engine.EnableCloseCharge.
+// It is triggered by the GUI.
+func EnableCloseChargeChangedEvent(checked bool) {
+	History.Record(State, "EnableCloseCharge")
+	State.PhysicsEngine.EnableCloseCharge = checked
+}
+
+// EnableFarChargeChangedEvent updates physics.Engine.EnableFarCharge.
+// It is triggered by the GUI.
+func EnableFarChargeChangedEvent(checked bool) {
+	History.Record(State, "EnableFarCharge")
+	State.PhysicsEngine.EnableFarCharge = checked
+}
+
+// SpeciesMatrixChangedEvent updates physics.Engine.SpeciesMatrix, assigns random species to the particles whose species
+// are outside the new matrix (or to all of them, if species were previously ignored), and recolors and redraws the
+// particles.
+// It is triggered by the GUI.
+func SpeciesMatrixChangedEvent(matrix [][]float64) {
+	History.Record(State, "")
+	physics.LockParticles()
+	reassignAll := State.PhysicsEngine.SpeciesMatrix == nil
+	State.PhysicsEngine.SpeciesMatrix = matrix
+	if species := len(matrix); species > 0 {
+		for _, p := range State.PhysicsEngine.Particles {
+			if reassignAll || p.Species() < 0 || p.Species() >= species {
+				p.SetSpecies(rand.Intn(species))
+			}
+		}
+	}
+	physics.RecolorParticles()
+	physics.UnlockParticles()
+	GUI.DrawParticles()
+}
+
+// validateEngineSettings validates the physics.Engine settings (see physics.EngineData.Validate), clamping any which
+// are invalid, and warns the user if any were.
+func validateEngineSettings() {
+	if err := State.PhysicsEngine.Validate(); err != nil {
+		log.Warnln(err.Error())
+		GUI.SetStatusText("Warning: "+err.Error(), 0)
+	}
 }
 
 // AllowMergeChangedEvent updates physics.Engine.AllowMerge.
 // It is triggered by the GUI.
 func AllowMergeChangedEvent(checked bool) {
+	History.Record(State, "AllowMerge")
 	State.PhysicsEngine.AllowMerge = checked
 }
 
-// WallBounceChangedEvent updates physics.Engine.WallBounce.
+// MergeMassRatioThresholdChangedEvent updates physics.Engine.MergeMassRatioThreshold.
+// It is triggered by the GUI.
+func MergeMassRatioThresholdChangedEvent(value float64) {
+	History.Record(State, "MergeMassRatioThreshold")
+	State.PhysicsEngine.MergeMassRatioThreshold = value
+}
+
+// MergeCloseChargeThresholdChangedEvent updates physics.Engine.MergeCloseChargeThreshold.
+// It is triggered by the GUI.
+func MergeCloseChargeThresholdChangedEvent(value float64) {
+	History.Record(State, "MergeCloseChargeThreshold")
+	State.PhysicsEngine.MergeCloseChargeThreshold = value
+}
+
+// CaptureSpeedChangedEvent updates physics.Engine.CaptureSpeed.
+// It is triggered by the GUI.
+func CaptureSpeedChangedEvent(value float64) {
+	History.Record(State, "CaptureSpeed")
+	State.PhysicsEngine.CaptureSpeed = value
+}
+
+// ChargeMergeRuleChangedEvent updates physics.Engine.ChargeMergeRule.
+// It is triggered by the GUI.
+func ChargeMergeRuleChangedEvent(value int) {
+	History.Record(State, "ChargeMergeRule")
+	State.PhysicsEngine.ChargeMergeRule = physics.ChargeMergeRule(value)
+}
+
+// AllowFissionChangedEvent updates physics.Engine.AllowFission.
+// It is triggered by the GUI.
+func AllowFissionChangedEvent(checked bool) {
+	History.Record(State, "AllowFission")
+	State.PhysicsEngine.AllowFission = checked
+}
+
+// MaxMassChangedEvent updates physics.Engine.MaxMass.
+// It is triggered by the GUI.
+func MaxMassChangedEvent(value float64) {
+	History.Record(State, "MaxMass")
+	State.PhysicsEngine.MaxMass = value
+}
+
+// MaxParticlesChangedEvent updates physics.Engine.MaxParticles (any particles beyond it are removed at the end of the
+// next update).
+// It is triggered by the GUI.
+func MaxParticlesChangedEvent(value int) {
+	History.Record(State, "MaxParticles")
+	State.PhysicsEngine.MaxParticles = value
+}
+
+// BounceCompleteDistFactorChangedEvent updates physics.Engine.BounceCompleteDistFactor.
+// It is triggered by the GUI.
+func BounceCompleteDistFactorChangedEvent(value float64) {
+	History.Record(State, "BounceCompleteDistFactor")
+	State.PhysicsEngine.BounceCompleteDistFactor = value
+}
+
+// RestitutionChangedEvent updates physics.Engine.Restitution.
+// It is triggered by the GUI.
+func RestitutionChangedEvent(value float64) {
+	History.Record(State, "Restitution")
+	State.PhysicsEngine.Restitution = value
+}
+
+// DragCoefficientChangedEvent updates physics.Engine.DragCoefficient.
+// It is triggered by the GUI.
+func DragCoefficientChangedEvent(value float64) {
+	History.Record(State, "DragCoefficient")
+	State.PhysicsEngine.DragCoefficient = value
+}
+
+// MaxAccelerationChangedEvent updates physics.Engine.MaxAcceleration.
+// It is triggered by the GUI.
+func MaxAccelerationChangedEvent(value float64) {
+	History.Record(State, "MaxAcceleration")
+	State.PhysicsEngine.MaxAcceleration = value
+}
+
+// QuadraticDragChangedEvent updates physics.Engine.QuadraticDrag.
+// It is triggered by the GUI.
+func QuadraticDragChangedEvent(checked bool) {
+	History.Record(State, "QuadraticDrag")
+	State.PhysicsEngine.QuadraticDrag = checked
+}
+
+// ExternalFieldChangedEvent updates physics.Engine.ExternalField from its strength and angle (in degrees clockwise
+// from the positive x-axis).
+// It is triggered by the GUI.
+func ExternalFieldChangedEvent(strength, angle float64) {
+	History.Record(State, "ExternalField")
+	State.PhysicsEngine.SetExternalFieldPolar(strength, angle)
+}
+
+// FrameAngularVelocityChangedEvent updates physics.Engine.FrameAngularVelocity.
+// It is triggered by the GUI.
+func FrameAngularVelocityChangedEvent(value float64) {
+	History.Record(State, "FrameAngularVelocity")
+	State.PhysicsEngine.FrameAngularVelocity = value
+}
+
+// WallBounceChangedEvent updates physics.Engine.WallBounce (and, since they are mutually exclusive, disables
+// physics.Engine.WrapBoundary if enabling). Any edges set to their own modes are reset, so it applies to all four.
 // It is triggered by the GUI.
 func WallBounceChangedEvent(checked bool) {
+	History.Record(State, "WallBounce")
 	State.PhysicsEngine.WallBounce = checked
+	State.PhysicsEngine.SetEdges(physics.EdgeDefault)
+	if checked {
+		State.PhysicsEngine.WrapBoundary = false
+	}
+}
+
+// WrapBoundaryChangedEvent updates physics.Engine.WrapBoundary (and, since they are mutually exclusive, disables
+// physics.Engine.WallBounce if enabling). Any edges set to their own modes are reset, so it applies to all four.
+// It is triggered by the GUI.
+func WrapBoundaryChangedEvent(checked bool) {
+	History.Record(State, "WrapBoundary")
+	State.PhysicsEngine.WrapBoundary = checked
+	State.PhysicsEngine.SetEdges(physics.EdgeDefault)
+	if checked {
+		State.PhysicsEngine.WallBounce = false
+	}
 }
 
 // HistoryTrailChangedEvent updates State.HistoryTrail, and updates all physics.Engine.Particles accordingly.
 // It is triggered by the GUI.
 func HistoryTrailChangedEvent(checked bool) {
 	State.HistoryTrail = checked
-	for _, p := range State.PhysicsEngine.Particles {
-		p.SetTrackHistory(checked)
-		p.SetHistorySize(State.HistoryLength)
-	}
+	physics.LockParticles()
+	defer physics.UnlockParticles()
+	physics.SetHistoryPrefill(State.HistoryPrefill)
+	physics.SetParticleHistory(checked, State.HistoryLength, State.HistoryStride)
 }
 
 // HistoryTrailLengthChangedEvent updates State.HistoryLength, and updates all physics.Engine.Particles accordingly.
 // It is triggered by the GUI.
 func HistoryTrailLengthChangedEvent(value int) {
 	State.HistoryLength = value
-	for _, p := range State.PhysicsEngine.Particles {
-		// Truncate the position history slice if it's longer than the newly requested length
-		if len(p.PositionHistory()) > value {
-			p.SetPositionHistory(p.PositionHistory()[len(p.PositionHistory())-value:])
-		}
-		p.SetHistorySize(value)
-	}
+	// Position histories longer than the newly requested length are truncated
+	physics.LockParticles()
+	defer physics.UnlockParticles()
+	physics.SetParticleHistory(State.HistoryTrail, value, State.HistoryStride)
+}
+
+// HistoryTrailStrideChangedEvent updates State.HistoryStride, and updates all physics.Engine.Particles accordingly.
+// It is triggered by the GUI.
+func HistoryTrailStrideChangedEvent(value int) {
+	State.HistoryStride = value
+	physics.LockParticles()
+	defer physics.UnlockParticles()
+	physics.SetParticleHistory(State.HistoryTrail, State.HistoryLength, value)
+}
+
+// ColorSchemeChangedEvent updates the physics.Engine.ColorScheme and recalculates the particle colors.
+// It is triggered by the GUI.
+func ColorSchemeChangedEvent(value int) {
+	History.Record(State, "ColorScheme")
+	State.PhysicsEngine.ColorScheme = physics.ColorScheme(value)
+	physics.LockParticles()
+	physics.RecolorParticles()
+	physics.UnlockParticles()
+	GUI.DrawParticles()
+}
+
+// HistoryPrefillChangedEvent updates State.HistoryPrefill, which pre-fills empty position histories from now on (see
+// physics.SetHistoryPrefill), including those of the current particles if trails are enabled, and redraws the
+// particles.
+// It is triggered by the GUI.
+func HistoryPrefillChangedEvent(value int) {
+	History.Record(State, "HistoryPrefill")
+	State.HistoryPrefill = physics.HistoryPrefill(value)
+	HistoryTrailChangedEvent(State.HistoryTrail)
+	GUI.DrawParticles()
+}
+
+// TrailStyleChangedEvent updates State.TrailStyle and redraws the particles (with their trails in the new style).
+// It is triggered by the GUI.
+func TrailStyleChangedEvent(value int) {
+	History.Record(State, "TrailStyle")
+	State.TrailStyle = state.TrailStyle(value)
+	GUI.DrawParticles()
+}
+
+// TrailColoringChangedEvent updates State.TrailColoring and redraws the particles (with their trails colored the new
+// way).
+// It is triggered by the GUI.
+func TrailColoringChangedEvent(value int) {
+	History.Record(State, "TrailColoring")
+	State.TrailColoring = state.TrailColoring(value)
+	GUI.DrawParticles()
+}
+
+// DrawRadiusScaleChangedEvent updates State.DrawRadiusScale and redraws the particles (at their new size).
+// It is triggered by the GUI.
+func DrawRadiusScaleChangedEvent(value float64) {
+	History.Record(State, "DrawRadiusScale")
+	State.DrawRadiusScale = value
+	GUI.DrawParticles()
+}
+
+// MinDrawRadiusChangedEvent updates State.MinDrawRadius and redraws the particles (the smallest at their new size).
+// It is triggered by the GUI.
+func MinDrawRadiusChangedEvent(value int) {
+	History.Record(State, "MinDrawRadius")
+	State.MinDrawRadius = value
+	GUI.DrawParticles()
+}
+
+// ShowVelocityVectorsEvent updates State.ShowVelocityVectors and redraws the particles (with or without their
+// velocity vector arrows).
+// It is triggered by the GUI.
+func ShowVelocityVectorsEvent(checked bool) {
+	History.Record(State, "ShowVelocityVectors")
+	State.ShowVelocityVectors = checked
+	GUI.DrawParticles()
+}
+
+// ShowParticleIDsEvent updates State.ShowParticleIDs and redraws the particles (with or without their IDs).
+// It is triggered by the GUI.
+func ShowParticleIDsEvent(checked bool) {
+	History.Record(State, "ShowParticleIDs")
+	State.ShowParticleIDs = checked
+	GUI.DrawParticles()
+}
+
+// DensityHeatmapEvent updates State.DensityHeatmap and redraws the particles (as a density heatmap or as circles).
+// It is triggered by the GUI.
+func DensityHeatmapEvent(checked bool) {
+	History.Record(State, "DensityHeatmap")
+	State.DensityHeatmap = checked
+	GUI.DrawParticles()
+}
+
+// CenterOfMassFrameEvent updates State.CenterOfMassFrame and redraws the particles (in the center of mass frame or
+// not).
+// It is triggered by the GUI.
+func CenterOfMassFrameEvent(checked bool) {
+	History.Record(State, "CenterOfMassFrame")
+	State.CenterOfMassFrame = checked
+	GUI.DrawParticles()
+}
+
+// SmoothRenderingEvent updates State.SmoothRendering and redraws the particles (as smooth circles or not).
+// It is triggered by the GUI.
+func SmoothRenderingEvent(checked bool) {
+	History.Record(State, "SmoothRendering")
+	State.SmoothRendering = checked
+	GUI.DrawParticles()
+}
+
+// BackgroundColorChangedEvent updates State.BackgroundColor and redraws the particles (over the new background).
+// It is triggered by the GUI.
+func BackgroundColorChangedEvent(color state.Color) {
+	History.Record(State, "BackgroundColor")
+	State.BackgroundColor = color
+	GUI.DrawParticles()
+}
+
+// WallColorChangedEvent updates State.WallColor and redraws the particles (with the walls in the new color).
+// It is triggered by the GUI.
+func WallColorChangedEvent(color state.Color) {
+	History.Record(State, "WallColor")
+	State.WallColor = color
+	GUI.DrawParticles()
 }
 
 // PhysicsLoopSpeedChangedEvent updates the State.PhysicsLoopSpeed. If the simulation is running, it restarts the
@@ -192,16 +750,26 @@ func HistoryTrailLengthChangedEvent(value int) {
 // It is triggered by the GUI.
 func PhysicsLoopSpeedChangedEvent(value int) {
 	State.PhysicsLoopSpeed = value
+	pausedLock.Lock()
+	defer pausedLock.Unlock()
 	if !paused {
 		physicsTicker.Reset(time.Duration(value) * time.Millisecond)
 	}
 }
 
+// StepsPerFrameChangedEvent updates the State.StepsPerFrame, which the physicsLoop uses from its next frame.
+// It is triggered by the GUI.
+func StepsPerFrameChangedEvent(value int) {
+	State.StepsPerFrame = value
+}
+
 // ResetEnvironmentEvent restores the physics.Engine.Particles to the states stored when they were first
 // generated/loaded.
 // It is triggered by the GUI.
 func ResetEnvironmentEvent() {
+	physics.LockParticles()
 	physics.RestoreInitialParticleStates()
+	physics.UnlockParticles()
 
 	// Clear existing particle history trails (while preserving the selected trail length)
 	hold := State.HistoryLength
@@ -209,24 +777,382 @@ func ResetEnvironmentEvent() {
 	State.HistoryLength = hold
 	HistoryTrailChangedEvent(State.HistoryTrail)
 
-	GUI.DrawParticles(State.PhysicsEngine.Particles)
+	GUI.DrawParticles()
+}
+
+// ZeroVelocitiesEvent stops all the physics.Engine.Particles (sets their velocities to zero) and, if paused, redraws
+// them (otherwise the physicsLoop will draw them, as they begin accelerating again).
+// It is triggered by the GUI.
+func ZeroVelocitiesEvent() {
+	History.Record(State, "")
+	physics.LockParticles()
+	physics.ZeroVelocities()
+	physics.UnlockParticles()
+	GUI.SetStatusText("Stopped all particles", 1500)
+	if isPaused() {
+		GUI.DrawParticles()
+	}
+}
+
+// StepOnceEvent advances the simulation by a single update (see physics.UpdateParticles) and draws the particles. It
+// does nothing unless the simulation is paused (so the physicsLoop isn't updating the particles at the same time).
+// It is triggered by the GUI.
+func StepOnceEvent() {
+	if !isPaused() {
+		return
+	}
+	History.Record(State, "")
+	mergeOccurred, mergeMultiple, mergeSource, mergedResult := physics.UpdateParticles()
+	// As in the physicsLoop, the particles are read holding the particles lock
+	physics.RLockParticles()
+	if mergeOccurred {
+		GUI.SetStatusText(mergeText(mergeMultiple, mergeSource, mergedResult), mergeStatusTime)
+	} else {
+		GUI.SetRoutineStatusText(diagnosticsText(), 0)
+	}
+	physics.RUnlockParticles()
+	GUI.DrawParticles()
+}
+
+// UndoEvent restores the state (settings and particles) from before the most recent undoable change (see History).
+// It is triggered by the GUI.
+func UndoEvent() {
+	if data, ok := History.Undo(State); ok {
+		restoreState(data)
+		GUI.SetStatusText("Undone", 1500)
+	} else {
+		GUI.SetStatusText("Nothing to undo", 1500)
+	}
+}
+
+// RedoEvent restores the state (settings and particles) from before the most recent undo (see History).
+// It is triggered by the GUI.
+func RedoEvent() {
+	if data, ok := History.Redo(State); ok {
+		restoreState(data)
+		GUI.SetStatusText("Redone", 1500)
+	} else {
+		GUI.SetStatusText("Nothing to redo", 1500)
+	}
+}
+
+// ToggleFixedEvent pins (fixes) the particle at position (x, y), or unpins it if already fixed.
+// It is triggered by the GUI.
+func ToggleFixedEvent(x, y float64) {
+	physics.RLockParticles()
+	p := physics.ParticleAt(x, y, float64(State.MinDrawRadius))
+	physics.RUnlockParticles()
+	if p == nil {
+		return
+	}
+	History.Record(State, "")
+	physics.LockParticles()
+	p.SetFixed(!p.Fixed())
+	fixed, name := p.Fixed(), p.ShortString()
+	physics.UnlockParticles()
+	if fixed {
+		GUI.SetStatusText("Pinned "+name, 1500)
+	} else {
+		GUI.SetStatusText("Unpinned "+name, 1500)
+	}
+	GUI.DrawParticles()
+}
+
+// ParticleClickedEvent has the GUI inspect the particle at position (x, y), continuously displaying its details and the
+// dominant force acting on it (see GUI.SetInspectedParticle), and redraws the particles. If there's no particle there,
+// inspection stops.
+// It is triggered by the GUI.
+func ParticleClickedEvent(x, y float64) {
+	physics.RLockParticles()
+	p := physics.ParticleAt(x, y, float64(State.MinDrawRadius))
+	physics.RUnlockParticles()
+	GUI.SetInspectedParticle(p)
+	GUI.DrawParticles()
+}
+
+// GrabParticleEvent selects the particle at position (x, y), if any, so that it (along with any other selected
+// particles) may be dragged to a new position (see DragParticlesEvent). If addToSelection is set, the particle is added
+// to the selected particles (or removed, if already selected). Otherwise, it replaces them, unless already selected (so
+// that the selected particles can be dragged together); if there's no particle there, the selection is cleared.
+// Returns whether a particle was grabbed. Particles can only be grabbed while paused.
+// It is triggered by the GUI.
+func GrabParticleEvent(x, y float64, addToSelection bool) bool {
+	if !isPaused() {
+		return false
+	}
+	particlesDragged = false
+
+	physics.RLockParticles()
+	selectedParticles = currentSelection()
+	p := physics.ParticleAt(x, y, float64(State.MinDrawRadius))
+	physics.RUnlockParticles()
+	i := -1
+	for j, s := range selectedParticles {
+		if s == p {
+			i = j
+		}
+	}
+	grabbed := p != nil
+	switch {
+	case p == nil:
+		if !addToSelection {
+			selectedParticles = nil
+		}
+	case addToSelection && i >= 0:
+		selectedParticles = append(selectedParticles[:i], selectedParticles[i+1:]...)
+		grabbed = false
+	case addToSelection:
+		selectedParticles = append(selectedParticles, p)
+	case i < 0:
+		selectedParticles = []*physics.Particle{p}
+	}
+
+	GUI.SetSelectedParticles(selectedParticles)
+	GUI.DrawParticles()
+	return grabbed
+}
+
+// DragParticlesEvent moves the selected particles (see GrabParticleEvent) by (dx, dy), and redraws the particles.
+// It is triggered by the GUI.
+func DragParticlesEvent(dx, dy float64) {
+	if !isPaused() || len(selectedParticles) == 0 {
+		return
+	}
+	if !particlesDragged {
+		History.Record(State, "")
+		particlesDragged = true
+	}
+	physics.LockParticles()
+	for _, p := range selectedParticles {
+		p.SetPosition(vector.NewWithValues([]float64{p.Position()[0] + dx, p.Position()[1] + dy}))
+	}
+	physics.UnlockParticles()
+	GUI.DrawParticles()
+}
+
+// DropParticlesEvent completes the dragging of the selected particles (see DragParticlesEvent), if they were dragged.
+// Their position history trails (which would otherwise jump to their new positions) are cleared, and the states of all
+// the particles are saved as their initial states (so that resetting returns them to the new positions).
+// It is triggered by the GUI.
+func DropParticlesEvent() {
+	if !particlesDragged {
+		return
+	}
+	particlesDragged = false
+	physics.LockParticles()
+	for _, p := range selectedParticles {
+		p.SetPositionHistory(nil)
+	}
+	physics.SaveInitialParticleStates()
+	physics.UnlockParticles()
+	GUI.SetStatusText("Moved "+strconv.Itoa(len(selectedParticles))+" particle(s)", 1500)
+	GUI.DrawParticles()
+}
+
+// AttractorEvent attracts the particles near (x, y) toward it, or repels them from it if repel is true, while the
+// simulation runs (see physics.SetAttractor), until ReleaseAttractorEvent. The attractor is transient, so the change
+// isn't recorded in the History.
+// It is triggered by the GUI.
+func AttractorEvent(x, y float64, repel bool) {
+	strength := attractorStrength
+	if repel {
+		strength = -strength
+	}
+	physics.SetAttractor(x, y, strength)
+}
+
+// ReleaseAttractorEvent stops attracting (or repelling) particles (see AttractorEvent).
+// It is triggered by the GUI.
+func ReleaseAttractorEvent() {
+	physics.ClearAttractor()
+}
+
+// currentSelection gets the selectedParticles which are still among the physics.Engine.Particles (particles are
+// replaced when, for example, a change is undone or a file loaded). The caller must hold the particles lock (see
+// physics.RLockParticles).
+func currentSelection() []*physics.Particle {
+	current := make(map[*physics.Particle]struct{}, len(State.PhysicsEngine.Particles))
+	for _, p := range State.PhysicsEngine.Particles {
+		current[p] = struct{}{}
+	}
+	var selection []*physics.Particle
+	for _, p := range selectedParticles {
+		if _, ok := current[p]; ok {
+			selection = append(selection, p)
+		}
+	}
+	return selection
+}
+
+// StartRecordingEvent informs the user that recording has started, and redraws the particles so that the recording
+// begins with the current state (even if paused).
+// It is triggered by the GUI after it provides a directory picker to the user (the selected directory is passed to
+// this function).
+func StartRecordingEvent(dir string) {
+	GUI.SetStatusText("Recording to: "+dir, 3000)
+	GUI.DrawParticles()
+}
+
+// StopRecordingEvent informs the user that recording has stopped.
+// It is triggered by the GUI.
+func StopRecordingEvent() {
+	GUI.SetStatusText("Recording stopped", 3000)
+}
+
+// StartTrajectoryEvent starts recording the particles' trajectories to file (see physics.StartTrajectory), and informs
+// the user whether it started.
+// It is triggered by the GUI after it provides a file picker to the user (the selected file is passed to this
+// function).
+func StartTrajectoryEvent(file string) bool {
+	if err := physics.StartTrajectory(file); err != nil {
+		log.Warnln("Unable to record trajectory: " + err.Error())
+		GUI.SetStatusText("Unable to record trajectory: "+err.Error(), 3000)
+		return false
+	}
+	GUI.SetStatusText("Recording trajectory to: "+file, 3000)
+	return true
+}
+
+// StopTrajectoryEvent stops recording the particles' trajectories, and informs the user it has stopped (or that the
+// file couldn't be finished).
+// It is triggered by the GUI.
+func StopTrajectoryEvent() {
+	if err := physics.StopTrajectory(); err != nil {
+		log.Warnln("Unable to finish writing trajectory: " + err.Error())
+		GUI.SetStatusText("Unable to finish writing trajectory: "+err.Error(), 3000)
+		return
+	}
+	GUI.SetStatusText("Trajectory recording stopped", 3000)
+}
+
+// ExportGenealogyEvent exports the genealogy of the particles (the mergers since they were generated, loaded, or reset)
+// to file (see physics.ExportGenealogy), and informs the user whether it was written.
+// It is triggered by the GUI after it provides a file picker to the user (the selected file is passed to this
+// function).
+func ExportGenealogyEvent(file string) {
+	if err := physics.ExportGenealogy(file); err != nil {
+		log.Warnln("Unable to export genealogy: " + err.Error())
+		GUI.SetStatusText("Unable to export genealogy: "+err.Error(), 3000)
+		return
+	}
+	GUI.SetStatusText("Genealogy ("+strconv.Itoa(len(physics.Genealogy()))+" mergers) exported to: "+file, 3000)
+}
+
+// ExportSVGEvent has the GUI export the current view to file as an SVG image (see GUI.ExportSVG), and informs the user
+// whether it was written.
+// It is triggered by the GUI after it provides a file picker to the user (the selected file is passed to this
+// function).
+func ExportSVGEvent(file string) {
+	if err := GUI.ExportSVG(file); err != nil {
+		log.Warnln("Unable to export SVG: " + err.Error())
+		GUI.SetStatusText("Unable to export SVG: "+err.Error(), 3000)
+		return
+	}
+	GUI.SetStatusText("View exported to: "+file, 3000)
+}
+
+// ShutdownEvent stops the physics loop (if running) and finishes writing the trajectory (if it's being recorded), so
+// that the app can exit cleanly.
+// It is triggered by the GUI when it is closing.
+func ShutdownEvent() {
+	pause(physicsDoneChan)
+	if err := physics.StopTrajectory(); err != nil {
+		log.Warnln("Unable to finish writing trajectory: " + err.Error())
+	}
+}
+
+// restoreState sets State (and the physics.Engine) to the provided snapshot (see History), and has the GUI update its
+// controls and redraw the particles.
+func restoreState(data *state.Data) {
+	physics.LockParticles()
+	*State = *data
+	physics.Engine = *data.PhysicsEngine
+	State.PhysicsEngine = &physics.Engine
+	// The snapshot's particles may have been colored using a different color scheme
+	physics.RecolorParticles()
+	physics.UnlockParticles()
+
+	// Particle history trails aren't included in the snapshots; restart them using the restored settings.
+	HistoryTrailChangedEvent(State.HistoryTrail)
+
+	GUI.LoadState(guis.GUIInitializationData{Data: State})
 }
 
 // PauseResumeEvent pauses and resumes the simulation (physics loop).
 // It is triggered by the GUI.
 func PauseResumeEvent() bool {
 	//Now resuming
-	if paused {
+	if isPaused() {
+		// Particles may only be selected (to drag) while paused
+		selectedParticles = nil
+		GUI.SetSelectedParticles(nil)
+		pausedLock.Lock()
 		paused = false
 		physicsTicker = time.NewTicker(time.Duration(State.PhysicsLoopSpeed) * time.Millisecond)
-		physicsDoneChan = make(chan bool)
-		go physicsLoop()
+		physicsDoneChan = make(chan bool, 1)
+		go physicsLoop(physicsTicker, physicsDoneChan)
+		pausedLock.Unlock()
 		//Now pausing
 	} else {
-		paused = true
-		physicsDoneChan <- true
-		physicsTicker.Stop()
+		// The physics loop may have paused the simulation itself meanwhile (see autoPause), in which case this does
+		// nothing
+		pause(physicsDoneChan)
 	}
 
+	return isPaused()
+}
+
+// isPaused gets whether the simulation is paused (see paused), holding pausedLock.
+func isPaused() bool {
+	pausedLock.Lock()
+	defer pausedLock.Unlock()
 	return paused
 }
+
+// pause marks the simulation paused, stops the physics loop ticker, and stops the physics loop whose done channel is
+// done, unless the simulation has already been paused (or paused and resumed, starting another loop) meanwhile.
+// Returns whether it paused the simulation. It's called both by the event handlers and from within the physics loop
+// (see autoPause), which may then return without reading done, so done is written to without blocking (it's buffered,
+// so the loop still reads it if it hasn't returned).
+func pause(done chan bool) bool {
+	pausedLock.Lock()
+	defer pausedLock.Unlock()
+	if paused || done != physicsDoneChan {
+		return false
+	}
+	paused = true
+	physicsTicker.Stop()
+	select {
+	case done <- true:
+	default:
+	}
+	return true
+}
+
+// AutoSlowdownChangedEvent updates State.AutoSlowdown.
+// It is triggered by the GUI.
+func AutoSlowdownChangedEvent(checked bool) {
+	History.Record(State, "AutoSlowdown")
+	State.AutoSlowdown = checked
+}
+
+// PauseOnMergeChangedEvent updates State.PauseOnMerge.
+// It is triggered by the GUI.
+func PauseOnMergeChangedEvent(checked bool) {
+	History.Record(State, "PauseOnMerge")
+	State.PauseOnMerge = checked
+}
+
+// PauseOnSpeedChangedEvent updates State.PauseOnSpeed.
+// It is triggered by the GUI.
+func PauseOnSpeedChangedEvent(checked bool) {
+	History.Record(State, "PauseOnSpeed")
+	State.PauseOnSpeed = checked
+}
+
+// PauseSpeedThresholdChangedEvent updates State.PauseSpeedThreshold.
+// It is triggered by the GUI.
+func PauseSpeedThresholdChangedEvent(value float64) {
+	History.Record(State, "PauseSpeedThreshold")
+	State.PauseSpeedThreshold = value
+}
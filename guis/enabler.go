@@ -3,14 +3,15 @@
 package guis
 
 import (
+	"image"
+
 	"GoGoGadgetGravity/physics"
 	"GoGoGadgetGravity/state"
 )
 
 // GUIInitializationData holds state (provided by main) for GUI creation/initialization (GUIEnabler.CreateGUI) and
 // reloading (when loading state from file with GUIEnabler.LoadState). This includes particles to be drawn in their
-// initial state - future updates to particles are requested by main, with particle state provided, using the
-// GUIEnabler.DrawParticles method.
+// initial state - future updates to particles are requested by main using the GUIEnabler.DrawParticles method.
 type GUIInitializationData struct {
 	*state.Data
 
@@ -32,14 +33,42 @@ type GUIEnabler interface {
 	// SetPhysicsLoopSpeed instructs the GUI that the main program has changed the physics loop speed (because the
 	// requested loop is too quick), so the GUI can adjust its control position/value.
 	SetPhysicsLoopSpeed(loopTime int)
-	// SetStatusText instructs the GUI to print the requested string in its status text control.
+	// SetStatusText instructs the GUI to print the requested string in its status text control. Text with a display
+	// time (in ms) is a notice (such as of a particle merge), which the GUI is expected to keep displayed for that time
+	// rather than replace it with routine status text. Text with a display time of 0 is displayed until replaced.
 	SetStatusText(text string, time int)
+	// SetRoutineStatusText instructs the GUI to print the requested string (such as periodic diagnostics) in its status
+	// text control for time ms (or until replaced, if 0), unless a notice (see SetStatusText) is being displayed.
+	SetRoutineStatusText(text string, time int)
 
-	// DrawParticles instructs the GUI to draw the particles within its display area.
-	DrawParticles(particles []*physics.Particle)
-	// UpdateView instructs the GUI to redraw the entire environment / recreate its display, such as when the
-	// EnvironmentSize is changed.
-	UpdateView(particles []*physics.Particle)
+	// DrawParticles instructs the GUI to draw the physics.Engine.Particles within its display area.
+	// It is called from both the physics loop and the event handlers, but never while the particles lock is held. The
+	// GUI is expected to hold the lock for reading while drawing (see physics.RLockParticles), and to read the
+	// physics.Engine.Particles only once it holds it, as they may be replaced meanwhile.
+	DrawParticles()
+	// Snapshot gets a copy of the most recently drawn frame (see DrawParticles) as an image, without writing any files,
+	// e.g. to embed renders in other programs or to compare renders in tests.
+	Snapshot() image.Image
+	// ExportSVG writes the physics.Engine.Particles to file as an SVG (vector) image of the environment as the GUI
+	// draws it (the particles as circles, the walls, and any trails), such as for publication-quality figures. It is
+	// never called while the particles lock is held, and, as for DrawParticles, the GUI is expected to read the
+	// particles holding it for reading (see physics.RLockParticles).
+	ExportSVG(file string) error
+	// SimulationPaused informs the GUI that the main app has paused the simulation itself (rather than at the user's
+	// request, such as when an auto-stop trigger fires). The GUI is expected to update its state as if the user had
+	// paused it.
+	SimulationPaused()
+	// SetSelectedParticles informs the GUI which particles the user has selected to drag to new positions (see
+	// ConnectGrabParticleEvent), so that it can highlight them when it next draws the particles.
+	SetSelectedParticles(particles []*physics.Particle)
+	// SetInspectedParticle informs the GUI which particle the user has clicked to inspect (see
+	// ConnectParticleClickedEvent), or nil for none. The GUI is expected to display the particle's details (such as its
+	// position, velocity, and the dominant force on it) each time it draws the particles, until another particle is
+	// inspected or the particle is gone (such as merged into another).
+	SetInspectedParticle(p *physics.Particle)
+	// UpdateView instructs the GUI to redraw the entire environment / recreate its display (drawing the
+	// physics.Engine.Particles; see DrawParticles), such as when the environment width or height is changed.
+	UpdateView()
 
 	// ConnectSaveStateEvent provides the GUI with the function to call when the user uses the GUI to request saving
 	// the current state to file.
@@ -49,11 +78,35 @@ type GUIEnabler interface {
 	// a saved state from file.
 	// The GUI is expected to provide a file picker, and then call this function, passing it the file path/name.
 	ConnectLoadStateEvent(func(file string))
-	// ConnectEnvironmentSizeChangedEvent provides the GUI with the function to call when the user uses the GUI to
-	// request an environment size change.
-	// The GUI is expected to resize/redraw its display area and then call this function, passing it the new size.
+	// ConnectSaveSettingsEvent provides the GUI with the function to call when the user uses the GUI to request saving
+	// the current settings (without the particles) to file.
+	// The GUI is expected to provide a file picker, and then call this function, passing it the file path/name.
+	ConnectSaveSettingsEvent(func(file string))
+	// ConnectLoadSettingsEvent provides the GUI with the function to call when the user uses the GUI to request applying
+	// saved settings from file to the current simulation (keeping the current particles).
+	// The GUI is expected to provide a file picker, and then call this function, passing it the file path/name.
+	ConnectLoadSettingsEvent(func(file string))
+	// ConnectResetSettingsEvent provides the GUI with the function to call when the user uses the GUI to request that
+	// the physics engine settings be restored to their defaults (keeping the current particles; see
+	// physics.EngineData.ResetToDefaults).
+	// The GUI is expected to call this function, which will in turn instruct the GUI to load the restored settings
+	// (see LoadState).
+	ConnectResetSettingsEvent(func())
+	// ConnectLoadPresetEvent provides the GUI with the function to call when the user uses the GUI to request loading
+	// one of the built-in scenarios (see physics.Presets).
+	// The GUI is expected to provide a selection of the presets, and then call this function, passing it the name of
+	// the selected preset.
+	ConnectLoadPresetEvent(func(name string))
+	// ConnectEnvironmentWidthChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request an environment width change.
+	// The GUI is expected to resize/redraw its display area and then call this function, passing it the new width.
+	// Particles will be generated and GUI instructed to draw them if currently paused.
+	ConnectEnvironmentWidthChangedEvent(func(value int))
+	// ConnectEnvironmentHeightChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request an environment height change.
+	// The GUI is expected to resize/redraw its display area and then call this function, passing it the new height.
 	// Particles will be generated and GUI instructed to draw them if currently paused.
-	ConnectEnvironmentSizeChangedEvent(func(value int))
+	ConnectEnvironmentHeightChangedEvent(func(value int))
 	// ConnectNumParticlesChangedEvent provides the GUI with the function to call when the user uses the GUI to request
 	// a change in the number of (to be generated) particles.
 	// The GUI is expected to change its state accordingly and then call this function, passing it the new number
@@ -64,6 +117,27 @@ type GUIEnabler interface {
 	// The GUI is expected to change its state accordingly and then call this function, passing it the new average mass.
 	// Particles will be generated and GUI instructed to draw them if currently paused.
 	ConnectAverageMassChangedEvent(func(value int))
+	// ConnectInitialVelocityModeChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the way the velocities of (to be generated) particles are initialized.
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new
+	// state.InitialVelocityMode. Particles will be generated and GUI instructed to draw them if currently paused.
+	ConnectInitialVelocityModeChangedEvent(func(value int))
+	// ConnectInitialSpeedChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// a change in the scale of the initial velocities of (to be generated) particles.
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new speed.
+	// Particles will be generated and GUI instructed to draw them if currently paused.
+	ConnectInitialSpeedChangedEvent(func(value float64))
+	// ConnectGenerationRegionChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the (circular) region (to be generated) particles are placed within.
+	// The GUI is expected to change its state accordingly and then call this function, passing it the center and radius
+	// of the region (a radius of 0 places particles anywhere in the environment). Particles will be generated and GUI
+	// instructed to draw them if currently paused.
+	ConnectGenerationRegionChangedEvent(func(x, y, radius float64))
+	// ConnectChargeDistributionChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the distribution the close charges of (to be generated) particles are drawn from.
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new
+	// state.ChargeDistribution. Particles will be generated and GUI instructed to draw them if currently paused.
+	ConnectChargeDistributionChangedEvent(func(value int))
 	// ConnectRegenParticlesEvent provides the GUI with the function to call when the user uses the GUI to request
 	// new particles be generated.
 	// The GUI is expected to call this method, which will generate new particles and instruct the GUI to draw them.
@@ -83,16 +157,123 @@ type GUIEnabler interface {
 	// The GUI is expected to change its state accordingly and then call this function, passing it the new far charge
 	// strength.
 	ConnectFarChargeStrengthChangedEvent(func(value float64))
+	// ConnectFarChargeExponentChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the physics engine "far charge" exponent (the power of distance the force is proportional
+	// to).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new exponent.
+	ConnectFarChargeExponentChangedEvent(func(value float64))
+	// ConnectFarChargeCutoffChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the physics engine "far charge" cutoff (the distance beyond which there is no far charge
+	// force, or 0 for none).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new cutoff.
+	ConnectFarChargeCutoffChangedEvent(func(value float64))
+	// ConnectGravityOnlyChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// the close and far charge forces be suppressed (leaving only gravity), or not.
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether only gravity should presently act between particles.
+	ConnectGravityOnlyChangedEvent(func(enabled bool))
+	// ConnectEnableGravityChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// the gravity force be enabled/disabled (regardless of its strength).
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether the gravity force should presently act between particles.
+	ConnectEnableGravityChangedEvent(func(enabled bool))
+	// ConnectEnableCloseChargeChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request the close charge force be enabled/disabled (regardless of its strength).
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether the close charge force should presently act between particles.
+	ConnectEnableCloseChargeChangedEvent(func(enabled bool))
+	// ConnectSpeciesMatrixChangedEvent provides the GUI with the function to call when the user uses the GUI to edit
+	// the species interaction matrix (see physics.EngineData.SpeciesMatrix).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the edited matrix
+	// (nil to ignore species), which will assign species to the particles as needed and instruct the GUI to draw them.
+	ConnectSpeciesMatrixChangedEvent(func(matrix [][]float64))
+	// ConnectEnableFarChargeChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request the far charge force be enabled/disabled (regardless of its strength).
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether the far charge force should presently act between particles.
+	ConnectEnableFarChargeChangedEvent(func(enabled bool))
 	// ConnectAllowMergeChangedEvent provides the GUI with the function to call when the user uses the GUI to request
 	// particle mergers be enabled/disabled.
 	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
 	// whether particle mergers should presently be allowed/disallowed.
 	ConnectAllowMergeChangedEvent(func(enabled bool))
+	// ConnectMergeMassRatioThresholdChangedEvent provides the GUI with the function to call when the user uses the GUI
+	// to request a change in the physics engine merge mass ratio threshold (the ratio between particle masses above
+	// which colliding particles may merge).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new threshold.
+	ConnectMergeMassRatioThresholdChangedEvent(func(value float64))
+	// ConnectMergeCloseChargeThresholdChangedEvent provides the GUI with the function to call when the user uses the
+	// GUI to request a change in the physics engine merge close charge threshold (the summed same sign close charge of
+	// colliding particles above which they cannot merge).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new threshold.
+	ConnectMergeCloseChargeThresholdChangedEvent(func(value float64))
+	// ConnectCaptureSpeedChangedEvent provides the GUI with the function to call when the user uses the GUI to request a
+	// change in the physics engine capture speed (the approach speed of colliding particles above which they bounce
+	// rather than merge).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new speed.
+	ConnectCaptureSpeedChangedEvent(func(value float64))
+	// ConnectChargeMergeRuleChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the way the charges of merging particles are combined.
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new rule (a
+	// physics.ChargeMergeRule).
+	ConnectChargeMergeRuleChangedEvent(func(value int))
+	// ConnectAllowFissionChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// particle fission (splitting particles above the maximum mass) be enabled/disabled.
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether particle fission should presently be allowed/disallowed.
+	ConnectAllowFissionChangedEvent(func(enabled bool))
+	// ConnectMaxMassChangedEvent provides the GUI with the function to call when the user uses the GUI to request a
+	// change in the physics engine maximum mass (the mass above which particles split, if fission is allowed).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new mass.
+	ConnectMaxMassChangedEvent(func(value float64))
+	// ConnectMaxParticlesChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// a change in the physics engine maximum number of particles (0 for unlimited).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new maximum.
+	ConnectMaxParticlesChangedEvent(func(value int))
+	// ConnectBounceCompleteDistFactorChangedEvent provides the GUI with the function to call when the user uses the GUI
+	// to request a change in the physics engine bounce complete distance factor (the multiple of their combined radii
+	// particles must separate by before a bounce is complete).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new factor.
+	ConnectBounceCompleteDistFactorChangedEvent(func(value float64))
+	// ConnectRestitutionChangedEvent provides the GUI with the function to call when the user uses the GUI to request a
+	// change in the physics engine coefficient of restitution (how elastic bounces are).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new coefficient.
+	ConnectRestitutionChangedEvent(func(value float64))
+	// ConnectDragCoefficientChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the physics engine drag coefficient (the strength of the background drag opposing particle
+	// velocities).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new coefficient.
+	ConnectDragCoefficientChangedEvent(func(value float64))
+	// ConnectMaxAccelerationChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the physics engine maximum (summed) particle acceleration (0 for unlimited).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new maximum.
+	ConnectMaxAccelerationChangedEvent(func(value float64))
+	// ConnectQuadraticDragChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// drag be proportional to the square of particle speeds (rather than their speeds), or not.
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether drag should presently be quadratic.
+	ConnectQuadraticDragChangedEvent(func(enabled bool))
+	// ConnectExternalFieldChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// a change in the physics engine external field (the uniform acceleration applied to every particle).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new field
+	// strength and angle (in degrees clockwise from the positive x-axis, so 90 is down).
+	ConnectExternalFieldChangedEvent(func(strength, angle float64))
+	// ConnectFrameAngularVelocityChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the angular velocity of the physics engine's rotating reference frame (0 for none).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new angular
+	// velocity (in radians per time unit, clockwise as drawn if positive).
+	ConnectFrameAngularVelocityChangedEvent(func(value float64))
 	// ConnectWallBounceChangedEvent provides the GUI with the function to call when the user uses the GUI to request
 	// to enable/disable particles bouncing off environment walls.
 	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
 	// whether particle wall bounces should presently be enabled/disabled.
 	ConnectWallBounceChangedEvent(func(enabled bool))
+	// ConnectWrapBoundaryChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// to enable/disable the environment wrapping around (particles crossing an edge reappear on the opposite side).
+	// The GUI is expected to change its state accordingly (wrapping and wall bounce are mutually exclusive, and
+	// particles near an edge should be drawn on both sides) and then call this function, passing it a bool indicating
+	// whether the environment should presently wrap around.
+	ConnectWrapBoundaryChangedEvent(func(enabled bool))
 	// ConnectHistoryTrailChangedEvent provides the GUI with the function to call when the user uses the GUI to request
 	// to enable/disable particle position history (trail).
 	// The GUI is expected to change its state accordingly (and begin using the history state stored with the particles
@@ -103,20 +284,205 @@ type GUIEnabler interface {
 	// request a change in the number of previous positions (trail length) of a particle the physics engine should track.
 	// The GUI is expected to change its state accordingly and then call this function, passing it the new trail length.
 	ConnectHistoryTrailLengthChangedEvent(func(value int))
+	// ConnectHistoryTrailStrideChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in how often (every how many position updates) the physics engine should store the positions of
+	// a particle in its history (trail).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new stride.
+	ConnectHistoryTrailStrideChangedEvent(func(value int))
+	// ConnectColorSchemeChangedEvent provides the GUI with the function to call when the user uses the GUI to request a
+	// change in the scheme used to color the particles.
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new scheme (an
+	// index into physics.ColorSchemes), which will recalculate the particle colors and instruct the GUI to draw them.
+	ConnectColorSchemeChangedEvent(func(value int))
+	// ConnectTrailStyleChangedEvent provides the GUI with the function to call when the user uses the GUI to request a
+	// change in the style particle position history trails are drawn in.
+	// The GUI is expected to change its state accordingly (drawing trails in the new style) and then call this
+	// function, passing it the new style (a state.TrailStyle).
+	ConnectTrailStyleChangedEvent(func(value int))
+	// ConnectTrailColoringChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// a change in the way particle position history trails are colored.
+	// The GUI is expected to change its state accordingly (coloring trails the new way) and then call this function,
+	// passing it the new coloring (a state.TrailColoring).
+	ConnectTrailColoringChangedEvent(func(value int))
+	// ConnectHistoryPrefillChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the way empty particle position histories are pre-filled (so trails appear immediately).
+	// The GUI is expected to call this function, passing it the new mode (a physics.HistoryPrefill), which will
+	// pre-fill the empty histories and instruct the GUI to draw the particles.
+	ConnectHistoryPrefillChangedEvent(func(value int))
+	// ConnectDrawRadiusScaleChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the size particles are drawn at.
+	// The GUI is expected to change its state accordingly (drawing particles with their radii multiplied by the new
+	// scale) and then call this function, passing it the new scale.
+	ConnectDrawRadiusScaleChangedEvent(func(value float64))
+	// ConnectMinDrawRadiusChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the smallest radius particles are drawn with.
+	// The GUI is expected to change its state accordingly (drawing smaller particles at the new minimum radius) and
+	// then call this function, passing it the new minimum radius.
+	ConnectMinDrawRadiusChangedEvent(func(value int))
+	// ConnectShowVelocityVectorsEvent provides the GUI with the function to call when the user uses the GUI to request
+	// velocity vector arrows be drawn over the particles, or not.
+	// The GUI is expected to change its state accordingly (drawing the arrows or not) and then call this function,
+	// passing it a bool indicating whether the arrows should presently be shown.
+	ConnectShowVelocityVectorsEvent(func(enabled bool))
+	// ConnectShowParticleIDsEvent provides the GUI with the function to call when the user uses the GUI to request
+	// each particle's ID be drawn next to it, or not.
+	// The GUI is expected to change its state accordingly (drawing the IDs or not) and then call this function, passing
+	// it a bool indicating whether the IDs should presently be shown.
+	ConnectShowParticleIDsEvent(func(enabled bool))
+	// ConnectDensityHeatmapEvent provides the GUI with the function to call when the user uses the GUI to request the
+	// particles be drawn as a density heatmap, or as circles.
+	// The GUI is expected to change its state accordingly (drawing the heatmap or not) and then call this function,
+	// passing it a bool indicating whether the heatmap should presently be drawn.
+	ConnectDensityHeatmapEvent(func(enabled bool))
+	// ConnectCenterOfMassFrameEvent provides the GUI with the function to call when the user uses the GUI to request
+	// the particles be drawn in their center of mass frame (so the system stays centered even if it drifts), or not.
+	// The GUI is expected to change its state accordingly (shifting the drawn particles or not) and then call this
+	// function, passing it a bool indicating whether the center of mass frame should presently be used.
+	ConnectCenterOfMassFrameEvent(func(enabled bool))
+	// ConnectSmoothRenderingEvent provides the GUI with the function to call when the user uses the GUI to request the
+	// particles be drawn as smooth (anti-aliased) circles, or hard-edged ones.
+	// The GUI is expected to change its state accordingly (drawing smooth circles or not) and then call this function,
+	// passing it a bool indicating whether smooth rendering should presently be used.
+	ConnectSmoothRenderingEvent(func(enabled bool))
+	// ConnectBackgroundColorChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the color the environment is filled with (behind the particles).
+	// The GUI is expected to change its state accordingly (drawing the new background) and then call this function,
+	// passing it the new color.
+	ConnectBackgroundColorChangedEvent(func(color state.Color))
+	// ConnectWallColorChangedEvent provides the GUI with the function to call when the user uses the GUI to request a
+	// change in the color of the box drawn at the environment's walls.
+	// The GUI is expected to change its state accordingly (drawing the walls in the new color) and then call this
+	// function, passing it the new color.
+	ConnectWallColorChangedEvent(func(color state.Color))
 	// ConnectPhysicsLoopSpeedChangedEvent provides the GUI with the function to call when the user uses the GUI to
 	// request a change in the physics iteration speed.
 	// The GUI is expected to change its state accordingly and then call this function, passing it the new speed
 	// (iteration interval in ms).
 	ConnectPhysicsLoopSpeedChangedEvent(func(value int))
+	// ConnectStepsPerFrameChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// a change in the number of physics iterations run between drawing the particles.
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new number of
+	// iterations per frame.
+	ConnectStepsPerFrameChangedEvent(func(value int))
+	// ConnectAutoSlowdownChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// the physics iteration speed be automatically slowed when the simulation can't keep up with it, or not.
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether to slow down automatically.
+	ConnectAutoSlowdownChangedEvent(func(enabled bool))
 	// ConnectResetEnvironmentEvent provides the GUI with the function to call when the user uses the GUI to request
 	// that the environment be reset - that is, that the particles will be returned to their original (generated)
 	// position and their historical positions removed.
 	// The GUI is expected to call this method, which will in turn instruct the GUI to draw the particles.
 	ConnectResetEnvironmentEvent(func())
+	// ConnectZeroVelocitiesEvent provides the GUI with the function to call when the user uses the GUI to request that
+	// all the particles be stopped (their velocities set to zero), whether the simulation is paused or running.
+	// The GUI is expected to call this method, which will in turn instruct the GUI to draw the particles (if paused).
+	ConnectZeroVelocitiesEvent(func())
 	// ConnectPauseResumeEvent provides the GUI with the function to call when the user uses the GUI to request the
 	// simulation pause or resume.
 	// The GUI is expected to call this method, which will return a bool indicating whether the simulation is currently
 	// paused or running. The GUI will then update its state accordingly (e.g. disabling controls while simulation is
 	// running).
 	ConnectPauseResumeEvent(func() (paused bool))
+	// ConnectStepOnceEvent provides the GUI with the function to call when the user uses the GUI to request the
+	// (paused) simulation advance by a single update.
+	// The GUI is expected to call this method (only while the simulation is paused), which will in turn instruct the
+	// GUI to draw the particles.
+	ConnectStepOnceEvent(func())
+	// ConnectPauseOnMergeChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// the simulation automatically pause when particles merge, or not.
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether to pause on mergers.
+	ConnectPauseOnMergeChangedEvent(func(enabled bool))
+	// ConnectPauseOnSpeedChangedEvent provides the GUI with the function to call when the user uses the GUI to request
+	// the simulation automatically pause when a particle's speed exceeds the pause speed threshold, or not.
+	// The GUI is expected to change its state accordingly and then call this function, passing it a bool indicating
+	// whether to pause on fast particles.
+	ConnectPauseOnSpeedChangedEvent(func(enabled bool))
+	// ConnectPauseSpeedThresholdChangedEvent provides the GUI with the function to call when the user uses the GUI to
+	// request a change in the particle speed above which the simulation automatically pauses (if enabled).
+	// The GUI is expected to change its state accordingly and then call this function, passing it the new threshold.
+	ConnectPauseSpeedThresholdChangedEvent(func(value float64))
+	// ConnectUndoEvent provides the GUI with the function to call when the user uses the GUI to request the most recent
+	// change to the settings or particles be undone.
+	// The GUI is expected to call this method, which will in turn instruct the GUI to load the restored state (see
+	// LoadState).
+	ConnectUndoEvent(func())
+	// ConnectToggleFixedEvent provides the GUI with the function to call when the user uses the GUI to request the
+	// particle at a position be pinned in place (fixed) or, if already fixed, unpinned.
+	// The GUI is expected to call this function, passing it the requested position (in environment units), which will
+	// toggle the particle (if any) there and instruct the GUI to draw the particles.
+	ConnectToggleFixedEvent(func(x, y float64))
+	// ConnectParticleClickedEvent provides the GUI with the function to call when the user clicks a position in the
+	// GUI's display of the environment, to inspect the particle there.
+	// The GUI is expected to call this function, passing it the clicked position (in environment units), which will
+	// inform the GUI of the particle (if any) there to inspect (see SetInspectedParticle).
+	ConnectParticleClickedEvent(func(x, y float64))
+	// ConnectGrabParticleEvent provides the GUI with the function to call when the user presses the mouse on a position
+	// in the GUI's display of the environment, to select the particle there so that it may be dragged (only possible
+	// while paused).
+	// The GUI is expected to call this function, passing it the position (in environment units) and whether the
+	// particle should be added to (or removed from) the current selection rather than replacing it. The function
+	// returns whether a particle was grabbed, in which case the GUI is expected to report the mouse movement (see
+	// ConnectDragParticlesEvent) until the mouse is released (see ConnectDropParticlesEvent).
+	ConnectGrabParticleEvent(func(x, y float64, addToSelection bool) (grabbed bool))
+	// ConnectDragParticlesEvent provides the GUI with the function to call when the user moves the mouse while dragging
+	// the grabbed particle(s).
+	// The GUI is expected to call this function, passing it the distance moved (in environment units) since the
+	// particles were grabbed or last dragged, which will move the selected particles and instruct the GUI to draw the
+	// particles.
+	ConnectDragParticlesEvent(func(dx, dy float64))
+	// ConnectDropParticlesEvent provides the GUI with the function to call when the user releases the mouse after
+	// dragging the grabbed particle(s).
+	// The GUI is expected to call this function, which will save the new positions as the initial particle states (so
+	// that resetting the particles returns to them).
+	ConnectDropParticlesEvent(func())
+	// ConnectAttractorEvent provides the GUI with the function to call when the user holds the mouse on a position in
+	// the GUI's display of the environment (or moves it while held), to attract the particles near it toward it (or
+	// repel them from it) while the simulation runs.
+	// The GUI is expected to call this function, passing it the position (in environment units) and whether the
+	// particles should be repelled rather than attracted, each time the held mouse moves, until the mouse is released
+	// (see ConnectReleaseAttractorEvent).
+	ConnectAttractorEvent(func(x, y float64, repel bool))
+	// ConnectReleaseAttractorEvent provides the GUI with the function to call when the user releases the mouse after
+	// holding it to attract (or repel) particles.
+	// The GUI is expected to call this function, which will stop attracting the particles.
+	ConnectReleaseAttractorEvent(func())
+	// ConnectRedoEvent provides the GUI with the function to call when the user uses the GUI to request the most
+	// recently undone change be redone.
+	// The GUI is expected to call this method, which will in turn instruct the GUI to load the restored state (see
+	// LoadState).
+	ConnectRedoEvent(func())
+	// ConnectStartRecordingEvent provides the GUI with the function to call when the user uses the GUI to start
+	// recording the simulation.
+	// The GUI is expected to provide a directory picker, begin capturing the frames it draws (see DrawParticles) to the
+	// selected directory, and then call this function, passing it the directory.
+	ConnectStartRecordingEvent(func(dir string))
+	// ConnectStopRecordingEvent provides the GUI with the function to call when the user uses the GUI to stop
+	// recording the simulation.
+	// The GUI is expected to stop capturing frames (and finish writing them) and then call this function.
+	ConnectStopRecordingEvent(func())
+	// ConnectStartTrajectoryEvent provides the GUI with the function to call when the user uses the GUI to start
+	// recording the particles' trajectories (positions and velocities each step) to a file.
+	// The GUI is expected to provide a file picker and then call this function, passing it the selected file. The
+	// function returns whether recording started (it may fail, e.g. if the file can't be created).
+	ConnectStartTrajectoryEvent(func(file string) (started bool))
+	// ConnectStopTrajectoryEvent provides the GUI with the function to call when the user uses the GUI to stop
+	// recording the particles' trajectories.
+	// The GUI is expected to call this function, which finishes writing the file.
+	ConnectStopTrajectoryEvent(func())
+	// ConnectExportGenealogyEvent provides the GUI with the function to call when the user uses the GUI to export the
+	// genealogy of the particles (the mergers they formed from; see physics.Genealogy) to a file.
+	// The GUI is expected to provide a file picker and then call this function, passing it the selected file (a .dot or
+	// .gv file is written as a Graphviz graph, and any other as JSON).
+	ConnectExportGenealogyEvent(func(file string))
+	// ConnectExportSVGEvent provides the GUI with the function to call when the user uses the GUI to export the current
+	// view as an SVG image.
+	// The GUI is expected to provide a file picker and then call this function, passing it the selected file, which
+	// will in turn instruct the GUI to write the SVG (see ExportSVG).
+	ConnectExportSVGEvent(func(file string))
+	// ConnectShutdownEvent provides the GUI with the function to call when it is closing (e.g. the user closes the
+	// window), which stops the simulation and finishes writing any recordings (see ConnectStartTrajectoryEvent).
+	// The GUI is expected to call this function before CreateGUI returns, and to finish any recordings of its own.
+	ConnectShutdownEvent(func())
 }
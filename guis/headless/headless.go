@@ -0,0 +1,455 @@
+// Package headless provides a guis.GUIEnabler implementation which doesn't display anything (or require a display),
+// for running simulations in CI, on servers, etc.
+package headless
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"GoGoGadgetGravity/guis"
+	"GoGoGadgetGravity/physics"
+	"GoGoGadgetGravity/state"
+)
+
+// Headless is the headless implementation of guis.GUIEnabler. Rather than creating a window, CreateGUI runs the
+// simulation for Steps steps and returns. Status text is logged, and particles are optionally drawn to PNG files.
+type Headless struct {
+	// Steps is the number of simulation steps (calls to physics.UpdateParticles) CreateGUI runs.
+	Steps int
+	// FrameDir is the directory to which frames (the particles, drawn as PNG images) are written. If empty, no frames
+	// are written.
+	FrameDir string
+	// FrameInterval is the number of steps between written frames (e.g. 10 writes every tenth frame). Values less than
+	// 1 write every frame.
+	FrameInterval int
+
+	// environmentWidth and environmentHeight are kept in sync with state.Data.PhysicsEngine.EnvironmentWidth and
+	// EnvironmentHeight and are used to size the frames.
+	environmentWidth, environmentHeight int
+	// drawRadiusScale is kept in sync with state.Data.DrawRadiusScale and is the multiplier applied to particle radii
+	// when drawing them.
+	drawRadiusScale float64
+	// minDrawRadius is kept in sync with state.Data.MinDrawRadius and is the smallest radius particles are drawn with.
+	minDrawRadius int
+	// frame is the number of frames drawn (or not, depending on FrameInterval) so far.
+	frame int
+	// particles are the particles most recently drawn (or not), which Snapshot renders.
+	particles []*physics.Particle
+	// shutdownEventHandler is called once the steps have been run (see ConnectShutdownEvent).
+	shutdownEventHandler func()
+}
+
+// CreateGUI implements guis.GUIEnabler.CreateGUI. It draws the initial particles and then runs the simulation for
+// Steps steps, drawing the particles after each, and then shuts down (see ConnectShutdownEvent).
+func (h *Headless) CreateGUI(initialValues guis.GUIInitializationData) {
+	h.environmentWidth = initialValues.PhysicsEngine.EnvironmentWidth
+	h.environmentHeight = initialValues.PhysicsEngine.EnvironmentHeight
+	h.drawRadiusScale = initialValues.DrawRadiusScale
+	h.minDrawRadius = initialValues.MinDrawRadius
+	h.DrawParticles()
+
+	for i := 0; i < h.Steps; i++ {
+		mergeOccurred, _, mergeSource, mergedResult := physics.UpdateParticles()
+		if mergeOccurred {
+			h.SetStatusText(fmt.Sprintf("Step %d: merged %s. Now: %s", i+1, mergeSource.ShortString(),
+				mergedResult.ShortString()), 0)
+		}
+		h.DrawParticles()
+	}
+
+	h.SetStatusText(fmt.Sprintf("Completed %d steps, %d particles remain", h.Steps, len(physics.Engine.Particles)), 0)
+	// The summary can be compared between runs (see physics.Summarize)
+	h.SetStatusText("Run summary: "+physics.Summarize().String(), 0)
+	if h.shutdownEventHandler != nil {
+		h.shutdownEventHandler()
+	}
+}
+
+// LoadState implements guis.GUIEnabler.LoadState.
+func (h *Headless) LoadState(initialValues guis.GUIInitializationData) {
+	h.environmentWidth = initialValues.PhysicsEngine.EnvironmentWidth
+	h.environmentHeight = initialValues.PhysicsEngine.EnvironmentHeight
+	h.drawRadiusScale = initialValues.DrawRadiusScale
+	h.minDrawRadius = initialValues.MinDrawRadius
+	h.DrawParticles()
+}
+
+// SetPhysicsLoopSpeed implements guis.GUIEnabler.SetPhysicsLoopSpeed. The headless GUI runs the simulation as fast as
+// possible, so it is ignored.
+func (h *Headless) SetPhysicsLoopSpeed(loopTime int) {}
+
+// SetStatusText implements guis.GUIEnabler.SetStatusText by logging the text. The display time is ignored.
+func (h *Headless) SetStatusText(text string, time int) {
+	log.Infoln(text)
+}
+
+// SetRoutineStatusText implements guis.GUIEnabler.SetRoutineStatusText by logging the text at debug level. The display
+// time is ignored.
+func (h *Headless) SetRoutineStatusText(text string, time int) {
+	log.Debugln(text)
+}
+
+// DrawParticles implements guis.GUIEnabler.DrawParticles. If FrameDir is set, the particles are drawn to a PNG file
+// in it (every FrameInterval calls), holding the particles lock for reading (see physics.RLockParticles).
+func (h *Headless) DrawParticles() {
+	physics.RLockParticles()
+	defer physics.RUnlockParticles()
+	particles := physics.Engine.Particles
+	h.particles = particles
+	frame := h.frame
+	h.frame++
+	if h.FrameDir == "" || (h.FrameInterval > 1 && frame%h.FrameInterval != 0) {
+		return
+	}
+
+	img := h.render(particles)
+	file := filepath.Join(h.FrameDir, fmt.Sprintf("frame_%06d.png", frame))
+	f, err := os.Create(file)
+	if err != nil {
+		log.Warnln("Unable to create frame file: " + err.Error())
+		return
+	}
+	defer f.Close()
+	if err = png.Encode(f, img); err != nil {
+		log.Warnln("Unable to write frame file: " + err.Error())
+	}
+}
+
+// Snapshot implements guis.GUIEnabler.Snapshot. As frames are only drawn to file every FrameInterval calls to
+// DrawParticles (if at all), the most recently drawn particles are rendered on request.
+func (h *Headless) Snapshot() image.Image {
+	return h.render(h.particles)
+}
+
+// ExportSVG implements guis.GUIEnabler.ExportSVG. The headless GUI only renders raster frames, so it returns an error.
+func (h *Headless) ExportSVG(file string) error {
+	return errors.New("the headless GUI doesn't export SVG images")
+}
+
+// render draws the provided particles, as filled circles on a white background the size of the environment.
+func (h *Headless) render(particles []*physics.Particle) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, h.environmentWidth, h.environmentHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for _, p := range particles {
+		c := &image.Uniform{C: color.NRGBA{R: p.R, G: p.G, B: p.B, A: p.A}}
+		m := &circle{x: int(math.Round(p.Position()[0])), y: int(math.Round(p.Position()[1])),
+			r: int(math.Max(math.Round(float64(p.Radius)*h.drawRadiusScale), math.Max(float64(h.minDrawRadius), 1)))}
+		draw.DrawMask(img, m.Bounds(), c, image.Point{}, m, m.Bounds().Min, draw.Over)
+	}
+	return img
+}
+
+// UpdateView implements guis.GUIEnabler.UpdateView.
+func (h *Headless) UpdateView() {
+	h.environmentWidth = physics.Engine.EnvironmentWidth
+	h.environmentHeight = physics.Engine.EnvironmentHeight
+	h.DrawParticles()
+}
+
+// SimulationPaused implements guis.GUIEnabler.SimulationPaused. The headless GUI runs the simulation itself (for a set
+// number of steps), so it is ignored.
+func (h *Headless) SimulationPaused() {}
+
+// SetSelectedParticles implements guis.GUIEnabler.SetSelectedParticles. The headless GUI has no user to select
+// particles, so it is ignored.
+func (h *Headless) SetSelectedParticles(particles []*physics.Particle) {}
+
+// SetInspectedParticle implements guis.GUIEnabler.SetInspectedParticle. The headless GUI has no user to inspect
+// particles, so it is ignored.
+func (h *Headless) SetInspectedParticle(p *physics.Particle) {}
+
+// circle is an image.Image used as a mask for drawing filled circles, centered on (x, y) and of radius r.
+type circle struct {
+	x, y, r int
+}
+
+// ColorModel implements image.Image.ColorModel.
+func (c *circle) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+// Bounds implements image.Image.Bounds.
+func (c *circle) Bounds() image.Rectangle {
+	return image.Rect(c.x-c.r, c.y-c.r, c.x+c.r+1, c.y+c.r+1)
+}
+
+// At implements image.Image.At. Points within the circle are opaque, others transparent.
+func (c *circle) At(x, y int) color.Color {
+	dx, dy := x-c.x, y-c.y
+	if dx*dx+dy*dy <= c.r*c.r {
+		return color.Alpha{A: 255}
+	}
+	return color.Alpha{}
+}
+
+// The headless GUI has no controls, so the main app event handlers are never triggered and are simply discarded.
+
+// ConnectSaveStateEvent implements guis.GUIEnabler.ConnectSaveStateEvent
+func (h *Headless) ConnectSaveStateEvent(f func(file string)) {}
+
+// ConnectLoadStateEvent implements guis.GUIEnabler.ConnectLoadStateEvent
+func (h *Headless) ConnectLoadStateEvent(f func(file string)) {}
+
+// ConnectSaveSettingsEvent implements guis.GUIEnabler.ConnectSaveSettingsEvent
+func (h *Headless) ConnectSaveSettingsEvent(f func(file string)) {}
+
+// ConnectLoadSettingsEvent implements guis.GUIEnabler.ConnectLoadSettingsEvent
+func (h *Headless) ConnectLoadSettingsEvent(f func(file string)) {}
+
+// ConnectResetSettingsEvent implements guis.GUIEnabler.ConnectResetSettingsEvent
+func (h *Headless) ConnectResetSettingsEvent(f func()) {}
+
+// ConnectLoadPresetEvent implements guis.GUIEnabler.ConnectLoadPresetEvent
+func (h *Headless) ConnectLoadPresetEvent(f func(name string)) {}
+
+// ConnectEnvironmentWidthChangedEvent implements guis.GUIEnabler.ConnectEnvironmentWidthChangedEvent
+func (h *Headless) ConnectEnvironmentWidthChangedEvent(f func(value int)) {}
+
+// ConnectEnvironmentHeightChangedEvent implements guis.GUIEnabler.ConnectEnvironmentHeightChangedEvent
+func (h *Headless) ConnectEnvironmentHeightChangedEvent(f func(value int)) {}
+
+// ConnectNumParticlesChangedEvent implements guis.GUIEnabler.ConnectNumParticlesChangedEvent
+func (h *Headless) ConnectNumParticlesChangedEvent(f func(value int)) {}
+
+// ConnectAverageMassChangedEvent implements guis.GUIEnabler.ConnectAverageMassChangedEvent
+func (h *Headless) ConnectAverageMassChangedEvent(f func(value int)) {}
+
+// ConnectInitialVelocityModeChangedEvent implements guis.GUIEnabler.ConnectInitialVelocityModeChangedEvent
+func (h *Headless) ConnectInitialVelocityModeChangedEvent(f func(value int)) {}
+
+// ConnectInitialSpeedChangedEvent implements guis.GUIEnabler.ConnectInitialSpeedChangedEvent
+func (h *Headless) ConnectInitialSpeedChangedEvent(f func(value float64)) {}
+
+// ConnectGenerationRegionChangedEvent implements guis.GUIEnabler.ConnectGenerationRegionChangedEvent
+func (h *Headless) ConnectGenerationRegionChangedEvent(f func(x, y, radius float64)) {}
+
+// ConnectChargeDistributionChangedEvent implements guis.GUIEnabler.ConnectChargeDistributionChangedEvent
+func (h *Headless) ConnectChargeDistributionChangedEvent(f func(value int)) {}
+
+// ConnectRegenParticlesEvent implements guis.GUIEnabler.ConnectRegenParticlesEvent
+func (h *Headless) ConnectRegenParticlesEvent(f func()) {}
+
+// ConnectGravityStrengthChangedEvent implements guis.GUIEnabler.ConnectGravityStrengthChangedEvent
+func (h *Headless) ConnectGravityStrengthChangedEvent(f func(value float64)) {}
+
+// ConnectCloseChargeStrengthChangedEvent implements guis.GUIEnabler.ConnectCloseChargeStrengthChangedEvent
+func (h *Headless) ConnectCloseChargeStrengthChangedEvent(f func(value float64)) {}
+
+// ConnectFarChargeStrengthChangedEvent implements guis.GUIEnabler.ConnectFarChargeStrengthChangedEvent
+func (h *Headless) ConnectFarChargeStrengthChangedEvent(f func(value float64)) {}
+
+// ConnectFarChargeExponentChangedEvent implements guis.GUIEnabler.ConnectFarChargeExponentChangedEvent
+func (h *Headless) ConnectFarChargeExponentChangedEvent(f func(value float64)) {}
+
+// ConnectFarChargeCutoffChangedEvent implements guis.GUIEnabler.ConnectFarChargeCutoffChangedEvent
+func (h *Headless) ConnectFarChargeCutoffChangedEvent(f func(value float64)) {}
+
+// ConnectGravityOnlyChangedEvent implements guis.GUIEnabler.ConnectGravityOnlyChangedEvent
+func (h *Headless) ConnectGravityOnlyChangedEvent(f func(enabled bool)) {}
+
+// ConnectEnableGravityChangedEvent implements guis.GUIEnabler.ConnectEnableGravityChangedEvent
+func (h *Headless) ConnectEnableGravityChangedEvent(f func(enabled bool)) {}
+
+// ConnectEnableCloseChargeChangedEvent implements guis.GUIEnabler.ConnectEnableCloseChargeChangedEvent
+func (h *Headless) ConnectEnableCloseChargeChangedEvent(f func(enabled bool)) {}
+
+// ConnectSpeciesMatrixChangedEvent implements guis.GUIEnabler.ConnectSpeciesMatrixChangedEvent
+func (h *Headless) ConnectSpeciesMatrixChangedEvent(f func(matrix [][]float64)) {}
+
+// ConnectEnableFarChargeChangedEvent implements guis.GUIEnabler.ConnectEnableFarChargeChangedEvent
+func (h *Headless) ConnectEnableFarChargeChangedEvent(f func(enabled bool)) {}
+
+// ConnectAllowMergeChangedEvent implements guis.GUIEnabler.ConnectAllowMergeChangedEvent
+func (h *Headless) ConnectAllowMergeChangedEvent(f func(enabled bool)) {}
+
+// ConnectMergeMassRatioThresholdChangedEvent implements guis.GUIEnabler.ConnectMergeMassRatioThresholdChangedEvent
+func (h *Headless) ConnectMergeMassRatioThresholdChangedEvent(f func(value float64)) {}
+
+// ConnectMergeCloseChargeThresholdChangedEvent implements guis.GUIEnabler.ConnectMergeCloseChargeThresholdChangedEvent
+func (h *Headless) ConnectMergeCloseChargeThresholdChangedEvent(f func(value float64)) {}
+
+// ConnectCaptureSpeedChangedEvent implements guis.GUIEnabler.ConnectCaptureSpeedChangedEvent
+func (h *Headless) ConnectCaptureSpeedChangedEvent(f func(value float64)) {}
+
+// ConnectChargeMergeRuleChangedEvent implements guis.GUIEnabler.ConnectChargeMergeRuleChangedEvent
+func (h *Headless) ConnectChargeMergeRuleChangedEvent(f func(value int)) {}
+
+// ConnectAllowFissionChangedEvent implements guis.GUIEnabler.ConnectAllowFissionChangedEvent
+func (h *Headless) ConnectAllowFissionChangedEvent(f func(enabled bool)) {}
+
+// ConnectMaxMassChangedEvent implements guis.GUIEnabler.ConnectMaxMassChangedEvent
+func (h *Headless) ConnectMaxMassChangedEvent(f func(value float64)) {}
+
+// ConnectMaxParticlesChangedEvent implements guis.GUIEnabler.ConnectMaxParticlesChangedEvent
+func (h *Headless) ConnectMaxParticlesChangedEvent(f func(value int)) {}
+
+// ConnectBounceCompleteDistFactorChangedEvent implements guis.GUIEnabler.ConnectBounceCompleteDistFactorChangedEvent
+func (h *Headless) ConnectBounceCompleteDistFactorChangedEvent(f func(value float64)) {}
+
+// ConnectRestitutionChangedEvent implements guis.GUIEnabler.ConnectRestitutionChangedEvent
+func (h *Headless) ConnectRestitutionChangedEvent(f func(value float64)) {}
+
+// ConnectDragCoefficientChangedEvent implements guis.GUIEnabler.ConnectDragCoefficientChangedEvent
+func (h *Headless) ConnectDragCoefficientChangedEvent(f func(value float64)) {}
+
+// ConnectMaxAccelerationChangedEvent implements guis.GUIEnabler.ConnectMaxAccelerationChangedEvent
+func (h *Headless) ConnectMaxAccelerationChangedEvent(f func(value float64)) {}
+
+// ConnectQuadraticDragChangedEvent implements guis.GUIEnabler.ConnectQuadraticDragChangedEvent
+func (h *Headless) ConnectQuadraticDragChangedEvent(f func(enabled bool)) {}
+
+// ConnectExternalFieldChangedEvent implements guis.GUIEnabler.ConnectExternalFieldChangedEvent
+func (h *Headless) ConnectExternalFieldChangedEvent(f func(strength, angle float64)) {}
+
+// ConnectFrameAngularVelocityChangedEvent implements guis.GUIEnabler.ConnectFrameAngularVelocityChangedEvent
+func (h *Headless) ConnectFrameAngularVelocityChangedEvent(f func(value float64)) {}
+
+// ConnectWallBounceChangedEvent implements guis.GUIEnabler.ConnectWallBounceChangedEvent
+func (h *Headless) ConnectWallBounceChangedEvent(f func(enabled bool)) {}
+
+// ConnectWrapBoundaryChangedEvent implements guis.GUIEnabler.ConnectWrapBoundaryChangedEvent
+func (h *Headless) ConnectWrapBoundaryChangedEvent(f func(enabled bool)) {}
+
+// ConnectHistoryTrailChangedEvent implements guis.GUIEnabler.ConnectHistoryTrailChangedEvent
+func (h *Headless) ConnectHistoryTrailChangedEvent(f func(enabled bool)) {}
+
+// ConnectHistoryTrailLengthChangedEvent implements guis.GUIEnabler.ConnectHistoryTrailLengthChangedEvent
+func (h *Headless) ConnectHistoryTrailLengthChangedEvent(f func(value int)) {}
+
+// ConnectHistoryTrailStrideChangedEvent implements guis.GUIEnabler.ConnectHistoryTrailStrideChangedEvent
+func (h *Headless) ConnectHistoryTrailStrideChangedEvent(f func(value int)) {}
+
+// ConnectColorSchemeChangedEvent implements guis.GUIEnabler.ConnectColorSchemeChangedEvent
+func (h *Headless) ConnectColorSchemeChangedEvent(f func(value int)) {}
+
+// ConnectTrailStyleChangedEvent implements guis.GUIEnabler.ConnectTrailStyleChangedEvent. The headless GUI doesn't
+// draw trails, so it is ignored.
+func (h *Headless) ConnectTrailStyleChangedEvent(f func(value int)) {}
+
+// ConnectTrailColoringChangedEvent implements guis.GUIEnabler.ConnectTrailColoringChangedEvent. The headless GUI
+// doesn't draw trails, so it is ignored.
+func (h *Headless) ConnectTrailColoringChangedEvent(f func(value int)) {}
+
+// ConnectHistoryPrefillChangedEvent implements guis.GUIEnabler.ConnectHistoryPrefillChangedEvent. The headless GUI
+// doesn't draw trails, so it is ignored.
+func (h *Headless) ConnectHistoryPrefillChangedEvent(f func(value int)) {}
+
+// ConnectDrawRadiusScaleChangedEvent implements guis.GUIEnabler.ConnectDrawRadiusScaleChangedEvent
+func (h *Headless) ConnectDrawRadiusScaleChangedEvent(f func(value float64)) {}
+
+// ConnectMinDrawRadiusChangedEvent implements guis.GUIEnabler.ConnectMinDrawRadiusChangedEvent
+func (h *Headless) ConnectMinDrawRadiusChangedEvent(f func(value int)) {}
+
+// ConnectShowVelocityVectorsEvent implements guis.GUIEnabler.ConnectShowVelocityVectorsEvent
+func (h *Headless) ConnectShowVelocityVectorsEvent(f func(enabled bool)) {}
+
+// ConnectShowParticleIDsEvent implements guis.GUIEnabler.ConnectShowParticleIDsEvent
+func (h *Headless) ConnectShowParticleIDsEvent(f func(enabled bool)) {}
+
+// ConnectDensityHeatmapEvent implements guis.GUIEnabler.ConnectDensityHeatmapEvent
+func (h *Headless) ConnectDensityHeatmapEvent(f func(enabled bool)) {}
+
+// ConnectCenterOfMassFrameEvent implements guis.GUIEnabler.ConnectCenterOfMassFrameEvent
+func (h *Headless) ConnectCenterOfMassFrameEvent(f func(enabled bool)) {}
+
+// ConnectSmoothRenderingEvent implements guis.GUIEnabler.ConnectSmoothRenderingEvent
+func (h *Headless) ConnectSmoothRenderingEvent(f func(enabled bool)) {}
+
+// ConnectBackgroundColorChangedEvent implements guis.GUIEnabler.ConnectBackgroundColorChangedEvent
+func (h *Headless) ConnectBackgroundColorChangedEvent(f func(color state.Color)) {}
+
+// ConnectWallColorChangedEvent implements guis.GUIEnabler.ConnectWallColorChangedEvent
+func (h *Headless) ConnectWallColorChangedEvent(f func(color state.Color)) {}
+
+// ConnectPhysicsLoopSpeedChangedEvent implements guis.GUIEnabler.ConnectPhysicsLoopSpeedChangedEvent
+func (h *Headless) ConnectPhysicsLoopSpeedChangedEvent(f func(value int)) {}
+
+// ConnectStepsPerFrameChangedEvent implements guis.GUIEnabler.ConnectStepsPerFrameChangedEvent. The headless GUI
+// writes frames at its own FrameInterval instead.
+func (h *Headless) ConnectStepsPerFrameChangedEvent(f func(value int)) {}
+
+// ConnectAutoSlowdownChangedEvent implements guis.GUIEnabler.ConnectAutoSlowdownChangedEvent
+func (h *Headless) ConnectAutoSlowdownChangedEvent(f func(enabled bool)) {}
+
+// ConnectResetEnvironmentEvent implements guis.GUIEnabler.ConnectResetEnvironmentEvent
+func (h *Headless) ConnectResetEnvironmentEvent(f func()) {}
+
+// ConnectZeroVelocitiesEvent implements guis.GUIEnabler.ConnectZeroVelocitiesEvent
+func (h *Headless) ConnectZeroVelocitiesEvent(f func()) {}
+
+// ConnectPauseResumeEvent implements guis.GUIEnabler.ConnectPauseResumeEvent
+func (h *Headless) ConnectPauseResumeEvent(f func() (paused bool)) {}
+
+// ConnectStepOnceEvent implements guis.GUIEnabler.ConnectStepOnceEvent
+func (h *Headless) ConnectStepOnceEvent(f func()) {}
+
+// ConnectPauseOnMergeChangedEvent implements guis.GUIEnabler.ConnectPauseOnMergeChangedEvent
+func (h *Headless) ConnectPauseOnMergeChangedEvent(f func(enabled bool)) {}
+
+// ConnectPauseOnSpeedChangedEvent implements guis.GUIEnabler.ConnectPauseOnSpeedChangedEvent
+func (h *Headless) ConnectPauseOnSpeedChangedEvent(f func(enabled bool)) {}
+
+// ConnectPauseSpeedThresholdChangedEvent implements guis.GUIEnabler.ConnectPauseSpeedThresholdChangedEvent
+func (h *Headless) ConnectPauseSpeedThresholdChangedEvent(f func(value float64)) {}
+
+// ConnectUndoEvent implements guis.GUIEnabler.ConnectUndoEvent
+func (h *Headless) ConnectUndoEvent(f func()) {}
+
+// ConnectToggleFixedEvent implements guis.GUIEnabler.ConnectToggleFixedEvent
+func (h *Headless) ConnectToggleFixedEvent(f func(x, y float64)) {}
+
+// ConnectParticleClickedEvent implements guis.GUIEnabler.ConnectParticleClickedEvent
+func (h *Headless) ConnectParticleClickedEvent(f func(x, y float64)) {}
+
+// ConnectGrabParticleEvent implements guis.GUIEnabler.ConnectGrabParticleEvent
+func (h *Headless) ConnectGrabParticleEvent(f func(x, y float64, addToSelection bool) (grabbed bool)) {
+}
+
+// ConnectDragParticlesEvent implements guis.GUIEnabler.ConnectDragParticlesEvent
+func (h *Headless) ConnectDragParticlesEvent(f func(dx, dy float64)) {}
+
+// ConnectDropParticlesEvent implements guis.GUIEnabler.ConnectDropParticlesEvent
+func (h *Headless) ConnectDropParticlesEvent(f func()) {}
+
+// ConnectAttractorEvent implements guis.GUIEnabler.ConnectAttractorEvent
+func (h *Headless) ConnectAttractorEvent(f func(x, y float64, repel bool)) {}
+
+// ConnectReleaseAttractorEvent implements guis.GUIEnabler.ConnectReleaseAttractorEvent
+func (h *Headless) ConnectReleaseAttractorEvent(f func()) {}
+
+// ConnectRedoEvent implements guis.GUIEnabler.ConnectRedoEvent
+func (h *Headless) ConnectRedoEvent(f func()) {}
+
+// ConnectStartRecordingEvent implements guis.GUIEnabler.ConnectStartRecordingEvent. Frames are recorded using FrameDir
+// instead.
+func (h *Headless) ConnectStartRecordingEvent(f func(dir string)) {}
+
+// ConnectStopRecordingEvent implements guis.GUIEnabler.ConnectStopRecordingEvent
+func (h *Headless) ConnectStopRecordingEvent(f func()) {}
+
+// ConnectStartTrajectoryEvent implements guis.GUIEnabler.ConnectStartTrajectoryEvent. Trajectories are recorded using
+// the -trajectory flag instead.
+func (h *Headless) ConnectStartTrajectoryEvent(f func(file string) (started bool)) {}
+
+// ConnectStopTrajectoryEvent implements guis.GUIEnabler.ConnectStopTrajectoryEvent
+func (h *Headless) ConnectStopTrajectoryEvent(f func()) {}
+
+// ConnectExportGenealogyEvent implements guis.GUIEnabler.ConnectExportGenealogyEvent
+func (h *Headless) ConnectExportGenealogyEvent(f func(file string)) {}
+
+// ConnectExportSVGEvent implements guis.GUIEnabler.ConnectExportSVGEvent
+func (h *Headless) ConnectExportSVGEvent(f func(file string)) {}
+
+// ConnectShutdownEvent implements guis.GUIEnabler.ConnectShutdownEvent
+func (h *Headless) ConnectShutdownEvent(f func()) {
+	h.shutdownEventHandler = f
+}
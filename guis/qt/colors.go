@@ -0,0 +1,30 @@
+package qt
+
+import (
+	"fmt"
+
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"GoGoGadgetGravity/state"
+)
+
+// pickColor presents a color picker (with an alpha channel) titled title and starting at color current, and calls
+// selected with the color the user picks (if they don't cancel).
+func (q *Qt) pickColor(title string, current state.Color, selected func(c state.Color)) {
+	dlg := widgets.NewQColorDialog2(gui.NewQColor3(int(current.R), int(current.G), int(current.B), int(current.A)), nil)
+	dlg.SetWindowTitle(title)
+	dlg.SetOption(widgets.QColorDialog__ShowAlphaChannel, true)
+	// Anonymous function called on clicking OK
+	dlg.ConnectColorSelected(func(c *gui.QColor) {
+		selected(state.Color{R: uint8(c.Red()), G: uint8(c.Green()), B: uint8(c.Blue()), A: uint8(c.Alpha())})
+	})
+	// Show the dialog (waits for OK / cancel)
+	dlg.Show()
+}
+
+// setColorButton fills button with color c (as a swatch, with its alpha shown as a percentage).
+func setColorButton(button *widgets.QPushButton, c state.Color) {
+	button.SetStyleSheet(fmt.Sprintf("background-color: rgba(%d, %d, %d, %d);", c.R, c.G, c.B, c.A))
+	button.SetText(fmt.Sprintf("%d%% opaque", int(float64(c.A)/255*100+0.5)))
+}
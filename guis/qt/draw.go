@@ -1,49 +1,102 @@
 package qt
 
 import (
+	"encoding/binary"
 	"image"
-	"image/png"
 	"math"
-	"os"
 	"strconv"
-	"strings"
 
+	"github.com/atedja/go-vector"
+	log "github.com/sirupsen/logrus"
 	"github.com/therecipe/qt/gui"
 
 	"GoGoGadgetGravity/physics"
+	"GoGoGadgetGravity/state"
 )
 
-// DrawParticles implements guis.GUIEnabler.DrawParticles. Unsurprisingly, it draws the provided particles in their
-// current positions, and if enabled draws their position history trails.
-func (q *Qt) DrawParticles(particles []*physics.Particle) {
+const (
+	// velocityArrowScale is the length of a velocity vector arrow (see drawVelocityArrow) per unit of particle speed.
+	velocityArrowScale = 5
+	// maxVelocityArrowLength is the maximum length of velocity vector arrows, so the arrows of fast particles don't
+	// span the environment.
+	maxVelocityArrowLength = 60
+	// velocityArrowHeadLength is the length of the two short lines forming the head of velocity vector arrows.
+	velocityArrowHeadLength = 5
+)
+
+// DrawParticles implements guis.GUIEnabler.DrawParticles. Unsurprisingly, it draws the particles in their current
+// positions, and if enabled draws their position history trails. If the density heatmap is enabled, the
+// particles are instead drawn as a density field (see drawDensityHeatmap). If the center of mass frame is enabled, the
+// drawn positions are shifted so the particles' center of mass is at the center of the environment (see drawPosition).
+// The particles lock is held for reading while drawing (see physics.RLockParticles), so the particles aren't updated
+// or changed meanwhile. The physics.Engine.Particles are only read once it's held, as they may have been replaced
+// (e.g. by mergers, or by newly generated particles) since DrawParticles was called.
+func (q *Qt) DrawParticles() {
 	//timeStart := time.Now()
+	physics.RLockParticles()
+	defer physics.RUnlockParticles()
+	particles := physics.Engine.Particles
 
+	q.setFrame(particles)
+	if q.fitParticles {
+		q.updateParticleBounds()
+	}
 	q.StartIm2Qim(true)
 	q.DrawViewBox()
 
-	for _, p := range particles {
-		// If TrackHistory is enabled, each historical position is drawn, with successively older positions
-		// fainter (lower alpha)
-		if p.TrackHistory() {
-			for i, h := range p.PositionHistory() {
-				q.drawFilledCircle(
-					int(math.Round(h[0])),
-					int(math.Round(h[1])),
-					// Historical positions are drawn smaller
-					int(math.Max(float64(p.Radius)*0.75, 1)),
-					p.R, p.G, 0,
-					// Calculate the alpha, which will have a minimum of 16 and a maximum
-					// 16+240*((index-1)/HistorySize) - e.g. 232 if HistorySize is 10
-					16+uint8((float64(p.A)-16)*(float64(i)/
-						math.Min(float64(p.HistorySize()), float64(len(p.PositionHistory()))))))
+	if q.densityHeatmap {
+		q.drawDensityHeatmap(particles)
+	} else {
+		for _, p := range particles {
+			// If TrackHistory is enabled, each historical position is drawn (or, with the TrailLines style,
+			// connected), with successively older positions fainter (lower alpha)
+			if p.TrackHistory() && q.trailStyle == state.TrailLines {
+				q.drawTrailLines(p)
+			} else if p.TrackHistory() {
+				for i, h := range p.PositionHistory() {
+					hx, hy := q.drawPosition(h)
+					// The color depends on the age of the position, from 0 for the oldest toward 1 for the newest
+					r, g, b, a := q.trailColor(p, float64(i)/
+						math.Min(float64(p.HistorySize()), float64(len(p.PositionHistory()))))
+					q.drawWrappedFilledCircle(
+						int(math.Round(hx)),
+						int(math.Round(hy)),
+						// Historical positions are drawn smaller
+						int(math.Max(float64(q.drawRadius(p))*0.75, 1)),
+						r, g, b, a)
+				}
+			}
+			x, y := q.drawPosition(p.Position())
+			cx, cy := int(math.Round(x)), int(math.Round(y))
+			q.drawWrappedFilledCircle(cx, cy, q.drawRadius(p), p.R, p.G, p.B, p.A)
+			// Fixed (pinned) particles are outlined
+			if p.Fixed() {
+				q.drawCircleBorder(cx, cy, q.drawRadius(p)+3, 0, 0, 255, 255)
 			}
+			// Selected particles (see SetSelectedParticles) are outlined outside the fixed outline
+			if _, ok := q.selected[p]; ok {
+				q.drawCircleBorder(cx, cy, q.drawRadius(p)+5, 255, 165, 0, 255)
+			}
+		}
+	}
+	// Velocity vector arrows are drawn over all the particles
+	if q.showVelocityVectors {
+		for _, p := range particles {
+			q.drawVelocityArrow(p)
 		}
-		q.drawFilledCircle(int(math.Round(p.Position()[0])), int(math.Round(p.Position()[1])), p.Radius, p.R, p.G, 0, p.A)
 	}
-	// If not showing a (temporary) particle merge message, display the number of particles in the tatusbar
-	if !strings.HasPrefix(q.statusbar.CurrentMessage(), "merging") {
-		q.statusbar.ShowMessage("# of Particles: "+strconv.Itoa(len(particles)), 0)
+	// The View follows the particles as they spread out or contract, unless the user has zoomed it
+	if q.fitParticles && !q.zoomed {
+		q.fitView()
+	}
+	q.updateInspection(particles)
+	// Display the number of particles in the statusbar (warning if it has reached the maximum), unless showing other
+	// status text (e.g. a particle merge, or particle details)
+	count := "# of Particles: " + strconv.Itoa(len(particles))
+	if physics.AtParticleLimit() {
+		count += " (Warning: limit reached)"
 	}
+	q.showStatus(count, statusCount, 0)
 
 	//Threaded solution is slower in this situation...
 	//Make each thread handle at least 10 particles so we're not over-threading
@@ -72,27 +125,122 @@ func (q *Qt) DrawParticles(particles []*physics.Particle) {
 
 	q.StopIm2Qim()
 
+	if q.showParticleIDs {
+		q.drawParticleIDs(particles)
+	}
+
 	//fmt.Println("DrawParticles time: " + time.Since(timeStart).String())
 }
 
-// DrawViewBox draws a box indicated the bounds/walls of the environment
+// SetSelectedParticles implements guis.GUIEnabler.SetSelectedParticles
+func (q *Qt) SetSelectedParticles(particles []*physics.Particle) {
+	q.selected = make(map[*physics.Particle]struct{}, len(particles))
+	for _, p := range particles {
+		q.selected[p] = struct{}{}
+	}
+}
+
+// drawTrailLines draws the position history trail of Particle p as lines connecting its consecutive historical
+// positions (and the newest to its current position), with successively older segments fainter (lower alpha, as for
+// the circles drawn by DrawParticles).
+func (q *Qt) drawTrailLines(p *physics.Particle) {
+	history := p.PositionHistory()
+	count := math.Min(float64(p.HistorySize()), float64(len(history)))
+	for i, h := range history {
+		next := p.Position()
+		if i+1 < len(history) {
+			next = history[i+1]
+		}
+		x0, y0 := q.drawPosition(h)
+		x1, y1 := q.drawPosition(next)
+		// When the environment wraps around, consecutive positions on opposite sides are not connected (the particle
+		// crossed the edge rather than the environment)
+		if q.wrapBoundary && (math.Abs(x1-x0) > float64(q.EnvironmentWidth)/2 ||
+			math.Abs(y1-y0) > float64(q.EnvironmentHeight)/2) {
+			continue
+		}
+		r, g, b, a := q.trailColor(p, float64(i)/count)
+		q.drawLine(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1)), r, g, b, a)
+	}
+}
+
+// trailColor gets the color of Particle p's trail at a historical position of age fraction age (from 0 for the oldest
+// position toward 1 for the newest). With the TrailColorParticle coloring it's p's color, and with TrailColorAge it's
+// the cold-to-hot gradient of the density heatmap (see heatmapColor). Either way, the alpha has a minimum of 16 and
+// rises with the age fraction to p's alpha - e.g. a maximum of 16+240*((HistorySize-1)/HistorySize), 232 if
+// HistorySize is 10 and p is opaque.
+func (q *Qt) trailColor(p *physics.Particle, age float64) (r, g, b, a uint8) {
+	a = 16 + uint8((float64(p.A)-16)*age)
+	if q.trailColoring == state.TrailColorAge {
+		r, g, b, _ = heatmapColor(age)
+		return r, g, b, a
+	}
+	return p.R, p.G, p.B, a
+}
+
+// drawVelocityArrow draws an arrow from the center of Particle p along its velocity, with length proportional to its
+// speed (see velocityArrowScale, maxVelocityArrowLength). The arrows are magenta, so they stand out from the particles.
+// In the center of mass frame, the velocity is relative to the center of mass velocity (see setFrame).
+func (q *Qt) drawVelocityArrow(p *physics.Particle) {
+	vx, vy := p.Velocity()[0]-q.frameVelocity[0], p.Velocity()[1]-q.frameVelocity[1]
+	speed := math.Hypot(vx, vy)
+	length := math.Min(speed*velocityArrowScale, maxVelocityArrowLength)
+	if length < 1 {
+		return
+	}
+	dx, dy := vx/speed, vy/speed
+	x0, y0 := q.drawPosition(p.Position())
+	x1, y1 := x0+dx*length, y0+dy*length
+	q.drawLine(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1)), 255, 0, 255, 255)
+
+	// The head is two lines back from the tip, each 30 degrees either side of the shaft
+	headLength := math.Min(velocityArrowHeadLength, length/2)
+	for _, angle := range [2]float64{math.Pi / 6, -math.Pi / 6} {
+		sin, cos := math.Sincos(angle)
+		hx, hy := -(dx*cos-dy*sin)*headLength, -(dx*sin+dy*cos)*headLength
+		q.drawLine(int(math.Round(x1)), int(math.Round(y1)), int(math.Round(x1+hx)), int(math.Round(y1+hy)),
+			255, 0, 255, 255)
+	}
+}
+
+// drawParticleIDs draws the ID of each of the provided particles just above and to the right of it. The text is drawn
+// onto the Canvas with a QPainter (so it must be called after the particles have been drawn, and im2qim mode stopped),
+// and so isn't included in recordings (which capture the particles as drawn in im2qim mode).
+func (q *Qt) drawParticleIDs(particles []*physics.Particle) {
+	painter := gui.NewQPainter2(q.Canvas)
+	painter.SetPen2(gui.NewQColor3(0, 0, 0, 255))
+	for _, p := range particles {
+		r := q.drawRadius(p)
+		px, py := q.drawPosition(p.Position())
+		x, y := int(math.Round(px)), int(math.Round(py))
+		painter.DrawText3(q.toCanvas(x+r+2), q.toCanvas(y-r), strconv.Itoa(p.ID()))
+	}
+	painter.End()
+
+	q.Pixmap.SetPixmap(gui.NewQPixmap().FromImage(q.Canvas, 0))
+}
+
+// drawRadius gets the radius Particle p is drawn with, which is its Radius scaled by the drawRadiusScale (but at least
+// the minDrawRadius, and 1 pixel). Only the drawing is affected; p's Radius (used for collisions) is unchanged.
+func (q *Qt) drawRadius(p *physics.Particle) int {
+	return int(math.Max(math.Round(float64(p.Radius)*q.drawRadiusScale), math.Max(float64(q.minDrawRadius), 1)))
+}
+
+// DrawViewBox draws a box (in the wall color) indicating the bounds/walls of the environment
 func (q *Qt) DrawViewBox() {
 	if !q.im2qim {
 		q.Canvas = q.Pixmap.Pixmap().ToImage()
 	}
 
+	// Drawn in Canvas coordinates, so the box lies on the edges of the Canvas whatever the RenderScale
+	right, bottom := q.canvasWidth-1, q.canvasHeight-1
+	c := q.wallColor
 	// Sides
-	for _, x := range [2]int{0, q.EnvironmentSize - 1} {
-		for y := 0; y < q.EnvironmentSize; y++ {
-			q.setPixel(x, y, 0, 0, 255, 255)
-		}
-	}
+	q.drawCanvasLine(0, 0, 0, bottom, c.R, c.G, c.B, c.A)
+	q.drawCanvasLine(right, 0, right, bottom, c.R, c.G, c.B, c.A)
 	// Top & Bottom
-	for _, y := range [2]int{0, q.EnvironmentSize - 1} {
-		for x := 0; x < q.EnvironmentSize; x++ {
-			q.setPixel(x, y, 0, 0, 255, 255)
-		}
-	}
+	q.drawCanvasLine(0, 0, right, 0, c.R, c.G, c.B, c.A)
+	q.drawCanvasLine(0, bottom, right, bottom, c.R, c.G, c.B, c.A)
 
 	if !q.im2qim {
 		q.Pixmap.SetPixmap(gui.NewQPixmap().FromImage(q.Canvas, 0))
@@ -102,8 +250,9 @@ func (q *Qt) DrawViewBox() {
 // drawCircleBorder draws a rasterized circle border (ring 1 pixel wide), centered on (cx, cy) and of the
 // color provided by r,g,b,a, using the Midpoint Circle algorithm.
 func (q *Qt) drawCircleBorder(cx, cy, rad int, r, g, b, a uint8) {
-	// If circle falls entirely outside the environment, return
-	if (cx+rad < 0 || cx-rad > q.EnvironmentSize) && (cy+rad < 0 || cy-rad > q.EnvironmentSize) {
+	cx, cy, rad = q.toCanvas(cx), q.toCanvas(cy), q.toCanvasLength(rad)
+	// If circle falls entirely outside the canvas, return
+	if (cx+rad < 0 || cx-rad > q.canvasWidth) && (cy+rad < 0 || cy-rad > q.canvasHeight) {
 		return
 	}
 
@@ -137,8 +286,9 @@ func (q *Qt) drawCircleBorder(cx, cy, rad int, r, g, b, a uint8) {
 // using a (heavy) modification to the Midpoint Circle algorithm.
 // This method is adapted from https://stackoverflow.com/q/10878209/5061881.
 func (q *Qt) drawFilledCircle(cx, cy, rad int, r, g, b, a uint8) {
-	// If circle falls entirely outside the environment, return
-	if (cx+rad < 0 || cx-rad > q.EnvironmentSize) && (cy+rad < 0 || cy-rad > q.EnvironmentSize) {
+	cx, cy, rad = q.toCanvas(cx), q.toCanvas(cy), q.toCanvasLength(rad)
+	// If circle falls entirely outside the canvas, return
+	if (cx+rad < 0 || cx-rad > q.canvasWidth) && (cy+rad < 0 || cy-rad > q.canvasHeight) {
 		return
 	}
 
@@ -165,6 +315,56 @@ func (q *Qt) drawFilledCircle(cx, cy, rad int, r, g, b, a uint8) {
 	}
 }
 
+// drawSmoothCircle draws a filled-in, anti-aliased circle, centered on (cx, cy) and of the color provided by r,g,b,a.
+// Each pixel's coverage is estimated from the distance between its center and the circle's center: pixels entirely
+// within the circle are set (as by drawFilledCircle), and those on its edge are blended over the pixel already drawn
+// (see blendPixel), in proportion to their coverage.
+func (q *Qt) drawSmoothCircle(cx, cy, rad int, r, g, b, a uint8) {
+	cx, cy, rad = q.toCanvas(cx), q.toCanvas(cy), q.toCanvasLength(rad)
+	// If circle falls entirely outside the canvas, return
+	if cx+rad < 0 || cx-rad > q.canvasWidth || cy+rad < 0 || cy-rad > q.canvasHeight {
+		return
+	}
+
+	// The edge is at a radius of rad+0.5 (matching the extent of the circles drawn by drawFilledCircle), and a pixel's
+	// coverage ramps from 1 to 0 as its center goes from half a pixel inside the edge to half a pixel outside it
+	for y := cy - rad - 1; y <= cy+rad+1; y++ {
+		for x := cx - rad - 1; x <= cx+rad+1; x++ {
+			coverage := float64(rad) + 1 - math.Hypot(float64(x-cx), float64(y-cy))
+			if coverage >= 1 {
+				q.setPixel(x, y, r, g, b, a)
+			} else if coverage > 0 {
+				q.blendPixel(x, y, r, g, b, a, coverage)
+			}
+		}
+	}
+}
+
+// drawWrappedFilledCircle draws a filled-in circle (see drawFilledCircle, or drawSmoothCircle if smooth rendering is
+// enabled) and, if the environment wraps around, also draws it on the opposite side(s) of the environment wherever it
+// extends beyond an edge.
+func (q *Qt) drawWrappedFilledCircle(cx, cy, rad int, r, g, b, a uint8) {
+	drawCircle := q.drawFilledCircle
+	if q.smoothRendering {
+		drawCircle = q.drawSmoothCircle
+	}
+	drawCircle(cx, cy, rad, r, g, b, a)
+	if !q.wrapBoundary {
+		return
+	}
+
+	for _, dx := range [3]int{-q.EnvironmentWidth, 0, q.EnvironmentWidth} {
+		for _, dy := range [3]int{-q.EnvironmentHeight, 0, q.EnvironmentHeight} {
+			// Skip the original circle, and any copies which fall entirely outside the environment
+			if (dx == 0 && dy == 0) || cx+dx+rad < 0 || cx+dx-rad >= q.EnvironmentWidth ||
+				cy+dy+rad < 0 || cy+dy-rad >= q.EnvironmentHeight {
+				continue
+			}
+			drawCircle(cx+dx, cy+dy, rad, r, g, b, a)
+		}
+	}
+}
+
 // drawTwoCenteredLines draws two lines of length 2*dx+1, centered on (cx,cy) and of the color provided by r,g,b,a,
 // and with a gap of 2*dx-1 rows/pixels between them (that is, the line at cy and dy-1 lines to either side of it are
 // not drawn).
@@ -176,6 +376,98 @@ func (q *Qt) drawTwoCenteredLines(cx, cy, dx, dy int, r, g, b, a uint8) {
 	}
 }
 
+// drawLine draws a line from (x0,y0) to (x1,y1), of the color provided by r,g,b,a (see drawCanvasLine).
+func (q *Qt) drawLine(x0, y0, x1, y1 int, r, g, b, a uint8) {
+	q.drawCanvasLine(q.toCanvas(x0), q.toCanvas(y0), q.toCanvas(x1), q.toCanvas(y1), r, g, b, a)
+}
+
+// drawCanvasLine draws a line from Canvas coordinates (x0,y0) to (x1,y1), of the color provided by r,g,b,a, using
+// Bresenham's line algorithm.
+func (q *Qt) drawCanvasLine(x0, y0, x1, y1 int, r, g, b, a uint8) {
+	dx, dy := x1-x0, -(y1 - y0)
+	sx, sy := 1, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy > 0 {
+		dy, sy = -dy, -1
+	}
+	err := dx + dy
+
+	for {
+		q.setPixel(x0, y0, r, g, b, a)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// setFrame sets the frameOffset and frameVelocity the particles are drawn with: in the center of mass frame (if enabled,
+// and there are particles), the offset from the particles' center of mass to the center of the environment and the
+// center of mass velocity, and otherwise zero.
+func (q *Qt) setFrame(particles []*physics.Particle) {
+	q.frameOffset, q.frameVelocity = [2]float64{}, [2]float64{}
+	if !q.centerOfMassFrame || len(particles) == 0 {
+		return
+	}
+	c, v := physics.CenterOfMass(), physics.CenterOfMassVelocity()
+	q.frameOffset = [2]float64{float64(q.EnvironmentWidth)/2 - c[0], float64(q.EnvironmentHeight)/2 - c[1]}
+	q.frameVelocity = [2]float64{v[0], v[1]}
+}
+
+// updateParticleBounds sets the particleBounds to the particles' bounding box, shifted by the frameOffset (see
+// setFrame). The caller must hold the particles lock (see physics.RLockParticles).
+func (q *Qt) updateParticleBounds() {
+	minX, minY, maxX, maxY := physics.BoundingBox()
+	q.particleBounds = [4]float64{minX + q.frameOffset[0], minY + q.frameOffset[1], maxX + q.frameOffset[0],
+		maxY + q.frameOffset[1]}
+}
+
+// drawPosition gets the (environment) coordinates a particle at pos is drawn at: pos shifted by the frameOffset (see
+// setFrame), and wrapped back into the environment if it wraps around. The particle's position itself is unchanged.
+func (q *Qt) drawPosition(pos vector.Vector) (x, y float64) {
+	x, y = pos[0]+q.frameOffset[0], pos[1]+q.frameOffset[1]
+	if q.wrapBoundary {
+		x, y = wrapCoordinate(x, float64(q.EnvironmentWidth)), wrapCoordinate(y, float64(q.EnvironmentHeight))
+	}
+	return x, y
+}
+
+// wrapCoordinate wraps coordinate v into the range 0 (inclusive) to size (exclusive).
+func wrapCoordinate(v, size float64) float64 {
+	v = math.Mod(v, size)
+	if v < 0 {
+		v += size
+	}
+	return v
+}
+
+// toCanvas converts an environment coordinate to a Canvas coordinate, per the RenderScale. The draw methods above take
+// environment coordinates, the ones below (and setPixel) Canvas coordinates.
+func (q *Qt) toCanvas(v int) int {
+	if q.RenderScale == 1 {
+		return v
+	}
+	return int(math.Floor(float64(v) * q.RenderScale))
+}
+
+// toCanvasLength converts an environment length (e.g. a radius) to a Canvas length, per the RenderScale.
+func (q *Qt) toCanvasLength(v int) int {
+	if q.RenderScale == 1 {
+		return v
+	}
+	return int(math.Round(float64(v) * q.RenderScale))
+}
+
 // drawHLine draws a horizontal line from (x0,y0) to (x1,y0), of the color provided by r,g,b,a.
 func (q *Qt) drawHLine(x0, y0, x1 int, r, g, b, a uint8) {
 	for x := x0; x <= x1; x++ {
@@ -190,8 +482,25 @@ func (q *Qt) drawVLine(x0, y0, y1 int, r, g, b, a uint8) {
 	}
 }
 
-// setPixel sets the color of a single pixel
+// setPixel sets the color of a single pixel. If the background color isn't transparent, translucent colors are
+// blended over the pixel's current color (see blendPixel) instead, so the background shows through them (rather than
+// being replaced by a translucent pixel, through which whatever is behind the Canvas would show).
 func (q *Qt) setPixel(x, y int, r, g, b, a uint8) {
+	if a < 255 && q.backgroundColor.A > 0 {
+		q.blendPixel(x, y, r, g, b, a, 1)
+		return
+	}
+	q.putPixel(x, y, r, g, b, a)
+}
+
+// putPixel replaces the color of a single pixel.
+func (q *Qt) putPixel(x, y int, r, g, b, a uint8) {
+	// Pixels outside the canvas are not drawn (checking the offset into the back-buffer isn't enough, as pixels
+	// beyond the left or right edge would otherwise be drawn on the adjacent row)
+	if x < 0 || y < 0 || x >= q.canvasWidth || y >= q.canvasHeight {
+		return
+	}
+
 	if q.im2qim {
 		// Setting the pixel color bytes in the back-buffer is >5x the speed of img.Set()
 		s := q.tempImage.PixOffset(x, y)
@@ -208,40 +517,142 @@ func (q *Qt) setPixel(x, y int, r, g, b, a uint8) {
 	}
 }
 
-// StartIm2Qim enables im2qim mode for drawing on the Canvas (Canvas -> file -> standard library image)
-func (q *Qt) StartIm2Qim(blank bool) {
-	if blank {
-		q.tempImage = image.NewNRGBA(image.Rect(0, 0, q.EnvironmentSize, q.EnvironmentSize))
+// blendPixel blends the color provided by r,g,b,a, with its alpha scaled by coverage (0 to 1), over the current color
+// of a single pixel (the "over" operator, on the non-premultiplied colors). In im2qim mode the current color is read
+// from the back-buffer, so it is the color most recently set by setPixel (or blendPixel), or the background color.
+func (q *Qt) blendPixel(x, y int, r, g, b, a uint8, coverage float64) {
+	if x < 0 || y < 0 || x >= q.canvasWidth || y >= q.canvasHeight {
+		return
+	}
+
+	var dr, dg, db, da uint8
+	if q.im2qim {
+		s := q.tempImage.PixOffset(x, y)
+		if s < 0 || s >= len(q.tempImage.Pix) {
+			return
+		}
+		dr, dg, db, da = q.tempImage.Pix[s], q.tempImage.Pix[s+1], q.tempImage.Pix[s+2], q.tempImage.Pix[s+3]
 	} else {
-		// Write Canvas (a QImage) out to file and read it back to tempImage (an image.Image). Because I can't
-		// figure out how to convert between the two using byte arrays etc.
-		q.Canvas.Save("./tmp.png", "PNG", 100)
-		reader, _ := os.Open("./tmp.png")
-		p, _, _ := image.Decode(reader)
-		q.tempImage, _ = p.(*image.NRGBA)
-		reader.Close()
-		os.Remove("./tmp.png")
+		// Pixel returns a QRgb (0xAARRGGBB, not premultiplied)
+		c := q.Canvas.Pixel2(x, y)
+		dr, dg, db, da = uint8(c>>16), uint8(c>>8), uint8(c), uint8(c>>24)
+	}
+
+	sa := float64(a) / 255 * coverage
+	bda := float64(da) / 255 * (1 - sa)
+	oa := sa + bda
+	if oa <= 0 {
+		return
+	}
+	blend := func(sc, dc uint8) uint8 {
+		return uint8(math.Round((float64(sc)*sa + float64(dc)*bda) / oa))
+	}
+	q.putPixel(x, y, blend(r, dr), blend(g, dg), blend(b, db), uint8(math.Round(oa*255)))
+}
+
+// StartIm2Qim enables im2qim mode for drawing on the Canvas (Canvas -> standard library image). If blank, drawing starts
+// from an image filled with the background color (transparent by default), otherwise the current contents of the
+// Canvas are copied.
+func (q *Qt) StartIm2Qim(blank bool) {
+	q.tempImage = image.NewNRGBA(image.Rect(0, 0, q.canvasWidth, q.canvasHeight))
+	if blank && q.backgroundColor.A > 0 {
+		bg := q.backgroundColor
+		for s := 0; s < len(q.tempImage.Pix); s += 4 {
+			q.tempImage.Pix[s], q.tempImage.Pix[s+1], q.tempImage.Pix[s+2], q.tempImage.Pix[s+3] = bg.R, bg.G, bg.B, bg.A
+		}
+	} else if !blank {
+		// The QImage Bits / ConstBits bindings return the pixel data as a C string (so it is cut off at the first zero
+		// byte), so the pixels are read individually. Pixel returns a QRgb (0xAARRGGBB, not premultiplied).
+		for y := 0; y < q.canvasHeight; y++ {
+			for x := 0; x < q.canvasWidth; x++ {
+				c := q.Canvas.Pixel2(x, y)
+				s := q.tempImage.PixOffset(x, y)
+				q.tempImage.Pix[s], q.tempImage.Pix[s+1], q.tempImage.Pix[s+2], q.tempImage.Pix[s+3] =
+					uint8(c>>16), uint8(c>>8), uint8(c), uint8(c>>24)
+			}
+		}
 	}
 
 	q.im2qim = true
 }
 
-// StopIm2Qim disables im2qim mode for drawing on the Canvas (standard library image -> file -> canvas)
+// StopIm2Qim disables im2qim mode for drawing on the Canvas (standard library image -> canvas)
 func (q *Qt) StopIm2Qim() {
 	q.im2qim = false
 
-	// Write tempImage (an image.Image) out to a file and read it back to Canvas (a QImage).
-	out, _ := os.Create("./tmp.png")
-	png.Encode(out, q.tempImage)
-	q.Canvas.Load("./tmp.png", "")
-	out.Close()
-	os.Remove("./tmp.png")
+	// QImage can't be constructed directly from a Go buffer (the data isn't copied, and the binding frees its copy of
+	// it as soon as the constructor returns), so tempImage is loaded as an (uncompressed, in-memory) bitmap instead.
+	bmp := nrgbaToBMP(q.tempImage)
+	q.Canvas.LoadFromData(bmp, len(bmp), "BMP")
 
-	// For future efforts, something like the below seems like it should be close, but it doesn't work.
-	/*var buf bytes.Buffer
-	w := io.Writer(&buf)
-	png.Encode(w, tempImage)
-	Canvas.LoadFromData(buf.Bytes(), buf.Len(), "")*/
+	q.imgLock.Lock()
+	q.frame = q.tempImage
+	q.imgLock.Unlock()
 
 	q.Pixmap.SetPixmap(gui.NewQPixmap().FromImage(q.Canvas, 0))
+
+	q.recorderLock.Lock()
+	defer q.recorderLock.Unlock()
+	if q.recorder != nil {
+		if err := q.recorder.capture(q.tempImage); err != nil {
+			log.Warnln("Unable to record frame: " + err.Error())
+		}
+	}
+}
+
+// Snapshot implements guis.GUIEnabler.Snapshot. The frame is the particles as drawn in im2qim mode (like recordings,
+// it doesn't include particle IDs, which are drawn directly on the Canvas). If nothing has been drawn yet, a blank
+// (transparent) image the size of the environment is returned.
+// It is safe to call from any goroutine, including while the physics loop is drawing the particles (see imgLock).
+func (q *Qt) Snapshot() image.Image {
+	q.imgLock.Lock()
+	defer q.imgLock.Unlock()
+	if q.frame == nil {
+		return image.NewNRGBA(image.Rect(0, 0, q.canvasWidth, q.canvasHeight))
+	}
+	img := image.NewNRGBA(q.frame.Rect)
+	copy(img.Pix, q.frame.Pix)
+	return img
+}
+
+// nrgbaToBMP encodes img as an uncompressed, top-down, 32 bits per pixel BMP (with a BITMAPV4HEADER, so the alpha
+// channel is included). The pixels are stored as little-endian 0xAARRGGBB values (B, G, R, A byte order - the same
+// layout as a QImage__Format_ARGB32 image), so the NRGBA (R, G, B, A byte order) pixels just have their red and blue
+// bytes swapped.
+func nrgbaToBMP(img *image.NRGBA) []byte {
+	const fileHeaderSize, infoHeaderSize = 14, 108
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	offset := fileHeaderSize + infoHeaderSize
+	buf := make([]byte, offset+w*h*4)
+
+	// BITMAPFILEHEADER
+	buf[0], buf[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(buf[2:], uint32(len(buf)))
+	binary.LittleEndian.PutUint32(buf[10:], uint32(offset))
+
+	// BITMAPV4HEADER. The height is negative, indicating the rows are stored top-down (as in img).
+	i := buf[fileHeaderSize:]
+	binary.LittleEndian.PutUint32(i[0:], infoHeaderSize)
+	binary.LittleEndian.PutUint32(i[4:], uint32(int32(w)))
+	binary.LittleEndian.PutUint32(i[8:], uint32(int32(-h)))
+	binary.LittleEndian.PutUint16(i[12:], 1)  // Planes
+	binary.LittleEndian.PutUint16(i[14:], 32) // Bits per pixel
+	binary.LittleEndian.PutUint32(i[16:], 3)  // BI_BITFIELDS compression (uncompressed, with the masks below)
+	binary.LittleEndian.PutUint32(i[20:], uint32(w*h*4))
+	binary.LittleEndian.PutUint32(i[40:], 0x00FF0000) // Red mask
+	binary.LittleEndian.PutUint32(i[44:], 0x0000FF00) // Green mask
+	binary.LittleEndian.PutUint32(i[48:], 0x000000FF) // Blue mask
+	binary.LittleEndian.PutUint32(i[52:], 0xFF000000) // Alpha mask
+	binary.LittleEndian.PutUint32(i[56:], 0x73524742) // LCS_sRGB color space
+
+	px := buf[offset:]
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+w*4]
+		out := px[y*w*4 : (y+1)*w*4]
+		for x := 0; x < len(row); x += 4 {
+			out[x], out[x+1], out[x+2], out[x+3] = row[x+2], row[x+1], row[x], row[x+3]
+		}
+	}
+
+	return buf
 }
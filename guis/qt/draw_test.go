@@ -0,0 +1,184 @@
+package qt
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// newTestQt creates a Qt drawing (in im2qim mode) on a blank width x height back-buffer, without a Qt display.
+func newTestQt(width, height int) *Qt {
+	q := &Qt{RenderScale: 1, canvasWidth: width, canvasHeight: height, im2qim: true}
+	q.tempImage = image.NewNRGBA(image.Rect(0, 0, width, height))
+	return q
+}
+
+// drawnPixels gets the coordinates of the pixels of q's back-buffer which have been drawn on (aren't transparent).
+func drawnPixels(q *Qt) map[[2]int]bool {
+	drawn := make(map[[2]int]bool)
+	b := q.tempImage.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if q.tempImage.NRGBAAt(x, y).A != 0 {
+				drawn[[2]int{x, y}] = true
+			}
+		}
+	}
+	return drawn
+}
+
+// TestDrawLine checks the pixels drawn by Bresenham's line algorithm, in either direction.
+func TestDrawLine(t *testing.T) {
+	tests := []struct {
+		name           string
+		x0, y0, x1, y1 int
+		want           [][2]int
+	}{
+		{"single pixel", 2, 3, 2, 3, [][2]int{{2, 3}}},
+		{"horizontal", 0, 1, 3, 1, [][2]int{{0, 1}, {1, 1}, {2, 1}, {3, 1}}},
+		{"diagonal", 0, 0, 4, 4, [][2]int{{0, 0}, {1, 1}, {2, 2}, {3, 3}, {4, 4}}},
+		{"anti-diagonal", 4, 0, 0, 4, [][2]int{{4, 0}, {3, 1}, {2, 2}, {1, 3}, {0, 4}}},
+		{"shallow", 0, 0, 3, 1, [][2]int{{0, 0}, {1, 0}, {2, 1}, {3, 1}}},
+		{"steep", 1, 0, 2, 3, [][2]int{{1, 0}, {1, 1}, {2, 2}, {2, 3}}},
+		{"steep reversed", 2, 3, 1, 0, [][2]int{{2, 3}, {2, 2}, {1, 1}, {1, 0}}},
+		{"clipped", 3, 3, 6, 6, [][2]int{{3, 3}, {4, 4}}},
+	}
+	for _, test := range tests {
+		q := newTestQt(5, 5)
+		q.drawLine(test.x0, test.y0, test.x1, test.y1, 255, 255, 255, 255)
+		drawn := drawnPixels(q)
+		for _, p := range test.want {
+			if !drawn[p] {
+				t.Errorf("%s: pixel %v not drawn", test.name, p)
+			}
+			delete(drawn, p)
+		}
+		for p := range drawn {
+			t.Errorf("%s: unexpected pixel %v drawn", test.name, p)
+		}
+	}
+}
+
+// TestDrawViewBox checks that the view box is drawn on the edges of the Canvas, whatever the RenderScale.
+func TestDrawViewBox(t *testing.T) {
+	for _, scale := range []float64{1, 0.5} {
+		q := newTestQt(4, 3)
+		q.RenderScale = scale
+		q.wallColor.A = 255
+		q.DrawViewBox()
+		drawn := drawnPixels(q)
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				if edge := x == 0 || y == 0 || x == 3 || y == 2; drawn[[2]int{x, y}] != edge {
+					t.Errorf("scale %g: pixel (%d, %d) drawn %v, want %v", scale, x, y, !edge, edge)
+				}
+			}
+		}
+	}
+}
+
+// TestSnapshot checks that Snapshot gets a copy of the most recently drawn frame, or a blank image the size of the
+// Canvas if nothing has been drawn.
+func TestSnapshot(t *testing.T) {
+	tests := []struct {
+		name  string
+		drawn bool
+	}{
+		{"blank", false},
+		{"drawn", true},
+	}
+	for _, test := range tests {
+		q := newTestQt(4, 3)
+		if test.drawn {
+			q.drawLine(0, 0, 3, 2, 10, 20, 30, 255)
+			q.frame = q.tempImage
+		}
+		img, ok := q.Snapshot().(*image.NRGBA)
+		if !ok || img.Rect != image.Rect(0, 0, 4, 3) {
+			t.Errorf("%s: got %v, want a 4x3 NRGBA image", test.name, img)
+			continue
+		}
+		want := make(map[[2]int]bool)
+		if test.drawn {
+			want = drawnPixels(q)
+		}
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				if drawn := img.NRGBAAt(x, y).A != 0; drawn != want[[2]int{x, y}] {
+					t.Errorf("%s: pixel (%d, %d) drawn %v, want %v", test.name, x, y, drawn, !drawn)
+				}
+			}
+		}
+		// The snapshot is a copy, so changing it doesn't change the frame
+		img.Pix[3] = 0
+		if test.drawn && q.frame.Pix[3] == 0 {
+			t.Errorf("%s: changing the snapshot changed the frame", test.name)
+		}
+	}
+}
+
+// bmpToNRGBA decodes a BMP encoded by nrgbaToBMP (top-down, 32 bits per pixel, in B, G, R, A byte order).
+func bmpToNRGBA(bmp []byte) *image.NRGBA {
+	offset := binary.LittleEndian.Uint32(bmp[10:])
+	w := int(int32(binary.LittleEndian.Uint32(bmp[18:])))
+	h := -int(int32(binary.LittleEndian.Uint32(bmp[22:])))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	px := bmp[offset:]
+	for i := 0; i < w*h*4; i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = px[i+2], px[i+1], px[i], px[i+3]
+	}
+	return img
+}
+
+// TestNRGBAToBMP checks the header and the pixel layout (top-down rows, B, G, R, A bytes) of BMPs encoded by
+// nrgbaToBMP, and that the pixels decode back unchanged, including those of sub-images (whose rows are longer than
+// the image is wide).
+func TestNRGBAToBMP(t *testing.T) {
+	full := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			full.SetNRGBA(x, y, color.NRGBA{R: uint8(10*x + 1), G: uint8(10*y + 2), B: uint8(x + y + 3),
+				A: uint8(50*x + y)})
+		}
+	}
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{"image", full},
+		{"sub-image", full.SubImage(image.Rect(1, 1, 3, 3)).(*image.NRGBA)},
+	}
+	for _, test := range tests {
+		w, h := test.img.Rect.Dx(), test.img.Rect.Dy()
+		bmp := nrgbaToBMP(test.img)
+		const offset = 14 + 108
+		if len(bmp) != offset+w*h*4 || bmp[0] != 'B' || bmp[1] != 'M' ||
+			binary.LittleEndian.Uint32(bmp[2:]) != uint32(len(bmp)) ||
+			binary.LittleEndian.Uint32(bmp[10:]) != offset ||
+			binary.LittleEndian.Uint32(bmp[14:]) != 108 ||
+			int32(binary.LittleEndian.Uint32(bmp[18:])) != int32(w) ||
+			int32(binary.LittleEndian.Uint32(bmp[22:])) != int32(-h) ||
+			binary.LittleEndian.Uint16(bmp[28:]) != 32 {
+			t.Errorf("%s: got header % x", test.name, bmp[:offset])
+			continue
+		}
+		// The first pixel stored is the top left one, and the second the one to its right
+		tl, next := test.img.NRGBAAt(test.img.Rect.Min.X, test.img.Rect.Min.Y),
+			test.img.NRGBAAt(test.img.Rect.Min.X+1, test.img.Rect.Min.Y)
+		if want := []byte{tl.B, tl.G, tl.R, tl.A, next.B, next.G, next.R, next.A}; string(bmp[offset:offset+8]) !=
+			string(want) {
+			t.Errorf("%s: got first pixels % x, want % x", test.name, bmp[offset:offset+8], want)
+		}
+
+		got := bmpToNRGBA(bmp)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				want := test.img.NRGBAAt(test.img.Rect.Min.X+x, test.img.Rect.Min.Y+y)
+				if c := got.NRGBAAt(x, y); c != want {
+					t.Errorf("%s: pixel (%d, %d) got %v, want %v", test.name, x, y, c, want)
+				}
+			}
+		}
+	}
+}
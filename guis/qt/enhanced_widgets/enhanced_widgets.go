@@ -67,6 +67,13 @@ type ESlider struct {
 
 	// Scale is the scale factor applied to convert the slider value (which must be an integer) to the user/engine scale
 	Scale float64
+	// Deferred indicates whether, while the user is dragging the slider, the value changed event handlers are held off
+	// until the slider is released (and then called once, with the final value). Changes made by other means (the
+	// keyboard, clicking the groove, SetValue, etc.) always call the handlers immediately.
+	Deferred bool
+	// pressValue is the value of the slider when the user last pressed it, used by Deferred sliders to determine
+	// whether the value changed during the drag.
+	pressValue int
 
 	// valueChangedEventHandlers is a slice of functions to be called when the slider value is changed. Appended to
 	// using ConnectValueChangedEvent
@@ -143,7 +150,27 @@ func (w *ESlider) triggerValueChangedEvent(value int) {
 		w.ValueLabel.SetText(fmt.Sprintf("%.2f", float64(value)*w.Scale))
 	}
 
-	// Call all the subscribed event handlers
+	if w.Deferred && w.Slider().IsSliderDown() {
+		return
+	}
+	w.callValueChangedEventHandlers(value)
+}
+
+// triggerSliderPressedEvent is the method connected to the MainWidget (Qt library) slider pressed event.
+func (w *ESlider) triggerSliderPressedEvent() {
+	w.pressValue = w.GetValue()
+}
+
+// triggerSliderReleasedEvent is the method connected to the MainWidget (Qt library) slider released event. If the
+// slider is Deferred, it calls the value changed event handlers held off during the drag.
+func (w *ESlider) triggerSliderReleasedEvent() {
+	if w.Deferred && w.GetValue() != w.pressValue {
+		w.callValueChangedEventHandlers(w.GetValue())
+	}
+}
+
+// callValueChangedEventHandlers calls all the subscribed value changed event handlers.
+func (w *ESlider) callValueChangedEventHandlers(value int) {
 	for _, handler := range w.valueChangedEventHandlers {
 		handler(value)
 	}
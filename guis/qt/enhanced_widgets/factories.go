@@ -38,6 +38,8 @@ func NewESlider(min, max, interval, value int, scale float64) *ESlider {
 	w.Scale = scale
 
 	tmpSlider.ConnectValueChanged(w.triggerValueChangedEvent)
+	tmpSlider.ConnectSliderPressed(w.triggerSliderPressedEvent)
+	tmpSlider.ConnectSliderReleased(w.triggerSliderReleasedEvent)
 	// Add the slider to the layout and set it as the ESlider MainWidget
 	pLayout.AddWidget3(tmpSlider, 0, 0, 1, 2, 0)
 	w.MainWidget = tmpSlider
@@ -1,7 +1,9 @@
 package qt
 
 import (
+	"math"
 	"os"
+	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -10,6 +12,19 @@ import (
 	"github.com/therecipe/qt/widgets"
 
 	eWidgets "GoGoGadgetGravity/guis/qt/enhanced_widgets"
+	"GoGoGadgetGravity/physics"
+	"GoGoGadgetGravity/state"
+)
+
+const (
+	// zoomFactorPerNotch is the factor by which the View is scaled for each notch the mouse wheel is scrolled.
+	zoomFactorPerNotch = 1.15
+	// clickDragThreshold is the distance (in screen pixels) the mouse may move while the left button is pressed and
+	// still be considered a click rather than a drag (pan).
+	clickDragThreshold = 4
+	// fitParticlesMargin is the margin (in environment units) left around the particles' bounding box when the View is
+	// fit to the particles (see fitView).
+	fitParticlesMargin = 20
 )
 
 // EventSystemData holds the main app event handlers which are passed to the GUI using the Connect*Event methods,
@@ -19,12 +34,30 @@ type EventSystemData struct {
 	saveStateEventHandler func(value string)
 	// See Qt.ConnectLoadStateEvent
 	loadStateEventHandler func(value string)
-	// See Qt.ConnectEnvironmentSizeChangedEvent
-	environmentSizeChangedEventHandler func(value int)
+	// See Qt.ConnectSaveSettingsEvent
+	saveSettingsEventHandler func(file string)
+	// See Qt.ConnectLoadSettingsEvent
+	loadSettingsEventHandler func(file string)
+	// See Qt.ConnectResetSettingsEvent
+	resetSettingsEventHandler func()
+	// See Qt.ConnectLoadPresetEvent
+	loadPresetEventHandler func(name string)
+	// See Qt.ConnectEnvironmentWidthChangedEvent
+	environmentWidthChangedEventHandler func(value int)
+	// See Qt.ConnectEnvironmentHeightChangedEvent
+	environmentHeightChangedEventHandler func(value int)
 	// See Qt.ConnectNumParticlesChangedEvent
 	numParticlesChangedEventHandler func(value int)
 	// See Qt.ConnectAverageMassChangedEvent
 	averageMassChangedEventHandler func(value int)
+	// See Qt.ConnectInitialVelocityModeChangedEvent
+	initialVelocityModeChangedEventHandler func(value int)
+	// See Qt.ConnectInitialSpeedChangedEvent
+	initialSpeedChangedEventHandler func(value float64)
+	// See Qt.ConnectGenerationRegionChangedEvent
+	generationRegionChangedEventHandler func(x, y, radius float64)
+	// See Qt.ConnectChargeDistributionChangedEvent
+	chargeDistributionChangedEventHandler func(value int)
 	// See Qt.ConnectRegenParticlesEvent
 	regenParticlesEventHandler func()
 	// See Qt.ConnectGravityStrengthChangedEvent
@@ -33,20 +66,138 @@ type EventSystemData struct {
 	closeChargeStrengthChangedEventHandler func(value float64)
 	// See Qt.ConnectFarChargeStrengthChangedEvent
 	farChargeStrengthChangedEventHandler func(value float64)
+	// See Qt.ConnectFarChargeExponentChangedEvent
+	farChargeExponentChangedEventHandler func(value float64)
+	// See Qt.ConnectFarChargeCutoffChangedEvent
+	farChargeCutoffChangedEventHandler func(value float64)
+	// See Qt.ConnectGravityOnlyChangedEvent
+	gravityOnlyChangedEventHandler func(enabled bool)
+	// See Qt.ConnectEnableGravityChangedEvent
+	enableGravityChangedEventHandler func(enabled bool)
+	// See Qt.ConnectEnableCloseChargeChangedEvent
+	enableCloseChargeChangedEventHandler func(enabled bool)
+	// See Qt.ConnectSpeciesMatrixChangedEvent
+	speciesMatrixChangedEventHandler func(matrix [][]float64)
+	// See Qt.ConnectEnableFarChargeChangedEvent
+	enableFarChargeChangedEventHandler func(enabled bool)
 	// See Qt.ConnectAllowMergeChangedEvent
 	allowMergeChangedEventHandler func(enabled bool)
+	// See Qt.ConnectMergeMassRatioThresholdChangedEvent
+	mergeMassRatioThresholdChangedEventHandler func(value float64)
+	// See Qt.ConnectMergeCloseChargeThresholdChangedEvent
+	mergeCloseChargeThresholdChangedEventHandler func(value float64)
+	// See Qt.ConnectCaptureSpeedChangedEvent
+	captureSpeedChangedEventHandler func(value float64)
+	// See Qt.ConnectChargeMergeRuleChangedEvent
+	chargeMergeRuleChangedEventHandler func(value int)
+	// See Qt.ConnectAllowFissionChangedEvent
+	allowFissionChangedEventHandler func(enabled bool)
+	// See Qt.ConnectMaxMassChangedEvent
+	maxMassChangedEventHandler func(value float64)
+	// See Qt.ConnectMaxParticlesChangedEvent
+	maxParticlesChangedEventHandler func(value int)
+	// See Qt.ConnectBounceCompleteDistFactorChangedEvent
+	bounceCompleteDistFactorChangedEventHandler func(value float64)
+	// See Qt.ConnectRestitutionChangedEvent
+	restitutionChangedEventHandler func(value float64)
+	// See Qt.ConnectDragCoefficientChangedEvent
+	dragCoefficientChangedEventHandler func(value float64)
+	// See Qt.ConnectMaxAccelerationChangedEvent
+	maxAccelerationChangedEventHandler func(value float64)
+	// See Qt.ConnectQuadraticDragChangedEvent
+	quadraticDragChangedEventHandler func(enabled bool)
+	// See Qt.ConnectExternalFieldChangedEvent
+	externalFieldChangedEventHandler func(strength, angle float64)
+	// See Qt.ConnectFrameAngularVelocityChangedEvent
+	frameAngularVelocityChangedEventHandler func(value float64)
 	// See Qt.ConnectWallBounceChangedEvent
 	wallBounceChangedEventHandler func(enabled bool)
+	// See Qt.ConnectWrapBoundaryChangedEvent
+	wrapBoundaryChangedEventHandler func(enabled bool)
 	// See Qt.ConnectHistoryTrailChangedEvent
 	historyTrailChangedEventHandler func(enabled bool)
 	// See Qt.ConnectHistoryTrailLengthChangedEvent
 	historyTrailLengthChangedEventHandler func(value int)
+	// See Qt.ConnectHistoryTrailStrideChangedEvent
+	historyTrailStrideChangedEventHandler func(value int)
+	// See Qt.ConnectColorSchemeChangedEvent
+	colorSchemeChangedEventHandler func(value int)
+	// See Qt.ConnectTrailStyleChangedEvent
+	trailStyleChangedEventHandler func(value int)
+	// See Qt.ConnectTrailColoringChangedEvent
+	trailColoringChangedEventHandler func(value int)
+	// See Qt.ConnectHistoryPrefillChangedEvent
+	historyPrefillChangedEventHandler func(value int)
+	// See Qt.ConnectDrawRadiusScaleChangedEvent
+	drawRadiusScaleChangedEventHandler func(value float64)
+	// See Qt.ConnectMinDrawRadiusChangedEvent
+	minDrawRadiusChangedEventHandler func(value int)
+	// See Qt.ConnectShowVelocityVectorsEvent
+	showVelocityVectorsEventHandler func(enabled bool)
+	// See Qt.ConnectShowParticleIDsEvent
+	showParticleIDsEventHandler func(enabled bool)
+	// See Qt.ConnectDensityHeatmapEvent
+	densityHeatmapEventHandler func(enabled bool)
+	// See Qt.ConnectCenterOfMassFrameEvent
+	centerOfMassFrameEventHandler func(enabled bool)
+	// See Qt.ConnectSmoothRenderingEvent
+	smoothRenderingEventHandler func(enabled bool)
+	// See Qt.ConnectBackgroundColorChangedEvent
+	backgroundColorChangedEventHandler func(color state.Color)
+	// See Qt.ConnectWallColorChangedEvent
+	wallColorChangedEventHandler func(color state.Color)
 	// See Qt.ConnectPhysicsLoopSpeedChangedEvent
 	physicsLoopSpeedChangedEventHandler func(value int)
+	// See Qt.ConnectStepsPerFrameChangedEvent
+	stepsPerFrameChangedEventHandler func(value int)
+	// See Qt.ConnectAutoSlowdownChangedEvent
+	autoSlowdownChangedEventHandler func(enabled bool)
 	// See Qt.ConnectResetEnvironmentEvent
 	resetEnvironmentEventHandler func()
+	// See Qt.ConnectZeroVelocitiesEvent
+	zeroVelocitiesEventHandler func()
 	// See Qt.ConnectPauseResumeEvent
 	pauseResumeEventHandler func() (paused bool)
+	// See Qt.ConnectStepOnceEvent
+	stepOnceEventHandler func()
+	// See Qt.ConnectPauseOnMergeChangedEvent
+	pauseOnMergeChangedEventHandler func(enabled bool)
+	// See Qt.ConnectPauseOnSpeedChangedEvent
+	pauseOnSpeedChangedEventHandler func(enabled bool)
+	// See Qt.ConnectPauseSpeedThresholdChangedEvent
+	pauseSpeedThresholdChangedEventHandler func(value float64)
+	// See Qt.ConnectUndoEvent
+	undoEventHandler func()
+	// See Qt.ConnectRedoEvent
+	redoEventHandler func()
+	// See Qt.ConnectToggleFixedEvent
+	toggleFixedEventHandler func(x, y float64)
+	// See Qt.ConnectParticleClickedEvent
+	particleClickedEventHandler func(x, y float64)
+	// See Qt.ConnectGrabParticleEvent
+	grabParticleEventHandler func(x, y float64, addToSelection bool) (grabbed bool)
+	// See Qt.ConnectDragParticlesEvent
+	dragParticlesEventHandler func(dx, dy float64)
+	// See Qt.ConnectDropParticlesEvent
+	dropParticlesEventHandler func()
+	// See Qt.ConnectAttractorEvent
+	attractorEventHandler func(x, y float64, repel bool)
+	// See Qt.ConnectReleaseAttractorEvent
+	releaseAttractorEventHandler func()
+	// See Qt.ConnectStartRecordingEvent
+	startRecordingEventHandler func(dir string)
+	// See Qt.ConnectStopRecordingEvent
+	stopRecordingEventHandler func()
+	// See Qt.ConnectStartTrajectoryEvent
+	startTrajectoryEventHandler func(file string) (started bool)
+	// See Qt.ConnectStopTrajectoryEvent
+	stopTrajectoryEventHandler func()
+	// See Qt.ConnectExportGenealogyEvent
+	exportGenealogyEventHandler func(file string)
+	// See Qt.ConnectExportSVGEvent
+	exportSVGEventHandler func(file string)
+	// See Qt.ConnectShutdownEvent
+	shutdownEventHandler func()
 }
 
 // SaveButtonClickEvent is triggered when the user clicks the SaveStateButton. It presents a file picker and passes the
@@ -57,11 +208,11 @@ func (q *Qt) SaveButtonClickEvent(checked bool) {
 	if err != nil {
 		log.Warnln("Unable to get current directory: " + err.Error())
 	}
-	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.json")
+	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.json *.json.gz")
 	dlg.SetAcceptMode(widgets.QFileDialog__AcceptSave)
 	// Anonymous function called on selection of valid file / clicking Save
 	dlg.ConnectFileSelected(func(file string) {
-		if !strings.HasSuffix(file, ".json") {
+		if !strings.HasSuffix(file, ".json") && !strings.HasSuffix(file, ".json"+state.GzipExtension) {
 			file += ".json"
 		}
 		// Tell the main app the selected file
@@ -84,7 +235,7 @@ func (q *Qt) LoadButtonClickEvent(checked bool) {
 	if err != nil {
 		log.Warnln("Unable to get current directory: " + err.Error())
 	}
-	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.json")
+	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.json *.json.gz")
 	dlg.SetAcceptMode(widgets.QFileDialog__AcceptOpen)
 	// Anonymous function called on selection of valid file / clicking Open
 	dlg.ConnectFileSelected(func(file string) {
@@ -100,18 +251,111 @@ func (q *Qt) ConnectLoadStateEvent(f func(file string)) {
 	q.EventSystem.loadStateEventHandler = f
 }
 
-// EnvironmentSizeSliderChangedEvent is triggered when the user changes the value of the Environment Size slider and
+// SaveSettingsButtonClickEvent is triggered when the user clicks the SaveSettingsButton. It presents a file picker and
+// passes the selected file back to the main app using the provided event handler.
+func (q *Qt) SaveSettingsButtonClickEvent(checked bool) {
+	path, err := os.Getwd()
+	// Path will be ""
+	if err != nil {
+		log.Warnln("Unable to get current directory: " + err.Error())
+	}
+	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.json *.json.gz")
+	dlg.SetAcceptMode(widgets.QFileDialog__AcceptSave)
+	// Anonymous function called on selection of valid file / clicking Save
+	dlg.ConnectFileSelected(func(file string) {
+		if !strings.HasSuffix(file, ".json") && !strings.HasSuffix(file, ".json"+state.GzipExtension) {
+			file += ".json"
+		}
+		// Tell the main app the selected file
+		q.EventSystem.saveSettingsEventHandler(file)
+	})
+	// Show the dialog (waits for save / cancel)
+	dlg.Show()
+}
+
+// ConnectSaveSettingsEvent implements guis.GUIEnabler.ConnectSaveSettingsEvent
+func (q *Qt) ConnectSaveSettingsEvent(f func(file string)) {
+	q.EventSystem.saveSettingsEventHandler = f
+}
+
+// LoadSettingsButtonClickEvent is triggered when the user clicks the LoadSettingsButton. It presents a file picker and
+// passes the selected file back to the main app using the provided event handler.
+func (q *Qt) LoadSettingsButtonClickEvent(checked bool) {
+	path, err := os.Getwd()
+	// Path will be ""
+	if err != nil {
+		log.Warnln("Unable to get current directory: " + err.Error())
+	}
+	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.json *.json.gz")
+	dlg.SetAcceptMode(widgets.QFileDialog__AcceptOpen)
+	// Anonymous function called on selection of valid file / clicking Open
+	dlg.ConnectFileSelected(func(file string) {
+		// Tell the main app the selected file
+		q.EventSystem.loadSettingsEventHandler(file)
+	})
+	// Show the dialog (waits for open / cancel)
+	dlg.Show()
+}
+
+// ConnectLoadSettingsEvent implements guis.GUIEnabler.ConnectLoadSettingsEvent
+func (q *Qt) ConnectLoadSettingsEvent(f func(file string)) {
+	q.EventSystem.loadSettingsEventHandler = f
+}
+
+// ResetSettingsButtonClickEvent is triggered when the user clicks the ResetSettingsButton. It informs the main app of
+// this request by calling the provided event handler.
+func (q *Qt) ResetSettingsButtonClickEvent(checked bool) {
+	q.EventSystem.resetSettingsEventHandler()
+}
+
+// ConnectResetSettingsEvent implements guis.GUIEnabler.ConnectResetSettingsEvent
+func (q *Qt) ConnectResetSettingsEvent(f func()) {
+	q.EventSystem.resetSettingsEventHandler = f
+}
+
+// PresetComboActivatedEvent is triggered when the user selects an item in the PresetCombo. If the item is a preset
+// (rather than the prompt), it passes the preset name back to the main app using the provided event handler. The combo
+// box then returns to the prompt, so the same preset may be selected (reloaded) again.
+func (q *Qt) PresetComboActivatedEvent(index int) {
+	if index == 0 {
+		return
+	}
+	name := q.PresetCombo.ItemText(index)
+	q.PresetCombo.SetCurrentIndex(0)
+	q.EventSystem.loadPresetEventHandler(name)
+}
+
+// ConnectLoadPresetEvent implements guis.GUIEnabler.ConnectLoadPresetEvent
+func (q *Qt) ConnectLoadPresetEvent(f func(name string)) {
+	q.EventSystem.loadPresetEventHandler = f
+}
+
+// EnvironmentWidthSliderChangedEvent is triggered when the user changes the value of the Environment Width slider and
 // passes that value back to the main app using the provided event handler.
-func (q *Qt) EnvironmentSizeSliderChangedEvent(value int) {
-	q.EnvironmentSize = value
+func (q *Qt) EnvironmentWidthSliderChangedEvent(value int) {
+	q.EnvironmentWidth = value
 	if !q.loadingState {
-		q.EventSystem.environmentSizeChangedEventHandler(value)
+		q.EventSystem.environmentWidthChangedEventHandler(value)
 	} // We know this isn't scaled
 }
 
-// ConnectEnvironmentSizeChangedEvent implements guis.GUIEnabler.ConnectEnvironmentSizeChangedEvent
-func (q *Qt) ConnectEnvironmentSizeChangedEvent(f func(value int)) {
-	q.EventSystem.environmentSizeChangedEventHandler = f
+// ConnectEnvironmentWidthChangedEvent implements guis.GUIEnabler.ConnectEnvironmentWidthChangedEvent
+func (q *Qt) ConnectEnvironmentWidthChangedEvent(f func(value int)) {
+	q.EventSystem.environmentWidthChangedEventHandler = f
+}
+
+// EnvironmentHeightSliderChangedEvent is triggered when the user changes the value of the Environment Height slider
+// and passes that value back to the main app using the provided event handler.
+func (q *Qt) EnvironmentHeightSliderChangedEvent(value int) {
+	q.EnvironmentHeight = value
+	if !q.loadingState {
+		q.EventSystem.environmentHeightChangedEventHandler(value)
+	} // We know this isn't scaled
+}
+
+// ConnectEnvironmentHeightChangedEvent implements guis.GUIEnabler.ConnectEnvironmentHeightChangedEvent
+func (q *Qt) ConnectEnvironmentHeightChangedEvent(f func(value int)) {
+	q.EventSystem.environmentHeightChangedEventHandler = f
 }
 
 // NumParticlesSliderChangedEvent is triggered when the user changes the value of the Number of Particles slider and
@@ -140,6 +384,64 @@ func (q *Qt) ConnectAverageMassChangedEvent(f func(value int)) {
 	q.EventSystem.averageMassChangedEventHandler = f
 }
 
+// InitialVelocityModeComboChangedEvent is triggered when the user selects an initial velocity mode in the
+// InitialVelocityModeCombo and passes its index (the state.InitialVelocityMode) back to the main app using the provided
+// event handler.
+func (q *Qt) InitialVelocityModeComboChangedEvent(index int) {
+	if !q.loadingState {
+		q.EventSystem.initialVelocityModeChangedEventHandler(index)
+	}
+}
+
+// ConnectInitialVelocityModeChangedEvent implements guis.GUIEnabler.ConnectInitialVelocityModeChangedEvent
+func (q *Qt) ConnectInitialVelocityModeChangedEvent(f func(value int)) {
+	q.EventSystem.initialVelocityModeChangedEventHandler = f
+}
+
+// InitialSpeedSliderChangedEvent is triggered when the user changes the value of the Initial Speed slider and passes
+// that value (scaled) back to the main app using the provided event handler.
+func (q *Qt) InitialSpeedSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.initialSpeedChangedEventHandler(float64(value) *
+			q.FormItems["Initial Speed"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectInitialSpeedChangedEvent implements guis.GUIEnabler.ConnectInitialSpeedChangedEvent
+func (q *Qt) ConnectInitialSpeedChangedEvent(f func(value float64)) {
+	q.EventSystem.initialSpeedChangedEventHandler = f
+}
+
+// SpawnRegionSliderChangedEvent is triggered when the user changes the value of the Spawn Center X, Spawn Center Y, or
+// Spawn Radius slider, and passes the values of all three back to the main app using the provided event handler.
+func (q *Qt) SpawnRegionSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.generationRegionChangedEventHandler(
+			q.FormItems["Spawn Center X"].(*eWidgets.ESlider).GetScaledValue(),
+			q.FormItems["Spawn Center Y"].(*eWidgets.ESlider).GetScaledValue(),
+			q.FormItems["Spawn Radius (0 = Everywhere)"].(*eWidgets.ESlider).GetScaledValue())
+	}
+}
+
+// ConnectGenerationRegionChangedEvent implements guis.GUIEnabler.ConnectGenerationRegionChangedEvent
+func (q *Qt) ConnectGenerationRegionChangedEvent(f func(x, y, radius float64)) {
+	q.EventSystem.generationRegionChangedEventHandler = f
+}
+
+// ChargeDistributionComboChangedEvent is triggered when the user selects a charge distribution in the
+// ChargeDistributionCombo and passes its index (the state.ChargeDistribution) back to the main app using the provided
+// event handler.
+func (q *Qt) ChargeDistributionComboChangedEvent(index int) {
+	if !q.loadingState {
+		q.EventSystem.chargeDistributionChangedEventHandler(index)
+	}
+}
+
+// ConnectChargeDistributionChangedEvent implements guis.GUIEnabler.ConnectChargeDistributionChangedEvent
+func (q *Qt) ConnectChargeDistributionChangedEvent(f func(value int)) {
+	q.EventSystem.chargeDistributionChangedEventHandler = f
+}
+
 // RegenButtonClickEvent is triggered when the user clicks the RegenButton. It informs the main app of this request by
 // calling the provided event handler.
 func (q *Qt) RegenButtonClickEvent(checked bool) {
@@ -148,6 +450,14 @@ func (q *Qt) RegenButtonClickEvent(checked bool) {
 	}
 }
 
+// RegenWhileDraggingClickEvent is triggered when the user (un)checks the RegenWhileDraggingCheck. Unless checked, the
+// generation sliders only pass their values back to the main app (so the particles are only regenerated) when released.
+func (q *Qt) RegenWhileDraggingClickEvent(checked bool) {
+	for _, name := range generationSliders {
+		q.FormItems[name].(*eWidgets.ESlider).Deferred = !checked
+	}
+}
+
 // ConnectRegenParticlesEvent implements guis.GUIEnabler.ConnectRegenParticlesEvent
 func (q *Qt) ConnectRegenParticlesEvent(f func()) {
 	q.EventSystem.regenParticlesEventHandler = f
@@ -195,6 +505,100 @@ func (q *Qt) ConnectFarChargeStrengthChangedEvent(f func(value float64)) {
 	q.EventSystem.farChargeStrengthChangedEventHandler = f
 }
 
+// FarChargeExponentSliderChangedEvent is triggered when the user changes the value of the Far Charge Exponent slider
+// and passes that value (scaled from slider to engine units) back to the main app using the provided event handler.
+func (q *Qt) FarChargeExponentSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.farChargeExponentChangedEventHandler(float64(value) *
+			q.FormItems["Far Charge Exponent"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectFarChargeExponentChangedEvent implements guis.GUIEnabler.ConnectFarChargeExponentChangedEvent
+func (q *Qt) ConnectFarChargeExponentChangedEvent(f func(value float64)) {
+	q.EventSystem.farChargeExponentChangedEventHandler = f
+}
+
+// FarChargeCutoffSliderChangedEvent is triggered when the user changes the value of the Far Charge Cutoff slider and
+// passes that value back to the main app using the provided event handler.
+func (q *Qt) FarChargeCutoffSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.farChargeCutoffChangedEventHandler(float64(value) *
+			q.FormItems["Far Charge Cutoff (0 = None)"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectFarChargeCutoffChangedEvent implements guis.GUIEnabler.ConnectFarChargeCutoffChangedEvent
+func (q *Qt) ConnectFarChargeCutoffChangedEvent(f func(value float64)) {
+	q.EventSystem.farChargeCutoffChangedEventHandler = f
+}
+
+// GravityOnlyClickEvent is triggered when the user clicks the GravityOnlyCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) GravityOnlyClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.gravityOnlyChangedEventHandler(checked)
+	}
+}
+
+// ConnectGravityOnlyChangedEvent implements guis.GUIEnabler.ConnectGravityOnlyChangedEvent
+func (q *Qt) ConnectGravityOnlyChangedEvent(f func(enabled bool)) {
+	q.EventSystem.gravityOnlyChangedEventHandler = f
+}
+
+// EnableGravityClickEvent is triggered when the user clicks the EnableGravityCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) EnableGravityClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.enableGravityChangedEventHandler(checked)
+	}
+}
+
+// ConnectEnableGravityChangedEvent implements guis.GUIEnabler.ConnectEnableGravityChangedEvent
+func (q *Qt) ConnectEnableGravityChangedEvent(f func(enabled bool)) {
+	q.EventSystem.enableGravityChangedEventHandler = f
+}
+
+// EnableCloseChargeClickEvent is triggered when the user clicks the EnableCloseChargeCheck. It passes the current
+// checked state back to the main app using the provided handler.
+func (q *Qt) EnableCloseChargeClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.enableCloseChargeChangedEventHandler(checked)
+	}
+}
+
+// ConnectEnableCloseChargeChangedEvent implements guis.GUIEnabler.ConnectEnableCloseChargeChangedEvent
+func (q *Qt) ConnectEnableCloseChargeChangedEvent(f func(enabled bool)) {
+	q.EventSystem.enableCloseChargeChangedEventHandler = f
+}
+
+// SpeciesMatrixButtonClickEvent is triggered when the user clicks the SpeciesMatrixButton. It presents the species
+// matrix editor and passes the edited matrix back to the main app using the provided event handler.
+func (q *Qt) SpeciesMatrixButtonClickEvent(checked bool) {
+	q.editSpeciesMatrix(q.speciesMatrix, func(matrix [][]float64) {
+		q.speciesMatrix = matrix
+		q.EventSystem.speciesMatrixChangedEventHandler(matrix)
+	})
+}
+
+// ConnectSpeciesMatrixChangedEvent implements guis.GUIEnabler.ConnectSpeciesMatrixChangedEvent
+func (q *Qt) ConnectSpeciesMatrixChangedEvent(f func(matrix [][]float64)) {
+	q.EventSystem.speciesMatrixChangedEventHandler = f
+}
+
+// EnableFarChargeClickEvent is triggered when the user clicks the EnableFarChargeCheck. It passes the current checked
+// state back to the main app using the provided handler.
+func (q *Qt) EnableFarChargeClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.enableFarChargeChangedEventHandler(checked)
+	}
+}
+
+// ConnectEnableFarChargeChangedEvent implements guis.GUIEnabler.ConnectEnableFarChargeChangedEvent
+func (q *Qt) ConnectEnableFarChargeChangedEvent(f func(enabled bool)) {
+	q.EventSystem.enableFarChargeChangedEventHandler = f
+}
+
 // AllowMergeClickEvent is triggered when the user clicks the AllowMergeCheck. It passes the current checked state back
 // to the main app using the provided handler.
 func (q *Qt) AllowMergeClickEvent(checked bool) {
@@ -208,9 +612,210 @@ func (q *Qt) ConnectAllowMergeChangedEvent(f func(enabled bool)) {
 	q.EventSystem.allowMergeChangedEventHandler = f
 }
 
+// MergeMassRatioSliderChangedEvent is triggered when the user changes the value of the Merge Mass Ratio slider and
+// passes that value (scaled from slider to engine units) back to the main app using the provided event handler.
+func (q *Qt) MergeMassRatioSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.mergeMassRatioThresholdChangedEventHandler(float64(value) *
+			q.FormItems["Merge Mass Ratio"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectMergeMassRatioThresholdChangedEvent implements guis.GUIEnabler.ConnectMergeMassRatioThresholdChangedEvent
+func (q *Qt) ConnectMergeMassRatioThresholdChangedEvent(f func(value float64)) {
+	q.EventSystem.mergeMassRatioThresholdChangedEventHandler = f
+}
+
+// MergeCloseChargeSliderChangedEvent is triggered when the user changes the value of the Merge Close Charge Limit
+// slider and passes that value (scaled from slider to engine units) back to the main app using the provided event
+// handler.
+func (q *Qt) MergeCloseChargeSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.mergeCloseChargeThresholdChangedEventHandler(float64(value) *
+			q.FormItems["Merge Close Charge Limit"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectMergeCloseChargeThresholdChangedEvent implements guis.GUIEnabler.ConnectMergeCloseChargeThresholdChangedEvent
+func (q *Qt) ConnectMergeCloseChargeThresholdChangedEvent(f func(value float64)) {
+	q.EventSystem.mergeCloseChargeThresholdChangedEventHandler = f
+}
+
+// CaptureSpeedSliderChangedEvent is triggered when the user changes the value of the Merge Capture Speed slider and
+// passes that value (scaled from slider to engine units) back to the main app using the provided event handler.
+func (q *Qt) CaptureSpeedSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.captureSpeedChangedEventHandler(float64(value) *
+			q.FormItems["Merge Capture Speed"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectCaptureSpeedChangedEvent implements guis.GUIEnabler.ConnectCaptureSpeedChangedEvent
+func (q *Qt) ConnectCaptureSpeedChangedEvent(f func(value float64)) {
+	q.EventSystem.captureSpeedChangedEventHandler = f
+}
+
+// ChargeMergeRuleComboChangedEvent is triggered when the user selects a charge merge rule in the ChargeMergeRuleCombo
+// and passes its index (the physics.ChargeMergeRule) back to the main app using the provided event handler.
+func (q *Qt) ChargeMergeRuleComboChangedEvent(index int) {
+	if !q.loadingState {
+		q.EventSystem.chargeMergeRuleChangedEventHandler(index)
+	}
+}
+
+// ConnectChargeMergeRuleChangedEvent implements guis.GUIEnabler.ConnectChargeMergeRuleChangedEvent
+func (q *Qt) ConnectChargeMergeRuleChangedEvent(f func(value int)) {
+	q.EventSystem.chargeMergeRuleChangedEventHandler = f
+}
+
+// AllowFissionClickEvent is triggered when the user clicks the AllowFissionCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) AllowFissionClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.allowFissionChangedEventHandler(checked)
+	}
+}
+
+// ConnectAllowFissionChangedEvent implements guis.GUIEnabler.ConnectAllowFissionChangedEvent
+func (q *Qt) ConnectAllowFissionChangedEvent(f func(enabled bool)) {
+	q.EventSystem.allowFissionChangedEventHandler = f
+}
+
+// MaxMassSliderChangedEvent is triggered when the user changes the value of the Max Mass slider and passes that value
+// (scaled from slider to engine units) back to the main app using the provided event handler.
+func (q *Qt) MaxMassSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.maxMassChangedEventHandler(float64(value) * q.FormItems["Max Mass"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectMaxMassChangedEvent implements guis.GUIEnabler.ConnectMaxMassChangedEvent
+func (q *Qt) ConnectMaxMassChangedEvent(f func(value float64)) {
+	q.EventSystem.maxMassChangedEventHandler = f
+}
+
+// MaxParticlesSliderChangedEvent is triggered when the user changes the value of the Max Particles slider and passes
+// that value back to the main app using the provided event handler.
+func (q *Qt) MaxParticlesSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.maxParticlesChangedEventHandler(value)
+	} // We know this isn't scaled
+}
+
+// ConnectMaxParticlesChangedEvent implements guis.GUIEnabler.ConnectMaxParticlesChangedEvent
+func (q *Qt) ConnectMaxParticlesChangedEvent(f func(value int)) {
+	q.EventSystem.maxParticlesChangedEventHandler = f
+}
+
+// BounceCompleteDistSliderChangedEvent is triggered when the user changes the value of the Bounce Separation Factor
+// slider and passes that value (scaled from slider to engine units) back to the main app using the provided event
+// handler.
+func (q *Qt) BounceCompleteDistSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.bounceCompleteDistFactorChangedEventHandler(float64(value) *
+			q.FormItems["Bounce Separation Factor"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectBounceCompleteDistFactorChangedEvent implements guis.GUIEnabler.ConnectBounceCompleteDistFactorChangedEvent
+func (q *Qt) ConnectBounceCompleteDistFactorChangedEvent(f func(value float64)) {
+	q.EventSystem.bounceCompleteDistFactorChangedEventHandler = f
+}
+
+// RestitutionSliderChangedEvent is triggered when the user changes the value of the Restitution slider and passes that
+// value (scaled from slider to engine units) back to the main app using the provided event handler.
+func (q *Qt) RestitutionSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.restitutionChangedEventHandler(float64(value) *
+			q.FormItems["Restitution"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectRestitutionChangedEvent implements guis.GUIEnabler.ConnectRestitutionChangedEvent
+func (q *Qt) ConnectRestitutionChangedEvent(f func(value float64)) {
+	q.EventSystem.restitutionChangedEventHandler = f
+}
+
+// DragCoefficientSliderChangedEvent is triggered when the user changes the value of the Drag Coefficient slider and
+// passes that value (scaled from slider to engine units) back to the main app using the provided event handler.
+func (q *Qt) DragCoefficientSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.dragCoefficientChangedEventHandler(float64(value) *
+			q.FormItems["Drag Coefficient"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectDragCoefficientChangedEvent implements guis.GUIEnabler.ConnectDragCoefficientChangedEvent
+func (q *Qt) ConnectDragCoefficientChangedEvent(f func(value float64)) {
+	q.EventSystem.dragCoefficientChangedEventHandler = f
+}
+
+// MaxAccelerationSliderChangedEvent is triggered when the user changes the value of the Max Acceleration slider and
+// passes that value (scaled from slider to engine units) back to the main app using the provided event handler.
+func (q *Qt) MaxAccelerationSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.maxAccelerationChangedEventHandler(float64(value) *
+			q.FormItems["Max Acceleration (0 = Unlimited)"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectMaxAccelerationChangedEvent implements guis.GUIEnabler.ConnectMaxAccelerationChangedEvent
+func (q *Qt) ConnectMaxAccelerationChangedEvent(f func(value float64)) {
+	q.EventSystem.maxAccelerationChangedEventHandler = f
+}
+
+// QuadraticDragClickEvent is triggered when the user clicks the QuadraticDragCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) QuadraticDragClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.quadraticDragChangedEventHandler(checked)
+	}
+}
+
+// ConnectQuadraticDragChangedEvent implements guis.GUIEnabler.ConnectQuadraticDragChangedEvent
+func (q *Qt) ConnectQuadraticDragChangedEvent(f func(enabled bool)) {
+	q.EventSystem.quadraticDragChangedEventHandler = f
+}
+
+// ExternalFieldSliderChangedEvent is triggered when the user changes the value of the External Field Strength or
+// External Field Angle slider and passes both values (scaled from slider to engine units) back to the main app using
+// the provided event handler.
+func (q *Qt) ExternalFieldSliderChangedEvent(int) {
+	if !q.loadingState {
+		q.EventSystem.externalFieldChangedEventHandler(
+			q.FormItems["External Field Strength"].(*eWidgets.ESlider).GetScaledValue(),
+			q.FormItems["External Field Angle"].(*eWidgets.ESlider).GetScaledValue())
+	}
+}
+
+// ConnectExternalFieldChangedEvent implements guis.GUIEnabler.ConnectExternalFieldChangedEvent
+func (q *Qt) ConnectExternalFieldChangedEvent(f func(strength, angle float64)) {
+	q.EventSystem.externalFieldChangedEventHandler = f
+}
+
+// FrameAngularVelocitySliderChangedEvent is triggered when the user changes the value of the Frame Angular Velocity
+// slider and passes that value (scaled from slider to engine units) back to the main app using the provided event
+// handler.
+func (q *Qt) FrameAngularVelocitySliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.frameAngularVelocityChangedEventHandler(float64(value) *
+			q.FormItems["Frame Angular Velocity"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectFrameAngularVelocityChangedEvent implements guis.GUIEnabler.ConnectFrameAngularVelocityChangedEvent
+func (q *Qt) ConnectFrameAngularVelocityChangedEvent(f func(value float64)) {
+	q.EventSystem.frameAngularVelocityChangedEventHandler = f
+}
+
 // WallBounceClickEvent is triggered when the user clicks the WallBounceCheck. It passes the current checked state back
 // to the main app using the provided handler.
 func (q *Qt) WallBounceClickEvent(checked bool) {
+	// Wall bounce and wrap boundary are mutually exclusive
+	if checked {
+		q.WrapBoundaryCheck.SetChecked(false)
+		q.wrapBoundary = false
+	}
 	if !q.loadingState {
 		q.EventSystem.wallBounceChangedEventHandler(checked)
 	}
@@ -221,6 +826,24 @@ func (q *Qt) ConnectWallBounceChangedEvent(f func(enabled bool)) {
 	q.EventSystem.wallBounceChangedEventHandler = f
 }
 
+// WrapBoundaryClickEvent is triggered when the user clicks the WrapBoundaryCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) WrapBoundaryClickEvent(checked bool) {
+	// Wall bounce and wrap boundary are mutually exclusive
+	if checked {
+		q.WallBounceCheck.SetChecked(false)
+	}
+	q.wrapBoundary = checked
+	if !q.loadingState {
+		q.EventSystem.wrapBoundaryChangedEventHandler(checked)
+	}
+}
+
+// ConnectWrapBoundaryChangedEvent implements guis.GUIEnabler.ConnectWrapBoundaryChangedEvent
+func (q *Qt) ConnectWrapBoundaryChangedEvent(f func(enabled bool)) {
+	q.EventSystem.wrapBoundaryChangedEventHandler = f
+}
+
 // HistoryTrailClickEvent is triggered when the user clicks the HistoryTrailCheck. It passes the current checked state
 // back to the main app using the provided handler.
 func (q *Qt) HistoryTrailClickEvent(checked bool) {
@@ -247,6 +870,205 @@ func (q *Qt) ConnectHistoryTrailLengthChangedEvent(f func(value int)) {
 	q.EventSystem.historyTrailLengthChangedEventHandler = f
 }
 
+// HistoryTrailStrideSliderChangedEvent is triggered when the user changes the value of the History Trail Stride slider
+// and passes that value back to the main app using the provided event handler.
+func (q *Qt) HistoryTrailStrideSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.historyTrailStrideChangedEventHandler(value)
+	}
+}
+
+// ConnectHistoryTrailStrideChangedEvent implements guis.GUIEnabler.ConnectHistoryTrailStrideChangedEvent
+func (q *Qt) ConnectHistoryTrailStrideChangedEvent(f func(value int)) {
+	q.EventSystem.historyTrailStrideChangedEventHandler = f
+}
+
+// ColorSchemeComboChangedEvent is triggered when the user selects a color scheme in the ColorSchemeCombo and passes its
+// index back to the main app using the provided event handler.
+func (q *Qt) ColorSchemeComboChangedEvent(index int) {
+	if !q.loadingState {
+		q.EventSystem.colorSchemeChangedEventHandler(index)
+	}
+}
+
+// ConnectColorSchemeChangedEvent implements guis.GUIEnabler.ConnectColorSchemeChangedEvent
+func (q *Qt) ConnectColorSchemeChangedEvent(f func(value int)) {
+	q.EventSystem.colorSchemeChangedEventHandler = f
+}
+
+// TrailStyleComboChangedEvent is triggered when the user selects a trail style in the TrailStyleCombo and passes its
+// index (the state.TrailStyle) back to the main app using the provided event handler.
+func (q *Qt) TrailStyleComboChangedEvent(index int) {
+	q.trailStyle = state.TrailStyle(index)
+	if !q.loadingState {
+		q.EventSystem.trailStyleChangedEventHandler(index)
+	}
+}
+
+// ConnectTrailStyleChangedEvent implements guis.GUIEnabler.ConnectTrailStyleChangedEvent
+func (q *Qt) ConnectTrailStyleChangedEvent(f func(value int)) {
+	q.EventSystem.trailStyleChangedEventHandler = f
+}
+
+// TrailColoringComboChangedEvent is triggered when the user selects a trail coloring in the TrailColoringCombo and
+// passes its index (the state.TrailColoring) back to the main app using the provided event handler.
+func (q *Qt) TrailColoringComboChangedEvent(index int) {
+	q.trailColoring = state.TrailColoring(index)
+	if !q.loadingState {
+		q.EventSystem.trailColoringChangedEventHandler(index)
+	}
+}
+
+// ConnectTrailColoringChangedEvent implements guis.GUIEnabler.ConnectTrailColoringChangedEvent
+func (q *Qt) ConnectTrailColoringChangedEvent(f func(value int)) {
+	q.EventSystem.trailColoringChangedEventHandler = f
+}
+
+// HistoryPrefillComboChangedEvent is triggered when the user selects a trail pre-fill mode in the HistoryPrefillCombo
+// and passes its index (the physics.HistoryPrefill) back to the main app using the provided event handler.
+func (q *Qt) HistoryPrefillComboChangedEvent(index int) {
+	if !q.loadingState {
+		q.EventSystem.historyPrefillChangedEventHandler(index)
+	}
+}
+
+// ConnectHistoryPrefillChangedEvent implements guis.GUIEnabler.ConnectHistoryPrefillChangedEvent
+func (q *Qt) ConnectHistoryPrefillChangedEvent(f func(value int)) {
+	q.EventSystem.historyPrefillChangedEventHandler = f
+}
+
+// DrawRadiusScaleSliderChangedEvent is triggered when the user changes the value of the Particle Draw Size slider. It
+// redraws the particles at the new size and passes the (scaled) value back to the main app using the provided event
+// handler.
+func (q *Qt) DrawRadiusScaleSliderChangedEvent(value int) {
+	q.drawRadiusScale = float64(value) * q.FormItems["Particle Draw Size"].(*eWidgets.ESlider).Scale
+	if !q.loadingState {
+		q.EventSystem.drawRadiusScaleChangedEventHandler(q.drawRadiusScale)
+	}
+}
+
+// ConnectDrawRadiusScaleChangedEvent implements guis.GUIEnabler.ConnectDrawRadiusScaleChangedEvent
+func (q *Qt) ConnectDrawRadiusScaleChangedEvent(f func(value float64)) {
+	q.EventSystem.drawRadiusScaleChangedEventHandler = f
+}
+
+// MinDrawRadiusSliderChangedEvent is triggered when the user changes the value of the Min Particle Draw Radius slider.
+// It redraws the smallest particles at the new size and passes the value back to the main app using the provided event
+// handler.
+func (q *Qt) MinDrawRadiusSliderChangedEvent(value int) {
+	q.minDrawRadius = value
+	if !q.loadingState {
+		q.EventSystem.minDrawRadiusChangedEventHandler(value)
+	}
+}
+
+// ConnectMinDrawRadiusChangedEvent implements guis.GUIEnabler.ConnectMinDrawRadiusChangedEvent
+func (q *Qt) ConnectMinDrawRadiusChangedEvent(f func(value int)) {
+	q.EventSystem.minDrawRadiusChangedEventHandler = f
+}
+
+// ShowVelocityVectorsClickEvent is triggered when the user clicks the ShowVelocityVectorsCheck. It passes the current
+// checked state back to the main app using the provided handler (which redraws the particles, with or without the
+// arrows).
+func (q *Qt) ShowVelocityVectorsClickEvent(checked bool) {
+	q.showVelocityVectors = checked
+	if !q.loadingState {
+		q.EventSystem.showVelocityVectorsEventHandler(checked)
+	}
+}
+
+// ConnectShowVelocityVectorsEvent implements guis.GUIEnabler.ConnectShowVelocityVectorsEvent
+func (q *Qt) ConnectShowVelocityVectorsEvent(f func(enabled bool)) {
+	q.EventSystem.showVelocityVectorsEventHandler = f
+}
+
+// ShowParticleIDsClickEvent is triggered when the user clicks the ShowParticleIDsCheck. It passes the current checked
+// state back to the main app using the provided handler (which redraws the particles, with or without their IDs).
+func (q *Qt) ShowParticleIDsClickEvent(checked bool) {
+	q.showParticleIDs = checked
+	if !q.loadingState {
+		q.EventSystem.showParticleIDsEventHandler(checked)
+	}
+}
+
+// ConnectShowParticleIDsEvent implements guis.GUIEnabler.ConnectShowParticleIDsEvent
+func (q *Qt) ConnectShowParticleIDsEvent(f func(enabled bool)) {
+	q.EventSystem.showParticleIDsEventHandler = f
+}
+
+// DensityHeatmapClickEvent is triggered when the user clicks the DensityHeatmapCheck. It passes the current checked
+// state back to the main app using the provided handler (which redraws the particles, as a heatmap or as circles).
+func (q *Qt) DensityHeatmapClickEvent(checked bool) {
+	q.densityHeatmap = checked
+	if !q.loadingState {
+		q.EventSystem.densityHeatmapEventHandler(checked)
+	}
+}
+
+// ConnectDensityHeatmapEvent implements guis.GUIEnabler.ConnectDensityHeatmapEvent
+func (q *Qt) ConnectDensityHeatmapEvent(f func(enabled bool)) {
+	q.EventSystem.densityHeatmapEventHandler = f
+}
+
+// CenterOfMassFrameClickEvent is triggered when the user clicks the CenterOfMassFrameCheck. It passes the current
+// checked state back to the main app using the provided handler (which redraws the particles, in the center of mass
+// frame or not).
+func (q *Qt) CenterOfMassFrameClickEvent(checked bool) {
+	q.centerOfMassFrame = checked
+	if !q.loadingState {
+		q.EventSystem.centerOfMassFrameEventHandler(checked)
+	}
+}
+
+// ConnectCenterOfMassFrameEvent implements guis.GUIEnabler.ConnectCenterOfMassFrameEvent
+func (q *Qt) ConnectCenterOfMassFrameEvent(f func(enabled bool)) {
+	q.EventSystem.centerOfMassFrameEventHandler = f
+}
+
+// SmoothRenderingClickEvent is triggered when the user clicks the SmoothRenderingCheck. It passes the current checked
+// state back to the main app using the provided handler (which redraws the particles, smoothly or not).
+func (q *Qt) SmoothRenderingClickEvent(checked bool) {
+	q.smoothRendering = checked
+	if !q.loadingState {
+		q.EventSystem.smoothRenderingEventHandler(checked)
+	}
+}
+
+// ConnectSmoothRenderingEvent implements guis.GUIEnabler.ConnectSmoothRenderingEvent
+func (q *Qt) ConnectSmoothRenderingEvent(f func(enabled bool)) {
+	q.EventSystem.smoothRenderingEventHandler = f
+}
+
+// BackgroundColorButtonClickEvent is triggered when the user clicks the BackgroundColorButton. It presents a color
+// picker and passes the selected color back to the main app using the provided event handler.
+func (q *Qt) BackgroundColorButtonClickEvent(checked bool) {
+	q.pickColor("Background Color", q.backgroundColor, func(c state.Color) {
+		q.backgroundColor = c
+		setColorButton(q.BackgroundColorButton, c)
+		q.EventSystem.backgroundColorChangedEventHandler(c)
+	})
+}
+
+// ConnectBackgroundColorChangedEvent implements guis.GUIEnabler.ConnectBackgroundColorChangedEvent
+func (q *Qt) ConnectBackgroundColorChangedEvent(f func(color state.Color)) {
+	q.EventSystem.backgroundColorChangedEventHandler = f
+}
+
+// WallColorButtonClickEvent is triggered when the user clicks the WallColorButton. It presents a color picker and
+// passes the selected color back to the main app using the provided event handler.
+func (q *Qt) WallColorButtonClickEvent(checked bool) {
+	q.pickColor("Wall Color", q.wallColor, func(c state.Color) {
+		q.wallColor = c
+		setColorButton(q.WallColorButton, c)
+		q.EventSystem.wallColorChangedEventHandler(c)
+	})
+}
+
+// ConnectWallColorChangedEvent implements guis.GUIEnabler.ConnectWallColorChangedEvent
+func (q *Qt) ConnectWallColorChangedEvent(f func(color state.Color)) {
+	q.EventSystem.wallColorChangedEventHandler = f
+}
+
 // PhysicsLoopSliderChangedEvent is triggered when the user changes the value of the Physics Loop Speed slider
 // and passes that value back to the main app using the provided event handler.
 func (q *Qt) PhysicsLoopSliderChangedEvent(value int) {
@@ -260,6 +1082,32 @@ func (q *Qt) ConnectPhysicsLoopSpeedChangedEvent(f func(value int)) {
 	q.EventSystem.physicsLoopSpeedChangedEventHandler = f
 }
 
+// StepsPerFrameSliderChangedEvent is triggered when the user changes the value of the Steps Per Frame slider and
+// passes that value back to the main app using the provided event handler.
+func (q *Qt) StepsPerFrameSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.stepsPerFrameChangedEventHandler(value)
+	} // We know this isn't scaled
+}
+
+// ConnectStepsPerFrameChangedEvent implements guis.GUIEnabler.ConnectStepsPerFrameChangedEvent
+func (q *Qt) ConnectStepsPerFrameChangedEvent(f func(value int)) {
+	q.EventSystem.stepsPerFrameChangedEventHandler = f
+}
+
+// AutoSlowdownClickEvent is triggered when the user clicks the AutoSlowdownCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) AutoSlowdownClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.autoSlowdownChangedEventHandler(checked)
+	}
+}
+
+// ConnectAutoSlowdownChangedEvent implements guis.GUIEnabler.ConnectAutoSlowdownChangedEvent
+func (q *Qt) ConnectAutoSlowdownChangedEvent(f func(enabled bool)) {
+	q.EventSystem.autoSlowdownChangedEventHandler = f
+}
+
 // ResetButtonClickEvent is triggered when the user clicks the ResetButton. It informs the main app of this request by
 // calling the provided event handler.
 func (q *Qt) ResetButtonClickEvent(checked bool) {
@@ -271,34 +1119,84 @@ func (q *Qt) ConnectResetEnvironmentEvent(f func()) {
 	q.EventSystem.resetEnvironmentEventHandler = f
 }
 
+// ZeroVelocitiesButtonClickEvent is triggered when the user clicks the ZeroVelocitiesButton. It informs the main app of
+// this request by calling the provided event handler.
+func (q *Qt) ZeroVelocitiesButtonClickEvent(checked bool) {
+	q.EventSystem.zeroVelocitiesEventHandler()
+}
+
+// ConnectZeroVelocitiesEvent implements guis.GUIEnabler.ConnectZeroVelocitiesEvent
+func (q *Qt) ConnectZeroVelocitiesEvent(f func()) {
+	q.EventSystem.zeroVelocitiesEventHandler = f
+}
+
 // PauseButtonClickEvent is triggered when the user clicks the PauseButton. It informs the main app of this request by
 // calling the provided event handler, which returns whether the simulation is currently paused, which is used to
 // enable/disable GUI elements and update the PauseButton text.
 func (q *Qt) PauseButtonClickEvent(checked bool) {
-	paused := q.EventSystem.pauseResumeEventHandler()
+	q.updatePauseControls(q.EventSystem.pauseResumeEventHandler())
+}
+
+// SimulationPaused implements guis.GUIEnabler.SimulationPaused
+func (q *Qt) SimulationPaused() {
+	q.updatePauseControls(true)
+}
 
+// updatePauseControls updates the PauseButton text and enables/disables the GUI elements which may only be used while
+// the simulation is paused, according to whether it now is.
+func (q *Qt) updatePauseControls(paused bool) {
 	// Now pausing
 	if paused {
 		q.PauseButton.SetText("Resume")
 
 		q.SaveStateButton.SetEnabled(true)
 		q.LoadStateButton.SetEnabled(true)
-		q.FormItems["Environment Size (units*units)"].(*eWidgets.ESlider).SetEnabled(true)
+		q.SaveSettingsButton.SetEnabled(true)
+		q.LoadSettingsButton.SetEnabled(true)
+		q.ResetSettingsButton.SetEnabled(true)
+		q.PresetCombo.SetEnabled(true)
+		q.FormItems["Environment Width (units)"].(*eWidgets.ESlider).SetEnabled(true)
+		q.FormItems["Environment Height (units)"].(*eWidgets.ESlider).SetEnabled(true)
 		q.FormItems["Number of Particles"].(*eWidgets.ESlider).SetEnabled(true)
 		q.FormItems["Average Mass"].(*eWidgets.ESlider).SetEnabled(true)
+		q.InitialVelocityModeCombo.SetEnabled(true)
+		q.FormItems["Initial Speed"].(*eWidgets.ESlider).SetEnabled(true)
+		q.FormItems["Spawn Center X"].(*eWidgets.ESlider).SetEnabled(true)
+		q.FormItems["Spawn Center Y"].(*eWidgets.ESlider).SetEnabled(true)
+		q.FormItems["Spawn Radius (0 = Everywhere)"].(*eWidgets.ESlider).SetEnabled(true)
+		q.ChargeDistributionCombo.SetEnabled(true)
+		q.SpeciesMatrixButton.SetEnabled(true)
 		q.RegenButton.SetEnabled(true)
 		q.ResetButton.SetEnabled(true)
+		q.UndoButton.SetEnabled(true)
+		q.RedoButton.SetEnabled(true)
+		q.StepButton.SetEnabled(true)
 		// Now resuming
 	} else {
 		q.PauseButton.SetText("Pause")
 
 		q.SaveStateButton.SetEnabled(false)
 		q.LoadStateButton.SetEnabled(false)
-		q.FormItems["Environment Size (units*units)"].(*eWidgets.ESlider).SetEnabled(false)
+		q.SaveSettingsButton.SetEnabled(false)
+		q.LoadSettingsButton.SetEnabled(false)
+		q.ResetSettingsButton.SetEnabled(false)
+		q.PresetCombo.SetEnabled(false)
+		q.FormItems["Environment Width (units)"].(*eWidgets.ESlider).SetEnabled(false)
+		q.FormItems["Environment Height (units)"].(*eWidgets.ESlider).SetEnabled(false)
 		q.FormItems["Number of Particles"].(*eWidgets.ESlider).SetEnabled(false)
 		q.FormItems["Average Mass"].(*eWidgets.ESlider).SetEnabled(false)
+		q.InitialVelocityModeCombo.SetEnabled(false)
+		q.FormItems["Initial Speed"].(*eWidgets.ESlider).SetEnabled(false)
+		q.FormItems["Spawn Center X"].(*eWidgets.ESlider).SetEnabled(false)
+		q.FormItems["Spawn Center Y"].(*eWidgets.ESlider).SetEnabled(false)
+		q.FormItems["Spawn Radius (0 = Everywhere)"].(*eWidgets.ESlider).SetEnabled(false)
+		q.ChargeDistributionCombo.SetEnabled(false)
+		q.SpeciesMatrixButton.SetEnabled(false)
 		q.RegenButton.SetEnabled(false)
 		q.ResetButton.SetEnabled(false)
+		q.UndoButton.SetEnabled(false)
+		q.RedoButton.SetEnabled(false)
+		q.StepButton.SetEnabled(false)
 	}
 }
 
@@ -307,10 +1205,483 @@ func (q *Qt) ConnectPauseResumeEvent(f func() (paused bool)) {
 	q.EventSystem.pauseResumeEventHandler = f
 }
 
+// StepButtonClickEvent is triggered when the user clicks the StepButton (which is only enabled while the simulation is
+// paused). It informs the main app of this request by calling the provided event handler.
+func (q *Qt) StepButtonClickEvent(checked bool) {
+	q.EventSystem.stepOnceEventHandler()
+}
+
+// ConnectStepOnceEvent implements guis.GUIEnabler.ConnectStepOnceEvent
+func (q *Qt) ConnectStepOnceEvent(f func()) {
+	q.EventSystem.stepOnceEventHandler = f
+}
+
+// PauseOnMergeClickEvent is triggered when the user clicks the PauseOnMergeCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) PauseOnMergeClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.pauseOnMergeChangedEventHandler(checked)
+	}
+}
+
+// ConnectPauseOnMergeChangedEvent implements guis.GUIEnabler.ConnectPauseOnMergeChangedEvent
+func (q *Qt) ConnectPauseOnMergeChangedEvent(f func(enabled bool)) {
+	q.EventSystem.pauseOnMergeChangedEventHandler = f
+}
+
+// PauseOnSpeedClickEvent is triggered when the user clicks the PauseOnSpeedCheck. It passes the current checked state
+// back to the main app using the provided handler.
+func (q *Qt) PauseOnSpeedClickEvent(checked bool) {
+	if !q.loadingState {
+		q.EventSystem.pauseOnSpeedChangedEventHandler(checked)
+	}
+}
+
+// ConnectPauseOnSpeedChangedEvent implements guis.GUIEnabler.ConnectPauseOnSpeedChangedEvent
+func (q *Qt) ConnectPauseOnSpeedChangedEvent(f func(enabled bool)) {
+	q.EventSystem.pauseOnSpeedChangedEventHandler = f
+}
+
+// PauseSpeedThresholdSliderChangedEvent is triggered when the user changes the value of the Pause Speed Threshold
+// slider and passes that value (scaled from slider to engine units) back to the main app using the provided event
+// handler.
+func (q *Qt) PauseSpeedThresholdSliderChangedEvent(value int) {
+	if !q.loadingState {
+		q.EventSystem.pauseSpeedThresholdChangedEventHandler(float64(value) *
+			q.FormItems["Pause Speed Threshold"].(*eWidgets.ESlider).Scale)
+	}
+}
+
+// ConnectPauseSpeedThresholdChangedEvent implements guis.GUIEnabler.ConnectPauseSpeedThresholdChangedEvent
+func (q *Qt) ConnectPauseSpeedThresholdChangedEvent(f func(value float64)) {
+	q.EventSystem.pauseSpeedThresholdChangedEventHandler = f
+}
+
+// UndoButtonClickEvent is triggered when the user clicks the UndoButton. It informs the main app of this request by
+// calling the provided event handler.
+func (q *Qt) UndoButtonClickEvent(checked bool) {
+	q.EventSystem.undoEventHandler()
+}
+
+// ConnectUndoEvent implements guis.GUIEnabler.ConnectUndoEvent
+func (q *Qt) ConnectUndoEvent(f func()) {
+	q.EventSystem.undoEventHandler = f
+}
+
+// RedoButtonClickEvent is triggered when the user clicks the RedoButton. It informs the main app of this request by
+// calling the provided event handler.
+func (q *Qt) RedoButtonClickEvent(checked bool) {
+	q.EventSystem.redoEventHandler()
+}
+
+// ConnectRedoEvent implements guis.GUIEnabler.ConnectRedoEvent
+func (q *Qt) ConnectRedoEvent(f func()) {
+	q.EventSystem.redoEventHandler = f
+}
+
+// RecordButtonClickEvent is triggered when the user clicks the RecordButton. If not recording, it presents a directory
+// picker and starts recording the drawn frames to the selected directory (see recorder), otherwise it stops recording.
+// The main app is informed using the provided event handlers.
+func (q *Qt) RecordButtonClickEvent(checked bool) {
+	q.recorderLock.Lock()
+	recording := q.recorder != nil
+	q.recorderLock.Unlock()
+	if recording {
+		q.stopRecording()
+		return
+	}
+
+	path, err := os.Getwd()
+	// Path will be ""
+	if err != nil {
+		log.Warnln("Unable to get current directory: " + err.Error())
+	}
+	dlg := widgets.NewQFileDialog2(nil, "Select Directory", path, "")
+	dlg.SetFileMode(widgets.QFileDialog__Directory)
+	dlg.SetOption(widgets.QFileDialog__ShowDirsOnly, true)
+	// Anonymous function called on selection of a directory
+	dlg.ConnectFileSelected(func(dir string) {
+		q.recorderLock.Lock()
+		q.recorder = newRecorder(dir, q.FormItems["Record Every N Frames"].(*eWidgets.ESlider).GetValue(),
+			q.RecordGIFCheck.IsChecked(),
+			q.FormItems["Physics Loop (ms)"].(*eWidgets.ESlider).GetValue()*
+				q.FormItems["Record Every N Frames"].(*eWidgets.ESlider).GetValue())
+		q.recorderLock.Unlock()
+
+		q.RecordButton.SetText("Stop Recording")
+		q.RecordGIFCheck.SetEnabled(false)
+		q.FormItems["Record Every N Frames"].(*eWidgets.ESlider).SetEnabled(false)
+		// Tell the main app the selected directory
+		q.EventSystem.startRecordingEventHandler(dir)
+	})
+	// Show the dialog (waits for selection / cancel)
+	dlg.Show()
+}
+
+// stopRecording stops the current recording (see finishRecording), resets the recording controls, and informs the main
+// app using the provided event handler.
+func (q *Qt) stopRecording() {
+	q.finishRecording()
+	q.RecordButton.SetText("Start Recording")
+	q.RecordGIFCheck.SetEnabled(true)
+	q.FormItems["Record Every N Frames"].(*eWidgets.ESlider).SetEnabled(true)
+	q.EventSystem.stopRecordingEventHandler()
+}
+
+// finishRecording stops the current recording, if any, writing the GIF (if recording one).
+func (q *Qt) finishRecording() {
+	q.recorderLock.Lock()
+	r := q.recorder
+	q.recorder = nil
+	q.recorderLock.Unlock()
+	if r == nil {
+		return
+	}
+
+	if file, err := r.stop(); err != nil {
+		log.Warnln("Unable to write recording " + file + ": " + err.Error())
+	} else {
+		log.Infoln("Recording (" + strconv.Itoa(r.captured) + " frames) written to: " + file)
+	}
+}
+
+// TrajectoryButtonClickEvent is triggered when the user clicks the TrajectoryButton. If not recording trajectories, it
+// presents a file picker and passes the selected file back to the main app using the provided event handler (which
+// starts recording). Otherwise, it stops recording using the provided event handler.
+func (q *Qt) TrajectoryButtonClickEvent(checked bool) {
+	if q.recordingTrajectory {
+		q.recordingTrajectory = false
+		q.TrajectoryButton.SetText("Start Trajectory Recording")
+		q.EventSystem.stopTrajectoryEventHandler()
+		return
+	}
+
+	path, err := os.Getwd()
+	// Path will be ""
+	if err != nil {
+		log.Warnln("Unable to get current directory: " + err.Error())
+	}
+	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.jsonl")
+	dlg.SetAcceptMode(widgets.QFileDialog__AcceptSave)
+	// Anonymous function called on selection of valid file / clicking Save
+	dlg.ConnectFileSelected(func(file string) {
+		if !strings.HasSuffix(file, ".jsonl") {
+			file += ".jsonl"
+		}
+		// Tell the main app the selected file
+		if q.EventSystem.startTrajectoryEventHandler(file) {
+			q.recordingTrajectory = true
+			q.TrajectoryButton.SetText("Stop Trajectory Recording")
+		}
+	})
+	// Show the dialog (waits for save / cancel)
+	dlg.Show()
+}
+
+// ConnectStartTrajectoryEvent implements guis.GUIEnabler.ConnectStartTrajectoryEvent
+func (q *Qt) ConnectStartTrajectoryEvent(f func(file string) (started bool)) {
+	q.EventSystem.startTrajectoryEventHandler = f
+}
+
+// ConnectStopTrajectoryEvent implements guis.GUIEnabler.ConnectStopTrajectoryEvent
+func (q *Qt) ConnectStopTrajectoryEvent(f func()) {
+	q.EventSystem.stopTrajectoryEventHandler = f
+}
+
+// ExportGenealogyButtonClickEvent is triggered when the user clicks the ExportGenealogyButton. It presents a file
+// picker and passes the selected file back to the main app using the provided event handler.
+func (q *Qt) ExportGenealogyButtonClickEvent(checked bool) {
+	path, err := os.Getwd()
+	// Path will be ""
+	if err != nil {
+		log.Warnln("Unable to get current directory: " + err.Error())
+	}
+	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.json *.dot")
+	dlg.SetAcceptMode(widgets.QFileDialog__AcceptSave)
+	// Anonymous function called on selection of valid file / clicking Save
+	dlg.ConnectFileSelected(func(file string) {
+		if !strings.HasSuffix(file, ".json") && !strings.HasSuffix(file, ".dot") && !strings.HasSuffix(file, ".gv") {
+			file += ".json"
+		}
+		// Tell the main app the selected file
+		q.EventSystem.exportGenealogyEventHandler(file)
+	})
+	// Show the dialog (waits for save / cancel)
+	dlg.Show()
+}
+
+// ConnectExportGenealogyEvent implements guis.GUIEnabler.ConnectExportGenealogyEvent
+func (q *Qt) ConnectExportGenealogyEvent(f func(file string)) {
+	q.EventSystem.exportGenealogyEventHandler = f
+}
+
+// ExportSVGButtonClickEvent is triggered when the user clicks the ExportSVGButton. It presents a file picker and passes
+// the selected file back to the main app using the provided event handler.
+func (q *Qt) ExportSVGButtonClickEvent(checked bool) {
+	path, err := os.Getwd()
+	// Path will be ""
+	if err != nil {
+		log.Warnln("Unable to get current directory: " + err.Error())
+	}
+	dlg := widgets.NewQFileDialog2(nil, "Select File", path, "*.svg")
+	dlg.SetAcceptMode(widgets.QFileDialog__AcceptSave)
+	// Anonymous function called on selection of valid file / clicking Save
+	dlg.ConnectFileSelected(func(file string) {
+		if !strings.HasSuffix(file, ".svg") {
+			file += ".svg"
+		}
+		// Tell the main app the selected file
+		q.EventSystem.exportSVGEventHandler(file)
+	})
+	// Show the dialog (waits for save / cancel)
+	dlg.Show()
+}
+
+// ConnectExportSVGEvent implements guis.GUIEnabler.ConnectExportSVGEvent
+func (q *Qt) ConnectExportSVGEvent(f func(file string)) {
+	q.EventSystem.exportSVGEventHandler = f
+}
+
+// windowCloseEvent is triggered when the main window is closed. It informs the main app using the provided event
+// handler (which stops the simulation and finishes its recordings), and then finishes any recording in progress (e.g.
+// so the GIF is written), before the window closes and the app exits.
+func (q *Qt) windowCloseEvent(e *gui.QCloseEvent) {
+	q.EventSystem.shutdownEventHandler()
+	q.finishRecording()
+	e.Accept()
+}
+
+// ConnectShutdownEvent implements guis.GUIEnabler.ConnectShutdownEvent
+func (q *Qt) ConnectShutdownEvent(f func()) {
+	q.EventSystem.shutdownEventHandler = f
+}
+
+// ConnectStartRecordingEvent implements guis.GUIEnabler.ConnectStartRecordingEvent
+func (q *Qt) ConnectStartRecordingEvent(f func(dir string)) {
+	q.EventSystem.startRecordingEventHandler = f
+}
+
+// ConnectStopRecordingEvent implements guis.GUIEnabler.ConnectStopRecordingEvent
+func (q *Qt) ConnectStopRecordingEvent(f func()) {
+	q.EventSystem.stopRecordingEventHandler = f
+}
+
+// ConnectToggleFixedEvent implements guis.GUIEnabler.ConnectToggleFixedEvent
+func (q *Qt) ConnectToggleFixedEvent(f func(x, y float64)) {
+	q.EventSystem.toggleFixedEventHandler = f
+}
+
+// ConnectParticleClickedEvent implements guis.GUIEnabler.ConnectParticleClickedEvent
+func (q *Qt) ConnectParticleClickedEvent(f func(x, y float64)) {
+	q.EventSystem.particleClickedEventHandler = f
+}
+
+// ConnectGrabParticleEvent implements guis.GUIEnabler.ConnectGrabParticleEvent
+func (q *Qt) ConnectGrabParticleEvent(f func(x, y float64, addToSelection bool) (grabbed bool)) {
+	q.EventSystem.grabParticleEventHandler = f
+}
+
+// ConnectDragParticlesEvent implements guis.GUIEnabler.ConnectDragParticlesEvent
+func (q *Qt) ConnectDragParticlesEvent(f func(dx, dy float64)) {
+	q.EventSystem.dragParticlesEventHandler = f
+}
+
+// ConnectDropParticlesEvent implements guis.GUIEnabler.ConnectDropParticlesEvent
+func (q *Qt) ConnectDropParticlesEvent(f func()) {
+	q.EventSystem.dropParticlesEventHandler = f
+}
+
+// ConnectAttractorEvent implements guis.GUIEnabler.ConnectAttractorEvent
+func (q *Qt) ConnectAttractorEvent(f func(x, y float64, repel bool)) {
+	q.EventSystem.attractorEventHandler = f
+}
+
+// ConnectReleaseAttractorEvent implements guis.GUIEnabler.ConnectReleaseAttractorEvent
+func (q *Qt) ConnectReleaseAttractorEvent(f func()) {
+	q.EventSystem.releaseAttractorEventHandler = f
+}
+
+// viewMousePressEvent is triggered when the user presses a mouse button in the View. Right clicks request the particle
+// under the cursor be pinned/unpinned (the position is passed back to the main app using the provided event handler).
+// Left button presses request the particle under the cursor be grabbed (selected; added to the selection if Ctrl or
+// Shift is held), which the main app only allows while paused. If a particle is grabbed, dragging moves the selected
+// particles (see viewMouseMoveEvent), and otherwise it pans the View. Either way, the press may be a click (see
+// viewMouseReleaseEvent). Holding the middle button attracts nearby particles toward the cursor (or, with Shift held,
+// repels them) while the simulation runs, until it's released.
+func (q *Qt) viewMousePressEvent(e *gui.QMouseEvent) {
+	switch e.Button() {
+	case core.Qt__MiddleButton:
+		q.attracting = true
+		q.moveAttractor(e)
+	case core.Qt__RightButton:
+		// MapToScene accounts for the View's scaling and panning. The Canvas is at the Scene origin, and each of its
+		// pixels is an environment unit, so Scene coordinates are environment coordinates (once any center of mass
+		// frame shift is undone; see environmentPosition).
+		x, y := q.environmentPosition(q.View.MapToScene(e.Pos()))
+		q.EventSystem.toggleFixedEventHandler(x, y)
+	case core.Qt__LeftButton:
+		q.pressX, q.pressY = e.Pos().X(), e.Pos().Y()
+		x, y := q.environmentPosition(q.View.MapToScene(e.Pos()))
+		add := e.Modifiers()&(core.Qt__ControlModifier|core.Qt__ShiftModifier) != 0
+		if q.EventSystem.grabParticleEventHandler(x, y, add) {
+			q.dragging = true
+			// Dragging moves the particles by the distance the mouse moves, so the Scene position is kept
+			pos := q.View.MapToScene(e.Pos())
+			q.dragX, q.dragY = pos.X(), pos.Y()
+			return
+		}
+		// The default handling starts the drag (pan), if the View is zoomed in far enough to be panned
+		q.View.MousePressEventDefault(e)
+	default:
+		q.View.MousePressEventDefault(e)
+	}
+}
+
+// viewMouseMoveEvent is triggered when the user moves the mouse in the View. While dragging grabbed particles (see
+// viewMousePressEvent), the distance moved (in environment units) is passed back to the main app using the provided
+// event handler, which moves the particles. While attracting particles, the attractor follows the cursor. Otherwise,
+// the default handling pans the View (if dragging).
+func (q *Qt) viewMouseMoveEvent(e *gui.QMouseEvent) {
+	if q.attracting {
+		q.moveAttractor(e)
+	}
+	if !q.dragging {
+		q.View.MouseMoveEventDefault(e)
+		return
+	}
+	pos := q.View.MapToScene(e.Pos())
+	q.EventSystem.dragParticlesEventHandler(pos.X()-q.dragX, pos.Y()-q.dragY)
+	q.dragX, q.dragY = pos.X(), pos.Y()
+}
+
+// viewMouseReleaseEvent is triggered when the user releases a mouse button in the View. If grabbed particles were being
+// dragged, the main app is informed they've been dropped (using the provided event handler). If the left button was
+// released without being dragged (that is, it was clicked rather than used to pan the View or move particles), the
+// details of the particle under the cursor are requested (the position is passed back to the main app using the
+// provided event handler). Releasing the middle button stops attracting particles.
+func (q *Qt) viewMouseReleaseEvent(e *gui.QMouseEvent) {
+	if q.attracting && e.Button() == core.Qt__MiddleButton {
+		q.attracting = false
+		q.EventSystem.releaseAttractorEventHandler()
+		return
+	}
+	if q.dragging && e.Button() == core.Qt__LeftButton {
+		q.dragging = false
+		q.EventSystem.dropParticlesEventHandler()
+	} else {
+		q.View.MouseReleaseEventDefault(e)
+	}
+	if e.Button() == core.Qt__LeftButton &&
+		math.Abs(float64(e.Pos().X()-q.pressX))+math.Abs(float64(e.Pos().Y()-q.pressY)) <= clickDragThreshold {
+		x, y := q.environmentPosition(q.View.MapToScene(e.Pos()))
+		q.EventSystem.particleClickedEventHandler(x, y)
+	}
+}
+
+// moveAttractor passes the cursor position of mouse event e (in environment coordinates; see environmentPosition) back
+// to the main app using the provided event handler, to attract particles toward it (or, with Shift held, repel them).
+func (q *Qt) moveAttractor(e *gui.QMouseEvent) {
+	x, y := q.environmentPosition(q.View.MapToScene(e.Pos()))
+	q.EventSystem.attractorEventHandler(x, y, e.Modifiers()&core.Qt__ShiftModifier != 0)
+}
+
+// environmentPosition converts a Scene position (see viewMousePressEvent) to environment coordinates, undoing the shift
+// applied to drawn positions in the center of mass frame (see drawPosition).
+func (q *Qt) environmentPosition(pos *core.QPointF) (x, y float64) {
+	x, y = pos.X()-q.frameOffset[0], pos.Y()-q.frameOffset[1]
+	if q.wrapBoundary {
+		x, y = wrapCoordinate(x, float64(q.EnvironmentWidth)), wrapCoordinate(y, float64(q.EnvironmentHeight))
+	}
+	return x, y
+}
+
+// viewWheelEvent is triggered when the user scrolls the mouse wheel in the View. It zooms the View in or out, around
+// the cursor.
+func (q *Qt) viewWheelEvent(e *gui.QWheelEvent) {
+	// Each wheel "notch" is 120 units (1/8ths of a degree)
+	factor := math.Pow(zoomFactorPerNotch, float64(e.AngleDelta().Y())/120)
+	q.View.Scale(factor, factor)
+	q.zoomed = true
+}
+
+// connectShortcuts sets up the keyboard shortcuts for window: Space pauses/resumes, R resets the particles, G
+// generates new particles, and S saves the state to file. Each clicks the corresponding button, so the shortcuts
+// follow the same code paths (and do nothing while the button is disabled, such as while the simulation is running).
+// The shortcuts only apply while window is active, so they're ignored while a dialog (e.g. the file picker) has focus.
+func (q *Qt) connectShortcuts(window *widgets.QMainWindow) {
+	shortcuts := map[string]*widgets.QPushButton{
+		"Space": q.PauseButton,
+		"R":     q.ResetButton,
+		"G":     q.RegenButton,
+		"S":     q.SaveStateButton,
+	}
+	for key, button := range shortcuts {
+		button := button
+		s := widgets.NewQShortcut(window)
+		s.SetKey(gui.NewQKeySequence2(key, gui.QKeySequence__PortableText))
+		s.SetContext(core.Qt__WindowShortcut)
+		s.ConnectActivated(func() {
+			button.Click()
+		})
+	}
+}
+
+// ResetViewButtonClickEvent is triggered when the user clicks the ResetViewButton. It undoes any zooming and panning,
+// fitting the Scene in the View again.
+func (q *Qt) ResetViewButtonClickEvent(checked bool) {
+	q.zoomed = false
+	q.fitView()
+}
+
+// FollowInspectedClickEvent is triggered when the user clicks the FollowInspectedCheck. Following only moves the View,
+// so it isn't passed back to the main app.
+func (q *Qt) FollowInspectedClickEvent(checked bool) {
+	q.followInspected = checked
+}
+
+// FitParticlesClickEvent is triggered when the user clicks the FitParticlesCheck. It undoes any zooming and panning,
+// fitting the View to the particles (or again to the whole environment). Like following, it only moves the View, so it
+// isn't passed back to the main app.
+func (q *Qt) FitParticlesClickEvent(checked bool) {
+	q.fitParticles = checked
+	if checked {
+		physics.RLockParticles()
+		q.updateParticleBounds()
+		physics.RUnlockParticles()
+	}
+	q.zoomed = false
+	q.fitView()
+}
+
 // resizeEvent is triggered when the window (and therefore View) is resized. It scales View such that Scene will
-// fit in it.
+// fit in it (see fitView).
+// If the user has zoomed the View (see viewWheelEvent), the zoom is kept instead.
 func (q *Qt) resizeEvent(e *gui.QResizeEvent) {
-	//This doesn't control what's included in the scene or whether scene items are cut off (they're not) - it makes the
-	// Scene fit in the View (scales it) so that the View doesn't have scrollbars to move around the Scene.
-	q.View.FitInView(q.Scene.ItemsBoundingRect(), core.Qt__KeepAspectRatio)
+	if q.zoomed {
+		return
+	}
+	q.fitView()
+}
+
+// fitView scales the View so that the whole environment fits in it, as large as possible with its aspect ratio kept
+// (so a square environment stays square), and centers the environment in it. The scale is computed from the size of
+// the View's viewport (the area the Scene is drawn in, within the View's frame) and the environment size, rather than
+// using FitInView, which leaves a margin.
+// If fitParticles is set, the View is instead fit to the particles' bounding box as last drawn (see particleBounds),
+// plus fitParticlesMargin, within the environment.
+func (q *Qt) fitView() {
+	viewport := q.View.Viewport()
+	w, h := float64(viewport.Width()), float64(viewport.Height())
+	x, y, envW, envH := 0.0, 0.0, float64(q.EnvironmentWidth), float64(q.EnvironmentHeight)
+	if w <= 0 || h <= 0 || envW <= 0 || envH <= 0 {
+		return
+	}
+	if b := q.particleBounds; q.fitParticles && b[2] > b[0] && b[3] > b[1] {
+		x, y = math.Max(b[0]-fitParticlesMargin, 0), math.Max(b[1]-fitParticlesMargin, 0)
+		envW, envH = math.Min(b[2]+fitParticlesMargin, envW)-x, math.Min(b[3]+fitParticlesMargin, envH)-y
+	}
+	scale := math.Min(w/envW, h/envH)
+	q.View.ResetTransform()
+	q.View.Scale(scale, scale)
+	q.View.CenterOn2(x+envW/2, y+envH/2)
 }
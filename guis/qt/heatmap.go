@@ -0,0 +1,104 @@
+package qt
+
+import (
+	"math"
+
+	"GoGoGadgetGravity/physics"
+)
+
+// heatmapCellSize is the size (in environment units) of the square grid cells particle mass is binned into when drawing
+// the density heatmap.
+const heatmapCellSize = 10
+
+// heatmapColors are the colors the (normalized) density is mapped to, evenly spaced from the lowest density (just
+// above zero) to the highest.
+var heatmapColors = [...][3]uint8{{0, 0, 160}, {0, 160, 255}, {0, 220, 80}, {255, 230, 0}, {255, 40, 0}}
+
+// drawDensityHeatmap draws the particles as a density field (rather than as circles): their mass is binned into a grid
+// of heatmapCellSize cells, normalized by the densest cell, and each pixel is colored by the density interpolated from
+// the cells around it.
+func (q *Qt) drawDensityHeatmap(particles []*physics.Particle) {
+	cols := int(math.Ceil(float64(q.EnvironmentWidth) / heatmapCellSize))
+	rows := int(math.Ceil(float64(q.EnvironmentHeight) / heatmapCellSize))
+	grid := make([]float64, cols*rows)
+
+	// Each particle's mass is split between the four cells (centers) nearest it, so the field moves smoothly with the
+	// particles rather than jumping from cell to cell
+	for _, p := range particles {
+		x, y := q.drawPosition(p.Position())
+		gx, gy := x/heatmapCellSize-0.5, y/heatmapCellSize-0.5
+		x0, y0 := int(math.Floor(gx)), int(math.Floor(gy))
+		fx, fy := gx-float64(x0), gy-float64(y0)
+		q.addHeat(grid, cols, rows, x0, y0, p.Mass()*(1-fx)*(1-fy))
+		q.addHeat(grid, cols, rows, x0+1, y0, p.Mass()*fx*(1-fy))
+		q.addHeat(grid, cols, rows, x0, y0+1, p.Mass()*(1-fx)*fy)
+		q.addHeat(grid, cols, rows, x0+1, y0+1, p.Mass()*fx*fy)
+	}
+
+	var maxHeat float64
+	for _, h := range grid {
+		maxHeat = math.Max(maxHeat, h)
+	}
+	if maxHeat == 0 {
+		return
+	}
+
+	// Pixels are drawn in Canvas coordinates, each taking the (bilinearly) interpolated density at its center
+	for y := 0; y < q.canvasHeight; y++ {
+		gy := (float64(y)+0.5)/q.RenderScale/heatmapCellSize - 0.5
+		y0 := int(math.Floor(gy))
+		fy := gy - float64(y0)
+		for x := 0; x < q.canvasWidth; x++ {
+			gx := (float64(x)+0.5)/q.RenderScale/heatmapCellSize - 0.5
+			x0 := int(math.Floor(gx))
+			fx := gx - float64(x0)
+			h := q.heatAt(grid, cols, rows, x0, y0)*(1-fx)*(1-fy) + q.heatAt(grid, cols, rows, x0+1, y0)*fx*(1-fy) +
+				q.heatAt(grid, cols, rows, x0, y0+1)*(1-fx)*fy + q.heatAt(grid, cols, rows, x0+1, y0+1)*fx*fy
+			if h <= 0 {
+				continue
+			}
+			// The square root keeps sparse regions visible next to dense clusters
+			r, g, b, a := heatmapColor(math.Sqrt(h / maxHeat))
+			q.setPixel(x, y, r, g, b, a)
+		}
+	}
+}
+
+// addHeat adds mass to the heatmap grid cell (x, y). Cells beyond an edge are wrapped around if the environment wraps,
+// and otherwise ignored.
+func (q *Qt) addHeat(grid []float64, cols, rows, x, y int, mass float64) {
+	if x, y, ok := q.heatmapCell(cols, rows, x, y); ok {
+		grid[y*cols+x] += mass
+	}
+}
+
+// heatAt returns the mass binned into heatmap grid cell (x, y) (see addHeat).
+func (q *Qt) heatAt(grid []float64, cols, rows, x, y int) float64 {
+	if x, y, ok := q.heatmapCell(cols, rows, x, y); ok {
+		return grid[y*cols+x]
+	}
+	return 0
+}
+
+// heatmapCell returns the heatmap grid cell at (x, y), wrapped around if the environment wraps, and whether it is in
+// the grid.
+func (q *Qt) heatmapCell(cols, rows, x, y int) (int, int, bool) {
+	if q.wrapBoundary {
+		x, y = (x%cols+cols)%cols, (y%rows+rows)%rows
+	}
+	return x, y, x >= 0 && y >= 0 && x < cols && y < rows
+}
+
+// heatmapColor maps a normalized density v (0 to 1) to a color, interpolated between the heatmapColors. The alpha rises
+// with the density, so the lowest densities fade into the background.
+func heatmapColor(v float64) (r, g, b, a uint8) {
+	v = math.Max(0, math.Min(v, 1))
+	pos := v * float64(len(heatmapColors)-1)
+	i := int(math.Min(math.Floor(pos), float64(len(heatmapColors)-2)))
+	t := pos - float64(i)
+	lerp := func(c0, c1 uint8) uint8 {
+		return uint8(float64(c0) + (float64(c1)-float64(c0))*t)
+	}
+	lo, hi := heatmapColors[i], heatmapColors[i+1]
+	return lerp(lo[0], hi[0]), lerp(lo[1], hi[1]), lerp(lo[2], hi[2]), uint8(64 + 191*math.Min(v*4, 1))
+}
@@ -0,0 +1,58 @@
+package qt
+
+import (
+	"fmt"
+
+	"GoGoGadgetGravity/physics"
+)
+
+// inspectedGoneStatusTime is the time (ms) the status text noting the inspected particle is gone is displayed for.
+const inspectedGoneStatusTime = 3000
+
+// SetInspectedParticle implements guis.GUIEnabler.SetInspectedParticle
+func (q *Qt) SetInspectedParticle(p *physics.Particle) {
+	q.inspected = p
+	// The count replaces the details of the particle which was inspected when the particles are next drawn
+	if p == nil && q.statusPriority == statusInspect {
+		q.statusPriority = statusCount
+	}
+}
+
+// updateInspection displays the current details of the inspected particle (see SetInspectedParticle), if any, in the
+// statusbar: its position, velocity, mass, charges, and the dominant force on it (see physics.DominantForce). If
+// following it, the View is centered on it. If it's no longer among the particles (it has merged into another, or the
+// particles have been replaced), inspection stops, with a note in the statusbar.
+func (q *Qt) updateInspection(particles []*physics.Particle) {
+	if q.inspected == nil {
+		return
+	}
+	p := q.inspected
+	if !containsParticle(particles, p) {
+		q.SetInspectedParticle(nil)
+		q.showStatus(fmt.Sprintf("Particle #%d is gone (merged or removed); no longer inspecting it", p.ID()),
+			statusNotice, inspectedGoneStatusTime)
+		return
+	}
+
+	pos, vel := p.Position(), p.Velocity()
+	force, magnitude := physics.DominantForce(p)
+	q.showStatus(fmt.Sprintf("Particle #%d: Position: (%.1f, %.1f); Velocity: (%.3g, %.3g); Mass: %.4g; "+
+		"Close Charge: %.3g; Far Charge: %.3g; Dominant Force: %s (%.4g)", p.ID(), pos[0], pos[1], vel[0], vel[1],
+		p.Mass(), p.CloseCharge(), p.FarCharge(), force, magnitude), statusInspect, 0)
+
+	if q.followInspected {
+		// The View is centered on where the particle is drawn (which is shifted in the center of mass frame)
+		x, y := q.drawPosition(pos)
+		q.View.CenterOn2(x, y)
+	}
+}
+
+// containsParticle determines whether Particle p is among particles.
+func containsParticle(particles []*physics.Particle, p *physics.Particle) bool {
+	for _, o := range particles {
+		if o == p {
+			return true
+		}
+	}
+	return false
+}
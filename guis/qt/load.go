@@ -6,6 +6,7 @@ import (
 	"math"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
@@ -14,6 +15,7 @@ import (
 	"GoGoGadgetGravity/guis"
 	eWidgets "GoGoGadgetGravity/guis/qt/enhanced_widgets"
 	"GoGoGadgetGravity/physics"
+	"GoGoGadgetGravity/state"
 )
 
 // Qt is the struct containing GUI control handles and state data
@@ -24,12 +26,17 @@ type Qt struct {
 	// added to the scene, but the way this is implemented, it contains only Pixmap.
 	Scene *widgets.QGraphicsScene
 	// Pixmap is the pixel-array image where the particles are drawn. The "pixels" that can be individually addressed
-	// are determined by the EnvironmentSize. Each "pixel" may be drawn on the screen as multiple pixels, or less than
-	// one pixel, depending on the size of the window and therefore the size of the View, Scene, and this object.
+	// are determined by the EnvironmentWidth and EnvironmentHeight (scaled by RenderScale). Each "pixel" may be drawn
+	// on the screen as multiple pixels, or less than one pixel, depending on the size of the window and therefore the
+	// size of the View, Scene, and this object.
 	// It is created from the Canvas.
 	Pixmap *widgets.QGraphicsPixmapItem
 	// statusbar is the status text control at the bottom of the window which is updated with the SetStatusText method.
 	statusbar *widgets.QStatusBar
+	// statusPriority is the priority of the current status text, which is kept (unless replaced by text of the same or
+	// higher priority) until statusUntil, or indefinitely if statusUntil is zero (see showStatus).
+	statusPriority statusPriority
+	statusUntil    time.Time
 
 	// GridLayout is the main window layout.
 	GridLayout *widgets.QGridLayout
@@ -43,60 +50,285 @@ type Qt struct {
 	SaveStateButton *widgets.QPushButton
 	// LoadStateButton is the button which the user clicks to load the current simulation state from file
 	LoadStateButton *widgets.QPushButton
+	// SaveSettingsButton is the button which the user clicks to save the current settings (without the particles) to
+	// file
+	SaveSettingsButton *widgets.QPushButton
+	// LoadSettingsButton is the button which the user clicks to apply settings from file to the current simulation
+	LoadSettingsButton *widgets.QPushButton
+	// ResetSettingsButton is the button which the user clicks to restore the physics settings to their defaults
+	ResetSettingsButton *widgets.QPushButton
+	// PresetCombo is the dropdown the user selects a built-in scenario to load from (see physics.Presets). Its first
+	// item is a prompt rather than a preset.
+	PresetCombo *widgets.QComboBox
+	// UndoButton is the button which the user clicks to undo the most recent change to the settings or particles
+	UndoButton *widgets.QPushButton
+	// RedoButton is the button which the user clicks to redo the most recently undone change
+	RedoButton *widgets.QPushButton
+	// ResetViewButton is the button which the user clicks to undo any zooming/panning of the View
+	ResetViewButton *widgets.QPushButton
+	// FollowInspectedCheck is the checkbox the user (un)checks to indicate whether to keep the View centered on the
+	// inspected particle (see SetInspectedParticle).
+	FollowInspectedCheck *widgets.QCheckBox
+	// FitParticlesCheck is the checkbox the user (un)checks to indicate whether to fit the View to the particles'
+	// bounding box rather than the whole environment.
+	FitParticlesCheck *widgets.QCheckBox
 	// ResetButton is the button which the user clicks to revert particles to their original (generated/loaded) state
 	ResetButton *widgets.QPushButton
+	// ZeroVelocitiesButton is the button which the user clicks to stop all the particles (set their velocities to zero)
+	ZeroVelocitiesButton *widgets.QPushButton
 	// RegenButton is the button which the user clicks to generate a new set of particles
 	RegenButton *widgets.QPushButton
 	// PauseButton is the button which the user clicks to pause and resume the simulation
 	PauseButton *widgets.QPushButton
+	// StepButton is the button which the user clicks to advance the (paused) simulation by a single update
+	StepButton *widgets.QPushButton
+	// RecordButton is the button which the user clicks to start and stop recording the drawn frames
+	RecordButton *widgets.QPushButton
+	// TrajectoryButton is the button which the user clicks to start and stop recording the particles' trajectories
+	TrajectoryButton *widgets.QPushButton
+	// ExportGenealogyButton is the button which the user clicks to export the particles' genealogy (the mergers they
+	// formed from) to file
+	ExportGenealogyButton *widgets.QPushButton
+	// ExportSVGButton is the button which the user clicks to export the current view as an SVG image
+	ExportSVGButton *widgets.QPushButton
 
 	// Canvas is used to do pixel work on our Scene. It's bg is transparent. Like everything in the Scene, the
 	// visibility of non-transparent pixels will depend on when the Canvas (as a whole) was updated vs when Items in the
 	// Scene, if any, were updated.
 	Canvas *gui.QImage
-	// tempImage is used to go between Canvas & a temporary file (yes, file, because I'm dumb and can't sort out the
-	// back-buffer), so we can do quick work w/ the canvas (Canvas.SetPixel, e.g., is horrifically slow)
+	// tempImage is the back-buffer drawn on in im2qim mode and then copied to the Canvas, so we can do quick work w/ the
+	// canvas (Canvas.SetPixel, e.g., is horrifically slow)
 	tempImage *image.NRGBA
-	// imgLock is used to ensure thread-sfe access of tempImage
+	// imgLock is used to ensure thread-sfe access of tempImage (and frame)
 	imgLock sync.Mutex
-	// im2qim indicates whether the im2qim mode (Canvas <-> file <-> standard library image) is currently active,
+	// frame is the most recently completed tempImage (see StopIm2Qim and Snapshot). A new tempImage is created for each
+	// frame, so it isn't drawn on again.
+	frame *image.NRGBA
+	// im2qim indicates whether the im2qim mode (Canvas <-> standard library image) is currently active,
 	// as set by StartIm2Qim / StopIm2Qim.
 	im2qim bool
+	// recorder captures the drawn frames (see StopIm2Qim) while recording, and is nil otherwise.
+	recorder *recorder
+	// recorderLock is used to ensure thread-safe access of recorder (frames are drawn from the main app's physics
+	// loop, but recording is started/stopped by the GUI).
+	recorderLock sync.Mutex
+	// recordingTrajectory indicates whether the particles' trajectories are being recorded (see
+	// TrajectoryButtonClickEvent).
+	recordingTrajectory bool
 
 	//NoPen					*gui.QPen
 	//TestEllipse			*widgets.QGraphicsEllipseItem
 
+	// GravityOnlyCheck is the checkbox the user (un)checks to indicate whether the charge forces should be suppressed
+	// (leaving only gravity)
+	GravityOnlyCheck *widgets.QCheckBox
+	// EnableGravityCheck is the checkbox the user (un)checks to indicate whether the gravity force should act
+	EnableGravityCheck *widgets.QCheckBox
+	// EnableCloseChargeCheck is the checkbox the user (un)checks to indicate whether the close charge force should act
+	EnableCloseChargeCheck *widgets.QCheckBox
+	// SpeciesMatrixButton is the button which the user clicks to edit the species interaction matrix (see
+	// editSpeciesMatrix)
+	SpeciesMatrixButton *widgets.QPushButton
+	// EnableFarChargeCheck is the checkbox the user (un)checks to indicate whether the far charge force should act
+	EnableFarChargeCheck *widgets.QCheckBox
 	// AllowMergeCheck is the checkbox the user (un)checks to indicate whether particle mergers should be enabled
 	AllowMergeCheck *widgets.QCheckBox
+	// AllowFissionCheck is the checkbox the user (un)checks to indicate whether particles above the maximum mass should
+	// split
+	AllowFissionCheck *widgets.QCheckBox
+	// QuadraticDragCheck is the checkbox the user (un)checks to indicate whether drag is proportional to the square of
+	// particle speeds (rather than their speeds).
+	QuadraticDragCheck *widgets.QCheckBox
 	// WallBounceCheck is the checkbox the user (un)checks to indicate whether particles bounce off the "walls"
 	// (environment bounds).
 	WallBounceCheck *widgets.QCheckBox
+	// WrapBoundaryCheck is the checkbox the user (un)checks to indicate whether the environment wraps around (particles
+	// crossing an edge reappear on the opposite side).
+	WrapBoundaryCheck *widgets.QCheckBox
 	// HistoryTrailCheck is the checkbox the user (un)checks to indicate whether to track&display particle position
 	// history trails.
 	HistoryTrailCheck *widgets.QCheckBox
-
-	// EnvironmentSize is kept in sync with state.Data.PhysicsEngine.EnvironmentSize and is used to (re)size the canvas,
-	// determine whether pixels are in bounds when drawing particles, etc.
-	EnvironmentSize int
+	// RegenWhileDraggingCheck is the checkbox the user (un)checks to indicate whether particles are regenerated
+	// continuously while the generation sliders (see generationSliders) are dragged, rather than once on release.
+	RegenWhileDraggingCheck *widgets.QCheckBox
+	// ColorSchemeCombo is the dropdown the user selects the scheme used to color the particles from (see
+	// physics.ColorSchemes).
+	ColorSchemeCombo *widgets.QComboBox
+	// ChargeMergeRuleCombo is the dropdown the user selects the way the charges of merging particles are combined from
+	// (see physics.ChargeMergeRule).
+	ChargeMergeRuleCombo *widgets.QComboBox
+	// InitialVelocityModeCombo is the dropdown the user selects the way generated particle velocities are initialized
+	// from (the index is the state.InitialVelocityMode).
+	InitialVelocityModeCombo *widgets.QComboBox
+	// ChargeDistributionCombo is the dropdown the user selects the distribution generated particle close charges are
+	// drawn from (the index is the state.ChargeDistribution).
+	ChargeDistributionCombo *widgets.QComboBox
+	// TrailStyleCombo is the dropdown the user selects the style particle position history trails are drawn in from
+	// (the index is the state.TrailStyle).
+	TrailStyleCombo *widgets.QComboBox
+	// TrailColoringCombo is the dropdown the user selects the way particle position history trails are colored from
+	// (the index is the state.TrailColoring).
+	TrailColoringCombo *widgets.QComboBox
+	// HistoryPrefillCombo is the dropdown the user selects the way empty position histories are pre-filled from (the
+	// index is the physics.HistoryPrefill).
+	HistoryPrefillCombo *widgets.QComboBox
+	// PauseOnMergeCheck is the checkbox the user (un)checks to indicate whether the simulation automatically pauses
+	// when particles merge.
+	PauseOnMergeCheck *widgets.QCheckBox
+	// AutoSlowdownCheck is the checkbox the user (un)checks to indicate whether the physics loop speed is
+	// automatically slowed when the simulation can't keep up with it.
+	AutoSlowdownCheck *widgets.QCheckBox
+	// PauseOnSpeedCheck is the checkbox the user (un)checks to indicate whether the simulation automatically pauses
+	// when a particle's speed exceeds the pause speed threshold.
+	PauseOnSpeedCheck *widgets.QCheckBox
+	// ShowVelocityVectorsCheck is the checkbox the user (un)checks to indicate whether to draw velocity vector arrows
+	// over the particles.
+	ShowVelocityVectorsCheck *widgets.QCheckBox
+	// ShowParticleIDsCheck is the checkbox the user (un)checks to indicate whether to draw each particle's ID next to
+	// it.
+	ShowParticleIDsCheck *widgets.QCheckBox
+	// DensityHeatmapCheck is the checkbox the user (un)checks to indicate whether to draw the particles as a density
+	// heatmap rather than as circles.
+	DensityHeatmapCheck *widgets.QCheckBox
+	// CenterOfMassFrameCheck is the checkbox the user (un)checks to indicate whether to draw the particles in their
+	// center of mass frame.
+	CenterOfMassFrameCheck *widgets.QCheckBox
+	// SmoothRenderingCheck is the checkbox the user (un)checks to indicate whether to draw the particles as smooth
+	// (anti-aliased) circles.
+	SmoothRenderingCheck *widgets.QCheckBox
+	// BackgroundColorButton and WallColorButton are the buttons the user clicks to pick the color the environment is
+	// filled with and the color of its walls. Each is filled with its current color.
+	BackgroundColorButton, WallColorButton *widgets.QPushButton
+	// RecordGIFCheck is the checkbox the user (un)checks to indicate whether recordings are assembled into an animated
+	// GIF (rather than written as PNG frames).
+	RecordGIFCheck *widgets.QCheckBox
+
+	// wrapBoundary is kept in sync with state.Data.PhysicsEngine.WrapBoundary (and WallBounce) and indicates whether
+	// particles near the edges need to also be drawn on the opposite side.
+	wrapBoundary bool
+	// drawRadiusScale is kept in sync with state.Data.DrawRadiusScale and is the multiplier applied to particle radii
+	// when drawing them.
+	drawRadiusScale float64
+	// minDrawRadius is kept in sync with state.Data.MinDrawRadius and is the smallest radius particles are drawn with.
+	minDrawRadius int
+	// showVelocityVectors is kept in sync with state.Data.ShowVelocityVectors and indicates whether velocity vector
+	// arrows are drawn over the particles.
+	showVelocityVectors bool
+	// showParticleIDs is kept in sync with state.Data.ShowParticleIDs and indicates whether particle IDs are drawn next
+	// to the particles.
+	showParticleIDs bool
+	// densityHeatmap is kept in sync with state.Data.DensityHeatmap and indicates whether the particles are drawn as a
+	// density heatmap (see drawDensityHeatmap) rather than as circles.
+	densityHeatmap bool
+	// centerOfMassFrame is kept in sync with state.Data.CenterOfMassFrame and indicates whether the particles are drawn
+	// in their center of mass frame (see DrawParticles).
+	centerOfMassFrame bool
+	// smoothRendering is kept in sync with state.Data.SmoothRendering and indicates whether the particles are drawn as
+	// smooth, anti-aliased circles (see drawSmoothCircle).
+	smoothRendering bool
+	// backgroundColor and wallColor are kept in sync with state.Data.BackgroundColor and WallColor, and are the colors
+	// the environment is filled with (see StartIm2Qim) and its walls are drawn in (see DrawViewBox).
+	backgroundColor, wallColor state.Color
+	// speciesMatrix is kept in sync with physics.EngineData.SpeciesMatrix, and is the matrix the species matrix editor
+	// (see editSpeciesMatrix) starts at.
+	speciesMatrix [][]float64
+	// frameOffset and frameVelocity are the shift applied to drawn positions, and the velocity subtracted from drawn
+	// velocity vectors, when drawing in the center of mass frame (both are zero otherwise). They're set by
+	// DrawParticles.
+	frameOffset, frameVelocity [2]float64
+	// trailStyle is kept in sync with state.Data.TrailStyle and is the style particle position history trails are
+	// drawn in.
+	trailStyle state.TrailStyle
+	// trailColoring is kept in sync with state.Data.TrailColoring and is the way particle position history trails are
+	// colored (see trailColor).
+	trailColoring state.TrailColoring
+
+	// EnvironmentWidth and EnvironmentHeight are kept in sync with state.Data.PhysicsEngine.EnvironmentWidth and
+	// EnvironmentHeight and are used to (re)size the canvas, determine whether pixels are in bounds when drawing
+	// particles, etc.
+	EnvironmentWidth, EnvironmentHeight int
+	// RenderScale is the size of the Canvas relative to the environment, in (0, 1]. Values below 1 draw particles on a
+	// smaller Canvas (which the View stretches to the same on-screen size), trading precision for drawing speed in
+	// large environments. If unset (or out of range), 1 is used.
+	RenderScale float64
+	// canvasWidth and canvasHeight are the size of the Canvas in pixels (the environment size scaled by RenderScale),
+	// and are used to determine whether pixels are in bounds when drawing.
+	canvasWidth, canvasHeight int
 
 	// loadingState indicates whether the simulation state is currently being loaded. Primarily used to disable
 	// triggering connected main app event handlers during GUI control updates.
 	loadingState bool
 
+	// zoomed indicates whether the user has zoomed the View (in which case it isn't refit to the Scene when resized,
+	// until the view is reset).
+	zoomed bool
+	// pressX and pressY are the (View) position at which the left mouse button was last pressed, used to distinguish
+	// clicks from drags.
+	pressX, pressY int
+	// dragging indicates whether the user is dragging grabbed particles (see viewMousePressEvent), and dragX and dragY
+	// are the (environment) position they were last dragged to.
+	dragging     bool
+	dragX, dragY float64
+	// attracting indicates whether the user is holding the middle mouse button to attract (or repel) particles (see
+	// viewMousePressEvent).
+	attracting bool
+	// selected holds the particles the user has selected to drag (see SetSelectedParticles), which are highlighted.
+	selected map[*physics.Particle]struct{}
+	// inspected is the particle the user has clicked to inspect (see SetInspectedParticle), or nil for none, and
+	// followInspected (kept in sync with the FollowInspectedCheck) indicates whether the View is kept centered on it.
+	inspected       *physics.Particle
+	followInspected bool
+	// fitParticles (kept in sync with the FitParticlesCheck) indicates whether the View is fit to the particles rather
+	// than the whole environment (see fitView), and particleBounds is their bounding box (see physics.BoundingBox) as
+	// last drawn (shifted in the center of mass frame): its minimum x and y, and maximum x and y.
+	fitParticles   bool
+	particleBounds [4]float64
+
 	// EventSystem holds the main app functions which have been connected to this GUI, which are triggered during GUI
 	// interactions
 	EventSystem EventSystemData
 }
 
+// generationSliders are the FormItems names of the sliders setting how particles are generated (changing which
+// regenerates the particles while paused).
+var generationSliders = []string{"Environment Width (units)", "Environment Height (units)", "Number of Particles",
+	"Average Mass", "Initial Speed", "Spawn Center X", "Spawn Center Y", "Spawn Radius (0 = Everywhere)"}
+
+// spawnCircle gets the center and radius of the region particles are generated within, as shown by the spawn sliders:
+// those of the first of regions if it's a circle, and otherwise a radius of 0 (generating particles everywhere) at the
+// center of the environment.
+func (q *Qt) spawnCircle(regions []state.GenerationRegion) (x, y, radius float64) {
+	if len(regions) > 0 && regions[0].Shape == state.RegionCircle {
+		return regions[0].X, regions[0].Y, regions[0].Radius
+	}
+	return float64(q.EnvironmentWidth) / 2, float64(q.EnvironmentHeight) / 2, 0
+}
+
+// newCanvas (re)creates the Canvas and the Pixmap showing it, sized to the environment scaled by RenderScale. The
+// Pixmap is scaled back up, so Scene coordinates remain environment coordinates whatever the RenderScale.
+func (q *Qt) newCanvas() {
+	q.canvasWidth = int(math.Ceil(float64(q.EnvironmentWidth) * q.RenderScale))
+	q.canvasHeight = int(math.Ceil(float64(q.EnvironmentHeight) * q.RenderScale))
+	q.Canvas = gui.NewQImage().ConvertToFormat(gui.QImage__Format_ARGB32, core.Qt__AutoColor).
+		Scaled2(q.canvasWidth, q.canvasHeight, core.Qt__IgnoreAspectRatio, core.Qt__FastTransformation)
+	q.Pixmap = widgets.NewQGraphicsPixmapItem2(gui.NewQPixmap().FromImage(q.Canvas, 0), nil)
+	q.Pixmap.SetScale(1 / q.RenderScale)
+}
+
 // CreateGUI implements guis.GUIEnabler.CreateGUI.
 func (q *Qt) CreateGUI(initialValues guis.GUIInitializationData) {
-	q.EnvironmentSize = initialValues.PhysicsEngine.EnvironmentSize
+	q.EnvironmentWidth = initialValues.PhysicsEngine.EnvironmentWidth
+	q.EnvironmentHeight = initialValues.PhysicsEngine.EnvironmentHeight
+	if q.RenderScale <= 0 || q.RenderScale > 1 {
+		q.RenderScale = 1
+	}
 
 	widgets.NewQApplication(len(os.Args), os.Args)
 
 	// Main Window
 	var window = widgets.NewQMainWindow(nil, 0)
 	window.SetWindowTitle("GoGo Gadget Gravity")
+	window.ConnectCloseEvent(q.windowCloseEvent)
 	// Minimum / initial size
 	window.SetMinimumSize2(initialValues.WinMinWidth, initialValues.WinMinHeight)
 
@@ -110,6 +342,17 @@ func (q *Qt) CreateGUI(initialValues guis.GUIInitializationData) {
 
 	// When window is resized, View will be resized, and we need to scale View so that Scene fits
 	q.View.ConnectResizeEvent(q.resizeEvent)
+	// Clicking a particle shows its details, and right clicking it pins/unpins it. Dragging a particle (while paused)
+	// moves it, and otherwise dragging pans the View (once zoomed in). The mouse wheel zooms the View (around the
+	// cursor).
+	q.View.ConnectMousePressEvent(q.viewMousePressEvent)
+	q.View.ConnectMouseMoveEvent(q.viewMouseMoveEvent)
+	q.View.ConnectMouseReleaseEvent(q.viewMouseReleaseEvent)
+	q.View.ConnectWheelEvent(q.viewWheelEvent)
+	q.View.SetDragMode(widgets.QGraphicsView__ScrollHandDrag)
+	q.View.SetTransformationAnchor(widgets.QGraphicsView__AnchorUnderMouse)
+	q.View.SetHorizontalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	q.View.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
 
 	// mainWidget contains the primary window layout, GridLayout
 	mainWidget := widgets.NewQWidget(nil, 0)
@@ -150,13 +393,38 @@ func (q *Qt) CreateGUI(initialValues guis.GUIInitializationData) {
 	q.LoadStateButton = widgets.NewQPushButton2("Load State From File", nil)
 	q.LoadStateButton.ConnectClicked(q.LoadButtonClickEvent)
 	q.FormLayout.AddWidget(q.LoadStateButton)
+	q.SaveSettingsButton = widgets.NewQPushButton2("Save Settings To File", nil)
+	q.SaveSettingsButton.ConnectClicked(q.SaveSettingsButtonClickEvent)
+	q.FormLayout.AddWidget(q.SaveSettingsButton)
+	q.LoadSettingsButton = widgets.NewQPushButton2("Load Settings From File", nil)
+	q.LoadSettingsButton.ConnectClicked(q.LoadSettingsButtonClickEvent)
+	q.FormLayout.AddWidget(q.LoadSettingsButton)
+	q.ResetSettingsButton = widgets.NewQPushButton2("Reset Settings", nil)
+	q.ResetSettingsButton.ConnectClicked(q.ResetSettingsButtonClickEvent)
+	q.FormLayout.AddWidget(q.ResetSettingsButton)
+	q.PresetCombo = widgets.NewQComboBox(nil)
+	q.PresetCombo.AddItem("Select a Preset...", core.NewQVariant())
+	for _, p := range physics.Presets {
+		q.PresetCombo.AddItem(p.Name, core.NewQVariant())
+	}
+	q.PresetCombo.ConnectActivated(q.PresetComboActivatedEvent)
+	q.FormLayout.AddRow3("Load Preset", q.PresetCombo)
+	q.UndoButton = widgets.NewQPushButton2("Undo", nil)
+	q.UndoButton.ConnectClicked(q.UndoButtonClickEvent)
+	q.FormLayout.AddWidget(q.UndoButton)
+	q.RedoButton = widgets.NewQPushButton2("Redo", nil)
+	q.RedoButton.ConnectClicked(q.RedoButtonClickEvent)
+	q.FormLayout.AddWidget(q.RedoButton)
 	q.FormLayout.AddItem(widgets.NewQSpacerItem(0, 20, 1|4|8, 1|4))
-	q.FormItems["Environment Size (units*units)"] =
-		eWidgets.NewESlider(400, 2500, 191, q.EnvironmentSize, 1)
-	q.FormItems["Environment Size (units*units)"].(*eWidgets.ESlider).
-		ConnectValueChangedEvent(q.EnvironmentSizeSliderChangedEvent)
-	q.FormLayout.AddRow4("Environment Size (units*units)",
-		q.FormItems["Environment Size (units*units)"].AsEWidget().ParentLayout)
+	q.FormItems["Environment Width (units)"] = eWidgets.NewESlider(400, 2500, 191, q.EnvironmentWidth, 1)
+	q.FormItems["Environment Width (units)"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.EnvironmentWidthSliderChangedEvent)
+	q.FormLayout.AddRow4("Environment Width (units)", q.FormItems["Environment Width (units)"].AsEWidget().ParentLayout)
+	q.FormItems["Environment Height (units)"] = eWidgets.NewESlider(400, 2500, 191, q.EnvironmentHeight, 1)
+	q.FormItems["Environment Height (units)"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.EnvironmentHeightSliderChangedEvent)
+	q.FormLayout.AddRow4("Environment Height (units)",
+		q.FormItems["Environment Height (units)"].AsEWidget().ParentLayout)
 	q.FormItems["Number of Particles"] =
 		eWidgets.NewESlider(2, 1000, 91, initialValues.NumberOfParticles, 1)
 	q.FormItems["Number of Particles"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.NumParticlesSliderChangedEvent)
@@ -165,12 +433,52 @@ func (q *Qt) CreateGUI(initialValues guis.GUIInitializationData) {
 		eWidgets.NewESlider(15, 1500, 135, initialValues.AverageMass, 1)
 	q.FormItems["Average Mass"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.AverageMassSliderChangedEvent)
 	q.FormLayout.AddRow4("Average Mass", q.FormItems["Average Mass"].AsEWidget().ParentLayout)
+	q.InitialVelocityModeCombo = widgets.NewQComboBox(nil)
+	// Indexed by state.InitialVelocityMode
+	q.InitialVelocityModeCombo.AddItem("Zero", core.NewQVariant())
+	q.InitialVelocityModeCombo.AddItem("Thermal (Random)", core.NewQVariant())
+	q.InitialVelocityModeCombo.AddItem("Tangential (Rotating)", core.NewQVariant())
+	q.InitialVelocityModeCombo.SetCurrentIndex(int(initialValues.InitialVelocityMode))
+	q.InitialVelocityModeCombo.ConnectCurrentIndexChanged(q.InitialVelocityModeComboChangedEvent)
+	q.FormLayout.AddRow3("Initial Velocity", q.InitialVelocityModeCombo)
+	q.FormItems["Initial Speed"] = eWidgets.NewESlider(0, 100, 9, int(math.Round(initialValues.InitialSpeed/0.1)), 0.1)
+	q.FormItems["Initial Speed"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.InitialSpeedSliderChangedEvent)
+	q.FormLayout.AddRow4("Initial Speed", q.FormItems["Initial Speed"].AsEWidget().ParentLayout)
+	// The (circular) region particles are generated within
+	spawnX, spawnY, spawnRadius := q.spawnCircle(initialValues.GenerationRegions)
+	q.FormItems["Spawn Center X"] = eWidgets.NewESlider(0, 2500, 250, int(math.Round(spawnX)), 1)
+	q.FormItems["Spawn Center X"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.SpawnRegionSliderChangedEvent)
+	q.FormLayout.AddRow4("Spawn Center X", q.FormItems["Spawn Center X"].AsEWidget().ParentLayout)
+	q.FormItems["Spawn Center Y"] = eWidgets.NewESlider(0, 2500, 250, int(math.Round(spawnY)), 1)
+	q.FormItems["Spawn Center Y"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.SpawnRegionSliderChangedEvent)
+	q.FormLayout.AddRow4("Spawn Center Y", q.FormItems["Spawn Center Y"].AsEWidget().ParentLayout)
+	q.FormItems["Spawn Radius (0 = Everywhere)"] = eWidgets.NewESlider(0, 1250, 125, int(math.Round(spawnRadius)), 1)
+	q.FormItems["Spawn Radius (0 = Everywhere)"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.SpawnRegionSliderChangedEvent)
+	q.FormLayout.AddRow4("Spawn Radius (0 = Everywhere)",
+		q.FormItems["Spawn Radius (0 = Everywhere)"].AsEWidget().ParentLayout)
+	q.ChargeDistributionCombo = widgets.NewQComboBox(nil)
+	// Indexed by state.ChargeDistribution
+	q.ChargeDistributionCombo.AddItem("Uniform", core.NewQVariant())
+	q.ChargeDistributionCombo.AddItem("Bimodal (Strong)", core.NewQVariant())
+	q.ChargeDistributionCombo.AddItem("Normal (Weak)", core.NewQVariant())
+	q.ChargeDistributionCombo.SetCurrentIndex(int(initialValues.ChargeDistribution))
+	q.ChargeDistributionCombo.ConnectCurrentIndexChanged(q.ChargeDistributionComboChangedEvent)
+	q.FormLayout.AddRow3("Charge Distribution", q.ChargeDistributionCombo)
+	// Dragging these would otherwise regenerate the particles at every intermediate value
+	for _, name := range generationSliders {
+		q.FormItems[name].(*eWidgets.ESlider).Deferred = true
+	}
+	q.RegenWhileDraggingCheck = widgets.NewQCheckBox(nil)
+	q.RegenWhileDraggingCheck.ConnectClicked(q.RegenWhileDraggingClickEvent)
+	q.FormLayout.AddRow3("Regenerate While Dragging", q.RegenWhileDraggingCheck)
 	q.FormLayout.AddItem(widgets.NewQSpacerItem(0, 20, 1|4|8, 1|4))
 	q.RegenButton = widgets.NewQPushButton2("Generate New Particles", nil)
 	q.RegenButton.ConnectClicked(q.RegenButtonClickEvent)
 	q.FormLayout.AddWidget(q.RegenButton)
 	q.FormLayout.AddItem(widgets.NewQSpacerItem(0, 40, 1|4|8, 1|4))
-	q.FormItems["Gravity Strength"] = eWidgets.NewESlider(0, 5000, 455,
+	// Negative strengths make gravity repulsive
+	q.FormItems["Gravity Strength"] = eWidgets.NewESlider(-5000, 5000, 909,
 		int(initialValues.PhysicsEngine.GravityStrength/0.1), 0.1)
 	q.FormItems["Gravity Strength"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.GravityStrengthSliderChangedEvent)
 	q.FormLayout.AddRow4("Gravity Strength", q.FormItems["Gravity Strength"].AsEWidget().ParentLayout)
@@ -184,35 +492,281 @@ func (q *Qt) CreateGUI(initialValues guis.GUIInitializationData) {
 	q.FormItems["Far Charge Strength"].(*eWidgets.ESlider).
 		ConnectValueChangedEvent(q.FarChargeStrengthSliderChangedEvent)
 	q.FormLayout.AddRow4("Far Charge Strength", q.FormItems["Far Charge Strength"].AsEWidget().ParentLayout)
+	q.FormItems["Far Charge Exponent"] = eWidgets.NewESlider(-20, 20, 5,
+		int(math.Round(initialValues.PhysicsEngine.FarChargeExponent/0.1)), 0.1)
+	q.FormItems["Far Charge Exponent"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.FarChargeExponentSliderChangedEvent)
+	q.FormLayout.AddRow4("Far Charge Exponent", q.FormItems["Far Charge Exponent"].AsEWidget().ParentLayout)
+	q.FormItems["Far Charge Cutoff (0 = None)"] = eWidgets.NewESlider(0, 3500, 350,
+		int(math.Round(initialValues.PhysicsEngine.FarChargeCutoff)), 1)
+	q.FormItems["Far Charge Cutoff (0 = None)"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.FarChargeCutoffSliderChangedEvent)
+	q.FormLayout.AddRow4("Far Charge Cutoff (0 = None)",
+		q.FormItems["Far Charge Cutoff (0 = None)"].AsEWidget().ParentLayout)
+	q.EnableGravityCheck = widgets.NewQCheckBox(nil)
+	q.EnableGravityCheck.SetChecked(initialValues.PhysicsEngine.EnableGravity)
+	q.EnableGravityCheck.ConnectClicked(q.EnableGravityClickEvent)
+	q.FormLayout.AddRow3("Enable Gravity", q.EnableGravityCheck)
+	q.EnableCloseChargeCheck = widgets.NewQCheckBox(nil)
+	q.EnableCloseChargeCheck.SetChecked(initialValues.PhysicsEngine.EnableCloseCharge)
+	q.EnableCloseChargeCheck.ConnectClicked(q.EnableCloseChargeClickEvent)
+	q.FormLayout.AddRow3("Enable Close Charge", q.EnableCloseChargeCheck)
+	q.speciesMatrix = initialValues.PhysicsEngine.SpeciesMatrix
+	q.SpeciesMatrixButton = widgets.NewQPushButton2("Edit Species Matrix", nil)
+	q.SpeciesMatrixButton.ConnectClicked(q.SpeciesMatrixButtonClickEvent)
+	q.FormLayout.AddWidget(q.SpeciesMatrixButton)
+	q.EnableFarChargeCheck = widgets.NewQCheckBox(nil)
+	q.EnableFarChargeCheck.SetChecked(initialValues.PhysicsEngine.EnableFarCharge)
+	q.EnableFarChargeCheck.ConnectClicked(q.EnableFarChargeClickEvent)
+	q.FormLayout.AddRow3("Enable Far Charge", q.EnableFarChargeCheck)
+	q.GravityOnlyCheck = widgets.NewQCheckBox(nil)
+	q.GravityOnlyCheck.SetChecked(initialValues.PhysicsEngine.GravityOnly)
+	q.GravityOnlyCheck.ConnectClicked(q.GravityOnlyClickEvent)
+	q.FormLayout.AddRow3("Gravity Only", q.GravityOnlyCheck)
 	q.AllowMergeCheck = widgets.NewQCheckBox(nil)
 	q.AllowMergeCheck.SetChecked(initialValues.PhysicsEngine.AllowMerge)
 	q.AllowMergeCheck.ConnectClicked(q.AllowMergeClickEvent)
 	q.FormLayout.AddRow3("Particles Can Merge", q.AllowMergeCheck)
+	q.FormItems["Merge Mass Ratio"] = eWidgets.NewESlider(10, 100, 9,
+		int(math.Round(initialValues.PhysicsEngine.MergeMassRatioThreshold/0.1)), 0.1)
+	q.FormItems["Merge Mass Ratio"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.MergeMassRatioSliderChangedEvent)
+	q.FormLayout.AddRow4("Merge Mass Ratio", q.FormItems["Merge Mass Ratio"].AsEWidget().ParentLayout)
+	q.FormItems["Merge Close Charge Limit"] = eWidgets.NewESlider(0, 200, 20,
+		int(math.Round(initialValues.PhysicsEngine.MergeCloseChargeThreshold/0.01)), 0.01)
+	q.FormItems["Merge Close Charge Limit"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.MergeCloseChargeSliderChangedEvent)
+	q.FormLayout.AddRow4("Merge Close Charge Limit",
+		q.FormItems["Merge Close Charge Limit"].AsEWidget().ParentLayout)
+	// 0 means there's no limit
+	q.FormItems["Merge Capture Speed"] = eWidgets.NewESlider(0, 500, 49,
+		int(math.Round(initialValues.PhysicsEngine.CaptureSpeed/0.1)), 0.1)
+	q.FormItems["Merge Capture Speed"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.CaptureSpeedSliderChangedEvent)
+	q.FormLayout.AddRow4("Merge Capture Speed", q.FormItems["Merge Capture Speed"].AsEWidget().ParentLayout)
+	q.ChargeMergeRuleCombo = widgets.NewQComboBox(nil)
+	// Items in physics.ChargeMergeRule order
+	q.ChargeMergeRuleCombo.AddItem("Mass Weighted Average", core.NewQVariant())
+	q.ChargeMergeRuleCombo.AddItem("Sum (Clamped)", core.NewQVariant())
+	q.ChargeMergeRuleCombo.AddItem("Maximum", core.NewQVariant())
+	q.ChargeMergeRuleCombo.SetCurrentIndex(int(initialValues.PhysicsEngine.ChargeMergeRule))
+	q.ChargeMergeRuleCombo.ConnectCurrentIndexChanged(q.ChargeMergeRuleComboChangedEvent)
+	q.FormLayout.AddRow3("Merged Charges", q.ChargeMergeRuleCombo)
+	q.AllowFissionCheck = widgets.NewQCheckBox(nil)
+	q.AllowFissionCheck.SetChecked(initialValues.PhysicsEngine.AllowFission)
+	q.AllowFissionCheck.ConnectClicked(q.AllowFissionClickEvent)
+	q.FormLayout.AddRow3("Particles Can Split", q.AllowFissionCheck)
+	q.FormItems["Max Mass"] = eWidgets.NewESlider(10, 200, 19,
+		int(math.Round(initialValues.PhysicsEngine.MaxMass/100)), 100)
+	q.FormItems["Max Mass"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.MaxMassSliderChangedEvent)
+	q.FormLayout.AddRow4("Max Mass", q.FormItems["Max Mass"].AsEWidget().ParentLayout)
+	q.FormItems["Max Particles (0 = Unlimited)"] =
+		eWidgets.NewESlider(0, 5000, 500, initialValues.PhysicsEngine.MaxParticles, 1)
+	q.FormItems["Max Particles (0 = Unlimited)"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.MaxParticlesSliderChangedEvent)
+	q.FormLayout.AddRow4("Max Particles (0 = Unlimited)",
+		q.FormItems["Max Particles (0 = Unlimited)"].AsEWidget().ParentLayout)
+	q.FormItems["Bounce Separation Factor"] = eWidgets.NewESlider(100, 500, 40,
+		int(math.Round(initialValues.PhysicsEngine.BounceCompleteDistFactor/0.01)), 0.01)
+	q.FormItems["Bounce Separation Factor"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.BounceCompleteDistSliderChangedEvent)
+	q.FormLayout.AddRow4("Bounce Separation Factor",
+		q.FormItems["Bounce Separation Factor"].AsEWidget().ParentLayout)
+	q.FormItems["Restitution"] = eWidgets.NewESlider(0, 100, 10,
+		int(math.Round(initialValues.PhysicsEngine.Restitution/0.01)), 0.01)
+	q.FormItems["Restitution"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.RestitutionSliderChangedEvent)
+	q.FormLayout.AddRow4("Restitution", q.FormItems["Restitution"].AsEWidget().ParentLayout)
+	q.FormItems["Drag Coefficient"] = eWidgets.NewESlider(0, 100, 10,
+		int(math.Round(initialValues.PhysicsEngine.DragCoefficient/0.001)), 0.001)
+	q.FormItems["Drag Coefficient"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.DragCoefficientSliderChangedEvent)
+	q.FormLayout.AddRow4("Drag Coefficient", q.FormItems["Drag Coefficient"].AsEWidget().ParentLayout)
+	q.FormItems["Max Acceleration (0 = Unlimited)"] = eWidgets.NewESlider(0, 100, 10,
+		int(math.Round(initialValues.PhysicsEngine.MaxAcceleration/0.1)), 0.1)
+	q.FormItems["Max Acceleration (0 = Unlimited)"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.MaxAccelerationSliderChangedEvent)
+	q.FormLayout.AddRow4("Max Acceleration (0 = Unlimited)",
+		q.FormItems["Max Acceleration (0 = Unlimited)"].AsEWidget().ParentLayout)
+	q.QuadraticDragCheck = widgets.NewQCheckBox(nil)
+	q.QuadraticDragCheck.SetChecked(initialValues.PhysicsEngine.QuadraticDrag)
+	q.QuadraticDragCheck.ConnectClicked(q.QuadraticDragClickEvent)
+	q.FormLayout.AddRow3("Quadratic Drag", q.QuadraticDragCheck)
+	fieldStrength, fieldAngle := initialValues.PhysicsEngine.ExternalFieldPolar()
+	q.FormItems["External Field Strength"] = eWidgets.NewESlider(0, 100, 10, int(math.Round(fieldStrength/0.01)), 0.01)
+	q.FormItems["External Field Strength"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.ExternalFieldSliderChangedEvent)
+	q.FormLayout.AddRow4("External Field Strength",
+		q.FormItems["External Field Strength"].AsEWidget().ParentLayout)
+	q.FormItems["External Field Angle"] = eWidgets.NewESlider(0, 359, 45, int(math.Round(fieldAngle)), 1)
+	q.FormItems["External Field Angle"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.ExternalFieldSliderChangedEvent)
+	q.FormLayout.AddRow4("External Field Angle", q.FormItems["External Field Angle"].AsEWidget().ParentLayout)
+	q.FormItems["Frame Angular Velocity"] = eWidgets.NewESlider(-100, 100, 20,
+		int(math.Round(initialValues.PhysicsEngine.FrameAngularVelocity/0.0001)), 0.0001)
+	q.FormItems["Frame Angular Velocity"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.FrameAngularVelocitySliderChangedEvent)
+	q.FormLayout.AddRow4("Frame Angular Velocity", q.FormItems["Frame Angular Velocity"].AsEWidget().ParentLayout)
 	q.WallBounceCheck = widgets.NewQCheckBox(nil)
 	q.WallBounceCheck.SetChecked(initialValues.PhysicsEngine.WallBounce)
 	q.WallBounceCheck.ConnectClicked(q.WallBounceClickEvent)
 	q.FormLayout.AddRow3("Wall Bounce", q.WallBounceCheck)
+	q.WrapBoundaryCheck = widgets.NewQCheckBox(nil)
+	q.wrapBoundary = initialValues.PhysicsEngine.WrapBoundary && !initialValues.PhysicsEngine.WallBounce
+	q.WrapBoundaryCheck.SetChecked(q.wrapBoundary)
+	q.WrapBoundaryCheck.ConnectClicked(q.WrapBoundaryClickEvent)
+	q.FormLayout.AddRow3("Wrap Around Edges", q.WrapBoundaryCheck)
 	q.HistoryTrailCheck = widgets.NewQCheckBox(nil)
 	q.HistoryTrailCheck.ConnectClicked(q.HistoryTrailClickEvent)
-	q.HistoryTrailCheck.SetChecked(true)
+	q.HistoryTrailCheck.SetChecked(initialValues.HistoryTrail)
 	q.FormLayout.AddRow3("Show History Trail", q.HistoryTrailCheck)
 	q.FormItems["History Trail Length"] =
 		eWidgets.NewESlider(3, 100, 5, initialValues.HistoryLength, 1)
 	q.FormItems["History Trail Length"].(*eWidgets.ESlider).
 		ConnectValueChangedEvent(q.HistoryTrailLengthSliderChangedEvent)
 	q.FormLayout.AddRow4("History Trail Length", q.FormItems["History Trail Length"].AsEWidget().ParentLayout)
+	q.FormItems["History Trail Stride"] =
+		eWidgets.NewESlider(1, 20, 2, initialValues.HistoryStride, 1)
+	q.FormItems["History Trail Stride"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.HistoryTrailStrideSliderChangedEvent)
+	q.FormLayout.AddRow4("History Trail Stride", q.FormItems["History Trail Stride"].AsEWidget().ParentLayout)
+	q.TrailStyleCombo = widgets.NewQComboBox(nil)
+	// Indexed by state.TrailStyle
+	q.TrailStyleCombo.AddItem("Shrinking Circles", core.NewQVariant())
+	q.TrailStyleCombo.AddItem("Faded Lines", core.NewQVariant())
+	q.trailStyle = initialValues.TrailStyle
+	q.TrailStyleCombo.SetCurrentIndex(int(initialValues.TrailStyle))
+	q.TrailStyleCombo.ConnectCurrentIndexChanged(q.TrailStyleComboChangedEvent)
+	q.FormLayout.AddRow3("Trail Style", q.TrailStyleCombo)
+	q.TrailColoringCombo = widgets.NewQComboBox(nil)
+	// Indexed by state.TrailColoring
+	q.TrailColoringCombo.AddItem("Particle Color", core.NewQVariant())
+	q.TrailColoringCombo.AddItem("Age Gradient", core.NewQVariant())
+	q.trailColoring = initialValues.TrailColoring
+	q.TrailColoringCombo.SetCurrentIndex(int(initialValues.TrailColoring))
+	q.TrailColoringCombo.ConnectCurrentIndexChanged(q.TrailColoringComboChangedEvent)
+	q.FormLayout.AddRow3("Trail Color", q.TrailColoringCombo)
+	q.HistoryPrefillCombo = widgets.NewQComboBox(nil)
+	// Indexed by physics.HistoryPrefill
+	q.HistoryPrefillCombo.AddItem("None (Grow)", core.NewQVariant())
+	q.HistoryPrefillCombo.AddItem("Current Position", core.NewQVariant())
+	q.HistoryPrefillCombo.AddItem("Back-Extrapolated", core.NewQVariant())
+	q.HistoryPrefillCombo.SetCurrentIndex(int(initialValues.HistoryPrefill))
+	q.HistoryPrefillCombo.ConnectCurrentIndexChanged(q.HistoryPrefillComboChangedEvent)
+	q.FormLayout.AddRow3("Trail Pre-fill", q.HistoryPrefillCombo)
+	q.ColorSchemeCombo = widgets.NewQComboBox(nil)
+	for _, s := range physics.ColorSchemes {
+		q.ColorSchemeCombo.AddItem(s.Name, core.NewQVariant())
+	}
+	q.ColorSchemeCombo.SetCurrentIndex(int(initialValues.PhysicsEngine.ColorScheme))
+	q.ColorSchemeCombo.ConnectCurrentIndexChanged(q.ColorSchemeComboChangedEvent)
+	q.FormLayout.AddRow3("Color Scheme", q.ColorSchemeCombo)
+	q.drawRadiusScale = initialValues.DrawRadiusScale
+	q.FormItems["Particle Draw Size"] = eWidgets.NewESlider(5, 80, 15,
+		int(math.Round(initialValues.DrawRadiusScale/0.05)), 0.05)
+	q.FormItems["Particle Draw Size"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.DrawRadiusScaleSliderChangedEvent)
+	q.FormLayout.AddRow4("Particle Draw Size", q.FormItems["Particle Draw Size"].AsEWidget().ParentLayout)
+	q.minDrawRadius = initialValues.MinDrawRadius
+	q.FormItems["Min Particle Draw Radius"] = eWidgets.NewESlider(1, 20, 2, initialValues.MinDrawRadius, 1)
+	q.FormItems["Min Particle Draw Radius"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.MinDrawRadiusSliderChangedEvent)
+	q.FormLayout.AddRow4("Min Particle Draw Radius", q.FormItems["Min Particle Draw Radius"].AsEWidget().ParentLayout)
+	q.showVelocityVectors = initialValues.ShowVelocityVectors
+	q.ShowVelocityVectorsCheck = widgets.NewQCheckBox(nil)
+	q.ShowVelocityVectorsCheck.SetChecked(initialValues.ShowVelocityVectors)
+	q.ShowVelocityVectorsCheck.ConnectClicked(q.ShowVelocityVectorsClickEvent)
+	q.FormLayout.AddRow3("Show Velocity Vectors", q.ShowVelocityVectorsCheck)
+	q.showParticleIDs = initialValues.ShowParticleIDs
+	q.ShowParticleIDsCheck = widgets.NewQCheckBox(nil)
+	q.ShowParticleIDsCheck.SetChecked(initialValues.ShowParticleIDs)
+	q.ShowParticleIDsCheck.ConnectClicked(q.ShowParticleIDsClickEvent)
+	q.FormLayout.AddRow3("Show Particle IDs", q.ShowParticleIDsCheck)
+	q.densityHeatmap = initialValues.DensityHeatmap
+	q.DensityHeatmapCheck = widgets.NewQCheckBox(nil)
+	q.DensityHeatmapCheck.SetChecked(initialValues.DensityHeatmap)
+	q.DensityHeatmapCheck.ConnectClicked(q.DensityHeatmapClickEvent)
+	q.FormLayout.AddRow3("Density Heatmap", q.DensityHeatmapCheck)
+	q.centerOfMassFrame = initialValues.CenterOfMassFrame
+	q.CenterOfMassFrameCheck = widgets.NewQCheckBox(nil)
+	q.CenterOfMassFrameCheck.SetChecked(initialValues.CenterOfMassFrame)
+	q.CenterOfMassFrameCheck.ConnectClicked(q.CenterOfMassFrameClickEvent)
+	q.FormLayout.AddRow3("Center of Mass Frame", q.CenterOfMassFrameCheck)
+	q.smoothRendering = initialValues.SmoothRendering
+	q.SmoothRenderingCheck = widgets.NewQCheckBox(nil)
+	q.SmoothRenderingCheck.SetChecked(initialValues.SmoothRendering)
+	q.SmoothRenderingCheck.ConnectClicked(q.SmoothRenderingClickEvent)
+	q.FormLayout.AddRow3("Smooth Rendering", q.SmoothRenderingCheck)
+	q.backgroundColor = initialValues.BackgroundColor
+	q.BackgroundColorButton = widgets.NewQPushButton(nil)
+	setColorButton(q.BackgroundColorButton, initialValues.BackgroundColor)
+	q.BackgroundColorButton.ConnectClicked(q.BackgroundColorButtonClickEvent)
+	q.FormLayout.AddRow3("Background Color", q.BackgroundColorButton)
+	q.wallColor = initialValues.WallColor
+	q.WallColorButton = widgets.NewQPushButton(nil)
+	setColorButton(q.WallColorButton, initialValues.WallColor)
+	q.WallColorButton.ConnectClicked(q.WallColorButtonClickEvent)
+	q.FormLayout.AddRow3("Wall Color", q.WallColorButton)
 	q.FormItems["Physics Loop (ms)"] =
 		eWidgets.NewESlider(75, 1500, 142, initialValues.PhysicsLoopSpeed, 1)
 	q.FormItems["Physics Loop (ms)"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.PhysicsLoopSliderChangedEvent)
 	q.FormLayout.AddRow4("Physics Loop (ms)", q.FormItems["Physics Loop (ms)"].AsEWidget().ParentLayout)
+	q.FormItems["Steps Per Frame"] =
+		eWidgets.NewESlider(1, 100, 10, initialValues.StepsPerFrame, 1)
+	q.FormItems["Steps Per Frame"].(*eWidgets.ESlider).ConnectValueChangedEvent(q.StepsPerFrameSliderChangedEvent)
+	q.FormLayout.AddRow4("Steps Per Frame", q.FormItems["Steps Per Frame"].AsEWidget().ParentLayout)
+	q.AutoSlowdownCheck = widgets.NewQCheckBox(nil)
+	q.AutoSlowdownCheck.SetChecked(initialValues.AutoSlowdown)
+	q.AutoSlowdownCheck.ConnectClicked(q.AutoSlowdownClickEvent)
+	q.FormLayout.AddRow3("Auto Slowdown", q.AutoSlowdownCheck)
 	q.FormLayout.AddItem(widgets.NewQSpacerItem(0, 20, 1|4|8, 1|4))
+	q.ResetViewButton = widgets.NewQPushButton2("Reset View", nil)
+	q.ResetViewButton.ConnectClicked(q.ResetViewButtonClickEvent)
+	q.FormLayout.AddWidget(q.ResetViewButton)
+	q.FollowInspectedCheck = widgets.NewQCheckBox(nil)
+	q.FollowInspectedCheck.ConnectClicked(q.FollowInspectedClickEvent)
+	q.FormLayout.AddRow3("Follow Inspected Particle", q.FollowInspectedCheck)
+	q.FitParticlesCheck = widgets.NewQCheckBox(nil)
+	q.FitParticlesCheck.ConnectClicked(q.FitParticlesClickEvent)
+	q.FormLayout.AddRow3("Fit View to Particles", q.FitParticlesCheck)
 	q.ResetButton = widgets.NewQPushButton2("Reset Particles", nil)
 	q.ResetButton.ConnectClicked(q.ResetButtonClickEvent)
 	q.FormLayout.AddWidget(q.ResetButton)
+	q.ZeroVelocitiesButton = widgets.NewQPushButton2("Stop All Particles", nil)
+	q.ZeroVelocitiesButton.ConnectClicked(q.ZeroVelocitiesButtonClickEvent)
+	q.FormLayout.AddWidget(q.ZeroVelocitiesButton)
 	q.FormLayout.AddItem(widgets.NewQSpacerItem(0, 20, 1|4|8, 1|4))
 	q.PauseButton = widgets.NewQPushButton2("Start", nil)
 	q.PauseButton.ConnectClicked(q.PauseButtonClickEvent)
 	q.FormLayout.AddWidget(q.PauseButton)
+	q.StepButton = widgets.NewQPushButton2("Step", nil)
+	q.StepButton.ConnectClicked(q.StepButtonClickEvent)
+	q.FormLayout.AddWidget(q.StepButton)
+	q.PauseOnMergeCheck = widgets.NewQCheckBox(nil)
+	q.PauseOnMergeCheck.SetChecked(initialValues.PauseOnMerge)
+	q.PauseOnMergeCheck.ConnectClicked(q.PauseOnMergeClickEvent)
+	q.FormLayout.AddRow3("Pause on Merge", q.PauseOnMergeCheck)
+	q.PauseOnSpeedCheck = widgets.NewQCheckBox(nil)
+	q.PauseOnSpeedCheck.SetChecked(initialValues.PauseOnSpeed)
+	q.PauseOnSpeedCheck.ConnectClicked(q.PauseOnSpeedClickEvent)
+	q.FormLayout.AddRow3("Pause on Speed", q.PauseOnSpeedCheck)
+	q.FormItems["Pause Speed Threshold"] = eWidgets.NewESlider(1, 100, 10,
+		int(math.Round(initialValues.PauseSpeedThreshold)), 1)
+	q.FormItems["Pause Speed Threshold"].(*eWidgets.ESlider).
+		ConnectValueChangedEvent(q.PauseSpeedThresholdSliderChangedEvent)
+	q.FormLayout.AddRow4("Pause Speed Threshold", q.FormItems["Pause Speed Threshold"].AsEWidget().ParentLayout)
+	q.FormLayout.AddItem(widgets.NewQSpacerItem(0, 20, 1|4|8, 1|4))
+	q.FormItems["Record Every N Frames"] = eWidgets.NewESlider(1, 50, 7, 1, 1)
+	q.FormLayout.AddRow4("Record Every N Frames", q.FormItems["Record Every N Frames"].AsEWidget().ParentLayout)
+	q.RecordGIFCheck = widgets.NewQCheckBox(nil)
+	q.FormLayout.AddRow3("Record As GIF", q.RecordGIFCheck)
+	q.RecordButton = widgets.NewQPushButton2("Start Recording", nil)
+	q.RecordButton.ConnectClicked(q.RecordButtonClickEvent)
+	q.FormLayout.AddWidget(q.RecordButton)
+	q.TrajectoryButton = widgets.NewQPushButton2("Start Trajectory Recording", nil)
+	q.TrajectoryButton.ConnectClicked(q.TrajectoryButtonClickEvent)
+	q.FormLayout.AddWidget(q.TrajectoryButton)
+	q.ExportGenealogyButton = widgets.NewQPushButton2("Export Merge Tree", nil)
+	q.ExportGenealogyButton.ConnectClicked(q.ExportGenealogyButtonClickEvent)
+	q.FormLayout.AddWidget(q.ExportGenealogyButton)
+	q.ExportSVGButton = widgets.NewQPushButton2("Export SVG", nil)
+	q.ExportSVGButton.ConnectClicked(q.ExportSVGButtonClickEvent)
+	q.FormLayout.AddWidget(q.ExportSVGButton)
+
+	q.connectShortcuts(window)
 
 	q.loadingState = false
 
@@ -222,11 +776,9 @@ func (q *Qt) CreateGUI(initialValues guis.GUIInitializationData) {
 
 	//Conveniently, this also sets up the bounds on the Scene (though we can overwrite that later with SetSceneRect()
 	// if we want to zoom in/out)
-	q.Canvas = gui.NewQImage().ConvertToFormat(gui.QImage__Format_ARGB32, core.Qt__AutoColor).
-		Scaled2(q.EnvironmentSize, q.EnvironmentSize, core.Qt__KeepAspectRatio, core.Qt__FastTransformation)
-	q.Pixmap = widgets.NewQGraphicsPixmapItem2(gui.NewQPixmap().FromImage(q.Canvas, 0), nil)
+	q.newCanvas()
 
-	q.DrawParticles(initialValues.PhysicsEngine.Particles)
+	q.DrawParticles()
 
 	q.Scene.AddItem(q.Pixmap)
 	//endregion Canvas
@@ -270,43 +822,121 @@ func (q *Qt) CreateGUI(initialValues guis.GUIInitializationData) {
 func (q *Qt) LoadState(initialValues guis.GUIInitializationData) {
 	q.loadingState = true
 
-	q.EnvironmentSize = initialValues.PhysicsEngine.EnvironmentSize
-	q.FormItems["Environment Size (units*units)"].(*eWidgets.ESlider).
-		SetValue(initialValues.PhysicsEngine.EnvironmentSize)
+	q.EnvironmentWidth = initialValues.PhysicsEngine.EnvironmentWidth
+	q.EnvironmentHeight = initialValues.PhysicsEngine.EnvironmentHeight
+	q.FormItems["Environment Width (units)"].(*eWidgets.ESlider).SetValue(q.EnvironmentWidth)
+	q.FormItems["Environment Height (units)"].(*eWidgets.ESlider).SetValue(q.EnvironmentHeight)
 	q.FormItems["Number of Particles"].(*eWidgets.ESlider).SetValue(initialValues.NumberOfParticles)
 	q.FormItems["Average Mass"].(*eWidgets.ESlider).SetValue(initialValues.AverageMass)
+	q.InitialVelocityModeCombo.SetCurrentIndex(int(initialValues.InitialVelocityMode))
+	q.FormItems["Initial Speed"].(*eWidgets.ESlider).SetValueFromScaled(initialValues.InitialSpeed)
+	spawnX, spawnY, spawnRadius := q.spawnCircle(initialValues.GenerationRegions)
+	q.FormItems["Spawn Center X"].(*eWidgets.ESlider).SetValueFromScaled(spawnX)
+	q.FormItems["Spawn Center Y"].(*eWidgets.ESlider).SetValueFromScaled(spawnY)
+	q.FormItems["Spawn Radius (0 = Everywhere)"].(*eWidgets.ESlider).SetValueFromScaled(spawnRadius)
+	q.ChargeDistributionCombo.SetCurrentIndex(int(initialValues.ChargeDistribution))
 	q.FormItems["Gravity Strength"].(*eWidgets.ESlider).
 		SetValueFromScaled(initialValues.PhysicsEngine.GravityStrength)
 	q.FormItems["Close Charge Strength"].(*eWidgets.ESlider).
 		SetValueFromScaled(initialValues.PhysicsEngine.CloseChargeStrength)
 	q.FormItems["Far Charge Strength"].(*eWidgets.ESlider).
 		SetValueFromScaled(initialValues.PhysicsEngine.FarChargeStrength)
+	q.FormItems["Far Charge Exponent"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.FarChargeExponent)
+	q.FormItems["Far Charge Cutoff (0 = None)"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.FarChargeCutoff)
+	q.EnableGravityCheck.SetChecked(initialValues.PhysicsEngine.EnableGravity)
+	q.EnableCloseChargeCheck.SetChecked(initialValues.PhysicsEngine.EnableCloseCharge)
+	q.speciesMatrix = initialValues.PhysicsEngine.SpeciesMatrix
+	q.EnableFarChargeCheck.SetChecked(initialValues.PhysicsEngine.EnableFarCharge)
+	q.GravityOnlyCheck.SetChecked(initialValues.PhysicsEngine.GravityOnly)
 	q.AllowMergeCheck.SetChecked(initialValues.PhysicsEngine.AllowMerge)
+	q.FormItems["Merge Mass Ratio"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.MergeMassRatioThreshold)
+	q.FormItems["Merge Close Charge Limit"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.MergeCloseChargeThreshold)
+	q.FormItems["Merge Capture Speed"].(*eWidgets.ESlider).SetValueFromScaled(initialValues.PhysicsEngine.CaptureSpeed)
+	q.ChargeMergeRuleCombo.SetCurrentIndex(int(initialValues.PhysicsEngine.ChargeMergeRule))
+	q.AllowFissionCheck.SetChecked(initialValues.PhysicsEngine.AllowFission)
+	q.FormItems["Max Mass"].(*eWidgets.ESlider).SetValueFromScaled(initialValues.PhysicsEngine.MaxMass)
+	q.FormItems["Max Particles (0 = Unlimited)"].(*eWidgets.ESlider).
+		SetValue(initialValues.PhysicsEngine.MaxParticles)
+	q.FormItems["Bounce Separation Factor"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.BounceCompleteDistFactor)
+	q.FormItems["Restitution"].(*eWidgets.ESlider).SetValueFromScaled(initialValues.PhysicsEngine.Restitution)
+	q.FormItems["Drag Coefficient"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.DragCoefficient)
+	q.FormItems["Max Acceleration (0 = Unlimited)"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.MaxAcceleration)
+	q.QuadraticDragCheck.SetChecked(initialValues.PhysicsEngine.QuadraticDrag)
+	fieldStrength, fieldAngle := initialValues.PhysicsEngine.ExternalFieldPolar()
+	q.FormItems["External Field Strength"].(*eWidgets.ESlider).SetValueFromScaled(fieldStrength)
+	q.FormItems["External Field Angle"].(*eWidgets.ESlider).SetValueFromScaled(fieldAngle)
+	q.FormItems["Frame Angular Velocity"].(*eWidgets.ESlider).
+		SetValueFromScaled(initialValues.PhysicsEngine.FrameAngularVelocity)
 	q.WallBounceCheck.SetChecked(initialValues.PhysicsEngine.WallBounce)
+	q.wrapBoundary = initialValues.PhysicsEngine.WrapBoundary && !initialValues.PhysicsEngine.WallBounce
+	q.WrapBoundaryCheck.SetChecked(q.wrapBoundary)
 	q.HistoryTrailCheck.SetChecked(initialValues.HistoryTrail)
 	q.FormItems["History Trail Length"].(*eWidgets.ESlider).SetValue(initialValues.HistoryLength)
+	q.FormItems["History Trail Stride"].(*eWidgets.ESlider).SetValue(initialValues.HistoryStride)
+	q.TrailStyleCombo.SetCurrentIndex(int(initialValues.TrailStyle))
+	q.trailStyle = initialValues.TrailStyle
+	q.TrailColoringCombo.SetCurrentIndex(int(initialValues.TrailColoring))
+	q.trailColoring = initialValues.TrailColoring
+	q.HistoryPrefillCombo.SetCurrentIndex(int(initialValues.HistoryPrefill))
+	q.ColorSchemeCombo.SetCurrentIndex(int(initialValues.PhysicsEngine.ColorScheme))
+	q.drawRadiusScale = initialValues.DrawRadiusScale
+	q.FormItems["Particle Draw Size"].(*eWidgets.ESlider).SetValueFromScaled(initialValues.DrawRadiusScale)
+	q.minDrawRadius = initialValues.MinDrawRadius
+	q.FormItems["Min Particle Draw Radius"].(*eWidgets.ESlider).SetValue(initialValues.MinDrawRadius)
+	q.showVelocityVectors = initialValues.ShowVelocityVectors
+	q.ShowVelocityVectorsCheck.SetChecked(initialValues.ShowVelocityVectors)
+	q.showParticleIDs = initialValues.ShowParticleIDs
+	q.ShowParticleIDsCheck.SetChecked(initialValues.ShowParticleIDs)
+	q.densityHeatmap = initialValues.DensityHeatmap
+	q.DensityHeatmapCheck.SetChecked(initialValues.DensityHeatmap)
+	q.centerOfMassFrame = initialValues.CenterOfMassFrame
+	q.CenterOfMassFrameCheck.SetChecked(initialValues.CenterOfMassFrame)
+	q.smoothRendering = initialValues.SmoothRendering
+	q.SmoothRenderingCheck.SetChecked(initialValues.SmoothRendering)
+	q.backgroundColor = initialValues.BackgroundColor
+	setColorButton(q.BackgroundColorButton, initialValues.BackgroundColor)
+	q.wallColor = initialValues.WallColor
+	setColorButton(q.WallColorButton, initialValues.WallColor)
 	q.FormItems["Physics Loop (ms)"].(*eWidgets.ESlider).SetValue(initialValues.PhysicsLoopSpeed)
+	q.FormItems["Steps Per Frame"].(*eWidgets.ESlider).SetValue(initialValues.StepsPerFrame)
+	q.AutoSlowdownCheck.SetChecked(initialValues.AutoSlowdown)
+	q.PauseOnMergeCheck.SetChecked(initialValues.PauseOnMerge)
+	q.PauseOnSpeedCheck.SetChecked(initialValues.PauseOnSpeed)
+	q.FormItems["Pause Speed Threshold"].(*eWidgets.ESlider).SetValueFromScaled(initialValues.PauseSpeedThreshold)
 
 	q.loadingState = false
 
-	q.UpdateView(initialValues.PhysicsEngine.Particles)
+	// Inspection stops (without the note updateInspection would display) if the inspected particle has been replaced
+	physics.RLockParticles()
+	replaced := q.inspected != nil && !containsParticle(physics.Engine.Particles, q.inspected)
+	physics.RUnlockParticles()
+	if replaced {
+		q.SetInspectedParticle(nil)
+	}
+
+	q.UpdateView()
 }
 
 // UpdateView implements guis.GUIEnabler.UpdateView
-func (q *Qt) UpdateView(particles []*physics.Particle) {
+func (q *Qt) UpdateView() {
 	q.View.Hide()
 	q.View.SetScene(nil)
 	q.Scene.RemoveItem(q.Pixmap)
-	q.Canvas = gui.NewQImage().ConvertToFormat(gui.QImage__Format_ARGB32, core.Qt__AutoColor).
-		Scaled2(q.EnvironmentSize, q.EnvironmentSize, core.Qt__KeepAspectRatio, core.Qt__FastTransformation)
-	q.Pixmap = widgets.NewQGraphicsPixmapItem2(gui.NewQPixmap().FromImage(q.Canvas, 0), nil)
-	q.Scene.SetSceneRect2(0, 0, float64(q.EnvironmentSize), float64(q.EnvironmentSize))
-	q.View.SetSceneRect2(0, 0, float64(q.EnvironmentSize), float64(q.EnvironmentSize))
-	q.DrawParticles(particles)
+	q.newCanvas()
+	q.Scene.SetSceneRect2(0, 0, float64(q.EnvironmentWidth), float64(q.EnvironmentHeight))
+	q.View.SetSceneRect2(0, 0, float64(q.EnvironmentWidth), float64(q.EnvironmentHeight))
+	q.DrawParticles()
 	q.Scene.AddItem(q.Pixmap)
 	q.View.SetScene(q.Scene)
-	// Magic. Certain scales fit the View nicely, others leave big bezels, this makes it more likely to be the former
-	q.View.Scale(909/float64(q.View.Width()), 909/float64(q.View.Height()))
+	q.zoomed = false
+	q.fitView()
 	q.View.Show()
 }
 
@@ -315,8 +945,3 @@ func (q *Qt) SetPhysicsLoopSpeed(loopTime int) {
 	// We know there's no need to scale / use SetValueFRomScaled
 	q.FormItems["Physics Loop (ms)"].(*eWidgets.ESlider).SetValue(loopTime)
 }
-
-// SetStatusText implements guis.GUIEnabler.SetStatusText
-func (q *Qt) SetStatusText(text string, timeout int) {
-	q.statusbar.ShowMessage(text, timeout)
-}
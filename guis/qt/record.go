@@ -0,0 +1,112 @@
+package qt
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// recorder captures the frames drawn by DrawParticles while recording is active (see RecordButtonClickEvent), either
+// writing them to numbered PNG files or collecting them to be written as an animated GIF when recording stops.
+type recorder struct {
+	// dir is the directory the PNG frames (or GIF) are written to.
+	dir string
+	// interval is the number of drawn frames between captured frames (e.g. 10 captures every tenth frame). Values less
+	// than 1 capture every frame.
+	interval int
+	// asGIF indicates whether the captured frames are assembled into a GIF (rather than written as PNG files).
+	asGIF bool
+	// delay is the time between GIF frames, in 100ths of a second.
+	delay int
+
+	// frame is the number of frames drawn (whether captured or not) since recording started.
+	frame int
+	// captured is the number of frames captured since recording started.
+	captured int
+	// err is the error which occurred writing a frame, if any. Once set, no more frames are captured.
+	err error
+	// anim holds the captured frames (if asGIF).
+	anim gif.GIF
+	// paletteIndex caches the GIF palette index of each color captured so far (there are relatively few distinct
+	// colors, and finding the nearest palette color for every pixel is slow).
+	paletteIndex map[color.RGBA]uint8
+}
+
+// newRecorder is a factory for creating a new recorder, writing to directory dir and capturing every interval frames.
+// If asGIF, the frames are assembled into a GIF, with delay ms between frames.
+func newRecorder(dir string, interval int, asGIF bool, delay int) *recorder {
+	return &recorder{dir: dir, interval: interval, asGIF: asGIF, delay: (delay + 5) / 10,
+		paletteIndex: make(map[color.RGBA]uint8)}
+}
+
+// capture records the frame img, if it falls on the capture interval. Returns an error if the frame couldn't be
+// written, after which the recording is stopped (no more frames are captured).
+func (r *recorder) capture(img *image.NRGBA) error {
+	frame := r.frame
+	r.frame++
+	if r.err != nil || (r.interval > 1 && frame%r.interval != 0) {
+		return nil
+	}
+	r.captured++
+
+	if r.asGIF {
+		r.anim.Image = append(r.anim.Image, r.toPaletted(img))
+		r.anim.Delay = append(r.anim.Delay, r.delay)
+		return nil
+	}
+
+	f, err := os.Create(filepath.Join(r.dir, fmt.Sprintf("frame_%06d.png", r.captured-1)))
+	if err == nil {
+		err = png.Encode(f, img)
+		if cErr := f.Close(); err == nil {
+			err = cErr
+		}
+	}
+	r.err = err
+	return err
+}
+
+// stop finishes the recording, writing the GIF (if asGIF). Returns the path of the GIF, or of the directory the PNG
+// frames were written to, and the error (if any) which occurred writing them.
+func (r *recorder) stop() (string, error) {
+	if !r.asGIF {
+		return r.dir, r.err
+	}
+
+	file := filepath.Join(r.dir, "recording.gif")
+	if len(r.anim.Image) == 0 {
+		return file, fmt.Errorf("no frames were captured")
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+	return file, gif.EncodeAll(f, &r.anim)
+}
+
+// toPaletted converts img to a paletted (GIF) image. The Canvas background is transparent, so img is drawn over white
+// (as it appears in the View) before being reduced to the palette.
+func (r *recorder) toPaletted(img *image.NRGBA) *image.Paletted {
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	p := image.NewPaletted(img.Bounds(), palette.Plan9)
+	for i := 0; i < len(p.Pix); i++ {
+		c := color.RGBA{R: rgba.Pix[i*4], G: rgba.Pix[i*4+1], B: rgba.Pix[i*4+2], A: rgba.Pix[i*4+3]}
+		index, ok := r.paletteIndex[c]
+		if !ok {
+			index = uint8(p.Palette.Index(c))
+			r.paletteIndex[c] = index
+		}
+		p.Pix[i] = index
+	}
+	return p
+}
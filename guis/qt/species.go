@@ -0,0 +1,112 @@
+package qt
+
+import (
+	"fmt"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/widgets"
+)
+
+// maxSpecies is the most species the species matrix editor (see speciesMatrixDialog) allows.
+const maxSpecies = 8
+
+// speciesMatrixDialog is the dialog the user edits the species interaction matrix (see
+// physics.EngineData.SpeciesMatrix) in, as a grid of spin boxes: one row per species feeling the close charge force,
+// and one column per species exerting it.
+type speciesMatrixDialog struct {
+	dialog *widgets.QDialog
+	layout *widgets.QVBoxLayout
+	// countSpin is the spin box the user sets the number of species with (0 ignoring species).
+	countSpin *widgets.QSpinBox
+	// gridWidget holds the grid of cells (see setSpeciesCount), and is replaced whenever the number of species changes.
+	gridWidget *widgets.QWidget
+	// cells are the spin boxes holding the matrix entries, cells[p][o] being the multiplier of the force species o
+	// exerts on species p.
+	cells [][]*widgets.QDoubleSpinBox
+}
+
+// editSpeciesMatrix presents the species matrix editor, starting at matrix (which may be nil, for no species), and
+// calls apply with the edited matrix (nil if the user sets the number of species to 0) if the user clicks OK.
+func (q *Qt) editSpeciesMatrix(matrix [][]float64, apply func(matrix [][]float64)) {
+	d := &speciesMatrixDialog{dialog: widgets.NewQDialog(nil, 0)}
+	d.dialog.SetWindowTitle("Species Matrix")
+	d.dialog.SetModal(true)
+	d.layout = widgets.NewQVBoxLayout2(d.dialog)
+
+	help := widgets.NewQLabel2("Each entry multiplies the close charge force the column's species exerts on the "+
+		"row's species.\nNegative entries reverse the force, and 0 disables it.", nil, 0)
+	d.layout.AddWidget(help, 0, 0)
+
+	countLayout := widgets.NewQHBoxLayout()
+	countLayout.AddWidget(widgets.NewQLabel2("Number of Species (0 = Ignore Species)", nil, 0), 0, 0)
+	d.countSpin = widgets.NewQSpinBox(nil)
+	d.countSpin.SetRange(0, maxSpecies)
+	countLayout.AddWidget(d.countSpin, 0, 0)
+	d.layout.AddLayout(countLayout, 0)
+
+	d.gridWidget = widgets.NewQWidget(nil, 0)
+	d.layout.AddWidget(d.gridWidget, 0, 0)
+	d.setSpeciesCount(len(matrix), matrix)
+	d.countSpin.SetValue(len(matrix))
+	// Connected after the initial value is set, so the matrix isn't rebuilt (as if resized) straight away
+	d.countSpin.ConnectValueChanged(func(count int) {
+		d.setSpeciesCount(count, d.matrix())
+	})
+
+	buttons := widgets.NewQDialogButtonBox3(widgets.QDialogButtonBox__Ok|widgets.QDialogButtonBox__Cancel, nil)
+	// Anonymous function called on clicking OK
+	buttons.ConnectAccepted(func() {
+		apply(d.matrix())
+		d.dialog.Accept()
+	})
+	buttons.ConnectRejected(d.dialog.Reject)
+	d.layout.AddWidget(buttons, 0, 0)
+
+	// Show the dialog (waits for OK / cancel)
+	d.dialog.Show()
+}
+
+// setSpeciesCount replaces the grid with one of count species, filled from matrix where it has entries, and with 1 (no
+// change to the force) elsewhere.
+func (d *speciesMatrixDialog) setSpeciesCount(count int, matrix [][]float64) {
+	gridWidget := widgets.NewQWidget(nil, 0)
+	grid := widgets.NewQGridLayout(gridWidget)
+	d.cells = make([][]*widgets.QDoubleSpinBox, count)
+	for i := 0; i < count; i++ {
+		// Species are labelled across the top (exerting the force) and down the side (feeling it)
+		grid.AddWidget2(widgets.NewQLabel2(fmt.Sprintf("Species %d", i), nil, 0), 0, i+1, core.Qt__AlignCenter)
+		grid.AddWidget2(widgets.NewQLabel2(fmt.Sprintf("Species %d", i), nil, 0), i+1, 0, 0)
+		d.cells[i] = make([]*widgets.QDoubleSpinBox, count)
+		for j := 0; j < count; j++ {
+			cell := widgets.NewQDoubleSpinBox(nil)
+			cell.SetRange(-100, 100)
+			cell.SetSingleStep(0.1)
+			cell.SetDecimals(2)
+			cell.SetValue(1)
+			if i < len(matrix) && j < len(matrix[i]) {
+				cell.SetValue(matrix[i][j])
+			}
+			grid.AddWidget2(cell, i+1, j+1, 0)
+			d.cells[i][j] = cell
+		}
+	}
+	d.layout.ReplaceWidget(d.gridWidget, gridWidget, core.Qt__FindChildrenRecursively)
+	d.gridWidget.DeleteLater()
+	d.gridWidget = gridWidget
+	d.dialog.AdjustSize()
+}
+
+// matrix gets the matrix currently in the grid, or nil if there are no species.
+func (d *speciesMatrixDialog) matrix() [][]float64 {
+	if len(d.cells) == 0 {
+		return nil
+	}
+	matrix := make([][]float64, len(d.cells))
+	for i, row := range d.cells {
+		matrix[i] = make([]float64, len(row))
+		for j, cell := range row {
+			matrix[i][j] = cell.Value()
+		}
+	}
+	return matrix
+}
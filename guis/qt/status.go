@@ -0,0 +1,50 @@
+package qt
+
+import "time"
+
+// statusPriority is the priority of status text. Status text is only replaced by text of the same or higher priority,
+// until it times out (see showStatus).
+type statusPriority int
+
+const (
+	// statusCount is the particle count, shown when there is no other status text (see DrawParticles).
+	statusCount statusPriority = iota
+	// statusRoutine is routine status text, such as the periodic diagnostics (see SetRoutineStatusText). Status text
+	// with no timeout (see SetStatusText) is also kept at this priority once shown.
+	statusRoutine
+	// statusInspect is the details of the inspected particle, updated as it is drawn (see updateInspection).
+	statusInspect
+	// statusNotice is status text reporting an event, such as a particle merge or a user action (see SetStatusText).
+	statusNotice
+)
+
+// SetStatusText implements guis.GUIEnabler.SetStatusText. Text with a timeout is a notice, kept until it times out
+// (over any routine text, inspected particle details, or the particle count). Text without a timeout is kept until
+// replaced by other text, but not by the particle count.
+func (q *Qt) SetStatusText(text string, timeout int) {
+	q.showStatus(text, statusNotice, timeout)
+	if timeout <= 0 {
+		// Shown like a notice, but then only kept over the particle count
+		q.statusPriority = statusRoutine
+	}
+}
+
+// SetRoutineStatusText implements guis.GUIEnabler.SetRoutineStatusText
+func (q *Qt) SetRoutineStatusText(text string, timeout int) {
+	q.showStatus(text, statusRoutine, timeout)
+}
+
+// showStatus shows text in the statusbar for timeout ms (or until replaced, if timeout is 0) with the given priority,
+// unless the current status text has a higher priority and hasn't timed out.
+func (q *Qt) showStatus(text string, priority statusPriority, timeout int) {
+	expired := !q.statusUntil.IsZero() && time.Now().After(q.statusUntil)
+	if priority < q.statusPriority && !expired {
+		return
+	}
+	q.statusbar.ShowMessage(text, timeout)
+	q.statusPriority = priority
+	q.statusUntil = time.Time{}
+	if timeout > 0 {
+		q.statusUntil = time.Now().Add(time.Duration(timeout) * time.Millisecond)
+	}
+}
@@ -0,0 +1,104 @@
+package qt
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"GoGoGadgetGravity/physics"
+)
+
+// ExportSVG implements guis.GUIEnabler.ExportSVG. The SVG of the physics.Engine.Particles is written by writeSVG,
+// holding the particles lock for reading (see physics.RLockParticles).
+func (q *Qt) ExportSVG(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	physics.RLockParticles()
+	err = q.writeSVG(f, physics.Engine.Particles)
+	physics.RUnlockParticles()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeSVG writes the particles to w as an SVG image of the environment (in environment units, so unaffected by the
+// RenderScale), bypassing the Canvas entirely: the background (unless transparent) and walls as rects, each particle
+// as a circle of its drawn radius (see drawRadius) and color, and, if their position histories are tracked, their
+// trails as polylines (a segment each, so they fade with age as on screen; see trailColor). Positions are in the frame
+// the particles were last drawn in (see setFrame). The particles are always drawn as circles, even if the density
+// heatmap is enabled, and the fixed and selected particle outlines and particle IDs aren't included.
+func (q *Qt) writeSVG(w io.Writer, particles []*physics.Particle) error {
+	var b strings.Builder
+	width, height := float64(q.EnvironmentWidth), float64(q.EnvironmentHeight)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+		svgNumber(width), svgNumber(height), svgNumber(width), svgNumber(height))
+	if c := q.backgroundColor; c.A > 0 {
+		fmt.Fprintf(&b, "\t<rect width=\"100%%\" height=\"100%%\" %s/>\n", svgPaint("fill", c.R, c.G, c.B, c.A))
+	}
+
+	for _, p := range particles {
+		if !p.TrackHistory() {
+			continue
+		}
+		history := p.PositionHistory()
+		count := math.Min(float64(p.HistorySize()), float64(len(history)))
+		for i, h := range history {
+			next := p.Position()
+			if i+1 < len(history) {
+				next = history[i+1]
+			}
+			x0, y0 := q.drawPosition(h)
+			x1, y1 := q.drawPosition(next)
+			// As for drawTrailLines, positions on opposite sides of a wrapping environment aren't connected
+			if q.wrapBoundary && (math.Abs(x1-x0) > width/2 || math.Abs(y1-y0) > height/2) {
+				continue
+			}
+			r, g, bl, a := q.trailColor(p, float64(i)/count)
+			fmt.Fprintf(&b, "\t<polyline points=\"%s,%s %s,%s\" fill=\"none\" %s/>\n", svgNumber(x0), svgNumber(y0),
+				svgNumber(x1), svgNumber(y1), svgPaint("stroke", r, g, bl, a))
+		}
+	}
+
+	for _, p := range particles {
+		x, y := q.drawPosition(p.Position())
+		rad := float64(q.drawRadius(p))
+		for _, dx := range [3]float64{0, -width, width} {
+			for _, dy := range [3]float64{0, -height, height} {
+				// As for drawWrappedFilledCircle, the copies of particles overlapping the edges of a wrapping
+				// environment are drawn on the opposite edges
+				if (dx != 0 || dy != 0) && (!q.wrapBoundary || x+dx+rad < 0 || x+dx-rad >= width ||
+					y+dy+rad < 0 || y+dy-rad >= height) {
+					continue
+				}
+				fmt.Fprintf(&b, "\t<circle cx=\"%s\" cy=\"%s\" r=\"%s\" %s/>\n", svgNumber(x+dx), svgNumber(y+dy),
+					svgNumber(rad), svgPaint("fill", p.R, p.G, p.B, p.A))
+			}
+		}
+	}
+
+	// The walls are a 1 unit wide stroke just inside the edges
+	c := q.wallColor
+	fmt.Fprintf(&b, "\t<rect x=\"0.5\" y=\"0.5\" width=\"%s\" height=\"%s\" fill=\"none\" stroke-width=\"1\" %s/>\n",
+		svgNumber(width-1), svgNumber(height-1), svgPaint("stroke", c.R, c.G, c.B, c.A))
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// svgPaint formats an SVG fill or stroke (per attr) of color r, g, b with alpha a, as the color and opacity attributes.
+func svgPaint(attr string, r, g, b, a uint8) string {
+	return fmt.Sprintf(`%s="rgb(%d,%d,%d)" %s-opacity="%s"`, attr, r, g, b, attr, svgNumber(float64(a)/255))
+}
+
+// svgNumber formats v for an SVG attribute, rounded to 3 decimal places (far finer than a pixel) without trailing
+// zeros.
+func svgNumber(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.3f", v), "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -1,6 +1,6 @@
 // GoGoGadgetGravity is a particle simulator, including physics engine and gui packages, which uses artificial physics:
 // Gravity is inversely proportional to distance^2.
-// It is always positive and therefore attractive.
+// It is always positive and therefore attractive (unless the gravity strength is negative).
 // Masses add. Radius is proxy.
 // closeCharge is inversely proportional to distance^3.
 // It may be negative or positive and therefore repulsive or attractive
@@ -14,16 +14,22 @@ package main
 import (
 	cryptorand "crypto/rand"
 	"encoding/binary"
+	"flag"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
 	"reflect"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/atedja/go-vector"
 	log "github.com/sirupsen/logrus"
 
 	"GoGoGadgetGravity/guis"
+	"GoGoGadgetGravity/guis/headless"
 	"GoGoGadgetGravity/guis/qt"
 	"GoGoGadgetGravity/physics"
 	"GoGoGadgetGravity/state"
@@ -35,70 +41,228 @@ var (
 
 	// State holds simulation state information.
 	State *state.Data
+	// History holds snapshots of State, recorded before changes to settings or particles, for undo/redo.
+	History *state.History
 
 	// physicsTicker is the ticker used for the execution of the physicsLoop (essentially, periodic calls to
 	// physics.UpdateParticles).
 	physicsTicker *time.Ticker
-	// physicsDoneChan is the channel used to pause/stop the physicsLoop.
+	// physicsDoneChan is the channel used to pause/stop the physicsLoop. It's buffered, so stopping the loop never
+	// blocks, even if the loop has already returned (see pause).
 	physicsDoneChan chan bool
-	// paused indicates whether the physicsLoop is currently running.
+	// paused indicates whether the physicsLoop is currently running. It's set from within the physicsLoop as well as by
+	// the event handlers (see autoPause), so it's only accessed holding pausedLock (see isPaused).
 	paused bool
+	// pausedLock guards paused, and the physicsTicker and physicsDoneChan of the running physicsLoop.
+	pausedLock sync.Mutex
+	// loopSlowdownMargin is the fraction by which State.PhysicsLoopSpeed is set longer than the actual execution time of
+	// the physicsLoop when it can't keep up with the requested speed, if State.AutoSlowdown is set. Negative values
+	// disable the automatic slowdown regardless.
+	loopSlowdownMargin float64
+
+	// selectedParticles are the particles the user has selected (while paused) to drag to new positions (see
+	// GrabParticleEvent). Selected particles which have since been replaced (such as by undoing) are ignored.
+	selectedParticles []*physics.Particle
+	// particlesDragged indicates whether the selectedParticles have been dragged since they were last grabbed.
+	particlesDragged bool
 )
 
 const (
 	// Initial / minimum window size
 	minW, minH = 1175, 855
+	// The maximum number of undo (and redo) steps kept in History
+	historyLimit = 50
+	// The number of physics loop iterations between updates of the diagnostics (energy, momentum, etc.) status text
+	diagnosticsInterval = 10
+	// The time (ms) particle merger (and warning) status text is displayed for
+	mergeStatusTime = 1500
+	// The time (ms) the reason the simulation was automatically paused (see State.PauseOnMerge) is displayed for
+	autoPauseStatusTime = 10000
+	// The weight given to each new measurement in the (exponentially) smoothed frame rate and step times
+	timingSmoothing = 0.1
+	// The strength (acceleration at the cursor) with which the user attracts or repels particles (see AttractorEvent)
+	attractorStrength = 0.5
 	// See physics.EngineData and state.Data. These are starting values passed to the GUI for initialization.
-	initialEnvironmentSize     = 800
+	initialEnvironmentWidth    = 800
+	initialEnvironmentHeight   = 800
 	initialNumParticles        = 50
 	initialAverageMass         = 250
+	initialSpeed               = 1
 	initialGravityStrength     = 15
 	initialCloseChargeStrength = 150000000
 	initialFarChargeStrength   = 7.5
 	initialHistLength          = 15
+	initialPauseSpeedThreshold = 20
 	initialLoopSpeed           = 75
 )
 
 // main is ... well, you know...
 func main() {
+	// Command line flags, used to select (and configure) the GUI
+	guiName := flag.String("gui", "qt", "the GUI to use: qt, or headless (no window; runs -steps steps and exits)")
+	steps := flag.Int("steps", 1000, "the number of simulation steps to run (headless only)")
+	frameDir := flag.String("frames", "", "the directory to write PNG frames to, if any (headless only)")
+	frameInterval := flag.Int("frame-interval", 1, "the number of steps between written frames (headless only)")
+	seed := flag.Int64("seed", 0, "the random seed used to generate particles (if not set, a random seed is used)")
+	load := flag.String("load", "", "a saved state file to start with (rather than random particles), or - for stdin")
+	eventLog := flag.String("event-log", "", "a file to log merge (and, with -log-bounces, bounce) events to, as JSON")
+	logBounces := flag.Bool("log-bounces", false, "whether to also log bounces to the -event-log file")
+	trajectory := flag.String("trajectory", "", "a file to record each step's particle positions and velocities to, "+
+		"as JSON Lines")
+	renderScale := flag.Float64("render-scale", 1, "the size of the drawing canvas relative to the environment, "+
+		"in (0, 1]; lower values draw faster in large environments (qt only)")
+	flag.Float64Var(&loopSlowdownMargin, "loop-margin", 0.05, "the fraction the physics loop time is increased "+
+		"beyond the actual execution time when the loop can't keep up, if the automatic slowdown is enabled (negative "+
+		"disables it)")
+	flag.Parse()
+	seeded := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seeded = true
+		}
+	})
+
 	paused = true
+	physicsDoneChan = make(chan bool, 1)
 
 	State = &state.Data{
-		NumberOfParticles: initialNumParticles,
-		AverageMass:       initialAverageMass,
-		HistoryTrail:      true,
-		HistoryLength:     initialHistLength,
-		PhysicsEngine:     &physics.Engine,
-		PhysicsLoopSpeed:  initialLoopSpeed,
+		Version:             state.CurrentVersion,
+		NumberOfParticles:   initialNumParticles,
+		AverageMass:         initialAverageMass,
+		InitialSpeed:        initialSpeed,
+		HistoryTrail:        true,
+		HistoryLength:       initialHistLength,
+		HistoryStride:       1,
+		DrawRadiusScale:     1,
+		MinDrawRadius:       1,
+		WallColor:           state.DefaultWallColor,
+		PauseSpeedThreshold: initialPauseSpeedThreshold,
+		PhysicsEngine:       &physics.Engine,
+		PhysicsLoopSpeed:    initialLoopSpeed,
+		StepsPerFrame:       1,
 	}
 
 	State.PhysicsEngine.Initialize()
+	History = state.NewHistory(historyLimit)
 	State.PhysicsEngine.GravityStrength = initialGravityStrength
 	State.PhysicsEngine.CloseChargeStrength = initialCloseChargeStrength
 	State.PhysicsEngine.FarChargeStrength = initialFarChargeStrength
-	State.PhysicsEngine.EnvironmentSize = initialEnvironmentSize
+	State.PhysicsEngine.EnvironmentWidth = initialEnvironmentWidth
+	State.PhysicsEngine.EnvironmentHeight = initialEnvironmentHeight
+	physics.SetHistoryPrefill(State.HistoryPrefill)
+	physics.SetParticleHistory(State.HistoryTrail, State.HistoryLength, State.HistoryStride)
 
-	GUI = &qt.Qt{}
+	switch *guiName {
+	case "qt":
+		GUI = &qt.Qt{RenderScale: *renderScale}
+	case "headless":
+		GUI = &headless.Headless{Steps: *steps, FrameDir: *frameDir, FrameInterval: *frameInterval}
+	default:
+		log.Fatalln("Unknown GUI: " + *guiName)
+	}
 	// Set up to get notified of GUI events (user control interaction)
 	GUI.ConnectSaveStateEvent(SaveStateEvent)
 	GUI.ConnectLoadStateEvent(LoadStateEvent)
-	GUI.ConnectEnvironmentSizeChangedEvent(EnvironmentSizeChangedEvent)
+	GUI.ConnectSaveSettingsEvent(SaveSettingsEvent)
+	GUI.ConnectLoadSettingsEvent(LoadSettingsEvent)
+	GUI.ConnectResetSettingsEvent(ResetSettingsEvent)
+	GUI.ConnectLoadPresetEvent(LoadPresetEvent)
+	GUI.ConnectEnvironmentWidthChangedEvent(EnvironmentWidthChangedEvent)
+	GUI.ConnectEnvironmentHeightChangedEvent(EnvironmentHeightChangedEvent)
 	GUI.ConnectNumParticlesChangedEvent(NumParticlesChangedEvent)
 	GUI.ConnectAverageMassChangedEvent(AverageMassChangedEvent)
+	GUI.ConnectInitialVelocityModeChangedEvent(InitialVelocityModeChangedEvent)
+	GUI.ConnectInitialSpeedChangedEvent(InitialSpeedChangedEvent)
+	GUI.ConnectGenerationRegionChangedEvent(GenerationRegionChangedEvent)
+	GUI.ConnectChargeDistributionChangedEvent(ChargeDistributionChangedEvent)
 	GUI.ConnectRegenParticlesEvent(RegenParticlesEvent)
 	GUI.ConnectGravityStrengthChangedEvent(GravityStrengthChangedEvent)
 	GUI.ConnectCloseChargeStrengthChangedEvent(CloseChargeStrengthChangedEvent)
 	GUI.ConnectFarChargeStrengthChangedEvent(FarChargeStrengthChangedEvent)
+	GUI.ConnectFarChargeExponentChangedEvent(FarChargeExponentChangedEvent)
+	GUI.ConnectFarChargeCutoffChangedEvent(FarChargeCutoffChangedEvent)
+	GUI.ConnectGravityOnlyChangedEvent(GravityOnlyChangedEvent)
+	GUI.ConnectEnableGravityChangedEvent(EnableGravityChangedEvent)
+	GUI.ConnectEnableCloseChargeChangedEvent(EnableCloseChargeChangedEvent)
+	GUI.ConnectEnableFarChargeChangedEvent(EnableFarChargeChangedEvent)
+	GUI.ConnectSpeciesMatrixChangedEvent(SpeciesMatrixChangedEvent)
 	GUI.ConnectAllowMergeChangedEvent(AllowMergeChangedEvent)
+	GUI.ConnectMergeMassRatioThresholdChangedEvent(MergeMassRatioThresholdChangedEvent)
+	GUI.ConnectMergeCloseChargeThresholdChangedEvent(MergeCloseChargeThresholdChangedEvent)
+	GUI.ConnectCaptureSpeedChangedEvent(CaptureSpeedChangedEvent)
+	GUI.ConnectChargeMergeRuleChangedEvent(ChargeMergeRuleChangedEvent)
+	GUI.ConnectAllowFissionChangedEvent(AllowFissionChangedEvent)
+	GUI.ConnectMaxMassChangedEvent(MaxMassChangedEvent)
+	GUI.ConnectMaxParticlesChangedEvent(MaxParticlesChangedEvent)
+	GUI.ConnectBounceCompleteDistFactorChangedEvent(BounceCompleteDistFactorChangedEvent)
+	GUI.ConnectRestitutionChangedEvent(RestitutionChangedEvent)
+	GUI.ConnectDragCoefficientChangedEvent(DragCoefficientChangedEvent)
+	GUI.ConnectMaxAccelerationChangedEvent(MaxAccelerationChangedEvent)
+	GUI.ConnectQuadraticDragChangedEvent(QuadraticDragChangedEvent)
+	GUI.ConnectExternalFieldChangedEvent(ExternalFieldChangedEvent)
+	GUI.ConnectFrameAngularVelocityChangedEvent(FrameAngularVelocityChangedEvent)
 	GUI.ConnectWallBounceChangedEvent(WallBounceChangedEvent)
+	GUI.ConnectWrapBoundaryChangedEvent(WrapBoundaryChangedEvent)
 	GUI.ConnectHistoryTrailChangedEvent(HistoryTrailChangedEvent)
 	GUI.ConnectHistoryTrailLengthChangedEvent(HistoryTrailLengthChangedEvent)
+	GUI.ConnectHistoryTrailStrideChangedEvent(HistoryTrailStrideChangedEvent)
+	GUI.ConnectColorSchemeChangedEvent(ColorSchemeChangedEvent)
+	GUI.ConnectTrailStyleChangedEvent(TrailStyleChangedEvent)
+	GUI.ConnectTrailColoringChangedEvent(TrailColoringChangedEvent)
+	GUI.ConnectHistoryPrefillChangedEvent(HistoryPrefillChangedEvent)
+	GUI.ConnectDrawRadiusScaleChangedEvent(DrawRadiusScaleChangedEvent)
+	GUI.ConnectMinDrawRadiusChangedEvent(MinDrawRadiusChangedEvent)
+	GUI.ConnectShowVelocityVectorsEvent(ShowVelocityVectorsEvent)
+	GUI.ConnectShowParticleIDsEvent(ShowParticleIDsEvent)
+	GUI.ConnectDensityHeatmapEvent(DensityHeatmapEvent)
+	GUI.ConnectCenterOfMassFrameEvent(CenterOfMassFrameEvent)
+	GUI.ConnectSmoothRenderingEvent(SmoothRenderingEvent)
+	GUI.ConnectBackgroundColorChangedEvent(BackgroundColorChangedEvent)
+	GUI.ConnectWallColorChangedEvent(WallColorChangedEvent)
 	GUI.ConnectPhysicsLoopSpeedChangedEvent(PhysicsLoopSpeedChangedEvent)
+	GUI.ConnectStepsPerFrameChangedEvent(StepsPerFrameChangedEvent)
+	GUI.ConnectAutoSlowdownChangedEvent(AutoSlowdownChangedEvent)
 	GUI.ConnectResetEnvironmentEvent(ResetEnvironmentEvent)
+	GUI.ConnectZeroVelocitiesEvent(ZeroVelocitiesEvent)
 	GUI.ConnectPauseResumeEvent(PauseResumeEvent)
+	GUI.ConnectStepOnceEvent(StepOnceEvent)
+	GUI.ConnectPauseOnMergeChangedEvent(PauseOnMergeChangedEvent)
+	GUI.ConnectPauseOnSpeedChangedEvent(PauseOnSpeedChangedEvent)
+	GUI.ConnectPauseSpeedThresholdChangedEvent(PauseSpeedThresholdChangedEvent)
+	GUI.ConnectUndoEvent(UndoEvent)
+	GUI.ConnectRedoEvent(RedoEvent)
+	GUI.ConnectToggleFixedEvent(ToggleFixedEvent)
+	GUI.ConnectParticleClickedEvent(ParticleClickedEvent)
+	GUI.ConnectGrabParticleEvent(GrabParticleEvent)
+	GUI.ConnectDragParticlesEvent(DragParticlesEvent)
+	GUI.ConnectDropParticlesEvent(DropParticlesEvent)
+	GUI.ConnectAttractorEvent(AttractorEvent)
+	GUI.ConnectReleaseAttractorEvent(ReleaseAttractorEvent)
+	GUI.ConnectStartRecordingEvent(StartRecordingEvent)
+	GUI.ConnectStopRecordingEvent(StopRecordingEvent)
+	GUI.ConnectStartTrajectoryEvent(StartTrajectoryEvent)
+	GUI.ConnectStopTrajectoryEvent(StopTrajectoryEvent)
+	GUI.ConnectExportGenealogyEvent(ExportGenealogyEvent)
+	GUI.ConnectExportSVGEvent(ExportSVGEvent)
+	GUI.ConnectShutdownEvent(ShutdownEvent)
 
-	initRandom()
-	GenerateParticles()
+	// The seed is logged so that runs with a random seed can be reproduced later
+	usedSeed := initRandom(*seed, seeded)
+	log.Infof("Random seed: %d (run with -seed %d to reproduce)", usedSeed, usedSeed)
+	if *eventLog != "" {
+		startEventLog(*eventLog, *logBounces)
+	}
+	if *load != "" {
+		loadStartupState(*load)
+	} else {
+		GenerateParticles()
+	}
+	// Started once the particles exist, so they are the first step recorded
+	if *trajectory != "" {
+		if err := physics.StartTrajectory(*trajectory); err != nil {
+			log.Fatalln("Unable to record trajectory: " + err.Error())
+		}
+	}
 
 	// Create the GUI and set initial control values, and show the GUI & draw the particles
 	initialValues := guis.GUIInitializationData{
@@ -107,70 +271,214 @@ func main() {
 				GravityStrength:     initialGravityStrength,
 				CloseChargeStrength: initialCloseChargeStrength,
 				FarChargeStrength:   initialFarChargeStrength,
-				EnvironmentSize:     initialEnvironmentSize,
+				EnvironmentWidth:    initialEnvironmentWidth,
+				EnvironmentHeight:   initialEnvironmentHeight,
 				AllowMerge:          true,
 				WallBounce:          true,
 				Particles:           State.PhysicsEngine.Particles,
+				// Not (presently) set by main; use the defaults set by Initialize
+				FarChargeExponent:         State.PhysicsEngine.FarChargeExponent,
+				FarChargeCutoff:           State.PhysicsEngine.FarChargeCutoff,
+				GravityOnly:               State.PhysicsEngine.GravityOnly,
+				EnableGravity:             State.PhysicsEngine.EnableGravity,
+				EnableCloseCharge:         State.PhysicsEngine.EnableCloseCharge,
+				EnableFarCharge:           State.PhysicsEngine.EnableFarCharge,
+				MergeMassRatioThreshold:   State.PhysicsEngine.MergeMassRatioThreshold,
+				MergeCloseChargeThreshold: State.PhysicsEngine.MergeCloseChargeThreshold,
+				CaptureSpeed:              State.PhysicsEngine.CaptureSpeed,
+				ChargeMergeRule:           State.PhysicsEngine.ChargeMergeRule,
+				AllowFission:              State.PhysicsEngine.AllowFission,
+				MaxMass:                   State.PhysicsEngine.MaxMass,
+				MaxParticles:              State.PhysicsEngine.MaxParticles,
+				BounceCompleteDistFactor:  State.PhysicsEngine.BounceCompleteDistFactor,
+				Restitution:               State.PhysicsEngine.Restitution,
+				DragCoefficient:           State.PhysicsEngine.DragCoefficient,
+				MaxAcceleration:           State.PhysicsEngine.MaxAcceleration,
+				QuadraticDrag:             State.PhysicsEngine.QuadraticDrag,
+				ExternalField:             State.PhysicsEngine.ExternalField,
+				FrameAngularVelocity:      State.PhysicsEngine.FrameAngularVelocity,
+				ColorScheme:               State.PhysicsEngine.ColorScheme,
 			},
-			NumberOfParticles: initialNumParticles,
-			AverageMass:       initialAverageMass,
-			HistoryLength:     initialHistLength,
-			PhysicsLoopSpeed:  initialLoopSpeed,
+			NumberOfParticles:   initialNumParticles,
+			AverageMass:         initialAverageMass,
+			InitialVelocityMode: State.InitialVelocityMode,
+			InitialSpeed:        State.InitialSpeed,
+			ChargeDistribution:  State.ChargeDistribution,
+			GenerationRegions:   State.GenerationRegions,
+			HistoryTrail:        State.HistoryTrail,
+			HistoryLength:       initialHistLength,
+			HistoryStride:       State.HistoryStride,
+			TrailStyle:          State.TrailStyle,
+			TrailColoring:       State.TrailColoring,
+			HistoryPrefill:      State.HistoryPrefill,
+			DrawRadiusScale:     State.DrawRadiusScale,
+			MinDrawRadius:       State.MinDrawRadius,
+			BackgroundColor:     State.BackgroundColor,
+			WallColor:           State.WallColor,
+			PauseSpeedThreshold: State.PauseSpeedThreshold,
+			PhysicsLoopSpeed:    initialLoopSpeed,
+			StepsPerFrame:       State.StepsPerFrame,
+			AutoSlowdown:        State.AutoSlowdown,
 		},
 		WinMinWidth:  minW,
 		WinMinHeight: minH,
 	}
+	// A loaded state provides all the initial values
+	if *load != "" {
+		initialValues.Data = State
+	}
+	// Returns once the GUI is closed (after calling ShutdownEvent)
 	GUI.CreateGUI(initialValues)
 
-	//Called after the window is closed
-	//physicsDoneChan <- true
-	//physicsTicker.Stop()
 	os.Exit(0)
 }
 
+// loadStartupState loads the simulation state from file (or stdin, if file is "-") at startup, exiting if it can't be
+// loaded.
+func loadStartupState(file string) {
+	r := io.Reader(os.Stdin)
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalln("Unable to open state file: " + err.Error())
+		}
+		defer f.Close()
+		r = f
+	}
+	if err := LoadStateFromReader(r); err != nil {
+		log.Fatalln("Unable to load state from " + file + ": " + err.Error())
+	}
+	log.Infoln("Settings and " + strconv.Itoa(len(State.PhysicsEngine.Particles)) + " particles loaded from " + file)
+}
+
+// startEventLog logs each physics.Event (mergers, and bounces if logBounces is set) to file, one JSON object per line,
+// exiting if the file can't be created. The file is left open (and written to) until the app exits.
+func startEventLog(file string, logBounces bool) {
+	f, err := os.Create(file)
+	if err != nil {
+		log.Fatalln("Unable to create event log: " + err.Error())
+	}
+	logger := log.New()
+	logger.SetOutput(f)
+	logger.SetFormatter(&log.JSONFormatter{})
+
+	physics.ReportBounces = logBounces
+	physics.EventListener = func(e physics.Event) {
+		particles := make([]log.Fields, len(e.Particles))
+		for i, p := range e.Particles {
+			particles[i] = eventParticleFields(p)
+		}
+		fields := log.Fields{"event": e.Kind.String(), "particles": particles}
+		if e.Result != nil {
+			fields["result"] = eventParticleFields(e.Result)
+		}
+		logger.WithFields(fields).Info(e.Kind.String())
+	}
+}
+
+// eventParticleFields gets the fields of Particle p logged to the event log (see startEventLog).
+func eventParticleFields(p *physics.Particle) log.Fields {
+	return log.Fields{"id": p.ID(), "mass": p.Mass(), "close_charge": p.CloseCharge(), "far_charge": p.FarCharge(),
+		"position": p.Position(), "velocity": p.Velocity()}
+}
+
 // physicsLoop loops forever / calls physics.UpdateParticles on the particles when the ticker ticks
-// and stops/returns when the physicsDoneChan is written to (or when paused).
-// The ticker is set up & started, or stopped, and this function is called as a goroutine, or physicsDoneChan is used
-// to exit from it, from PauseResumeEvent
-func physicsLoop() {
-	var startPhysicsExecTime time.Time
+// and stops/returns when done (its physicsDoneChan) is written to (or when paused).
+// The ticker is set up & started, or stopped, and this function is called as a goroutine, or done is used to exit from
+// it, from PauseResumeEvent. They're passed in (rather than read from physicsTicker and physicsDoneChan) as the
+// simulation may be resumed, replacing them, before a stopped loop has returned.
+func physicsLoop(ticker *time.Ticker, done chan bool) {
+	var startPhysicsExecTime, lastStartTime time.Time
+	// The smoothed frames (iterations) per second, and the time (ms) spent updating and drawing the particles
+	var fps, physicsTime, drawTime float64
+	// Whether a warning about skipped particle updates has been logged (it's only logged once, but displayed each time)
+	skippedLogged := false
+	// The number of iterations of the loop so far
+	iterations := 0
 
 	// Loop until done channel, executing the physics logic whenever the timer ticks
 	for {
 		// Waits for one of the conditions (since both cases are channels, it blocks rather than
-		// immediately falling through): either a value to written to physicsDoneChan, or a tick of the ticker
+		// immediately falling through): either a value to written to done, or a tick of the ticker
 		select {
 		// Executes, and so exits this function, if true (or, really anything) is written to the channel
-		case <-physicsDoneChan:
+		case <-done:
 			return
-		// Executes on physicsTicker tick
-		case <-physicsTicker.C:
-			if paused {
+		// Executes on ticker tick
+		case <-ticker.C:
+			if isPaused() {
 				return
 			} // Shouldn't be necessary but also doesn't hurt
 			startPhysicsExecTime = time.Now()
+			if !lastStartTime.IsZero() {
+				fps = smooth(fps, 1/startPhysicsExecTime.Sub(lastStartTime).Seconds(), iterations-1)
+			}
+			lastStartTime = startPhysicsExecTime
 
-			// Where all the magic happens
-			mergeOccurred, mergeMultiple, mergeSource, mergedResult := physics.UpdateParticles()
+			// Where all the magic happens: State.StepsPerFrame updates between draws ("turbo", if more than 1), unless
+			// an auto-stop trigger fires first (so the particles are drawn as they were when it did).
+			// The particles are read (for the status text and the auto-stop triggers) holding the particles lock, as
+			// the event handlers may be changing them meanwhile. It's released while updating and drawing, which take
+			// it themselves.
+			steps := stepsPerFrame()
+			skipped, ran, reason := 0, 0, ""
+			for ran < steps && reason == "" {
+				mergeOccurred, mergeMultiple, mergeSource, mergedResult := physics.UpdateParticles()
+				ran++
+				physics.RLockParticles()
+				skipped += physics.SkippedUpdates()
+				// Set status with merger info
+				if mergeOccurred {
+					GUI.SetStatusText(mergeText(mergeMultiple, mergeSource, mergedResult), mergeStatusTime)
+				}
+				reason = autoStopReason(mergeOccurred, mergeMultiple, mergeSource, mergedResult)
+				physics.RUnlockParticles()
+			}
+			// The physics time is per update, so it's comparable whatever the steps per frame
+			physicsTime = smooth(physicsTime, milliseconds(time.Since(startPhysicsExecTime))/float64(ran), iterations)
 
-			// Set status with merger info
-			if mergeOccurred {
-				statusText := fmt.Sprintf("Merging %s with %s", mergeSource.ShortString(),
-					reflect.ValueOf(mergeSource.MergingWith).MapKeys()[0].Interface().(*physics.Particle).ShortString())
-				if mergeMultiple {
-					statusText += " (et. al.)"
+			physics.RLockParticles()
+
+			// Warn if any particle updates were skipped because their forces weren't finite (e.g. the force
+			// strengths are too extreme)
+			if skipped > 0 {
+				warning := fmt.Sprintf("Warning: %d particle update(s) skipped due to infinite or NaN forces "+
+					"(try reducing the force strengths)", skipped)
+				if !skippedLogged {
+					log.Warnln(warning)
+					skippedLogged = true
 				}
-				statusText += ". Now: " + mergedResult.ShortString()
-				GUI.SetStatusText(statusText, 1500)
+				GUI.SetStatusText(warning, mergeStatusTime)
 			}
 
-			GUI.DrawParticles(State.PhysicsEngine.Particles)
+			// Periodically display the diagnostics and timings (unless a merger or warning is being displayed; see
+			// GUI.SetRoutineStatusText), which are kept until the next update
+			iterations++
+			if iterations%diagnosticsInterval == 0 {
+				text := diagnosticsText() + fmt.Sprintf("; FPS: %.1f (Physics: %.1f ms, Draw: %.1f ms)", fps,
+					physicsTime, drawTime)
+				if steps > 1 {
+					text += turboText(steps, fps, physicsTime, drawTime)
+				}
+				GUI.SetRoutineStatusText(text, diagnosticsInterval*State.PhysicsLoopSpeed)
+			}
+			physics.RUnlockParticles()
+
+			startDrawTime := time.Now()
+			GUI.DrawParticles()
+			drawTime = smooth(drawTime, milliseconds(time.Since(startDrawTime)), iterations-1)
+
+			// Pause if an auto-stop trigger fired (after drawing, so the particles are shown as they were when it did)
+			if reason != "" {
+				autoPause(done, "Paused: "+reason)
+				return
+			}
 
-			// Increase State.PhysicsLoopSpeed if actual execution time is longer than the requested time.
+			// Increase State.PhysicsLoopSpeed if actual execution time is longer than the requested time (if enabled).
+			// Otherwise the ticker drops the ticks missed meanwhile, so the next update starts immediately.
 			loopTime := int(time.Since(startPhysicsExecTime).Milliseconds())
-			fmt.Println(loopTime)
-			if loopTime > State.PhysicsLoopSpeed {
-				loopTime = int(float64(loopTime) * 1.05)
+			if State.AutoSlowdown && loopSlowdownMargin >= 0 && loopTime > State.PhysicsLoopSpeed {
+				loopTime = int(float64(loopTime) * (1 + loopSlowdownMargin))
 				GUI.SetPhysicsLoopSpeed(loopTime)
 				PhysicsLoopSpeedChangedEvent(loopTime)
 			}
@@ -178,40 +486,206 @@ func physicsLoop() {
 	}
 }
 
-// GenerateParticles generates random physics.Engine.Particles within the environment.
+// stepsPerFrame gets the number of physics.UpdateParticles calls the physicsLoop makes between drawing the particles
+// (see State.StepsPerFrame), which is at least 1.
+func stepsPerFrame() int {
+	if State.StepsPerFrame < 1 {
+		return 1
+	}
+	return State.StepsPerFrame
+}
+
+// autoStopReason gets the reason the simulation should automatically pause after an update (see State.PauseOnMerge
+// and State.PauseOnSpeed), given the merger info it returned (see physics.UpdateParticles), or "" if it shouldn't. The
+// caller must hold the particles lock (see physics.RLockParticles).
+func autoStopReason(mergeOccurred, mergeMultiple bool, mergeSource, mergedResult *physics.Particle) string {
+	if State.PauseOnMerge && mergeOccurred {
+		return mergeText(mergeMultiple, mergeSource, mergedResult)
+	}
+	if !State.PauseOnSpeed {
+		return ""
+	}
+	if p := physics.FastestParticle(); p != nil && p.Velocity().Magnitude() > State.PauseSpeedThreshold {
+		return fmt.Sprintf("%s exceeded the speed threshold (%.4g)", p.ShortString(), State.PauseSpeedThreshold)
+	}
+	return ""
+}
+
+// turboText gets the status text indicating the physicsLoop is running steps updates per frame (see
+// State.StepsPerFrame), given its smoothed frames per second and update and draw times (ms). The speedup is the
+// updates per second achieved, relative to drawing every update (each taking an update and a draw, and no less than
+// State.PhysicsLoopSpeed).
+func turboText(steps int, fps, physicsTime, drawTime float64) string {
+	stepsPerSecond := fps * float64(steps)
+	speedup := stepsPerSecond * math.Max(float64(State.PhysicsLoopSpeed), physicsTime+drawTime) / 1000
+	return fmt.Sprintf("; Turbo: %d steps/frame (%.0f steps/s, %.1fx)", steps, stepsPerSecond, speedup)
+}
+
+// autoPause pauses the simulation from within the physics loop whose done channel is done (which must then return),
+// such as when an auto-stop trigger fires, and tells the GUI and the user (displaying the reason). If the user has
+// paused the simulation meanwhile, it's left to the GUI (which paused it) to update its state.
+func autoPause(done chan bool, reason string) {
+	if !pause(done) {
+		return
+	}
+	GUI.SimulationPaused()
+	log.Infoln(reason)
+	GUI.SetStatusText(reason, autoPauseStatusTime)
+}
+
+// smooth adds a new measurement, sample, to the exponentially smoothed average avg (see timingSmoothing), and returns
+// the new average. count is the number of measurements previously added (the first measurement is used as is).
+func smooth(avg, sample float64, count int) float64 {
+	if count == 0 {
+		return sample
+	}
+	return avg + timingSmoothing*(sample-avg)
+}
+
+// milliseconds gets duration d in (fractional) milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// mergeText gets the description of a particle merger (see physics.UpdateParticles), as status text.
+func mergeText(mergeMultiple bool, mergeSource, mergedResult *physics.Particle) string {
+	other := reflect.ValueOf(mergeSource.MergingWith).MapKeys()[0].Interface().(*physics.Particle)
+	text := fmt.Sprintf("Merging #%d %s with #%d %s", mergeSource.ID(), mergeSource.ShortString(), other.ID(),
+		other.ShortString())
+	if mergeMultiple {
+		text += " (et. al.)"
+	}
+	return text + fmt.Sprintf(". Now: #%d %s", mergedResult.ID(), mergedResult.ShortString())
+}
+
+// diagnosticsText gets the number of particles (warning if it has reached physics.Engine.MaxParticles) and the
+// diagnostics (total kinetic energy and momentum, and center of mass) of the physics.Engine.Particles, as status text.
+func diagnosticsText() string {
+	m := physics.TotalMomentum()
+	c := physics.CenterOfMass()
+	count := strconv.Itoa(len(State.PhysicsEngine.Particles))
+	if physics.AtParticleLimit() {
+		count += " (Warning: limit reached)"
+	}
+	return fmt.Sprintf("# of Particles: %s; Kinetic Energy: %.4g; Momentum: (%.4g, %.4g); Center of Mass: (%.1f, %.1f)",
+		count, physics.TotalKineticEnergy(), m[0], m[1], c[0], c[1])
+}
+
+// GenerateParticles generates random physics.Engine.Particles within the environment (State.NumberOfParticles of them,
+// but no more than physics.Engine.MaxParticles), with close charges drawn from State.ChargeDistribution, velocities
+// initialized according to State.InitialVelocityMode, and random species (if physics.Engine.SpeciesMatrix is set).
+// The particles lock is held while the particles are replaced (see physics.LockParticles), so it mustn't be held by
+// the caller.
 func GenerateParticles() {
-	State.PhysicsEngine.Particles = make([]*physics.Particle, State.NumberOfParticles, State.NumberOfParticles)
+	n := State.NumberOfParticles
+	if State.PhysicsEngine.MaxParticles > 0 && n > State.PhysicsEngine.MaxParticles {
+		n = State.PhysicsEngine.MaxParticles
+	}
+	particles := make([]*physics.Particle, n, n)
 
 	var m, cc, fc, x, y float64
-	for i := 0; i < len(State.PhysicsEngine.Particles); i++ {
+	for i := 0; i < len(particles); i++ {
 		// Random mass, normally distributed around State.AverageMass
 		m = math.Min(math.Max(
 			rand.NormFloat64()*0.55*float64(State.AverageMass)+float64(State.AverageMass),
 			math.Max(4, 0.2*float64(State.AverageMass))), 1.75*float64(State.AverageMass))
-		// For the charges, we just want a random number across the range, not a normal distribution
-		cc = rand.Float64()*2.0 - 1.0
+		cc = closeCharge()
+		// For the far charge, we just want a random number across the range, not a normal distribution
 		fc = rand.Float64()
-		// Random position.
-		x = rand.Float64() * float64(State.PhysicsEngine.EnvironmentSize)
-		y = rand.Float64() * float64(State.PhysicsEngine.EnvironmentSize)
-		State.PhysicsEngine.Particles[i] = physics.NewParticle(m, cc, fc, x, y)
+		if State.PhysicsEngine.SignedFarCharge {
+			fc = 2*fc - 1
+		}
+		// Random position
+		x, y = generationPosition(i)
+		particles[i] = physics.NewParticle(m, cc, fc, x, y)
+		particles[i].SetVelocity(initialVelocity(x, y))
+		// Species are only assigned (randomly) if they affect the forces
+		if species := len(State.PhysicsEngine.SpeciesMatrix); species > 0 {
+			particles[i].SetSpecies(rand.Intn(species))
+		}
 	}
-	// Initialize history trails (enable/disable them in particles & create their empty position history "lists").
-	HistoryTrailChangedEvent(State.HistoryTrail)
-	HistoryTrailLengthChangedEvent(State.HistoryLength)
 
-	physics.SaveInitialParticleStates()
+	// Replace the particles (this initializes their history trails using the current settings, and saves their initial
+	// states)
+	physics.LockParticles()
+	physics.SetParticles(particles)
+	physics.UnlockParticles()
 }
 
-// initRandom seeds math.rand with crypto/rand (imported as cryptorand), such that future math.rand operations are more or less cryptographically
-// secure. It falls back to seeding with current nanosecond time. Without either, the math/rand package will always
-// initialize with the same seed (0, I think).
+// generationPosition gets a random position for the i-th generated particle: within the (i modulo the number of
+// regions)th of State.GenerationRegions, or anywhere in the environment if there are none.
+func generationPosition(i int) (x, y float64) {
+	if len(State.GenerationRegions) == 0 {
+		return rand.Float64() * float64(State.PhysicsEngine.EnvironmentWidth),
+			rand.Float64() * float64(State.PhysicsEngine.EnvironmentHeight)
+	}
+	r := State.GenerationRegions[i%len(State.GenerationRegions)]
+	switch r.Shape {
+	case state.RegionRectangle:
+		return r.X + (rand.Float64()-0.5)*r.Width, r.Y + (rand.Float64()-0.5)*r.Height
+	default:
+		// The square root spreads the positions uniformly over the circle's area (rather than bunching them at its
+		// center)
+		d := r.Radius * math.Sqrt(rand.Float64())
+		a := rand.Float64() * 2 * math.Pi
+		return r.X + d*math.Cos(a), r.Y + d*math.Sin(a)
+	}
+}
+
+// closeCharge gets a random close charge for a generated particle, drawn from State.ChargeDistribution.
+func closeCharge() float64 {
+	switch State.ChargeDistribution {
+	case state.ChargesBimodal:
+		// Normally distributed just inside -1 or 1 (clamped to the charge range)
+		c := math.Min(rand.NormFloat64()*0.1+0.9, 1)
+		if rand.Intn(2) == 0 {
+			return -c
+		}
+		return c
+	case state.ChargesNormal:
+		// Normally distributed around zero (clamped to the charge range)
+		return math.Min(math.Max(rand.NormFloat64()*0.35, -1), 1)
+	default:
+		// Just a random number across the range, not a normal distribution
+		return rand.Float64()*2.0 - 1.0
+	}
+}
+
+// initialVelocity gets the initial velocity of a particle generated at (x, y), according to State.InitialVelocityMode
+// (scaled by State.InitialSpeed).
+func initialVelocity(x, y float64) vector.Vector {
+	switch State.InitialVelocityMode {
+	case state.VelocityThermal:
+		return vector.NewWithValues([]float64{rand.NormFloat64() * State.InitialSpeed,
+			rand.NormFloat64() * State.InitialSpeed})
+	case state.VelocityTangential:
+		cx := float64(State.PhysicsEngine.EnvironmentWidth) / 2
+		cy := float64(State.PhysicsEngine.EnvironmentHeight) / 2
+		// The angular speed which gives particles at the nearest edge State.InitialSpeed
+		w := State.InitialSpeed / math.Max(math.Min(cx, cy), 1)
+		// Perpendicular to (x-cx, y-cy), so the particles circle the center (clockwise, as displayed)
+		return vector.NewWithValues([]float64{-(y - cy) * w, (x - cx) * w})
+	default:
+		return vector.New(2)
+	}
+}
+
+// initRandom seeds math.rand with the provided seed if seeded is true (so that runs, e.g. the generated particles, can
+// be reproduced). Otherwise, it seeds math.rand with crypto/rand (imported as cryptorand), such that future math.rand
+// operations are more or less cryptographically secure. It falls back to seeding with current nanosecond time. Without
+// either, the math/rand package will always initialize with the same seed (0, I think).
+// Returns the seed used.
 // See: https://stackoverflow.com/a/54491783/5061881
 // Imports:
 // cryptorand "crypto/rand"
 // log "github.com/sirupsen/logrus"
 // TODO: Move this to CCSL
-func initRandom() {
+func initRandom(seed int64, seeded bool) int64 {
+	if seeded {
+		rand.Seed(seed)
+		return seed
+	}
+
 	// Gets 8 bytes using the cryptographically secure random package, and casts them into a uint64 and then an int64
 	// (if you use a random byte for the most significant byte of a signed int64 you aren't randomly assigning the sign
 	// bit, thus the conversion to unsigned first). I believe it shouldn't matter whether you use LittleEndian or
@@ -220,8 +694,10 @@ func initRandom() {
 	_, err := cryptorand.Read(b[:])
 	if err != nil {
 		log.Warnln("Cannot seed math/rand package with cryptographically secure RNG, using time seed.")
-		rand.Seed(time.Now().UTC().UnixNano())
-		return
+		seed = time.Now().UTC().UnixNano()
+	} else {
+		seed = int64(binary.LittleEndian.Uint64(b[:]))
 	}
-	rand.Seed(int64(binary.LittleEndian.Uint64(b[:])))
+	rand.Seed(seed)
+	return seed
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"GoGoGadgetGravity/physics"
+	"GoGoGadgetGravity/state"
+)
+
+// TestGenerateParticlesLimit checks that regenerating the particles never generates more than
+// physics.Engine.MaxParticles of them (if set), however many are requested.
+func TestGenerateParticlesLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		requested    int
+		maxParticles int
+		want         int
+	}{
+		{"unlimited", 60, 0, 60},
+		{"under the limit", 60, 100, 60},
+		{"at the limit", 60, 60, 60},
+		{"over the limit", 60, 25, 25},
+	}
+	for _, test := range tests {
+		physics.Engine = physics.EngineData{}
+		physics.Engine.Initialize()
+		physics.Engine.MaxParticles = test.maxParticles
+		State = &state.Data{PhysicsEngine: &physics.Engine, NumberOfParticles: test.requested,
+			AverageMass: initialAverageMass, InitialSpeed: initialSpeed, HistoryStride: 1}
+
+		// Regenerating repeatedly replaces the particles rather than adding to them
+		for i := 0; i < 2; i++ {
+			GenerateParticles()
+			if got := len(physics.Engine.Particles); got != test.want {
+				t.Errorf("%s (regeneration %d): got %d particles, want %d", test.name, i+1, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package physics
+
+import "sync"
+
+// particlesLock guards Engine.Particles: both the slice (which mergers, fission, and particle limits replace or
+// reslice) and the states of the particles in it. UpdateParticles holds it for writing while it updates the particles,
+// so the GUI (reading the particles to draw or inspect them) and the main app (changing them in response to the user)
+// must hold it while they access the particles from another goroutine than the one updating them.
+//
+// Lock ordering: particlesLock is acquired before the package's other locks (attractorLock, genealogyLock, and
+// trajectoryLock, which UpdateParticles takes while holding it), and before any of the GUI's own locks (the GUI draws,
+// taking its image locks, while holding it for reading). None of those locks may be held while acquiring it. It isn't
+// reentrant, so the functions which acquire it (UpdateParticles and StartTrajectory, and the GUI's DrawParticles) must
+// not be called while it is held, and it must not be acquired for writing while it is held for reading.
+var particlesLock sync.RWMutex
+
+// LockParticles locks the Engine.Particles for changing them (see particlesLock), waiting for any update or read in
+// progress to finish. The particles won't be updated or read (by holders of RLockParticles) until UnlockParticles is
+// called.
+func LockParticles() {
+	particlesLock.Lock()
+}
+
+// UnlockParticles unlocks the Engine.Particles locked by LockParticles.
+func UnlockParticles() {
+	particlesLock.Unlock()
+}
+
+// RLockParticles locks the Engine.Particles for reading them (see particlesLock), waiting for any update or change in
+// progress to finish. Any number of readers may hold the lock at once, but the particles won't be updated or changed
+// until they have all called RUnlockParticles.
+func RLockParticles() {
+	particlesLock.RLock()
+}
+
+// RUnlockParticles unlocks the Engine.Particles locked by RLockParticles.
+func RUnlockParticles() {
+	particlesLock.RUnlock()
+}
@@ -0,0 +1,51 @@
+package physics
+
+import "testing"
+
+// TestParticlesLock checks that the particles may be read and changed, holding the particles lock, while they're
+// updated in another goroutine (as the GUI and the event handlers do while the physics loop runs). It's only
+// meaningful when run with the race detector (go test -race).
+func TestParticlesLock(t *testing.T) {
+	resetEngine(randomParticles(100, 97)...)
+	// Mergers replace the Engine.Particles slice, as well as changing the particles in it
+	Engine.AllowMerge = true
+
+	const steps = 50
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < steps; i++ {
+			UpdateParticles()
+		}
+	}()
+
+	reads := 0
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		RLockParticles()
+		var mass, x float64
+		for _, p := range Engine.Particles {
+			mass += p.Mass()
+			x += p.Position()[0]
+		}
+		RUnlockParticles()
+		if mass <= 0 || x <= 0 {
+			t.Errorf("got total mass %g and x %g, want positive", mass, x)
+		}
+
+		LockParticles()
+		if reads%10 == 0 {
+			ZeroVelocities()
+		}
+		ConfineParticles()
+		UnlockParticles()
+		reads++
+	}
+	if len(Engine.Particles) == 0 {
+		t.Error("got no particles")
+	}
+}
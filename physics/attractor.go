@@ -0,0 +1,69 @@
+package physics
+
+import (
+	"sync"
+
+	"github.com/atedja/go-vector"
+)
+
+// attractorRange is the distance (in environment units) from the attractor at which its acceleration has fallen to half
+// its strength (see SetAttractor).
+const attractorRange = 100
+
+// attractorPoint is a transient point particles are attracted to (or repelled from; see SetAttractor).
+type attractorPoint struct {
+	position vector.Vector
+	strength float64
+}
+
+var (
+	// attractor is the current attractor, or nil if there isn't one.
+	attractor *attractorPoint
+	// attractorLock guards attractor, which is set and cleared by the GUI while the particles are updated.
+	attractorLock sync.Mutex
+)
+
+// SetAttractor sets (replacing any current one) a transient attractor at (x, y), which accelerates each (non-fixed)
+// particle toward it, or away from it if strength is negative, until cleared with ClearAttractor. The acceleration is
+// strength at the attractor (though particles exactly on it aren't accelerated) and falls off with distance d as
+// 1/(1 + (d/attractorRange)²), so only nearby particles are much affected. It's applied (as is the external field)
+// without being averaged with the other forces. The attractor isn't part of the EngineData, so isn't saved.
+func SetAttractor(x, y, strength float64) {
+	attractorLock.Lock()
+	defer attractorLock.Unlock()
+	attractor = &attractorPoint{position: vector.NewWithValues([]float64{x, y}), strength: strength}
+}
+
+// ClearAttractor clears the attractor set with SetAttractor, if any.
+func ClearAttractor() {
+	attractorLock.Lock()
+	defer attractorLock.Unlock()
+	attractor = nil
+}
+
+// currentAttractor returns the current attractor, or nil if there isn't one. The attractorPoint is never modified once
+// set, so it's safe to use after the lock is released (for the duration of an update).
+func currentAttractor() *attractorPoint {
+	attractorLock.Lock()
+	defer attractorLock.Unlock()
+	return attractor
+}
+
+// attractorAcceleration calculates the acceleration (velocity change, scaled by time step dt, if using the
+// SemiImplicitEuler integrator) of Particle p due to attractor a (see SetAttractor), which may be nil.
+func attractorAcceleration(p *Particle, a *attractorPoint, dt float64) vector.Vector {
+	if a == nil {
+		return vector.New(2)
+	}
+	d := minimumImage(vector.Subtract(a.position, p.Position()))
+	dist := d.Magnitude()
+	if dist == 0 {
+		return vector.New(2)
+	}
+	scale := a.strength / (1 + (dist/attractorRange)*(dist/attractorRange)) / dist
+	if Engine.Integrator == SemiImplicitEuler {
+		scale *= dt
+	}
+	d.Scale(scale)
+	return d
+}
@@ -0,0 +1,146 @@
+package physics
+
+import "math"
+
+// ColorScheme is the type for the schemes which may be used to calculate the display colors (the R, G, B, and A
+// proxies) of particles (see EngineData.ColorScheme). It is an index into ColorSchemes.
+type ColorScheme int
+
+const (
+	// ChargeRedGreen colors negative close charge red and positive close charge green (the closer to -1 or 1, the more
+	// red or green; 0 is black). This is the default (zero value) scheme.
+	ChargeRedGreen ColorScheme = iota
+	// ChargeDiverging colors negative close charge red and positive close charge blue, fading to light grey at 0.
+	ChargeDiverging
+	// ChargeColorblind colors negative close charge orange and positive close charge blue (0 is black), which remain
+	// distinguishable with the common forms of color blindness.
+	ChargeColorblind
+	// Speed colors particles by their speed (velocity magnitude), from dark purple (stationary) through teal to yellow
+	// (fast).
+	Speed
+	// Species colors particles by their species (see EngineData.SpeciesMatrix), each species a distinct hue.
+	Species
+)
+
+// ColorFunc is the type for functions which calculate the display color (red, green, blue, and alpha) of a particle.
+type ColorFunc func(p *Particle) (r, g, b, a uint8)
+
+// ColorSchemeData describes a ColorScheme.
+type ColorSchemeData struct {
+	// Name is the display name of the scheme.
+	Name string
+	// Color calculates the display color of a particle.
+	Color ColorFunc
+}
+
+// ColorSchemes holds the available color schemes, indexed by ColorScheme. Additional schemes may be appended.
+// In all the built-in schemes, alpha is a proxy for farCharge (see farChargeAlpha).
+var ColorSchemes = []ColorSchemeData{
+	ChargeRedGreen:   {Name: "Charge (Red/Green)", Color: chargeRedGreenColor},
+	ChargeDiverging:  {Name: "Charge (Red/Blue)", Color: chargeDivergingColor},
+	ChargeColorblind: {Name: "Charge (Colorblind Safe)", Color: chargeColorblindColor},
+	Speed:            {Name: "Speed", Color: speedColor},
+	Species:          {Name: "Species", Color: speciesColor},
+}
+
+// speedColorMidpoint is the speed at which particles are colored with the midpoint of the Speed scheme's color ramp.
+// Slower particles approach the start of the ramp, and faster particles its end.
+const speedColorMidpoint = 2
+
+// RecolorParticles recalculates the display colors of all Engine.Particles, such as after Engine.ColorScheme is
+// changed.
+func RecolorParticles() {
+	for _, p := range Engine.Particles {
+		p.updateColor()
+	}
+}
+
+// updateColor recalculates the display color proxies (R, G, B, and A) using the Engine.ColorScheme (or the default
+// scheme, if it isn't valid).
+func (p *Particle) updateColor() {
+	scheme := ColorSchemes[ChargeRedGreen]
+	if Engine.ColorScheme >= 0 && int(Engine.ColorScheme) < len(ColorSchemes) {
+		scheme = ColorSchemes[Engine.ColorScheme]
+	}
+	p.R, p.G, p.B, p.A = scheme.Color(p)
+}
+
+// chargeRedGreenColor implements the ChargeRedGreen ColorScheme.
+func chargeRedGreenColor(p *Particle) (r, g, b, a uint8) {
+	// Negative charge is red, the closer to -1 the more red (0 is black)
+	if p.CloseCharge() < 0 {
+		// We don't want to round here, it's expensive and unnecessary
+		r = uint8(255.0 * math.Abs(p.CloseCharge()))
+		// Positive charge is green, the closer to 1 the more green (0 is black)
+	} else {
+		// We don't want to round here, it's expensive and unnecessary
+		g = uint8(255.0 * math.Abs(p.CloseCharge()))
+	}
+	return r, g, 0, farChargeAlpha(p)
+}
+
+// chargeDivergingColor implements the ChargeDiverging ColorScheme.
+func chargeDivergingColor(p *Particle) (r, g, b, a uint8) {
+	r, g, b = divergingColor(p.CloseCharge(), [3]uint8{180, 4, 38}, [3]uint8{221, 221, 221}, [3]uint8{59, 76, 192})
+	return r, g, b, farChargeAlpha(p)
+}
+
+// chargeColorblindColor implements the ChargeColorblind ColorScheme.
+func chargeColorblindColor(p *Particle) (r, g, b, a uint8) {
+	r, g, b = divergingColor(p.CloseCharge(), [3]uint8{230, 159, 0}, [3]uint8{0, 0, 0}, [3]uint8{0, 114, 178})
+	return r, g, b, farChargeAlpha(p)
+}
+
+// speedColor implements the Speed ColorScheme.
+func speedColor(p *Particle) (r, g, b, a uint8) {
+	speed := 0.0
+	if p.Velocity() != nil {
+		speed = p.Velocity().Magnitude()
+	}
+	// Map the (unbounded) speed to 0-1, reaching 0.5 at speedColorMidpoint
+	t := speed / (speed + speedColorMidpoint)
+	r, g, b = divergingColor(2*t-1, [3]uint8{68, 1, 84}, [3]uint8{33, 145, 140}, [3]uint8{253, 231, 37})
+	return r, g, b, farChargeAlpha(p)
+}
+
+// speciesColor implements the Species ColorScheme. Successive species' hues are a golden angle apart, so any number of
+// species have distinct (and, for the first few, well separated) hues.
+func speciesColor(p *Particle) (r, g, b, a uint8) {
+	hue := math.Mod(float64(p.Species())*0.381966, 1)
+	if hue < 0 {
+		hue++
+	}
+	r, g, b = hueColor(hue)
+	return r, g, b, farChargeAlpha(p)
+}
+
+// hueColor gets the fully saturated, bright color of hue h (in the range 0 to 1, starting and ending at red).
+func hueColor(h float64) (r, g, b uint8) {
+	channel := func(offset float64) uint8 {
+		// The distance (in sixths of the hue circle) from the channel's peak, giving a trapezoidal ramp
+		k := math.Mod(offset+h*6, 6)
+		return uint8(230 * (1 - math.Max(0, math.Min(math.Min(k, 4-k), 1))))
+	}
+	return channel(5), channel(3), channel(1)
+}
+
+// farChargeAlpha calculates the alpha proxy for the farCharge of Particle p, from its magnitude (so negative charges,
+// if Engine.SignedFarCharge is set, are as opaque as the positive charges of the same size).
+func farChargeAlpha(p *Particle) uint8 {
+	// Alpha range 48 - 255 (we don't want 0 charge to be fully transparent, we want to always be able to see particles)
+	return uint8(207*math.Abs(p.FarCharge())) + 48
+}
+
+// divergingColor interpolates a color for value v (in the range -1 to 1) between the colors low (at -1), mid (at 0),
+// and high (at 1).
+func divergingColor(v float64, low, mid, high [3]uint8) (r, g, b uint8) {
+	end := high
+	if v < 0 {
+		end = low
+	}
+	t := math.Min(math.Abs(v), 1)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return lerp(mid[0], end[0]), lerp(mid[1], end[1]), lerp(mid[2], end[2])
+}
@@ -0,0 +1,173 @@
+package physics
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/atedja/go-vector"
+)
+
+// TotalKineticEnergy calculates the total kinetic energy (the sum of mass * speed^2 / 2) of the Engine.Particles.
+func TotalKineticEnergy() float64 {
+	e := 0.0
+	for _, p := range Engine.Particles {
+		speed := p.Velocity().Magnitude()
+		e += 0.5 * p.Mass() * speed * speed
+	}
+	return e
+}
+
+// TotalMomentum calculates the total momentum (the sum of mass * velocity) of the Engine.Particles.
+func TotalMomentum() vector.Vector {
+	m := vector.New(2)
+	for _, p := range Engine.Particles {
+		m[0] += p.Mass() * p.Velocity()[0]
+		m[1] += p.Mass() * p.Velocity()[1]
+	}
+	return m
+}
+
+// FastestParticle gets the fastest (largest velocity magnitude) of the Engine.Particles, or nil if there are no
+// particles.
+func FastestParticle() *Particle {
+	var fastest *Particle
+	speed := 0.0
+	for _, p := range Engine.Particles {
+		if s := p.Velocity().Magnitude(); fastest == nil || s > speed {
+			fastest, speed = p, s
+		}
+	}
+	return fastest
+}
+
+// CenterOfMass calculates the center of mass (the mass weighted average position) of the Engine.Particles. If the
+// environment wraps around, the positions are used as is (particles near opposite edges aren't treated as close).
+// Returns the zero vector if there are no particles.
+func CenterOfMass() vector.Vector {
+	c := vector.New(2)
+	mass := 0.0
+	for _, p := range Engine.Particles {
+		c[0] += p.Mass() * p.Position()[0]
+		c[1] += p.Mass() * p.Position()[1]
+		mass += p.Mass()
+	}
+	if mass > 0 {
+		c.Scale(1 / mass)
+	}
+	return c
+}
+
+// CenterOfMassVelocity calculates the velocity of the center of mass (the total momentum divided by the total mass) of
+// the Engine.Particles. Returns the zero vector if there are no particles.
+func CenterOfMassVelocity() vector.Vector {
+	v := TotalMomentum()
+	mass := 0.0
+	for _, p := range Engine.Particles {
+		mass += p.Mass()
+	}
+	if mass > 0 {
+		v.Scale(1 / mass)
+	}
+	return v
+}
+
+// BoundingBox calculates the smallest rectangle containing all of the Engine.Particles (including their radii), as its
+// minimum and maximum x and y coordinates. If the environment wraps around, the positions are used as is (particles
+// overlapping opposite edges make the box span the environment). Returns all zeros if there are no particles.
+func BoundingBox() (minX, minY, maxX, maxY float64) {
+	if len(Engine.Particles) == 0 {
+		return 0, 0, 0, 0
+	}
+	minX, minY, maxX, maxY = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range Engine.Particles {
+		r := float64(p.Radius)
+		minX, maxX = math.Min(minX, p.Position()[0]-r), math.Max(maxX, p.Position()[0]+r)
+		minY, maxY = math.Min(minY, p.Position()[1]-r), math.Max(maxY, p.Position()[1]+r)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// ClosestPair finds the two Engine.Particles whose centers are nearest each other, and the distance between their
+// centers (the nearest distance, if the environment wraps around). It is read-only, so it's useful for finding near
+// misses and tuning the collision settings; every pair is compared, so it is slow with many particles. Returns nil
+// particles (and a distance of 0) if there are fewer than two particles.
+func ClosestPair() (*Particle, *Particle, float64) {
+	var a, b *Particle
+	closest := 0.0
+	for i, p := range Engine.Particles {
+		for _, o := range Engine.Particles[i+1:] {
+			d := minimumImage(vector.Subtract(p.Position(), o.Position())).Magnitude()
+			if a == nil || d < closest {
+				a, b, closest = p, o, d
+			}
+		}
+	}
+	return a, b, closest
+}
+
+// DominantForce gets the name ("Gravity", "Close Charge", or "Far Charge") and magnitude of the largest of the
+// accelerations Particle p currently feels from the other particles (see ForcesOn).
+func DominantForce(p *Particle) (name string, magnitude float64) {
+	g, c, f := ForcesOn(p)
+	name, magnitude = "Gravity", g.Magnitude()
+	if m := c.Magnitude(); m > magnitude {
+		name, magnitude = "Close Charge", m
+	}
+	if m := f.Magnitude(); m > magnitude {
+		name, magnitude = "Far Charge", m
+	}
+	return name, magnitude
+}
+
+// RunSummary summarizes the state of the Engine.Particles (see Summarize), such as at the end of a run.
+type RunSummary struct {
+	// Particles is the number of particles
+	Particles int
+	// TotalMass is the total mass of the particles
+	TotalMass float64
+	// CenterOfMass is the particles' center of mass (see CenterOfMass)
+	CenterOfMass vector.Vector
+	// Hash is a (hex) SHA-256 hash of each particle's ID, mass, position, and velocity
+	Hash string
+}
+
+// Summarize summarizes the current state of the Engine.Particles, so that runs can be compared: running the same
+// scenario (e.g. with the same random seed) for the same number of steps before and after a change to the engine, and
+// comparing the summaries, checks whether the change affects the simulation's behavior. The hash covers every particle
+// (sorted by ID, so it doesn't depend on the order of the Engine.Particles), with its values printed to 10 significant
+// digits (so it isn't affected by differences in the last bits of the values, which are expected from reordering
+// floating point operations).
+func Summarize() RunSummary {
+	particles := make([]*Particle, len(Engine.Particles))
+	copy(particles, Engine.Particles)
+	sort.Slice(particles, func(i, j int) bool {
+		return particles[i].ID() < particles[j].ID()
+	})
+
+	s := RunSummary{Particles: len(particles), CenterOfMass: CenterOfMass()}
+	h := sha256.New()
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', 10, 64)
+	}
+	for _, p := range particles {
+		s.TotalMass += p.Mass()
+		pos, vel := p.Position(), p.Velocity()
+		fmt.Fprintf(h, "%d %s %s %s %s %s\n", p.ID(), format(p.Mass()), format(pos[0]), format(pos[1]),
+			format(vel[0]), format(vel[1]))
+	}
+	s.Hash = fmt.Sprintf("%x", h.Sum(nil))
+	return s
+}
+
+// String formats the RunSummary for logging.
+func (s RunSummary) String() string {
+	com := "none"
+	if len(s.CenterOfMass) == 2 {
+		com = fmt.Sprintf("(%.6g, %.6g)", s.CenterOfMass[0], s.CenterOfMass[1])
+	}
+	return fmt.Sprintf("particles: %d, total mass: %.6g, center of mass: %s, hash: %s", s.Particles, s.TotalMass,
+		com, s.Hash)
+}
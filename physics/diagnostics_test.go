@@ -0,0 +1,77 @@
+package physics
+
+import "testing"
+
+// TestClosestPair checks that the two particles nearest each other (across the edges, if the environment wraps around)
+// and their distance are found.
+func TestClosestPair(t *testing.T) {
+	tests := []struct {
+		name      string
+		positions [][2]float64
+		wrap      bool
+		// wantA and wantB are the indexes of the closest particles (-1 if there aren't two particles)
+		wantA, wantB int
+		wantDist     float64
+	}{
+		{"none", nil, false, -1, -1, 0},
+		{"one", [][2]float64{{100, 100}}, false, -1, -1, 0},
+		{"three", [][2]float64{{100, 100}, {400, 400}, {103, 104}}, false, 0, 2, 5},
+		{"wrapped", [][2]float64{{100, 100}, {2, 400}, {797, 400}}, true, 1, 2, 5},
+		{"not wrapped", [][2]float64{{100, 100}, {2, 400}, {797, 404}, {100, 110}}, false, 0, 3, 10},
+	}
+	for _, test := range tests {
+		particles := make([]*Particle, len(test.positions))
+		for i, pos := range test.positions {
+			particles[i] = NewParticle(10, 0, 0, pos[0], pos[1])
+		}
+		resetEngine(particles...)
+		Engine.WallBounce, Engine.WrapBoundary = !test.wrap, test.wrap
+		a, b, d := ClosestPair()
+		if test.wantA < 0 {
+			if a != nil || b != nil || d != 0 {
+				t.Errorf("%s: got %v and %v (%g apart), want none", test.name, a, b, d)
+			}
+			continue
+		}
+		want := map[*Particle]bool{particles[test.wantA]: true, particles[test.wantB]: true}
+		if !want[a] || !want[b] || a == b || !closeTo(d, test.wantDist) {
+			t.Errorf("%s: got %v and %v (%g apart), want particles %d and %d (%g apart)", test.name, a, b, d,
+				test.wantA, test.wantB, test.wantDist)
+		}
+	}
+}
+
+// TestBoundingBox checks that the box contains all the particles, including their radii, and that particles overlapping
+// opposite edges of a wrapping environment make it span the environment.
+func TestBoundingBox(t *testing.T) {
+	tests := []struct {
+		name      string
+		positions [][2]float64
+		wrap      bool
+		// want is the box (minimum x and y, maximum x and y) of the particle centers, which is grown by their radius
+		// (all zeros if there are no particles)
+		want [4]float64
+	}{
+		{"none", nil, false, [4]float64{}},
+		{"one", [][2]float64{{100, 200}}, false, [4]float64{100, 200, 100, 200}},
+		{"several", [][2]float64{{100, 200}, {400, 50}, {250, 300}}, false, [4]float64{100, 50, 400, 300}},
+		{"wrapped", [][2]float64{{1, 400}, {799, 2}, {400, 798}}, true, [4]float64{1, 2, 799, 798}},
+	}
+	for _, test := range tests {
+		particles := make([]*Particle, len(test.positions))
+		for i, pos := range test.positions {
+			particles[i] = NewParticle(10, 0, 0, pos[0], pos[1])
+		}
+		resetEngine(particles...)
+		Engine.WallBounce, Engine.WrapBoundary = !test.wrap, test.wrap
+		r := 0.0
+		if len(particles) > 0 {
+			r = float64(particles[0].Radius)
+		}
+		want := [4]float64{test.want[0] - r, test.want[1] - r, test.want[2] + r, test.want[3] + r}
+		minX, minY, maxX, maxY := BoundingBox()
+		if got := [4]float64{minX, minY, maxX, maxY}; got != want {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+	}
+}
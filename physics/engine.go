@@ -3,6 +3,80 @@
 // are called iteratively/repeatedly via the main app physics loop).
 package physics
 
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/atedja/go-vector"
+)
+
+// Integrator is the type for the numerical integration methods which may be used to update particle velocities and
+// positions each step (see EngineData.Integrator).
+type Integrator int
+
+const (
+	// SemiImplicitEuler updates velocities from the accelerations at the current positions, and then updates positions
+	// using the new velocities. This is the default (zero value) integrator.
+	SemiImplicitEuler Integrator = iota
+	// Euler (explicit Euler) updates positions using the velocities from the start of the step, and then updates
+	// velocities from the accelerations at the starting positions.
+	Euler
+	// VelocityVerlet updates positions using the velocities and (previous) accelerations from the start of the step,
+	// calculates the accelerations at the new positions, and then updates velocities using the average of the previous
+	// and new accelerations. It conserves energy much better than the Euler integrators (e.g. for orbits).
+	VelocityVerlet
+)
+
+// EdgeMode is the type for the ways the edges of the environment may treat particles reaching them (see
+// EngineData.LeftEdge etc.).
+type EdgeMode int
+
+const (
+	// EdgeDefault edges follow the WallBounce and WrapBoundary settings: they bounce particles if WallBounce is set,
+	// wrap them around if WrapBoundary is set, and otherwise let them travel beyond the edge. This is the default (zero
+	// value) mode.
+	EdgeDefault EdgeMode = iota
+	// EdgeBounce edges bounce particles back into the environment.
+	EdgeBounce
+	// EdgeWrap edges move particles crossing them to the opposite side of the environment. Forces only act across the
+	// edges if both edges of an axis (e.g. LeftEdge and RightEdge) wrap.
+	EdgeWrap
+	// EdgeOpen edges let particles escape: particles which have entirely crossed them are removed.
+	EdgeOpen
+)
+
+// ChargeMergeRule is the type for the ways the charges of merging particles may be combined (see
+// EngineData.ChargeMergeRule).
+type ChargeMergeRule int
+
+const (
+	// ChargeMergeAverage gives the merged particle the mass weighted average of the merging particles' charges. This is
+	// the default (zero value) rule.
+	ChargeMergeAverage ChargeMergeRule = iota
+	// ChargeMergeSum gives the merged particle the sum of the merging particles' charges, clamped to the valid charge
+	// ranges (see Particle.SetCloseCharge and Particle.SetFarCharge).
+	ChargeMergeSum
+	// ChargeMergeMax gives the merged particle the largest of the merging particles' charges (for close charge, the
+	// most positive).
+	ChargeMergeMax
+)
+
+// HistoryPrefill is the type for the ways an empty position history may be pre-filled when position tracking starts,
+// so that a full trail is drawn immediately (see SetHistoryPrefill).
+type HistoryPrefill int
+
+const (
+	// PrefillNone leaves the position history empty, so the trail grows as the particle moves. This is the default
+	// (zero value) mode.
+	PrefillNone HistoryPrefill = iota
+	// PrefillRepeat fills the position history with the particle's current position.
+	PrefillRepeat
+	// PrefillExtrapolate fills the position history with the positions the particle would have had if it had been
+	// moving at its current velocity (extrapolated backward from its current position).
+	PrefillExtrapolate
+)
+
 // Engine is the EngineData instance, effectively the physics engine instance.
 // Particle objects use the fields of this struct instance. To control the behavior of the physics engine, set the
 // fields of this instance (via a pointer if desired). Do not create any other objects of this type (you will not be
@@ -12,54 +86,473 @@ var Engine EngineData
 // EngineData is the type for Engine. DO NOT create any other instances of this type. This type is exported so that
 // pointers to Engine can be created outside this package (e.g. as a field in state.Data).
 type EngineData struct {
-	// GravityStrength is the gravitational constant, essentially (acts on Mass)
+	// GravityStrength is the gravitational constant, essentially (acts on Mass). Gravity is attractive if it's positive,
+	// and repulsive ("anti-gravity") if it's negative.
 	GravityStrength float64 `json:"gravity_strength"`
 	// CloseChargeStrength is the Coulomb constant, essentially (acts on CloseCharge)
 	CloseChargeStrength float64 `json:"close_charge_strength"`
 	// FarChargeStrength is the Coulomb constant, essentially (acts on FarCharge)
 	FarChargeStrength float64 `json:"far_charge_strength"`
+	// FarChargeExponent is the power of the distance between particles the far charge force is proportional to. The
+	// default, 1, makes the force proportional to the distance (so it grows with distance); 0 makes it constant, and
+	// negative values make it fall off with distance.
+	FarChargeExponent float64 `json:"far_charge_exponent"`
+	// FarChargeCutoff is the distance beyond which particles don't exert far charge forces on each other, so that far
+	// charge acts like a finite range spring rather than pulling ever harder across the environment. 0 (the default)
+	// means there is no cutoff.
+	FarChargeCutoff float64 `json:"far_charge_cutoff"`
+	// GravityOnly determines whether the close and far charge forces are suppressed, leaving only gravity (and drag,
+	// the external field, and collisions). The particles keep their charges (which still determine their colors and
+	// whether they may merge), so the charge forces resume when it's disabled.
+	GravityOnly bool `json:"gravity_only"`
+	// EnableGravity, EnableCloseCharge, and EnableFarCharge determine whether each force acts between particles (all
+	// are enabled by default), so that any combination of them can be isolated. A disabled force is ignored entirely,
+	// regardless of its strength (which is kept, rather than zeroed, so the force resumes as it was when re-enabled).
+	// GravityOnly suppresses the charge forces even if they're enabled.
+	EnableGravity     bool `json:"enable_gravity"`
+	EnableCloseCharge bool `json:"enable_close_charge"`
+	EnableFarCharge   bool `json:"enable_far_charge"`
+	// SignedFarCharge determines whether particles' far charges range from -1 to 1, rather than from 0 to 1 (the
+	// default). The far charge force is proportional to the product of the charges, so particles whose far charges have
+	// the same sign attract (as all particles do by default) and those with opposite signs repel. Particle.SetFarCharge
+	// clamps charges to the range, so call ClampFarCharges after disabling it.
+	SignedFarCharge bool `json:"signed_far_charge"`
+	// SpeciesMatrix, if not nil, makes the close charge force between two particles depend on their species (see
+	// Particle.Species, similar to "particle life" models): the force is multiplied by SpeciesMatrix[p][o], where p is
+	// the species of the particle feeling the force and o that of the particle exerting it (so the matrix needn't be
+	// symmetric). It must be square; particles whose species are outside it are unaffected (the multiplier is 1).
+	// nil (the default) ignores species.
+	SpeciesMatrix [][]float64 `json:"species_matrix"`
 
-	// EnvironmentSize is the quantized size of the environment (relative to particle size, which is determined by mass)
-	EnvironmentSize int `json:"environment_size"`
+	// EnvironmentWidth and EnvironmentHeight are the quantized size of the environment (relative to particle size,
+	// which is determined by mass).
+	EnvironmentWidth  int `json:"environment_width"`
+	EnvironmentHeight int `json:"environment_height"`
 	// AllowMerge determines whether particles may merge when the collide. If disabled, particles always bounce. If
 	// enabled, they may merge or bounce depending on their relative masses and close charges.
 	AllowMerge bool `json:"allow_merge"`
 	// WallBounce determines whether particles bounce off the "walls" of the environment (or more accurately, whether
 	// the environment - as represented here in the physics engine and particle positions - is bounded by
-	// EnvironmentSize or is unbounded)
+	// EnvironmentWidth and EnvironmentHeight or is unbounded)
 	WallBounce bool `json:"wall_bounce"`
+	// WrapBoundary determines whether the environment wraps around (is toroidal): particles crossing an edge reappear
+	// on the opposite side, and forces between particles act across the edges (using the shortest distance between
+	// them). WrapBoundary and WallBounce are mutually exclusive; if both are set, WallBounce takes precedence.
+	WrapBoundary bool `json:"wrap_boundary"`
+	// LeftEdge, RightEdge, TopEdge, and BottomEdge set how each edge of the environment treats particles reaching it,
+	// independently of the others. Edges left at EdgeDefault follow WallBounce and WrapBoundary, which (via SetEdges)
+	// therefore remain a convenient way to set all four.
+	LeftEdge   EdgeMode `json:"left_edge"`
+	RightEdge  EdgeMode `json:"right_edge"`
+	TopEdge    EdgeMode `json:"top_edge"`
+	BottomEdge EdgeMode `json:"bottom_edge"`
 
-	// bounceCompleteDistFactor is used to determine when a particle bounce is complete (so forces don't get
-	// exceptionally large when particles get very close to each other)
-	bounceCompleteDistFactor float64
-	// mergeMassRatioThreshold is the ratio between particle masses above which particles may merge if not overridden
+	// TimeStep is the amount of simulation time each call to UpdateParticles advances the simulation by. Accelerations
+	// and velocities are scaled by it. Together with the physics loop speed (how often UpdateParticles is called), it
+	// determines how fast the simulation runs in real time.
+	TimeStep float64 `json:"time_step"`
+	// SubSteps is the number of steps each call to UpdateParticles divides TimeStep into (each sub-step being
+	// TimeStep/SubSteps). More sub-steps are more accurate/stable, but slower.
+	SubSteps int `json:"sub_steps"`
+	// AdaptiveSubstep enables dividing each of the SubSteps further, only when needed: when a pair of particles which
+	// could come into contact during a sub-step would close more than a fraction of their combined radii in it (see
+	// adaptiveSubsteps), the sub-step is divided so that they don't. This resolves fast close encounters finely
+	// without slowing the rest of the simulation.
+	AdaptiveSubstep bool `json:"adaptive_substep"`
+	// MaxAdaptiveSubsteps is the most sub-steps AdaptiveSubstep divides each of the SubSteps into.
+	MaxAdaptiveSubsteps int `json:"max_adaptive_substeps"`
+	// SofteningLength is added (in quadrature) to the distance between particles in the gravity and close charge force
+	// denominators, which keeps the forces from growing without bound as particles get very close to each other.
+	// 0 means no softening.
+	SofteningLength float64 `json:"softening_length"`
+	// MaxSpeed is the maximum speed (velocity magnitude) of a particle; faster particles are slowed to this speed.
+	// 0 means unlimited.
+	MaxSpeed float64 `json:"max_speed"`
+	// MaxAcceleration is the maximum magnitude of a particle's summed acceleration (from all the forces, drag, the
+	// external field, the attractor, and the rotating frame) each step; larger accelerations are scaled down to it.
+	// It's a safety net taming the huge forces between particles which get very close, without softening the forces at
+	// other distances.
+	// 0 means unlimited.
+	MaxAcceleration float64 `json:"max_acceleration"`
+	// DragCoefficient is the strength of the background (vacuum) drag, which opposes particle velocities so that the
+	// simulation slowly loses energy (and settles, rather than heating up from numerical error). The drag acceleration
+	// is -DragCoefficient * velocity, or -DragCoefficient * speed * velocity if QuadraticDrag is set. 0 means no drag.
+	DragCoefficient float64 `json:"drag_coefficient"`
+	// ExternalField is a uniform (constant, directional) acceleration applied to every non-fixed particle, like gravity
+	// near the Earth's surface, independent of the other particles (so, combined with WallBounce, particles settle
+	// against a wall). Positive y is down. nil (or the zero vector) means no external field. See
+	// SetExternalFieldPolar.
+	ExternalField vector.Vector `json:"external_field"`
+	// FrameAngularVelocity, if not 0, runs the simulation in a reference frame rotating (clockwise as drawn, y being
+	// down, if positive) at this many radians per time unit about the center of the environment, such as to model an
+	// accretion disk. The frame's fictitious forces are applied to every non-fixed particle (see frameAcceleration):
+	// the centrifugal acceleration (ω²r, outward from the center) and the Coriolis acceleration (-2ω × v).
+	FrameAngularVelocity float64 `json:"frame_angular_velocity"`
+	// QuadraticDrag determines whether drag is proportional to the square of the speed (rather than the speed).
+	QuadraticDrag bool `json:"quadratic_drag"`
+	// Integrator is the numerical integration method used to update particle velocities and positions each step.
+	Integrator Integrator `json:"integrator"`
+	// ColorScheme is the scheme used to calculate the display colors of particles (see ColorSchemes). Call
+	// RecolorParticles after changing it.
+	ColorScheme ColorScheme `json:"color_scheme"`
+
+	// UseBarnesHut determines whether the Barnes-Hut approximation is used when summing forces between particles. If
+	// enabled, a quadtree is built over the particle positions each update and distant groups of particles are treated
+	// as a single particle at their center of mass (rather than comparing every pair of particles). Collisions and
+	// interactions with nearby particles are still calculated exactly.
+	UseBarnesHut bool `json:"use_barnes_hut"`
+	// Theta is the Barnes-Hut opening angle: a quadtree node is approximated if its size divided by its distance from
+	// the particle is less than Theta. Smaller values are more accurate (0 is equivalent to the exact calculation) and
+	// slower.
+	// Barnes-Hut is not used while WrapBoundary is enabled.
+	Theta float64 `json:"theta"`
+	// Workers is the number of goroutines the force calculations are split across. Values less than 2 calculate the
+	// forces serially.
+	Workers int `json:"workers"`
+
+	// BounceCompleteDistFactor is used to determine when a particle bounce is complete (so forces don't get
+	// exceptionally large when particles get very close to each other): particles stop bouncing against each other once
+	// the distance between them exceeds this factor times their combined radii.
+	BounceCompleteDistFactor float64 `json:"bounce_complete_dist_factor"`
+	// Restitution is the coefficient of restitution of bounces (off walls and between particles), in the range 0 to 1:
+	// the fraction of the closing speed along the contact normal which is reversed. 1 (the default) is a perfectly
+	// elastic bounce, lower values lose energy, and 0 stops particles along the normal.
+	Restitution float64 `json:"restitution"`
+	// MergeMassRatioThreshold is the ratio between particle masses above which particles may merge if not overridden
 	// by close charge repulsion
-	mergeMassRatioThreshold float64
-	// mergeCloseChargeThreshold is the summed (same sign) close charge of two particles above which particles cannot
+	MergeMassRatioThreshold float64 `json:"merge_mass_ratio_threshold"`
+	// MergeCloseChargeThreshold is the summed (same sign) close charge of two particles above which particles cannot
 	// merge. If particles have opposite sign close charges, they are allowed to merge if AllowMerge is true and one is
 	// sufficiently larger than the other.
-	mergeCloseChargeThreshold float64
+	MergeCloseChargeThreshold float64 `json:"merge_close_charge_threshold"`
+	// CaptureSpeed is the approach speed (the closing speed along the line of centers) of two colliding particles above
+	// which they bounce rather than merge, even if they could otherwise merge (so fast, head-on collisions don't merge).
+	// 0 (the default) means there's no limit.
+	CaptureSpeed float64 `json:"capture_speed"`
+	// ChargeMergeRule is the way the close and far charges of merging particles are combined into those of the merged
+	// particle.
+	ChargeMergeRule ChargeMergeRule `json:"charge_merge_rule"`
+	// AllowFission determines whether particles more massive than MaxMass split in two (so that long runs with
+	// AllowMerge enabled don't collapse into a few enormous particles). Fixed particles never split.
+	AllowFission bool `json:"allow_fission"`
+	// MaxMass is the mass above which particles split in two, if AllowFission is enabled.
+	MaxMass float64 `json:"max_mass"`
+	// MaxParticles is the maximum number of particles. Particles don't split once there are this many, and any beyond
+	// it (such as added while paused) are removed at the end of each update. 0 means unlimited.
+	MaxParticles int `json:"max_particles"`
 
 	// Particles is the slice of particles the physics engine acts on.
-	Particles []*Particle `json:"particles"`
+	Particles []*Particle `json:"particles,omitempty"`
 	// initialParticles is used to reset particles to their original state
 	initialParticles []*Particle
+	// trackHistory, historySize, and historyStride are the position history settings given to particles added with
+	// AddParticle or SetParticles (see SetParticleHistory).
+	trackHistory  bool
+	historySize   int
+	historyStride int
+	// historyPrefill is the way empty position histories are pre-filled when tracking starts (see SetHistoryPrefill).
+	historyPrefill HistoryPrefill
+	// skippedUpdates is the number of particle velocity updates skipped during the last call to UpdateParticles
+	// because the forces acting on the particle weren't finite (see SkippedUpdates).
+	skippedUpdates int
+	// stepAttractor is the attractor (see SetAttractor) during the current call to UpdateParticles, or nil if none.
+	stepAttractor *attractorPoint
 }
 
+// maxForceStrength is the largest value (magnitude, for GravityStrength) Validate allows for the force strengths
+// (GravityStrength, CloseChargeStrength, and FarChargeStrength). Larger values quickly produce infinite accelerations.
+const maxForceStrength = 1e12
+
+// maxFarChargeExponent is the largest magnitude Validate allows for FarChargeExponent.
+const maxFarChargeExponent = 3
+
 // Initialize initializes the physics Engine and sets all default values (call before setting any Engine field values).
 // Does NOT initialize Particles.
+// It initializes the instance it is called on, which should be Engine except when preparing EngineData to be decoded
+// into (so that any values missing from the decoded data, such as from older save files, keep their defaults).
 // Presently, *only* sets default values, but a it's good idea to call it even if you're initializing all values,
 // in case other logic is added in future.
-func (*EngineData) Initialize() {
-	Engine.GravityStrength = 15
-	Engine.CloseChargeStrength = 150000000
-	Engine.FarChargeStrength = 7.5
-
-	Engine.EnvironmentSize = 800
-	Engine.AllowMerge = true
-	Engine.WallBounce = true
-
-	Engine.bounceCompleteDistFactor = 1.5
-	Engine.mergeMassRatioThreshold = 2.5
-	Engine.mergeCloseChargeThreshold = 0.25
+func (e *EngineData) Initialize() {
+	e.GravityStrength = 15
+	e.CloseChargeStrength = 150000000
+	e.FarChargeStrength = 7.5
+	e.FarChargeExponent = 1
+	e.FarChargeCutoff = 0
+	e.GravityOnly = false
+	e.EnableGravity = true
+	e.EnableCloseCharge = true
+	e.EnableFarCharge = true
+	e.SignedFarCharge = false
+	e.SpeciesMatrix = nil
+
+	e.EnvironmentWidth = 800
+	e.EnvironmentHeight = 800
+	e.AllowMerge = true
+	e.WallBounce = true
+	e.WrapBoundary = false
+	e.SetEdges(EdgeDefault)
+
+	e.TimeStep = 1
+	e.SubSteps = 1
+	e.AdaptiveSubstep = false
+	e.MaxAdaptiveSubsteps = 16
+	e.SofteningLength = 0
+	e.MaxSpeed = 0
+	e.MaxAcceleration = 0
+	e.DragCoefficient = 0
+	e.QuadraticDrag = false
+	e.ExternalField = nil
+	e.FrameAngularVelocity = 0
+	e.Integrator = SemiImplicitEuler
+	e.ColorScheme = ChargeRedGreen
+
+	e.UseBarnesHut = false
+	e.Theta = 0.5
+	e.Workers = 1
+
+	e.BounceCompleteDistFactor = 1.5
+	e.Restitution = 1
+	e.MergeMassRatioThreshold = 2.5
+	e.MergeCloseChargeThreshold = 0.25
+	e.CaptureSpeed = 0
+	e.ChargeMergeRule = ChargeMergeAverage
+	e.AllowFission = false
+	e.MaxMass = 5000
+	e.MaxParticles = 0
+}
+
+// ResetToDefaults restores all the settings to their defaults (see Initialize), except those describing the current
+// particles: the Particles themselves and the environment size they're placed within (EnvironmentWidth and
+// EnvironmentHeight).
+func (e *EngineData) ResetToDefaults() {
+	particles, width, height := e.Particles, e.EnvironmentWidth, e.EnvironmentHeight
+	e.Initialize()
+	e.Particles, e.EnvironmentWidth, e.EnvironmentHeight = particles, width, height
+}
+
+// SetEnvironmentSize sets both the EnvironmentWidth and EnvironmentHeight to size (a square environment).
+//
+// Deprecated: EnvironmentSize was the single dimension of the (always square) environment before the width and height
+// could differ. Set EnvironmentWidth and EnvironmentHeight instead.
+func (e *EngineData) SetEnvironmentSize(size int) {
+	e.EnvironmentWidth = size
+	e.EnvironmentHeight = size
+}
+
+// environmentExtent gets the size of the environment along axis (0 for the width, 1 for the height).
+func (e *EngineData) environmentExtent(axis int) float64 {
+	if axis == 0 {
+		return float64(e.EnvironmentWidth)
+	}
+	return float64(e.EnvironmentHeight)
+}
+
+// SetEdges sets the mode of all four edges of the environment (LeftEdge, RightEdge, TopEdge, and BottomEdge).
+func (e *EngineData) SetEdges(mode EdgeMode) {
+	e.LeftEdge, e.RightEdge, e.TopEdge, e.BottomEdge = mode, mode, mode, mode
+}
+
+// edgeMode gets the mode of the edge at the start (left or top, if far is false) or end (right or bottom) of axis (0
+// for the sides, 1 for the top and bottom). EdgeDefault is resolved according to WallBounce and WrapBoundary, so it is
+// only returned for edges particles may travel beyond.
+func (e *EngineData) edgeMode(axis int, far bool) EdgeMode {
+	var mode EdgeMode
+	switch {
+	case axis == 0 && !far:
+		mode = e.LeftEdge
+	case axis == 0:
+		mode = e.RightEdge
+	case !far:
+		mode = e.TopEdge
+	default:
+		mode = e.BottomEdge
+	}
+	if mode != EdgeDefault {
+		return mode
+	}
+	if e.WallBounce {
+		return EdgeBounce
+	}
+	if e.WrapBoundary {
+		return EdgeWrap
+	}
+	return EdgeDefault
+}
+
+// wrapsAxis indicates whether the environment wraps around along axis (both of its edges wrap), in which case forces
+// between particles act across those edges.
+func (e *EngineData) wrapsAxis(axis int) bool {
+	return e.edgeMode(axis, false) == EdgeWrap && e.edgeMode(axis, true) == EdgeWrap
+}
+
+// wrapping indicates whether the environment currently wraps around along either axis (see wrapsAxis).
+func (e *EngineData) wrapping() bool {
+	return e.wrapsAxis(0) || e.wrapsAxis(1)
+}
+
+// Validate checks that the engine settings are within their valid ranges (e.g. that the force strengths are finite, not
+// negative (except GravityStrength), and not so large that they produce infinite accelerations, and that there is at
+// least one sub-step), clamping any which aren't. Settings which aren't numbers (NaN) are reset to their defaults.
+// Returns an error describing the settings which were corrected, or nil if they were all valid.
+func (e *EngineData) Validate() error {
+	defaults := EngineData{}
+	defaults.Initialize()
+	var corrected []string
+
+	clamp := func(name string, value *float64, min, max, def float64) {
+		v := *value
+		if math.IsNaN(v) {
+			v = def
+		}
+		v = math.Max(min, math.Min(v, max))
+		if v != *value {
+			corrected = append(corrected, fmt.Sprintf("%s (%g -> %g)", name, *value, v))
+			*value = v
+		}
+	}
+	clampInt := func(name string, value *int, min int) {
+		if *value < min {
+			corrected = append(corrected, fmt.Sprintf("%s (%d -> %d)", name, *value, min))
+			*value = min
+		}
+	}
+
+	clamp("GravityStrength", &e.GravityStrength, -maxForceStrength, maxForceStrength, defaults.GravityStrength)
+	clamp("CloseChargeStrength", &e.CloseChargeStrength, 0, maxForceStrength, defaults.CloseChargeStrength)
+	clamp("FarChargeStrength", &e.FarChargeStrength, 0, maxForceStrength, defaults.FarChargeStrength)
+	clamp("FarChargeExponent", &e.FarChargeExponent, -maxFarChargeExponent, maxFarChargeExponent,
+		defaults.FarChargeExponent)
+	clamp("FarChargeCutoff", &e.FarChargeCutoff, 0, math.MaxFloat64, defaults.FarChargeCutoff)
+	// The species matrix must be square, with finite entries
+	for _, row := range e.SpeciesMatrix {
+		if len(row) != len(e.SpeciesMatrix) || !finite(row) {
+			corrected = append(corrected, fmt.Sprintf("SpeciesMatrix (%v -> none)", e.SpeciesMatrix))
+			e.SpeciesMatrix = nil
+			break
+		}
+	}
+	clampInt("EnvironmentWidth", &e.EnvironmentWidth, 1)
+	clampInt("EnvironmentHeight", &e.EnvironmentHeight, 1)
+	// Unknown edge modes are reset to the default
+	for _, edge := range [...]struct {
+		name string
+		mode *EdgeMode
+	}{{"LeftEdge", &e.LeftEdge}, {"RightEdge", &e.RightEdge}, {"TopEdge", &e.TopEdge}, {"BottomEdge", &e.BottomEdge}} {
+		if *edge.mode < EdgeDefault || *edge.mode > EdgeOpen {
+			corrected = append(corrected, fmt.Sprintf("%s (%d -> %d)", edge.name, *edge.mode, EdgeDefault))
+			*edge.mode = EdgeDefault
+		}
+	}
+	// A zero (or infinite) time step doesn't advance (or breaks) the simulation
+	if e.TimeStep <= 0 || math.IsNaN(e.TimeStep) || math.IsInf(e.TimeStep, 0) {
+		corrected = append(corrected, fmt.Sprintf("TimeStep (%g -> %g)", e.TimeStep, defaults.TimeStep))
+		e.TimeStep = defaults.TimeStep
+	}
+	clampInt("SubSteps", &e.SubSteps, 1)
+	clampInt("MaxAdaptiveSubsteps", &e.MaxAdaptiveSubsteps, 1)
+	clamp("SofteningLength", &e.SofteningLength, 0, math.MaxFloat64, defaults.SofteningLength)
+	clamp("MaxSpeed", &e.MaxSpeed, 0, math.MaxFloat64, defaults.MaxSpeed)
+	clamp("MaxAcceleration", &e.MaxAcceleration, 0, math.MaxFloat64, defaults.MaxAcceleration)
+	clamp("DragCoefficient", &e.DragCoefficient, 0, math.MaxFloat64, defaults.DragCoefficient)
+	// The external field must be a finite 2D vector
+	if e.ExternalField != nil && (len(e.ExternalField) != 2 || !finite(e.ExternalField)) {
+		corrected = append(corrected, fmt.Sprintf("ExternalField (%v -> none)", e.ExternalField))
+		e.ExternalField = nil
+	}
+	clamp("FrameAngularVelocity", &e.FrameAngularVelocity, -math.MaxFloat64, math.MaxFloat64,
+		defaults.FrameAngularVelocity)
+	clamp("Theta", &e.Theta, 0, math.MaxFloat64, defaults.Theta)
+	// Bounces must complete outside the distance at which particles collide
+	clamp("BounceCompleteDistFactor", &e.BounceCompleteDistFactor, 1, math.MaxFloat64,
+		defaults.BounceCompleteDistFactor)
+	clamp("Restitution", &e.Restitution, 0, 1, defaults.Restitution)
+	clamp("MergeMassRatioThreshold", &e.MergeMassRatioThreshold, 1, math.MaxFloat64,
+		defaults.MergeMassRatioThreshold)
+	clamp("MergeCloseChargeThreshold", &e.MergeCloseChargeThreshold, 0, math.MaxFloat64,
+		defaults.MergeCloseChargeThreshold)
+	clamp("CaptureSpeed", &e.CaptureSpeed, 0, math.MaxFloat64, defaults.CaptureSpeed)
+	// Unknown charge merge rules are reset to the default
+	if e.ChargeMergeRule < ChargeMergeAverage || e.ChargeMergeRule > ChargeMergeMax {
+		corrected = append(corrected, fmt.Sprintf("ChargeMergeRule (%d -> %d)", e.ChargeMergeRule,
+			defaults.ChargeMergeRule))
+		e.ChargeMergeRule = defaults.ChargeMergeRule
+	}
+	clamp("MaxMass", &e.MaxMass, 0, math.MaxFloat64, defaults.MaxMass)
+	clampInt("MaxParticles", &e.MaxParticles, 0)
+
+	if len(corrected) > 0 {
+		return fmt.Errorf("invalid settings corrected: %s", strings.Join(corrected, ", "))
+	}
+	return nil
+}
+
+// SetExternalFieldPolar sets the ExternalField from its strength (acceleration magnitude) and direction (angle, in
+// degrees clockwise from the positive x-axis, so 90 is down). A strength of 0 removes the field.
+func (e *EngineData) SetExternalFieldPolar(strength, angle float64) {
+	if strength == 0 {
+		e.ExternalField = nil
+		return
+	}
+	rad := angle * math.Pi / 180
+	e.ExternalField = vector.NewWithValues([]float64{strength * math.Cos(rad), strength * math.Sin(rad)})
+}
+
+// ExternalFieldPolar gets the strength and direction (angle, in degrees clockwise from the positive x-axis, in the
+// range 0 to 360) of the ExternalField. The angle of a missing (or zero) field is 90 (down).
+func (e *EngineData) ExternalFieldPolar() (strength, angle float64) {
+	if len(e.ExternalField) != 2 || e.ExternalField.Magnitude() == 0 {
+		return 0, 90
+	}
+	angle = math.Atan2(e.ExternalField[1], e.ExternalField[0]) * 180 / math.Pi
+	if angle < 0 {
+		angle += 360
+	}
+	return e.ExternalField.Magnitude(), angle
+}
+
+// speciesFactor gets the multiplier applied to the close charge force Particle o exerts on Particle p (see
+// EngineData.SpeciesMatrix).
+func speciesFactor(p, o *Particle) float64 {
+	return speciesPairFactor(p.Species(), o.Species())
+}
+
+// speciesPairFactor gets the multiplier applied to the close charge force a particle of species o exerts on a particle
+// of species p (see EngineData.SpeciesMatrix).
+func speciesPairFactor(p, o int) float64 {
+	if p < 0 || o < 0 || p >= len(Engine.SpeciesMatrix) || o >= len(Engine.SpeciesMatrix[p]) {
+		return 1
+	}
+	return Engine.SpeciesMatrix[p][o]
+}
+
+// AtParticleLimit indicates whether there are as many Engine.Particles as allowed (see EngineData.MaxParticles), in
+// which case particles can't split.
+func AtParticleLimit() bool {
+	return Engine.MaxParticles > 0 && len(Engine.Particles) >= Engine.MaxParticles
+}
+
+// SkippedUpdates gets the number of particle velocity updates skipped during the last call to UpdateParticles because
+// the forces acting on the particle weren't finite (such as with extreme force strengths, or particles very close to
+// each other). The particles keep their previous velocities rather than being corrupted (and corrupting the particles
+// they act on) with infinite or NaN values.
+func SkippedUpdates() int {
+	return Engine.skippedUpdates
+}
+
+// Clone creates a copy of the EngineData, including copies of its Particles (and the initial particle states used to
+// reset them; see SaveInitialParticleStates), such as to snapshot the Engine so it may be restored later.
+func (e *EngineData) Clone() *EngineData {
+	c := *e
+	if e.ExternalField != nil {
+		c.ExternalField = e.ExternalField.Clone()
+	}
+	if e.SpeciesMatrix != nil {
+		c.SpeciesMatrix = make([][]float64, len(e.SpeciesMatrix))
+		for i, row := range e.SpeciesMatrix {
+			c.SpeciesMatrix[i] = append([]float64(nil), row...)
+		}
+	}
+	c.Particles = cloneParticles(e.Particles)
+	c.initialParticles = cloneParticles(e.initialParticles)
+	return &c
 }
@@ -0,0 +1,159 @@
+package physics
+
+import (
+	"math"
+	"testing"
+)
+
+// TestExternalFieldPolar checks that the ExternalField is set from, and converted back to, its strength and direction.
+func TestExternalFieldPolar(t *testing.T) {
+	tests := []struct {
+		strength, angle       float64
+		wantX, wantY          float64
+		wantStrength, wantDir float64
+	}{
+		{0, 45, 0, 0, 0, 90},
+		{2, 0, 2, 0, 2, 0},
+		{2, 90, 0, 2, 2, 90},
+		{3, 180, -3, 0, 3, 180},
+		{3, -90, 0, -3, 3, 270},
+	}
+	for _, test := range tests {
+		e := EngineData{}
+		e.Initialize()
+		e.SetExternalFieldPolar(test.strength, test.angle)
+		if test.strength == 0 {
+			if e.ExternalField != nil {
+				t.Errorf("strength 0: got field %v, want none", e.ExternalField)
+			}
+		} else if !closeTo(e.ExternalField[0], test.wantX) || !closeTo(e.ExternalField[1], test.wantY) {
+			t.Errorf("strength %g, angle %g: got field %v, want [%g %g]", test.strength, test.angle, e.ExternalField,
+				test.wantX, test.wantY)
+		}
+		if strength, angle := e.ExternalFieldPolar(); !closeTo(strength, test.wantStrength) ||
+			!closeTo(angle, test.wantDir) {
+			t.Errorf("strength %g, angle %g: got polar %g, %g, want %g, %g", test.strength, test.angle, strength,
+				angle, test.wantStrength, test.wantDir)
+		}
+	}
+}
+
+// TestEdgeMode checks that edges left at EdgeDefault follow WallBounce and WrapBoundary, and others keep their mode.
+func TestEdgeMode(t *testing.T) {
+	tests := []struct {
+		mode             EdgeMode
+		wallBounce, wrap bool
+		want             EdgeMode
+		wantWraps        bool
+	}{
+		{EdgeDefault, true, false, EdgeBounce, false},
+		{EdgeDefault, false, true, EdgeWrap, true},
+		{EdgeDefault, true, true, EdgeBounce, false},
+		{EdgeDefault, false, false, EdgeDefault, false},
+		{EdgeOpen, true, false, EdgeOpen, false},
+		{EdgeWrap, false, false, EdgeWrap, false},
+	}
+	for _, test := range tests {
+		e := EngineData{}
+		e.Initialize()
+		e.WallBounce, e.WrapBoundary = test.wallBounce, test.wrap
+		e.LeftEdge = test.mode
+		if got := e.edgeMode(0, false); got != test.want {
+			t.Errorf("mode %d, WallBounce %v, WrapBoundary %v: got %d, want %d", test.mode, test.wallBounce,
+				test.wrap, got, test.want)
+		}
+		// The axis only wraps if both of its edges do
+		if got := e.wrapsAxis(0); got != test.wantWraps {
+			t.Errorf("mode %d, WallBounce %v, WrapBoundary %v: got wraps %v, want %v", test.mode, test.wallBounce,
+				test.wrap, got, test.wantWraps)
+		}
+	}
+}
+
+// TestValidateGravityStrength checks that Validate allows negative (repulsive) gravity, within the same bound as
+// positive gravity.
+func TestValidateGravityStrength(t *testing.T) {
+	tests := []struct {
+		strength, want float64
+		wantErr        bool
+	}{
+		{15, 15, false},
+		{-5, -5, false},
+		{-2 * maxForceStrength, -maxForceStrength, true},
+		{2 * maxForceStrength, maxForceStrength, true},
+		{math.NaN(), 15, true},
+	}
+	for _, test := range tests {
+		e := EngineData{}
+		e.Initialize()
+		e.GravityStrength = test.strength
+		err := e.Validate()
+		if e.GravityStrength != test.want || (err != nil) != test.wantErr {
+			t.Errorf("strength %g: got %g (error %v), want %g (error %v)", test.strength, e.GravityStrength, err,
+				test.want, test.wantErr)
+		}
+	}
+}
+
+// TestValidate checks that invalid settings are corrected (and reported), and valid ones left unchanged.
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func(e *EngineData)
+		check   func(e *EngineData) bool
+		wantErr bool
+	}{
+		{"defaults", func(e *EngineData) {}, func(e *EngineData) bool { return true }, false},
+		{"frame angular velocity", func(e *EngineData) { e.FrameAngularVelocity = -0.01 },
+			func(e *EngineData) bool { return e.FrameAngularVelocity == -0.01 }, false},
+		{"NaN frame angular velocity", func(e *EngineData) { e.FrameAngularVelocity = math.NaN() },
+			func(e *EngineData) bool { return e.FrameAngularVelocity == 0 }, true},
+		{"time step", func(e *EngineData) { e.TimeStep = 0 }, func(e *EngineData) bool { return e.TimeStep == 1 },
+			true},
+		{"sub-steps", func(e *EngineData) { e.SubSteps = 0 }, func(e *EngineData) bool { return e.SubSteps == 1 },
+			true},
+		{"restitution", func(e *EngineData) { e.Restitution = 2 },
+			func(e *EngineData) bool { return e.Restitution == 1 }, true},
+		{"external field", func(e *EngineData) { e.ExternalField = []float64{1} },
+			func(e *EngineData) bool { return e.ExternalField == nil }, true},
+		{"species matrix", func(e *EngineData) { e.SpeciesMatrix = [][]float64{{1, 2}} },
+			func(e *EngineData) bool { return e.SpeciesMatrix == nil }, true},
+		{"edge mode", func(e *EngineData) { e.TopEdge = EdgeOpen + 1 },
+			func(e *EngineData) bool { return e.TopEdge == EdgeDefault }, true},
+	}
+	for _, test := range tests {
+		e := EngineData{}
+		e.Initialize()
+		test.set(&e)
+		err := e.Validate()
+		if (err != nil) != test.wantErr || !test.check(&e) {
+			t.Errorf("%s: got error %v and %+v, want error %v", test.name, err, e, test.wantErr)
+		}
+	}
+}
+
+// TestClone checks that a cloned EngineData holds copies of the particles and other reference values, so changing it
+// doesn't change the original.
+func TestClone(t *testing.T) {
+	e := EngineData{}
+	e.Initialize()
+	e.FrameAngularVelocity = 0.02
+	e.SetExternalFieldPolar(1, 90)
+	e.SpeciesMatrix = [][]float64{{1, 0.5}, {-1, 1}}
+	e.Particles = []*Particle{NewParticle(10, 0.5, 0.5, 100, 100)}
+	e.initialParticles = cloneParticles(e.Particles)
+
+	c := e.Clone()
+	if c.FrameAngularVelocity != e.FrameAngularVelocity || len(c.Particles) != 1 || len(c.initialParticles) != 1 ||
+		c.Particles[0].String() != e.Particles[0].String() {
+		t.Fatalf("got clone %+v, want a copy of %+v", c, e)
+	}
+	c.ExternalField[0] = 5
+	c.SpeciesMatrix[0][1] = 5
+	c.Particles[0].Position()[0] = 5
+	c.initialParticles[0].Position()[0] = 5
+	if e.ExternalField[0] == 5 || e.SpeciesMatrix[0][1] == 5 || e.Particles[0].Position()[0] == 5 ||
+		e.initialParticles[0].Position()[0] == 5 {
+		t.Errorf("changing the clone changed the original")
+	}
+}
@@ -0,0 +1,49 @@
+package physics
+
+// EventKind is the type for the kinds of simulation events reported to the EventListener (see Event).
+type EventKind int
+
+const (
+	// MergeEvent is reported when particles merge.
+	MergeEvent EventKind = iota
+	// BounceEvent is reported when two particles bounce against each other (only if ReportBounces is set).
+	BounceEvent
+)
+
+// String gets the name of the EventKind.
+func (k EventKind) String() string {
+	switch k {
+	case MergeEvent:
+		return "merge"
+	case BounceEvent:
+		return "bounce"
+	}
+	return "unknown"
+}
+
+// Event describes a simulation event, such as a merger, reported to the EventListener.
+type Event struct {
+	// Kind is the kind of event.
+	Kind EventKind
+	// Particles are the particles involved: for mergers, those which merged (the largest first), and for bounces, the
+	// two particles bouncing.
+	Particles []*Particle
+	// Result is the merged particle, for mergers (nil for other events).
+	Result *Particle
+}
+
+var (
+	// EventListener, if not nil, is called with each Event as it occurs (on the goroutine calling UpdateParticles), so
+	// that events can be logged or analysed. It must not change the particles.
+	EventListener func(e Event)
+	// ReportBounces indicates whether bounces are reported to the EventListener (there may be many more bounces than
+	// mergers).
+	ReportBounces bool
+)
+
+// reportEvent reports Event e to the EventListener, if there is one.
+func reportEvent(e Event) {
+	if EventListener != nil {
+		EventListener(e)
+	}
+}
@@ -0,0 +1,157 @@
+package physics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GenealogyParticle is a particle in the genealogy (see Merger): its ID and its mass at the time of the merger.
+type GenealogyParticle struct {
+	ID   int     `json:"id"`
+	Mass float64 `json:"mass"`
+}
+
+// Merger is a merger recorded in the genealogy (see Genealogy).
+type Merger struct {
+	// Step is the step (call to UpdateParticles) the merger occurred in, counted from 1 since the genealogy was last
+	// cleared.
+	Step int `json:"step"`
+	// Parents are the particles which merged, the largest first.
+	Parents []GenealogyParticle `json:"parents"`
+	// Result is the merged particle.
+	Result GenealogyParticle `json:"result"`
+}
+
+// genealogyExport is the JSON format the genealogy is exported in (see WriteGenealogyJSON).
+type genealogyExport struct {
+	// Particles are all the particles in the genealogy (each merger's parents and result), in ID order.
+	Particles []GenealogyParticle `json:"particles"`
+	// Mergers are the mergers, in the order they occurred.
+	Mergers []Merger `json:"mergers"`
+}
+
+var (
+	// genealogy is the mergers recorded since the genealogy was last cleared.
+	genealogy []Merger
+	// genealogyStep is the number of steps run since the genealogy was last cleared.
+	genealogyStep int
+	// genealogyLock guards genealogy and genealogyStep, which are updated as the particles are while the GUI may be
+	// exporting them.
+	genealogyLock sync.Mutex
+)
+
+// Genealogy gets (a copy of) the mergers which have occurred since the particles were last replaced or reset (see
+// ClearGenealogy), in the order they occurred. Following the parents of the current particles back through the mergers
+// gives the tree of particles each formed from. Undoing changes doesn't remove mergers from it.
+func Genealogy() []Merger {
+	genealogyLock.Lock()
+	defer genealogyLock.Unlock()
+	mergers := make([]Merger, len(genealogy))
+	copy(mergers, genealogy)
+	return mergers
+}
+
+// ClearGenealogy clears the recorded mergers, and restarts the step count (see Merger.Step). It is called when the set
+// of particles is replaced (see SetParticles and ClearParticles) or reset (see RestoreInitialParticleStates), and
+// should also be called when the particles are otherwise replaced, such as when loaded from file.
+func ClearGenealogy() {
+	genealogyLock.Lock()
+	defer genealogyLock.Unlock()
+	genealogy = nil
+	genealogyStep = 0
+}
+
+// recordMerger adds the merger of parents into result to the genealogy.
+func recordMerger(parents []*Particle, result *Particle) {
+	genealogyLock.Lock()
+	defer genealogyLock.Unlock()
+	m := Merger{Step: genealogyStep + 1, Parents: make([]GenealogyParticle, len(parents)),
+		Result: GenealogyParticle{ID: result.ID(), Mass: result.Mass()}}
+	for i, p := range parents {
+		m.Parents[i] = GenealogyParticle{ID: p.ID(), Mass: p.Mass()}
+	}
+	genealogy = append(genealogy, m)
+}
+
+// advanceGenealogy counts a step (call to UpdateParticles) for the genealogy.
+func advanceGenealogy() {
+	genealogyLock.Lock()
+	defer genealogyLock.Unlock()
+	genealogyStep++
+}
+
+// ExportGenealogy writes the genealogy (see Genealogy) to file (replacing it): as a Graphviz DOT graph (see
+// WriteGenealogyDOT) if the file has a .dot or .gv extension, and otherwise as JSON (see WriteGenealogyJSON).
+func ExportGenealogy(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".dot", ".gv":
+		err = WriteGenealogyDOT(w, Genealogy())
+	default:
+		err = WriteGenealogyJSON(w, Genealogy())
+	}
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// WriteGenealogyJSON writes the mergers to w as a JSON object, with the "particles" involved (each merger's parents and
+// result, in ID order, with their masses at the time) and the "mergers" (see Merger).
+func WriteGenealogyJSON(w io.Writer, mergers []Merger) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(genealogyExport{Particles: genealogyParticles(mergers), Mergers: mergers})
+}
+
+// WriteGenealogyDOT writes the mergers to w as a Graphviz DOT directed graph, with a node for each particle involved
+// (labelled with its ID and mass) and an edge from each merger's parents to its result (labelled with the step).
+func WriteGenealogyDOT(w io.Writer, mergers []Merger) error {
+	var b strings.Builder
+	b.WriteString("digraph genealogy {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, p := range genealogyParticles(mergers) {
+		fmt.Fprintf(&b, "\tp%d [label=\"%d\\nmass %s\"];\n", p.ID, p.ID, strconv.FormatFloat(p.Mass, 'g', 6, 64))
+	}
+	for _, m := range mergers {
+		for _, p := range m.Parents {
+			fmt.Fprintf(&b, "\tp%d -> p%d [label=\"step %d\"];\n", p.ID, m.Result.ID, m.Step)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// genealogyParticles gets the particles involved in the mergers (each merger's parents and result), in ID order.
+// Particles involved in more than one merger (the result of one and a parent in a later one) are included once, with
+// their mass from the later merger.
+func genealogyParticles(mergers []Merger) []GenealogyParticle {
+	seen := make(map[int]GenealogyParticle)
+	for _, m := range mergers {
+		for _, p := range m.Parents {
+			seen[p.ID] = p
+		}
+		seen[m.Result.ID] = m.Result
+	}
+	particles := make([]GenealogyParticle, 0, len(seen))
+	for _, p := range seen {
+		particles = append(particles, p)
+	}
+	sort.Slice(particles, func(i, j int) bool { return particles[i].ID < particles[j].ID })
+	return particles
+}
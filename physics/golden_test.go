@@ -0,0 +1,48 @@
+package physics
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+)
+
+// goldenSummary is the expected summary of the golden scenario (see TestGolden). If a change to the engine
+// deliberately changes the simulation's behavior, check the change and then update it.
+var goldenSummary = RunSummary{
+	Particles:    22,
+	TotalMass:    1035.1175520235247,
+	CenterOfMass: []float64{484.9393190576154, 488.86109331800367},
+	Hash:         "1df25ce5880c949516b54a16b0ac4e05d60057c11d2178cabc7e7f1db6c4749b",
+}
+
+// goldenSteps is the number of steps the golden scenario is run for.
+const goldenSteps = 200
+
+// TestGolden runs a fixed scenario for a fixed number of steps, and compares its summary (see Summarize) with the
+// stored goldenSummary, so that changes which affect the simulation's behavior are caught.
+func TestGolden(t *testing.T) {
+	// The particles (including those created by mergers) are given the same IDs every run, as IDs are hashed
+	atomic.StoreInt64(&lastParticleID, 0)
+	resetEngine(randomParticles(40, 86)...)
+	Engine.SubSteps = 2
+	stats := Engine.Run(goldenSteps)
+	got := stats.Summary
+
+	const tolerance = 1e-6
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) <= tolerance*math.Max(1, math.Abs(b))
+	}
+	if got.Particles != goldenSummary.Particles {
+		t.Errorf("got %d particles, want %d", got.Particles, goldenSummary.Particles)
+	}
+	if !near(got.TotalMass, goldenSummary.TotalMass) {
+		t.Errorf("got total mass %g, want %g", got.TotalMass, goldenSummary.TotalMass)
+	}
+	if !near(got.CenterOfMass[0], goldenSummary.CenterOfMass[0]) ||
+		!near(got.CenterOfMass[1], goldenSummary.CenterOfMass[1]) {
+		t.Errorf("got center of mass %v, want %v", got.CenterOfMass, goldenSummary.CenterOfMass)
+	}
+	if got.Hash != goldenSummary.Hash {
+		t.Errorf("got hash %s, want %s", got.Hash, goldenSummary.Hash)
+	}
+}
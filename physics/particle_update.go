@@ -3,8 +3,11 @@ package physics
 import (
 	"math"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/atedja/go-vector"
+	log "github.com/sirupsen/logrus"
 )
 
 // InitializeParticles initializes all particles. It is used during restoration of state from file.
@@ -14,37 +17,344 @@ func InitializeParticles() {
 	}
 }
 
+// SetParticles replaces Engine.Particles with the provided particles (e.g. created with NewParticle, and placed and
+// given velocities by the caller, to set up a specific scenario such as a binary star or a lattice). The particles are
+// initialized (their proxies calculated), given the current position history settings (see SetParticleHistory), and
+// saved as the initial particle states (see SaveInitialParticleStates), so that resetting returns to them.
+// It, and the other functions which change the set of particles, must only be called while the simulation is paused.
+func SetParticles(particles []*Particle) {
+	for _, p := range particles {
+		p.initialize()
+		p.setHistory(Engine.trackHistory, Engine.historySize, Engine.historyStride)
+	}
+	Engine.Particles = particles
+	SaveInitialParticleStates()
+	ClearGenealogy()
+}
+
+// AddParticle adds Particle p to Engine.Particles, initializing it like SetParticles. The current states of all the
+// particles are then saved as the initial particle states.
+// It must only be called while the simulation is paused.
+func AddParticle(p *Particle) {
+	p.initialize()
+	p.setHistory(Engine.trackHistory, Engine.historySize, Engine.historyStride)
+	Engine.Particles = append(Engine.Particles, p)
+	SaveInitialParticleStates()
+}
+
+// ClearParticles removes all the particles (including their initial states, so resetting doesn't restore them).
+// It must only be called while the simulation is paused.
+func ClearParticles() {
+	Engine.Particles = make([]*Particle, 0)
+	SaveInitialParticleStates()
+	ClearGenealogy()
+}
+
+// ZeroVelocities stops all the particles, setting their velocities (and any accelerations stored by the
+// Engine.Integrator) to zero. Unlike the functions which change the set of particles, it may be called while the
+// simulation is running (the particles then immediately begin accelerating again).
+func ZeroVelocities() {
+	for _, p := range Engine.Particles {
+		p.SetVelocity(vector.New(2))
+		p.acceleration = nil
+		p.previousAcceleration = nil
+		// The color scheme may depend on velocity
+		p.updateColor()
+	}
+}
+
+// ConfineParticles moves any particles whose centers are outside the environment (e.g. after it has shrunk, or when
+// loaded from a file with a smaller environment) back inside it, and returns the number moved. Along axes which wrap
+// around, they are wrapped into the environment. Otherwise (whatever the edge modes, so shrinking the environment never
+// removes particles through open edges) they are clamped to the edge they're beyond (less their radius, as when
+// bouncing off it), and any velocity carrying them outward is reflected so they move back into the environment. Fixed
+// particles are moved, but stay fixed. Like ZeroVelocities, it may be called while the simulation is running.
+func ConfineParticles() int {
+	moved := 0
+	for _, p := range Engine.Particles {
+		outside := false
+		for axis := 0; axis < 2; axis++ {
+			size := Engine.environmentExtent(axis)
+			pos := p.Position()[axis]
+			if pos >= 0 && pos <= size {
+				continue
+			}
+			outside = true
+			if Engine.wrapsAxis(axis) {
+				wrapOverEdge(p.Position(), axis, pos > size)
+				continue
+			}
+			p.Position()[axis] = math.Max(float64(p.Radius), math.Min(pos, size-float64(p.Radius)-1))
+			if v := p.Velocity()[axis]; (pos < 0 && v < 0) || (pos > size && v > 0) {
+				p.Velocity()[axis] = -v
+			}
+		}
+		if outside {
+			moved++
+		}
+	}
+	return moved
+}
+
+// ClampFarCharges clamps the far charges of the particles to the range Engine.SignedFarCharge allows (see
+// Particle.SetFarCharge), such as after it's disabled, and returns the number changed.
+func ClampFarCharges() int {
+	clamped := 0
+	for _, p := range Engine.Particles {
+		if p.FarCharge() < minFarCharge() {
+			p.SetFarCharge(p.FarCharge())
+			clamped++
+		}
+	}
+	return clamped
+}
+
+// SetParticleHistory sets whether the positions of all the particles (including those added later) are tracked, how
+// many previous positions are kept, and how often they are stored (see Particle.TrackHistory, Particle.HistorySize, and
+// Particle.HistoryStride). Existing position histories longer than historySize are truncated, and empty ones of tracked
+// particles are pre-filled (see SetHistoryPrefill).
+func SetParticleHistory(trackHistory bool, historySize, historyStride int) {
+	Engine.trackHistory, Engine.historySize, Engine.historyStride = trackHistory, historySize, historyStride
+	for _, p := range Engine.Particles {
+		p.setHistory(trackHistory, historySize, historyStride)
+	}
+}
+
+// SetHistoryPrefill sets the way the empty position histories of tracked particles are pre-filled (when tracking is
+// set with SetParticleHistory, or particles are added with SetParticles or AddParticle), so that their trails are
+// drawn at full length as soon as they start moving rather than growing a position at a time. Histories which already
+// hold positions are left as they are. It doesn't pre-fill any histories itself.
+func SetHistoryPrefill(prefill HistoryPrefill) {
+	Engine.historyPrefill = prefill
+}
+
 // SaveInitialParticleStates saves a copy of all particles in their current (initial generated / just restored
 // from file) state, so they may be reverted to that state by the user during simulation.
 func SaveInitialParticleStates() {
-	Engine.initialParticles = make([]*Particle, len(Engine.Particles), len(Engine.Particles))
-	for i, p := range Engine.Particles {
-		Engine.initialParticles[i] = p.Clone()
-	}
+	Engine.initialParticles = cloneParticles(Engine.Particles)
 }
 
 // RestoreInitialParticleStates restores all particles to the states stored in Engine.initialParticles by
-// SaveInitialParticleStates, so the user may revert particles to their generated / restored from file states.
+// SaveInitialParticleStates, so the user may revert particles to their generated / restored from file states. The
+// genealogy is cleared (see ClearGenealogy).
 func RestoreInitialParticleStates() {
-	Engine.Particles = make([]*Particle, len(Engine.initialParticles), len(Engine.initialParticles))
-	for i, p := range Engine.initialParticles {
-		Engine.Particles[i] = p.Clone()
+	Engine.Particles = cloneParticles(Engine.initialParticles)
+	ClearGenealogy()
+}
+
+// ParticleAt gets the particle at position (x, y) in the environment - that is, the particle whose center is closest to
+// (x, y), if (x, y) is within its radius (or within minRadius, such as the minimum radius particles are drawn at, or a
+// couple units, if larger, so that small particles can be selected). Returns nil if there's no particle there.
+func ParticleAt(x, y, minRadius float64) *Particle {
+	var closest *Particle
+	closestDist := math.Inf(1)
+	for _, p := range Engine.Particles {
+		d := minimumImage(vector.Subtract(vector.NewWithValues([]float64{x, y}), p.Position())).Magnitude()
+		if d <= math.Max(math.Max(float64(p.Radius), minRadius), 2) && d < closestDist {
+			closest, closestDist = p, d
+		}
+	}
+	return closest
+}
+
+// ParticlesInRect gets the particles whose centers are within the rectangle with corners (x0, y0) and (x1, y1)
+// (in either order), including those exactly on its edges. They are returned in Engine.Particles order.
+// The particles aren't changed. If the environment wraps around, the rectangle doesn't (it should be within the
+// environment, where the particle positions are).
+func ParticlesInRect(x0, y0, x1, y1 float64) []*Particle {
+	x0, x1 = math.Min(x0, x1), math.Max(x0, x1)
+	y0, y1 = math.Min(y0, y1), math.Max(y0, y1)
+	var in []*Particle
+	for _, p := range Engine.Particles {
+		x, y := p.Position()[0], p.Position()[1]
+		if x >= x0 && x <= x1 && y >= y0 && y <= y1 {
+			in = append(in, p)
+		}
+	}
+	return in
+}
+
+// ParticlesInRadius gets the particles whose centers are within distance r of (cx, cy), including those exactly r
+// away. They are returned in Engine.Particles order.
+// The particles aren't changed. If the environment wraps around, distances are measured across the edges (as for the
+// forces between particles).
+func ParticlesInRadius(cx, cy, r float64) []*Particle {
+	var in []*Particle
+	for _, p := range Engine.Particles {
+		if minimumImage(vector.Subtract(p.Position(), vector.NewWithValues([]float64{cx, cy}))).Magnitude() <= r {
+			in = append(in, p)
+		}
+	}
+	return in
+}
+
+// mergeCount is the number of mergers (each creating a merged particle) since the app started. See Run.
+var mergeCount int
+
+// RunStats holds aggregate statistics of a Run of the simulation.
+type RunStats struct {
+	// Steps is the number of steps (calls to UpdateParticles) run.
+	Steps int
+	// Merges is the number of mergers which occurred (each of which may involve more than two particles).
+	Merges int
+	// Particles is the number of particles once the steps were run.
+	Particles int
+	// Elapsed is the (wall clock) time taken to run the steps.
+	Elapsed time.Duration
+	// Summary summarizes the particles once the steps were run (see Summarize), so that runs of the same scenario can
+	// be compared.
+	Summary RunSummary
+}
+
+// Run advances the simulation by the provided number of steps (calling UpdateParticles for each), as quickly as
+// possible, and returns aggregate statistics of the run. It is intended for benchmarking and profiling the physics
+// without a GUI, e.g. after setting up particles with SetParticles on a freshly Initialized Engine.
+// Like UpdateParticles, it updates the Engine.Particles using the Engine settings, so should only be called on the
+// Engine.
+func (e *EngineData) Run(steps int) RunStats {
+	stats := RunStats{}
+	merges := mergeCount
+	start := time.Now()
+	for ; stats.Steps < steps; stats.Steps++ {
+		UpdateParticles()
+	}
+	stats.Elapsed = time.Since(start)
+	stats.Merges = mergeCount - merges
+	stats.Particles = len(e.Particles)
+	stats.Summary = Summarize()
+	return stats
+}
+
+// cloneParticles creates a slice of copies (see Particle.Clone) of the provided particles.
+func cloneParticles(particles []*Particle) []*Particle {
+	c := make([]*Particle, len(particles), len(particles))
+	for i, p := range particles {
+		c[i] = p.Clone()
 	}
+	return c
 }
 
-// UpdateParticles updates the Engine.Particles based on interactions between them (and the environment).
+// UpdateParticles updates the Engine.Particles based on interactions between them (and the environment), advancing
+// the simulation by Engine.TimeStep. The time step is divided into Engine.SubSteps steps (each of which handles
+// collisions, mergers, and wall bounces), each of which may be divided further if Engine.AdaptiveSubstep is enabled
+// (see adaptiveSubsteps). Mergers are recorded in the genealogy (see Genealogy). If the trajectories are being recorded
+// (see StartTrajectory), the updated particles are then recorded. It holds the particles lock (see LockParticles) for
+// the duration of the update.
 // Returns bools for whether a particle merge occurred (from a collision), whether >2 particles were involved,
-// and the (largest) original particle & resulting merged particle.
+// and the (largest) original particle & resulting merged particle (from the last sub-step in which a merge occurred).
 func UpdateParticles() (bool, bool, *Particle, *Particle) {
+	particlesLock.Lock()
+	defer particlesLock.Unlock()
+
+	mergeOccurred, mergeMultiple := false, false
+	var mergeSource, mergedResult *Particle
+
+	subSteps := Engine.SubSteps
+	if subSteps < 1 {
+		subSteps = 1
+	}
+	dt := Engine.TimeStep / float64(subSteps)
+	Engine.skippedUpdates = 0
+
+	for i := 0; i < subSteps; i++ {
+		n := adaptiveSubsteps(dt)
+		for j := 0; j < n; j++ {
+			stepMerged, stepMultiple, stepSource, stepResult := step(dt / float64(n))
+			if stepMerged {
+				mergeOccurred = true
+				mergeSource, mergedResult = stepSource, stepResult
+			}
+			mergeMultiple = mergeMultiple || stepMultiple
+		}
+	}
+
+	limitParticles()
+
+	// Some color schemes depend on velocity, so update the colors for the new velocities
+	RecolorParticles()
+
+	recordTrajectory()
+	advanceGenealogy()
+
+	return mergeOccurred, mergeMultiple, mergeSource, mergedResult
+}
+
+// adaptiveSubstepFraction is the largest fraction of their combined radii a pair of particles which could come into
+// contact may close in a single step when Engine.AdaptiveSubstep is enabled (see adaptiveSubsteps).
+const adaptiveSubstepFraction = 0.5
+
+// adaptiveSubsteps determines the number of steps a step of time dt should be divided into, which is 1 unless
+// Engine.AdaptiveSubstep is enabled. If it is, each pair of particles closing on each other fast enough to come into
+// contact during the step (their closing distance, the rate their separation decreases times dt, is at least the gap
+// between them) is checked: if their closing distance exceeds adaptiveSubstepFraction of their combined radii, the step
+// is divided so that it doesn't, up to Engine.MaxAdaptiveSubsteps. Only the pairs which could come into contact during
+// the step (found using a spatialGrid, as for collisions) are checked.
+func adaptiveSubsteps(dt float64) int {
+	if !Engine.AdaptiveSubstep {
+		return 1
+	}
+	limit := Engine.MaxAdaptiveSubsteps
+	if limit < 1 {
+		limit = 1
+	}
+	n := 1
+	grid := newSpatialGrid(Engine.Particles)
+	for i, p := range Engine.Particles {
+		// Each pair is checked once, from the particle earlier in Engine.Particles
+		candidates, all := grid.candidates(p, dt)
+		if all {
+			candidates = nil
+			for j := i + 1; j < len(Engine.Particles); j++ {
+				candidates = append(candidates, j)
+			}
+		}
+		for _, j := range skipCandidatesBefore(candidates, i+1) {
+			if needed := pairSubsteps(p, Engine.Particles[j], dt); needed > n {
+				n = needed
+			}
+			if n >= limit {
+				return limit
+			}
+		}
+	}
+	return n
+}
+
+// pairSubsteps determines the number of steps a step of time dt should be divided into so that Particles p and o don't
+// close more than adaptiveSubstepFraction of their combined radii in each (see adaptiveSubsteps). Returns 1 if they
+// can't come into contact during the step.
+func pairSubsteps(p, o *Particle, dt float64) int {
+	v := minimumImage(vector.Subtract(p.Position(), o.Position()))
+	dist := v.Magnitude()
+	if dist == 0 {
+		return 1
+	}
+	// The rate the separation decreases is the relative velocity toward each other (along v)
+	w := vector.Subtract(o.Velocity(), p.Velocity())
+	closing := (v[0]*w[0] + v[1]*w[1]) / dist * dt
+	radii := float64(p.Radius + o.Radius)
+	if radii <= 0 || closing <= adaptiveSubstepFraction*radii || closing < dist-radii {
+		return 1
+	}
+	return int(math.Ceil(closing / (adaptiveSubstepFraction * radii)))
+}
+
+// step advances the simulation by a single step of (simulation) time dt. See UpdateParticles.
+func step(dt float64) (bool, bool, *Particle, *Particle) {
 	mergeOccurred, mergeMultiple := false, false
 	var mergeSource, mergedResult *Particle
 
-	updateParticleVelocities()
-	updateParticlePositions()
+	integrate(dt)
 
-	// Sort by mass. Used to merge to larger mass, and also a good order for drawing them.
+	// Sort by mass. Used to merge to larger mass, and also a good order for drawing them. sort.Slice isn't stable, so
+	// equal masses are ordered by ID, keeping the order (and so which particle a merge goes to, and the drawing order)
+	// the same every step and every run.
 	sort.Slice(Engine.Particles, func(i, j int) bool {
-		return Engine.Particles[i].Mass() > Engine.Particles[j].Mass()
+		p, o := Engine.Particles[i], Engine.Particles[j]
+		if p.Mass() != o.Mass() {
+			return p.Mass() > o.Mass()
+		}
+		return p.ID() < o.ID()
 	})
 
 	//region Handle Mergers
@@ -55,9 +365,11 @@ func UpdateParticles() (bool, bool, *Particle, *Particle) {
 		// Particles to be added
 		var addList []*Particle
 		var mergedParticle *Particle
-		var mass, closeCharge, farCharge float64
-		var position, velocity, tv vector.Vector
+		var mass float64
+		var position, velocity, acceleration, tv vector.Vector
 		var count float64
+		// The (largest) fixed particle involved in a merger, if any
+		var fixed *Particle
 
 		for i, p := range Engine.Particles {
 			if p.merging {
@@ -71,28 +383,48 @@ func UpdateParticles() (bool, bool, *Particle, *Particle) {
 					}
 					deleteList = append(deleteList, i)
 					mass = p.Mass()
-					// We average charges, weighted by mass of each particle
-					closeCharge = p.CloseCharge() * mass
-					farCharge = p.FarCharge() * mass
 					// The position is also average & weighted, which we do by scaling each position vector by the
 					// particle's mass, summing them, and then scaling the result back down by the total mass
 					tv = p.Position().Clone()
 					tv.Scale(mass)
 					position = tv
-					velocity = p.Velocity()
+					// The velocity is the momentum (mass weighted velocity) sum divided by the total mass, so that
+					// momentum is conserved
+					velocity = p.Velocity().Clone()
+					velocity.Scale(mass)
+					// The acceleration (only used by the VelocityVerlet integrator) is averaged the same way as position
+					acceleration = nil
+					if p.acceleration != nil {
+						acceleration = p.acceleration.Clone()
+						acceleration.Scale(mass)
+					}
+					parents := []*Particle{p}
+					fixed = nil
+					if p.Fixed() {
+						fixed = p
+					}
 					//fmt.Printf("Merge. Original mass: %f, closeCharge: %f, farCharge: %f, position: %v,
 					//velocity: %v\n", p.Mass(), p.CloseCharge(), p.FarCharge(), p.Position, p.Velocity)
-					// Sum up the masses & charges
-					for o, _ := range p.MergingWith {
+					// Sum up the masses (the charges are combined from the parents afterward; see mergedCharges)
+					for _, o := range p.mergingPartners() {
 						mass += o.Mass()
-						closeCharge += o.CloseCharge() * o.Mass()
-						farCharge += o.FarCharge() * o.Mass()
-						tv = o.Position().Clone()
+						// With a wrapping boundary, o may be on the other side of the environment, so use its
+						// position nearest to p
+						tv = vector.Add(p.Position(), minimumImage(vector.Subtract(o.Position(), p.Position())))
 						tv.Scale(o.Mass())
 						position = vector.Add(position, tv)
 						tv = o.Velocity().Clone()
-						tv.Scale(o.Mass() / p.Mass())
+						tv.Scale(o.Mass())
 						velocity = vector.Add(velocity, tv)
+						if acceleration != nil && o.acceleration != nil {
+							tv = o.acceleration.Clone()
+							tv.Scale(o.Mass())
+							acceleration = vector.Add(acceleration, tv)
+						}
+						parents = append(parents, o)
+						if o.Fixed() && (fixed == nil || o.Mass() > fixed.Mass()) {
+							fixed = o
+						}
 						// We've merged from o to p, so we won't need to do p to o once we get to o (and indeed,
 						// o will later be deleted)
 						delete(o.MergingWith, p)
@@ -101,18 +433,44 @@ func UpdateParticles() (bool, bool, *Particle, *Particle) {
 						//o.Velocity)
 					}
 
-					// Compute the averages and create the new merged particle
+					// Compute the averages and create the new merged particle. NewParticle clamps the combined charges
+					// (which may be out of range if summed, fractionally due to rounding, or further if unclamped
+					// charges were loaded from file) before the color proxies are derived from them.
 					position.Scale(1.0 / mass)
-					mergedParticle = NewParticle(mass, closeCharge/mass, farCharge/mass, position[0], position[1])
+					velocity.Scale(1.0 / mass)
+					closeCharge, farCharge := mergedCharges(parents, mass)
+					mergedParticle = NewParticle(mass, closeCharge, farCharge, position[0], position[1])
 					mergedParticle.SetVelocity(velocity)
+					// The species comes from the first (largest) particle involved in the merger
+					mergedParticle.SetSpecies(p.Species())
+					if acceleration != nil {
+						acceleration.Scale(1.0 / mass)
+						mergedParticle.acceleration = acceleration
+					}
+					// Mergers involving a fixed particle remain fixed, in place of the (largest) fixed particle
+					if fixed != nil {
+						mergedParticle.SetPosition(fixed.Position().Clone())
+						mergedParticle.SetFixed(true)
+					}
 					// History data comes from the first (largest) particle involved in the merger
 					mergedParticle.SetTrackHistory(p.TrackHistory())
 					mergedParticle.SetHistorySize(p.HistorySize())
+					mergedParticle.SetHistoryStride(p.HistoryStride())
 					mergedParticle.SetPositionHistory(p.PositionHistory())
+					// The merged particle has a new ID; its parents are kept (and logged) so it can be traced
+					parentIDs := make([]int, len(parents))
+					for i, o := range parents {
+						parentIDs[i] = o.ID()
+					}
+					mergedParticle.parentIDs = parentIDs
+					log.Debugf("Particles %v merged into particle %d", parentIDs, mergedParticle.ID())
+					reportEvent(Event{Kind: MergeEvent, Particles: parents, Result: mergedParticle})
+					recordMerger(parents, mergedParticle)
 					//fmt.Printf("Merge. New mass: %f, closeCharge: %f, farCharge: %f, position: %v, velocity: %v\n",
 					//mergedParticle.Mass(), mergedParticle.CloseCharge(), mergedParticle.FarCharge(),
 					//mergedParticle.Position, mergedParticle.Velocity)
 					addList = append(addList, mergedParticle)
+					mergeCount++
 					// Returned for GUI display purposes
 					mergedResult = mergedParticle
 					// If the merge list for this particle has already been cleared by handling mergers from other
@@ -142,190 +500,852 @@ func UpdateParticles() (bool, bool, *Particle, *Particle) {
 	}
 	//endregion Handle Mergers
 
-	//region Wall bounce
-	if Engine.WallBounce {
-		var n vector.Vector
-		var scale float64
-		var err error
-		var bounce bool
-		for _, p := range Engine.Particles {
-			bounce = false
-			// If the circle representing the particle extends beyond the sides...
-			if int(p.Position()[0])-p.Radius < 0 || int(p.Position()[0])+p.Radius > Engine.EnvironmentSize-1 {
-				// p.Velocity - n, where n is scaled by 2* the dot product of p.Velocity & n, reflects p.Velocity over
-				// (n rotated by 90 degrees). So n is horizontal, so that the reflection happens over a vertical line.
-				n = vector.NewWithValues([]float64{1, 0})
-				scale, err = vector.Dot(p.Velocity(), n)
-				if err == nil {
-					// Make sure the particle didn't go past the edge
-					p.Position()[0] = math.Max(float64(p.Radius), math.Min(p.Position()[0],
-						float64(Engine.EnvironmentSize)-float64(p.Radius)-1))
-					bounce = true
-				}
+	//region Handle Fission
+	if Engine.AllowFission && Engine.MaxMass > 0 {
+		// Particles are split (at most once each step) by replacing them with one fragment and appending the other.
+		// Splits which would exceed Engine.MaxParticles are skipped.
+		n := len(Engine.Particles)
+		for i := 0; i < n && !AtParticleLimit(); i++ {
+			p := Engine.Particles[i]
+			if p.Fixed() || p.Mass() <= Engine.MaxMass {
+				continue
 			}
-			// If not already bouncing on sides and the circle representing the particle extends beyond the
-			// top or bottom...
-			if !bounce && (int(p.Position()[1])-p.Radius < 0 ||
-				int(p.Position()[1])+p.Radius > Engine.EnvironmentSize-1) {
-				// p.Velocity - n, where n is scaled by 2* the dot product of p.Velocity & n, reflects p.Velocity over
-				// (n rotated by 90 degrees). So n is vertical, so that the reflection happens over a horizontal line.
-				n = vector.NewWithValues([]float64{0, 1})
-				scale, err = vector.Dot(p.Velocity(), n)
-				if err == nil {
-					// Make sure the particle didn't go past the edge
-					p.Position()[1] = math.Max(float64(p.Radius), math.Min(p.Position()[1],
-						float64(Engine.EnvironmentSize)-float64(p.Radius)-1))
-					bounce = true
+			a, b := p.split()
+			Engine.Particles[i] = a
+			Engine.Particles = append(Engine.Particles, b)
+		}
+	}
+	//endregion Handle Fission
+
+	//region Edges
+	// Each edge bounces, wraps, or removes (if open) the particles reaching it, according to its mode
+	escaped := make(map[*Particle]struct{})
+	for _, p := range Engine.Particles {
+		// Each axis is checked separately, so a particle in a corner bounces off both walls. Fixed particles aren't
+		// bounced, staying where they are pinned
+		for axis := 0; axis < 2; axis++ {
+			for _, far := range [2]bool{false, true} {
+				switch Engine.edgeMode(axis, far) {
+				case EdgeBounce:
+					if !p.Fixed() {
+						bounceOffWall(p, axis, far)
+					}
+				case EdgeWrap:
+					wrapOverEdge(p.Position(), axis, far)
+				case EdgeOpen:
+					if beyondEdge(p, axis, far) {
+						escaped[p] = struct{}{}
+					}
 				}
 			}
-			// Complete the reflection
-			if bounce {
-				scale *= 2
-				n.Scale(scale)
-				p.SetVelocity(vector.Subtract(p.Velocity(), n))
-			}
 		}
 	}
-	//endregion Wall bounce
+	if len(escaped) > 0 {
+		removeParticles(escaped)
+	}
+	//endregion Edges
 
 	return mergeOccurred, mergeMultiple, mergeSource, mergedResult
 }
 
-// updateParticleVelocities updates the Engine.Particles velocities by calculating and summing the three force
-// acceleration vectors acting on the Particle (based on the relative positions, masses, and charges of all other
-// Particles) and adding that to the current Particle's current Velocity.
-func updateParticleVelocities() {
-	var v, vc, vf, g, c, f vector.Vector
-	var mag float64
+// mergingPartners returns the particles p is merging with, sorted by ID. The MergingWith iteration order is random, so
+// merges are accumulated in this order instead, making the (floating point) results the same every run.
+func (p *Particle) mergingPartners() []*Particle {
+	partners := make([]*Particle, 0, len(p.MergingWith))
+	for o := range p.MergingWith {
+		partners = append(partners, o)
+	}
+	sort.Slice(partners, func(i, j int) bool {
+		return partners[i].ID() < partners[j].ID()
+	})
+	return partners
+}
 
+// mergedCharges combines the close and far charges of the parents (merging particles, whose total mass is mass)
+// according to Engine.ChargeMergeRule. The results aren't clamped.
+func mergedCharges(parents []*Particle, mass float64) (closeCharge, farCharge float64) {
+	switch Engine.ChargeMergeRule {
+	case ChargeMergeSum:
+		for _, o := range parents {
+			closeCharge += o.CloseCharge()
+			farCharge += o.FarCharge()
+		}
+	case ChargeMergeMax:
+		closeCharge, farCharge = parents[0].CloseCharge(), parents[0].FarCharge()
+		for _, o := range parents[1:] {
+			closeCharge = math.Max(closeCharge, o.CloseCharge())
+			farCharge = math.Max(farCharge, o.FarCharge())
+		}
+	default:
+		// Average the charges, weighted by the mass of each particle
+		for _, o := range parents {
+			closeCharge += o.CloseCharge() * o.Mass()
+			farCharge += o.FarCharge() * o.Mass()
+		}
+		closeCharge /= mass
+		farCharge /= mass
+	}
+	return closeCharge, farCharge
+}
+
+// limitParticles removes any Engine.Particles beyond Engine.MaxParticles (the most recently added).
+func limitParticles() {
+	if Engine.MaxParticles <= 0 || len(Engine.Particles) <= Engine.MaxParticles {
+		return
+	}
+	removed := make(map[*Particle]struct{})
+	for _, p := range Engine.Particles[Engine.MaxParticles:] {
+		removed[p] = struct{}{}
+	}
+	removeParticles(removed)
+}
+
+// removeParticles removes the removed particles from Engine.Particles (keeping the order of the rest).
+func removeParticles(removed map[*Particle]struct{}) {
+	kept := Engine.Particles[:0]
 	for _, p := range Engine.Particles {
-		// Force acceleration vectors (average of force vectors between p and each other particle it isn't merging with
-		// or bouncing against)
-		g = vector.New(2)
-		c = vector.New(2)
-		f = vector.New(2)
-		// Count of particles for which force interactions with p are calculated (for averaging)
-		ct := 0
-
-		// Work with p against every other particle (o)
-		for _, o := range Engine.Particles {
-			// If comparing against itself, or p & o are merging, we don't need to calculate their force effects
-			// on each other
-			if _, ok := p.MergingWith[o]; ok || p == o {
+		if _, ok := removed[p]; !ok {
+			kept = append(kept, p)
+		}
+	}
+	Engine.Particles = kept
+	// Particles which were bouncing against removed particles no longer are
+	for _, p := range Engine.Particles {
+		if _, ok := removed[p.bouncingAgainst]; ok {
+			p.bouncing, p.bouncingAgainst = false, nil
+		}
+	}
+}
+
+// bounceOffWall reflects the velocity of Particle p, and moves it back within the environment, if the circle
+// representing it extends beyond the environment edge at the start (left or top, if far is false) or end (right or
+// bottom) of axis (0 for the sides, 1 for the top and bottom).
+func bounceOffWall(p *Particle, axis int, far bool) {
+	size := Engine.environmentExtent(axis)
+	if (!far && int(p.Position()[axis])-p.Radius >= 0) || (far && int(p.Position()[axis])+p.Radius <= int(size)-1) {
+		return
+	}
+	// p.Velocity - n, where n is scaled by 2* the dot product of p.Velocity & n, reflects p.Velocity over
+	// (n rotated by 90 degrees). n is along the axis, so that the reflection happens over the wall. Scaling n by
+	// (1 + Engine.Restitution) instead reflects only the Restitution fraction of the velocity into the wall.
+	n := vector.New(2)
+	n[axis] = 1
+	scale, err := vector.Dot(p.Velocity(), n)
+	if err != nil {
+		return
+	}
+	// Make sure the particle didn't go past the edge
+	p.Position()[axis] = math.Max(float64(p.Radius), math.Min(p.Position()[axis],
+		size-float64(p.Radius)-1))
+	// Complete the reflection
+	n.Scale((1 + Engine.Restitution) * scale)
+	p.SetVelocity(vector.Subtract(p.Velocity(), n))
+}
+
+// fissionSeparationSpeed is the speed with which each of the fragments of a split particle moves away from the other
+// (relative to the velocity of the original particle). See split.
+const fissionSeparationSpeed = 0.5
+
+// split splits Particle p into two fragments, each with half its mass and the same charges (so that mass, momentum,
+// and charge are conserved). The fragments are placed just apart (so that they don't immediately collide and merge
+// again) either side of p's position, along the line perpendicular to its velocity, and move apart at
+// fissionSeparationSpeed. The first fragment keeps p's position history.
+func (p *Particle) split() (*Particle, *Particle) {
+	mass := p.Mass() / 2
+	a := NewParticle(mass, p.CloseCharge(), p.FarCharge(), p.Position()[0], p.Position()[1])
+	b := NewParticle(mass, p.CloseCharge(), p.FarCharge(), p.Position()[0], p.Position()[1])
+
+	// The direction the fragments separate in (perpendicular to the velocity, or horizontal if p is stationary)
+	d := vector.NewWithValues([]float64{1, 0})
+	if speed := p.Velocity().Magnitude(); speed > 0 {
+		d = vector.NewWithValues([]float64{-p.Velocity()[1] / speed, p.Velocity()[0] / speed})
+	}
+
+	// Offset each fragment by a little more than its radius, so they're not touching
+	offset := d.Clone()
+	offset.Scale(float64(a.Radius) + 1)
+	a.SetPosition(vector.Add(p.Position(), offset))
+	b.SetPosition(vector.Subtract(p.Position(), offset))
+
+	// The fragments have equal masses and equal but opposite separating velocities, so momentum is conserved
+	d.Scale(fissionSeparationSpeed)
+	a.SetVelocity(vector.Add(p.Velocity(), d))
+	b.SetVelocity(vector.Subtract(p.Velocity(), d))
+	if p.acceleration != nil {
+		a.acceleration = p.acceleration.Clone()
+		b.acceleration = p.acceleration.Clone()
+	}
+
+	for _, f := range []*Particle{a, b} {
+		f.SetSpecies(p.Species())
+		f.SetTrackHistory(p.TrackHistory())
+		f.SetHistorySize(p.HistorySize())
+		f.SetHistoryStride(p.HistoryStride())
+	}
+	a.SetPositionHistory(p.PositionHistory())
+
+	return a, b
+}
+
+// integrate updates the Engine.Particles velocities and positions by one step of time dt, using the Engine.Integrator.
+func integrate(dt float64) {
+	switch Engine.Integrator {
+	case Euler:
+		// Accelerations are calculated at the starting positions (and collisions handled), positions are updated using
+		// the starting (or, if bouncing, reflected) velocities, and finally the accelerations are applied
+		updateParticleVelocities(dt)
+		updateParticlePositions(dt)
+		for _, p := range Engine.Particles {
+			a := p.acceleration.Clone()
+			a.Scale(dt)
+			p.SetVelocity(vector.Add(p.Velocity(), a))
+			limitSpeed(p)
+		}
+	case VelocityVerlet:
+		// Positions are updated using the velocities and previous accelerations (x += v*dt + a*dt^2/2)
+		for _, p := range Engine.Particles {
+			p.previousAcceleration = p.acceleration
+			if p.Fixed() {
 				continue
 			}
+			d := p.Velocity().Clone()
+			d.Scale(dt)
+			if p.previousAcceleration != nil {
+				tv := p.previousAcceleration.Clone()
+				tv.Scale(0.5 * dt * dt)
+				d = vector.Add(d, tv)
+			}
+			p.movePosition(d)
+		}
+		// New accelerations are calculated at the new positions (and collisions handled)
+		updateParticleVelocities(dt)
+		// Velocities are updated using the average of the previous and new accelerations. Bounces have already
+		// reflected the velocity, so the (half step) accelerations are applied on top of the reflected velocity.
+		for _, p := range Engine.Particles {
+			a := p.acceleration.Clone()
+			if p.previousAcceleration != nil {
+				a = vector.Add(a, p.previousAcceleration)
+			}
+			a.Scale(0.5 * dt)
+			p.SetVelocity(vector.Add(p.Velocity(), a))
+			limitSpeed(p)
+		}
+	default:
+		updateParticleVelocities(dt)
+		updateParticlePositions(dt)
+	}
+}
+
+// updateParticleVelocities updates the Engine.Particles velocities by calculating and summing the three force
+// acceleration vectors acting on the Particle (based on the relative positions, masses, and charges of all other
+// Particles) and adding that to the current Particle's current Velocity (or, depending on the Engine.Integrator, storing
+// it to be applied by integrate). dt is the time step.
+// Forces which are disabled (see EngineData.EnableGravity) are left zero (see interactParticles).
+// If Engine.UseBarnesHut is enabled, the forces from distant groups of Particles are approximated using a quadtree
+// (see quadTree.accumulateForces), otherwise every pair of Particles is compared directly.
+// If Engine.Workers is greater than 1, the forces are calculated in parallel (see updateParticleVelocitiesParallel).
+// When comparing every pair, only nearby particles (found using a spatialGrid) are tested for collisions.
+func updateParticleVelocities(dt float64) {
+	var tree *quadTree
+	var grid *spatialGrid
 
-			// Get the distance (mag) between the two particles
-			v = vector.Subtract(p.Position(), o.Position())
-			mag = v.Magnitude()
+	// The attractor is read once per update, so it's the same for every particle even if the GUI changes it meanwhile
+	Engine.stepAttractor = currentAttractor()
 
-			// Stop bounce once separated
-			if p.bouncing && p.bouncingAgainst == o {
-				if mag > Engine.bounceCompleteDistFactor*float64(p.Radius+o.Radius) {
-					p.bouncing = false
-				}
+	// The quadtree doesn't account for a wrapping boundary, so Barnes-Hut isn't used while it's enabled
+	if Engine.UseBarnesHut && !Engine.wrapping() {
+		tree = newQuadTree(Engine.Particles)
+	} else {
+		grid = newSpatialGrid(Engine.Particles)
+	}
+
+	if Engine.Workers > 1 {
+		updateParticleVelocitiesParallel(dt, tree, grid)
+		return
+	}
+
+	for _, p := range Engine.Particles {
+		g, c, f, ct := sumForces(p, dt, tree, grid, nil)
+		applyForces(p, g, c, f, ct, dt)
+		if grid != nil {
+			grid.updateMaxSpeed(p)
+		}
+	}
+}
+
+// ForcesOn calculates the gravity, close charge, and far charge acceleration vectors Particle p currently feels from
+// the other Engine.Particles (averaged over the particles it isn't merging with or bouncing against, as when the
+// velocities are updated). Every particle is compared exactly (Barnes-Hut isn't used), and no collisions are detected
+// or handled, so no particle states are changed. Drag, the external field, and the rotating frame's fictitious forces,
+// which don't depend on the other particles, aren't included.
+func ForcesOn(p *Particle) (gravity, close, far vector.Vector) {
+	g, c, f, ct := sumForces(p, 0, nil, nil, &velocityUpdate{})
+	if ct > 0 {
+		g.Scale(1 / float64(ct))
+		c.Scale(1 / float64(ct))
+		f.Scale(1 / float64(ct))
+	}
+	return g, c, f
+}
+
+// sumForces sums the gravity, close charge, and far charge acceleration vectors the other Engine.Particles exert on
+// Particle p, and handles collisions (see interactParticles). Returns the summed vectors and the number of particles
+// which contributed to them (for averaging). dt is the time step, and deferred is passed to interactParticles.
+// If tree is not nil, the forces from distant groups of particles are approximated using it. Otherwise every other
+// particle is compared directly, and only the collision candidates found using grid are tested for collisions (none
+// are, if grid is nil).
+func sumForces(p *Particle, dt float64, tree *quadTree, grid *spatialGrid,
+	deferred *velocityUpdate) (g, c, f vector.Vector, ct int) {
+	// Force acceleration vectors (the sum of force vectors between p and each other particle it isn't merging with or
+	// bouncing against)
+	g, c, f = vector.New(2), vector.New(2), vector.New(2)
+
+	if tree != nil {
+		return g, c, f, tree.accumulateForces(p, dt, g, c, f, deferred)
+	}
+
+	// Work with p against every other particle (o), only testing the collision candidates for collisions
+	var candidates []int
+	all := false
+	if grid != nil {
+		candidates, all = grid.candidates(p, dt)
+	}
+	for i, o := range Engine.Particles {
+		candidates = skipCandidatesBefore(candidates, i)
+		candidate := all || (len(candidates) > 0 && candidates[0] == i)
+		bouncing, bouncingAgainst := p.bouncing, p.bouncingAgainst
+		if interactParticles(p, o, dt, g, c, f, deferred, candidate) {
+			ct++
+		}
+		// A new bounce changes the velocities of p and o, which may make more particles collision candidates (bounces
+		// are only handled here if not deferred)
+		if candidate && (p.bouncing != bouncing || p.bouncingAgainst != bouncingAgainst) {
+			grid.updateMaxSpeed(p)
+			grid.updateMaxSpeed(o)
+			candidates, all = grid.candidates(p, dt)
+		}
+	}
+	return g, c, f, ct
+}
+
+// skipCandidatesBefore removes the leading collision candidates (indexes, in ascending order; see
+// spatialGrid.candidates) less than i, and returns the remaining candidates.
+func skipCandidatesBefore(candidates []int, i int) []int {
+	for len(candidates) > 0 && candidates[0] < i {
+		candidates = candidates[1:]
+	}
+	return candidates
+}
+
+// velocityUpdate holds the results of calculating the forces acting on a particle during a parallel velocity update
+// (see updateParticleVelocitiesParallel), which are applied to the particle after all particles have been calculated.
+type velocityUpdate struct {
+	// g, c, and f are the summed gravity, close charge, and far charge acceleration vectors.
+	g, c, f vector.Vector
+	// ct is the number of particles which contributed to the force vectors (for averaging).
+	ct int
+	// collisions are the particles found to be colliding with the particle (to be merged with or bounced against).
+	collisions []*Particle
+	// bounceCompleteWith is the particle the particle was bouncing against, if they have now separated (so the bounce
+	// state can be cleared).
+	bounceCompleteWith *Particle
+}
+
+// updateParticleVelocitiesParallel updates the Engine.Particles velocities like updateParticleVelocities, but
+// calculates the forces acting on each particle in parallel, using Engine.Workers goroutines (each handling a
+// contiguous range of the particles). tree is the Barnes-Hut quadTree, if in use, and grid the spatialGrid otherwise.
+// The calculations only read particle state, and are stored in a scratch slice of velocityUpdate. Collisions (which
+// change merge and bounce states, and velocities) and the forces are then applied to the particles one at a time, in
+// order, once the parallel calculations are complete, so there are no data races.
+// Note that (unlike the serial calculation, where velocities already updated earlier in the loop are used when detecting
+// fast-moving collisions) every particle is calculated from the velocities at the start of the step.
+func updateParticleVelocitiesParallel(dt float64, tree *quadTree, grid *spatialGrid) {
+	updates := make([]velocityUpdate, len(Engine.Particles))
+
+	var wg sync.WaitGroup
+	chunk := int(math.Ceil(float64(len(Engine.Particles)) / float64(Engine.Workers)))
+	for start := 0; start < len(Engine.Particles); start += chunk {
+		end := start + chunk
+		if end > len(Engine.Particles) {
+			end = len(Engine.Particles)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				// Collisions are deferred, so velocities (and so the collision candidates) don't change until the
+				// updates are applied
+				u := &updates[i]
+				u.g, u.c, u.f, u.ct = sumForces(Engine.Particles[i], dt, tree, grid, u)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	for i, p := range Engine.Particles {
+		u := &updates[i]
+		if u.bounceCompleteWith != nil && p.bouncingAgainst == u.bounceCompleteWith {
+			p.bouncing = false
+		}
+		for _, o := range u.collisions {
+			// The merge/bounce states may have been changed by the collisions already handled (e.g. o found p
+			// colliding with it, and handled the collision, first)
+			if _, ok := p.MergingWith[o]; ok || (p.bouncing && p.bouncingAgainst == o) {
 				continue
 			}
+			v := minimumImage(vector.Subtract(p.Position(), o.Position()))
+			collideParticles(p, o, v, v.Magnitude())
+		}
+		applyForces(p, u.g, u.c, u.f, u.ct, dt)
+	}
+}
 
-			// New collision (not already bouncing against each other and distance between them is less than
-			// combined radii) - determine if merge or bounce
-			if !(p.bouncing && p.bouncingAgainst == o) && mag < float64(p.Radius+o.Radius) {
-				var massRatio float64
-				if Engine.AllowMerge {
-					if p.Mass() > o.Mass() {
-						massRatio = p.Mass() / o.Mass()
-					} else {
-						massRatio = o.Mass() / p.Mass()
-					}
-				}
+// applyForces averages the summed gravity, close charge, and far charge acceleration vectors acting on Particle p (over
+// ct, the number of particles which contributed to them), and applies them to p's velocity or, depending on the
+// Engine.Integrator, stores them to be applied by integrate. dt is the time step.
+func applyForces(p *Particle, g, c, f vector.Vector, ct int, dt float64) {
+	var scale float64
 
-				// Merge if mergers are enabled and the mass difference is sufficient and the close charge doesn't repel
-				// enough to prevent it
-				if Engine.AllowMerge && massRatio > Engine.mergeMassRatioThreshold &&
-					(math.Signbit(p.CloseCharge()) != math.Signbit(o.CloseCharge()) ||
-						math.Abs(p.CloseCharge())+math.Abs(o.CloseCharge()) < Engine.mergeCloseChargeThreshold) {
-					p.merging = true
-					// Add o to p's MergingWith (set its value to an empty anonymous struct, so that the key exists)
-					p.MergingWith[o] = struct{}{}
-					// If o doesn't already have p in it's MergingWith (because o came before p in the outer loop),
-					// add it
-					if _, ok := o.MergingWith[p]; !ok {
-						o.merging = true
-						o.MergingWith[p] = struct{}{}
-					}
-					// Bounce (see WallBounce logic in UpdateParticles for vector math description, except the direction of
-					// the reflecting vector is determined by which axis the particle's are moving along most, rather than
-					// which wall they're bouncing against)
-				} else {
-					var n vector.Vector
-					// Todo: this isn't quite right. I think perhaps we need to account for whether the (primary axis)
-					// velocities of the two particles are in the same or opposite directions ... and then multiply
-					// the reflection vector by -1 if ... same??
-					if math.Abs(p.Velocity()[0])+math.Abs(o.Velocity()[0]) >
-						math.Abs(p.Velocity()[1])+math.Abs(o.Velocity()[1]) {
-						n = vector.NewWithValues([]float64{0, 1})
-					} else {
-						n = vector.NewWithValues([]float64{1, 0})
-					}
+	// Compute the average force acceleration vectors (scaled by the time step when they are applied directly to the
+	// velocity, so they are velocity changes rather than accelerations). If no particles contributed forces (e.g.
+	// p is the only particle, or is merging with / bouncing against all the others), the vectors are left at zero
+	// rather than dividing by zero (which would make them, and then p's velocity and position, NaN).
+	if ct > 0 {
+		scale = 1.0 / float64(ct)
+		if Engine.Integrator == SemiImplicitEuler {
+			scale = dt / float64(ct)
+		}
+		g.Scale(scale)
+		c.Scale(scale)
+		f.Scale(scale)
+	}
 
-					scale, err := vector.Dot(p.Velocity(), n)
-					if err != nil {
-						continue
-					}
-					// We now know the math of the bounce will succeed, so it's safe to set the bouncing state
-					// (which gets unset when the particles are sufficiently separated)
-					p.bouncing = true
-					p.bouncingAgainst = o
-					scale *= 2
-					n.Scale(scale)
-					p.SetVelocity(vector.Subtract(p.Velocity(), n))
-				}
-				// If we have a new collision (bounce/merge), we don't need to calculate the forces between p & o
-				// (which happens below)
-				continue
+	// Drag acts on the particle's own velocity, so it isn't averaged
+	d := dragAcceleration(p)
+	if Engine.Integrator == SemiImplicitEuler {
+		d.Scale(dt)
+		// Drag can stop a particle, but not reverse it (as it would if drag*dt was large enough to overshoot)
+		if d.Magnitude() > p.Velocity().Magnitude() {
+			d = p.Velocity().Clone()
+			d.Scale(-1)
+		}
+	}
+
+	// The external field, attractor, and rotating frame are independent of the other particles, so they aren't
+	// averaged either
+	x := vector.Add(vector.Add(externalAcceleration(dt), attractorAcceleration(p, Engine.stepAttractor, dt)),
+		frameAcceleration(p, dt))
+
+	// The summed acceleration is limited to Engine.MaxAcceleration, if set
+	limitAcceleration(dt, g, c, f, d, x)
+
+	// Sum the (now averaged) acceleration vectors from each force (and drag, the external field, and the attractor)
+	// and apply it to the particle (add the summed acceleration vector to the velocity), or store it to be applied by
+	// the integrator. Fixed particles aren't accelerated (though they still exert forces on other particles), and
+	// neither are particles with non-finite forces (the update is skipped; see SkippedUpdates).
+	if p.Fixed() {
+		p.acceleration = vector.New(2)
+	} else if Engine.Integrator == SemiImplicitEuler {
+		v := vector.Add(vector.Add(vector.Add(vector.Add(vector.Add(p.Velocity(), g), c), f), d), x)
+		if !finite(v) {
+			Engine.skippedUpdates++
+			return
+		}
+		p.SetVelocity(v)
+		limitSpeed(p)
+	} else {
+		a := vector.Add(vector.Add(vector.Add(vector.Add(g, c), f), d), x)
+		if !finite(a) {
+			Engine.skippedUpdates++
+			a = vector.New(2)
+		}
+		p.acceleration = a
+	}
+}
+
+// finite indicates whether all the components of vector v are finite (not infinite or NaN).
+func finite(v vector.Vector) bool {
+	for _, x := range v {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// externalAcceleration calculates the acceleration (velocity change, scaled by time step dt, if using the
+// SemiImplicitEuler integrator) due to the EngineData.ExternalField.
+func externalAcceleration(dt float64) vector.Vector {
+	x := vector.New(2)
+	if len(Engine.ExternalField) == 2 {
+		x.Set(Engine.ExternalField)
+		if Engine.Integrator == SemiImplicitEuler {
+			x.Scale(dt)
+		}
+	}
+	return x
+}
+
+// frameAcceleration calculates the acceleration (velocity change, scaled by time step dt, if using the
+// SemiImplicitEuler integrator) of Particle p due to the rotating reference frame (see
+// EngineData.FrameAngularVelocity): the centrifugal acceleration ω²r, where r is p's position relative to the center of
+// the environment, plus the Coriolis acceleration -2ω × v, where v is p's velocity.
+func frameAcceleration(p *Particle, dt float64) vector.Vector {
+	w := Engine.FrameAngularVelocity
+	if w == 0 {
+		return vector.New(2)
+	}
+	pos, v := p.Position(), p.Velocity()
+	r := []float64{pos[0] - float64(Engine.EnvironmentWidth)/2, pos[1] - float64(Engine.EnvironmentHeight)/2}
+	// ω is perpendicular to the plane, so ω × v is v rotated a quarter turn (clockwise as drawn) and scaled by ω
+	a := vector.NewWithValues([]float64{w*w*r[0] + 2*w*v[1], w*w*r[1] - 2*w*v[0]})
+	if Engine.Integrator == SemiImplicitEuler {
+		a.Scale(dt)
+	}
+	return a
+}
+
+// dragAcceleration calculates the drag acceleration vector acting on Particle p (opposing its velocity; see
+// EngineData.DragCoefficient).
+func dragAcceleration(p *Particle) vector.Vector {
+	if Engine.DragCoefficient <= 0 {
+		return vector.New(2)
+	}
+
+	k := Engine.DragCoefficient
+	if Engine.QuadraticDrag {
+		k *= p.Velocity().Magnitude()
+	}
+	d := p.Velocity().Clone()
+	d.Scale(-k)
+	return d
+}
+
+// interactParticles handles the interaction between Particle p and another Particle o. If the two are colliding, it
+// determines whether they merge or bounce (and sets up those states accordingly; see collideParticles). Otherwise, it
+// adds the gravity, close charge, and far charge acceleration vectors o exerts on p to g, c, and f (in place). dt is
+// the time step (used to detect particles which would pass through each other during the step; see sweptCollision).
+// If deferred is not nil (when calculating in parallel), particle states are not changed; collisions and completed
+// bounces are instead recorded in deferred, to be handled later. New collisions are only tested for if
+// detectCollisions is set (that is, if o is a collision candidate; see spatialGrid).
+// Returns whether forces were added (that is, whether o should be counted when averaging the force vectors).
+func interactParticles(p, o *Particle, dt float64, g, c, f vector.Vector, deferred *velocityUpdate,
+	detectCollisions bool) bool {
+	// If comparing against itself, or p & o are merging, we don't need to calculate their force effects
+	// on each other
+	if _, ok := p.MergingWith[o]; ok || p == o {
+		return false
+	}
+
+	// Get the distance (mag) between the two particles (the nearest distance, if the boundary wraps)
+	v := minimumImage(vector.Subtract(p.Position(), o.Position()))
+	mag := v.Magnitude()
+
+	// Stop bounce once separated
+	if p.bouncing && p.bouncingAgainst == o {
+		if mag > Engine.BounceCompleteDistFactor*float64(p.Radius+o.Radius) {
+			if deferred != nil {
+				deferred.bounceCompleteWith = o
+			} else {
+				p.bouncing = false
 			}
-			// Increment the total number of particles for which forces are calculated between p & said particles,
-			// so that the forces can be averaged
-			ct++
+		}
+		return false
+	}
+
+	// New collision (not already bouncing against each other and distance between them is less than
+	// combined radii, or they would pass through each other during this step) - determine if merge or bounce
+	if detectCollisions && (mag < float64(p.Radius+o.Radius) || sweptCollision(p, o, v, dt)) {
+		if deferred != nil {
+			deferred.collisions = append(deferred.collisions, o)
+		} else {
+			collideParticles(p, o, v, mag)
+		}
+		// If we have a new collision (bounce/merge), we don't need to calculate the forces between p & o
+		// (which happens below)
+		return false
+	}
+
+	// v is the vector between p & o, which we need for calculating force vectors between the two.
+	// We need to a copy of it for each force (v for gravity, vc for close charge, vf for far charge)
+	vc := v.Clone()
+	vf := v.Clone()
+	// The (softened) distance used in the gravity and close charge denominators
+	soft := softenedDistance(mag)
+
+	// Simplified formula for getting v's unit vector (v/mag) and then scaling it by the
+	// felt force acceleration: f=G*m1*m2/mag^2 and a=f/m (own particle's mass divides out). v points from o to p, so
+	// it's negated to attract p toward o (or, if GravityStrength is negative, not, repelling p from o)
+	if Engine.EnableGravity {
+		v.Scale((Engine.GravityStrength * o.Mass() * -1) / math.Pow(soft, 3))
+		addInPlace(g, v)
+	}
+
+	if Engine.GravityOnly {
+		return true
+	}
+
+	// Simplified formula for getting vc's unit vector (vc/mag) and then scaling it by the
+	// felt force acceleration: f=C*c1*c2/mag^3 and a=f/m
+	if Engine.EnableCloseCharge {
+		vc.Scale((Engine.CloseChargeStrength * p.CloseCharge() * o.CloseCharge() * speciesFactor(p, o)) /
+			(p.Mass() * math.Pow(soft, 4)))
+		addInPlace(c, vc)
+	}
 
-			// v is the vector between p & o, which we need for calculating force vectors between the two.
-			// We need to a copy of it for each force (v for gravity, vc for close charge, vf for far charge)
-			vc = v.Clone()
-			vf = v.Clone()
+	// Simplified formula for getting vf's unit vector (vf/mag) and then scaling it by the
+	// felt force acceleration: f=C*c1*c2*mag and a=f/m (the distance divides out since proportional to
+	// distance rather than inversely and scaling to unit vector puts the magnitude on the divisor). For other
+	// Engine.FarChargeExponent values, f=C*c1*c2*mag^exponent, so the remaining mag^(exponent-1) is applied.
+	// There's no far charge force beyond Engine.FarChargeCutoff (if set).
+	if Engine.EnableFarCharge && !beyondFarChargeCutoff(mag) {
+		scale := (Engine.FarChargeStrength * p.FarCharge() * o.FarCharge() * -1) / p.Mass()
+		if Engine.FarChargeExponent != 1 {
+			scale *= math.Pow(mag, Engine.FarChargeExponent-1)
+		}
+		vf.Scale(scale)
+		addInPlace(f, vf)
+	}
 
-			// Simplified formula for getting v's unit vector (v/mag) and then scaling it by the
-			// felt force acceleration: f=G*m1*m2/mag^2 and a=f/m (own particle's mass divides out)
-			v.Scale((Engine.GravityStrength * o.Mass() * -1) / math.Pow(mag, 3))
-			g = vector.Add(g, v)
+	return true
+}
 
-			// Simplified formula for getting vc's unit vector (vc/mag) and then scaling it by the
-			// felt force acceleration: f=C*c1*c2/mag^3 and a=f/m
-			vc.Scale((Engine.CloseChargeStrength * p.CloseCharge() * o.CloseCharge()) /
-				(p.Mass() * math.Pow(mag, 4)))
-			c = vector.Add(c, vc)
+// beyondFarChargeCutoff indicates whether distance is beyond Engine.FarChargeCutoff (if set), so that there's no far
+// charge force.
+func beyondFarChargeCutoff(distance float64) bool {
+	return Engine.FarChargeCutoff > 0 && distance > Engine.FarChargeCutoff
+}
 
-			// Simplified formula for getting vf's unit vector (vf/mag) and then scaling it by the
-			// felt force acceleration: f=C*c1*c2*mag and a=f/m (the distance divides out since proportional to
-			// distance rather than inversely and scaling to unit vector puts the magnitude on the divisor).
-			vf.Scale((Engine.FarChargeStrength * p.FarCharge() * o.FarCharge() * -1) / p.Mass())
-			f = vector.Add(f, vf)
+// collideParticles handles a new collision between Particles p and o, which either merge (the merge is completed in
+// UpdateParticles) or bounce. v is the vector from o to p, and mag its magnitude (the distance between them).
+// Particles which could merge (see EngineData.MergeMassRatioThreshold and EngineData.MergeCloseChargeThreshold) still
+// bounce if approaching faster than Engine.CaptureSpeed.
+func collideParticles(p, o *Particle, v vector.Vector, mag float64) {
+	var massRatio float64
+	if Engine.AllowMerge {
+		if p.Mass() > o.Mass() {
+			massRatio = p.Mass() / o.Mass()
+		} else {
+			massRatio = o.Mass() / p.Mass()
 		}
+	}
+
+	// Merge if mergers are enabled and the mass difference is sufficient and the close charge doesn't repel
+	// enough to prevent it, unless the particles are approaching too fast to be captured
+	if Engine.AllowMerge && massRatio > Engine.MergeMassRatioThreshold &&
+		(math.Signbit(p.CloseCharge()) != math.Signbit(o.CloseCharge()) ||
+			math.Abs(p.CloseCharge())+math.Abs(o.CloseCharge()) < Engine.MergeCloseChargeThreshold) &&
+		!tooFastToCapture(p, o, v, mag) {
+		p.merging = true
+		// Add o to p's MergingWith (set its value to an empty anonymous struct, so that the key exists)
+		p.MergingWith[o] = struct{}{}
+		// If o doesn't already have p in it's MergingWith (because o came before p in the outer loop),
+		// add it
+		if _, ok := o.MergingWith[p]; !ok {
+			o.merging = true
+			o.MergingWith[p] = struct{}{}
+		}
+		return
+	}
+
+	// Bounce: the velocity components along the line of centers (the contact normal) are exchanged according to the
+	// particles' masses (and Engine.Restitution; the collision is elastic if it's 1), while the tangential components
+	// are preserved.
+	// The contact normal is undefined if the particles are at exactly the same position
+	if mag == 0 {
+		return
+	}
+	n := v.Clone()
+	n.Scale(1 / mag)
+	// The closing speed along the normal (negative if the particles are approaching each other)
+	closing, err := vector.Dot(vector.Subtract(p.Velocity(), o.Velocity()), n)
+	if err != nil {
+		return
+	}
+	// We now know the math of the bounce will succeed, so it's safe to set the bouncing state
+	// (which gets unset when the particles are sufficiently separated). Both particles' velocities are
+	// updated here, so o is put in the bouncing state too (otherwise o would bounce against p again, using
+	// p's already updated velocity).
+	p.bouncing = true
+	p.bouncingAgainst = o
+	o.bouncing = true
+	o.bouncingAgainst = p
+	// Particles which are already separating don't need their velocities changed
+	if closing < 0 {
+		// The impulse is (1 + Restitution) times that which would stop the particles along the normal
+		e := 1 + Engine.Restitution
+		totalMass := p.Mass() + o.Mass()
+		pFactor, oFactor := e*o.Mass()/totalMass, e*p.Mass()/totalMass
+		// Fixed particles don't move, so they act as if infinitely massive (the other particle is reflected as if by a
+		// wall)
+		if p.Fixed() && o.Fixed() {
+			pFactor, oFactor = 0, 0
+		} else if o.Fixed() {
+			pFactor, oFactor = e, 0
+		} else if p.Fixed() {
+			pFactor, oFactor = 0, e
+		}
+		pn := n.Clone()
+		pn.Scale(pFactor * closing)
+		p.SetVelocity(vector.Subtract(p.Velocity(), pn))
+		on := n.Clone()
+		on.Scale(oFactor * closing)
+		o.SetVelocity(vector.Add(o.Velocity(), on))
+	}
+
+	// Reported once the velocities have been updated
+	if ReportBounces {
+		reportEvent(Event{Kind: BounceEvent, Particles: []*Particle{p, o}})
+	}
+}
+
+// tooFastToCapture determines whether colliding Particles p and o are approaching each other faster (along the line of
+// centers; v is the vector from o to p, and mag its magnitude) than Engine.CaptureSpeed, so they bounce rather than
+// merge. Particles at exactly the same position have no line of centers, so can always be captured.
+func tooFastToCapture(p, o *Particle, v vector.Vector, mag float64) bool {
+	if Engine.CaptureSpeed <= 0 || mag == 0 {
+		return false
+	}
+	closing, err := vector.Dot(vector.Subtract(p.Velocity(), o.Velocity()), v)
+	if err != nil {
+		return false
+	}
+	// The closing speed is negative if the particles are approaching each other
+	return -closing/mag > Engine.CaptureSpeed
+}
 
-		// Compute the average force acceleration vectors
-		g.Scale(1.0 / float64(ct))
-		c.Scale(1.0 / float64(ct))
-		f.Scale(1.0 / float64(ct))
+// softenedDistance gets the distance used in the denominators of the gravity and close charge force formulas:
+// sqrt(mag^2 + Engine.SofteningLength^2). This keeps the forces bounded as the distance (mag) approaches zero.
+// Without softening, mag itself is returned (exactly).
+func softenedDistance(mag float64) float64 {
+	if Engine.SofteningLength == 0 {
+		return mag
+	}
+	return math.Sqrt(mag*mag + Engine.SofteningLength*Engine.SofteningLength)
+}
 
-		// Sum the (now averaged) acceleration vectors from each force and apply it to the particle
-		// (add the summed acceleration vector to the velocity)
-		p.SetVelocity(vector.Add(vector.Add(vector.Add(p.Velocity(), g), c), f))
+// addInPlace adds src to dst, modifying dst (rather than allocating a new vector, as vector.Add does).
+func addInPlace(dst, src vector.Vector) {
+	for i := range dst {
+		dst[i] += src[i]
 	}
 }
 
-// updateParticlePositions updates the Engine.Particles positions by calling Particle.UpdatePosition on each particle
-// (which adds the Particle's Velocity vector to its Position vector).
-func updateParticlePositions() {
+// updateParticlePositions updates the Engine.Particles positions by adding each Particle's Velocity vector, scaled by
+// the time step dt, to its Position vector (except for Fixed particles).
+func updateParticlePositions(dt float64) {
+	var d vector.Vector
 	for _, p := range Engine.Particles {
-		p.UpdatePosition()
+		// Fixed particles don't move
+		if p.Fixed() {
+			continue
+		}
+		d = p.Velocity().Clone()
+		d.Scale(dt)
+		p.movePosition(d)
+	}
+}
+
+// sweptCollision determines whether Particles p and o, which are not currently colliding, would pass through each other
+// during a step of time dt (so that fast particles can't "tunnel" through each other without a collision being
+// detected). v is the vector from o to p. The particles' relative motion during the step is a line segment; if the
+// closest approach along it is within their combined radii but they would no longer be colliding by the end of the
+// step, they would have passed through each other. (If they would still be colliding at the end of the step, the
+// collision is simply detected next step.)
+func sweptCollision(p, o *Particle, v vector.Vector, dt float64) bool {
+	// Relative velocity
+	w := vector.Subtract(p.Velocity(), o.Velocity())
+	ww, err := vector.Dot(w, w)
+	if err != nil || ww == 0 {
+		return false
+	}
+	vw, err := vector.Dot(v, w)
+	if err != nil {
+		return false
+	}
+
+	radii := float64(p.Radius + o.Radius)
+	distanceAt := func(t float64) float64 {
+		return math.Hypot(v[0]+w[0]*t, v[1]+w[1]*t)
+	}
+	// Time of closest approach, limited to the step
+	t := math.Max(0, math.Min(-vw/ww, dt))
+
+	return distanceAt(t) < radii && distanceAt(dt) >= radii
+}
+
+// limitSpeed clamps the magnitude of Particle p's velocity to Engine.MaxSpeed (if set).
+func limitSpeed(p *Particle) {
+	if Engine.MaxSpeed <= 0 {
+		return
+	}
+	if speed := p.Velocity().Magnitude(); speed > Engine.MaxSpeed {
+		v := p.Velocity().Clone()
+		v.Scale(Engine.MaxSpeed / speed)
+		p.SetVelocity(v)
+	}
+}
+
+// limitAcceleration scales the acceleration vectors parts (in place), which are summed to give a particle's acceleration
+// (or velocity change, scaled by time step dt, if using the SemiImplicitEuler integrator), so that the magnitude of
+// their sum is at most Engine.MaxAcceleration (if set). Scaling the parts, rather than their sum, leaves the summed
+// vector's direction unchanged.
+func limitAcceleration(dt float64, parts ...vector.Vector) {
+	if Engine.MaxAcceleration <= 0 {
+		return
+	}
+	limit := Engine.MaxAcceleration
+	if Engine.Integrator == SemiImplicitEuler {
+		limit *= dt
+	}
+	sum := vector.New(2)
+	for _, v := range parts {
+		sum = vector.Add(sum, v)
+	}
+	// Non-finite sums aren't limited (the update is skipped; see SkippedUpdates)
+	if magnitude := sum.Magnitude(); magnitude > limit && !math.IsInf(magnitude, 0) {
+		for _, v := range parts {
+			v.Scale(limit / magnitude)
+		}
+	}
+}
+
+// minimumImage adjusts the vector v between two positions (in place, and returns it) so that, along each axis the
+// environment wraps around (see EngineData.wrapsAxis), it is the shortest such vector - that is, the vector to the
+// nearest "image" of the other position, which may be across the edge of the environment. Otherwise, v is unchanged.
+func minimumImage(v vector.Vector) vector.Vector {
+	for i := range v {
+		if !Engine.wrapsAxis(i) {
+			continue
+		}
+		size := Engine.environmentExtent(i)
+		if v[i] > size/2 {
+			v[i] -= size
+		} else if v[i] < -size/2 {
+			v[i] += size
+		}
+	}
+	return v
+}
+
+// wrapOverEdge moves the provided position (in place) to the opposite side of the environment if it is beyond the edge
+// at the start (left or top, if far is false) or end (right or bottom) of axis (0 for the sides, 1 for the top and
+// bottom).
+func wrapOverEdge(position vector.Vector, axis int, far bool) {
+	size := Engine.environmentExtent(axis)
+	if (!far && position[axis] < 0) || (far && position[axis] >= size) {
+		position[axis] = math.Mod(position[axis], size)
+		if position[axis] < 0 {
+			position[axis] += size
+		}
+	}
+}
+
+// beyondEdge indicates whether the circle representing Particle p is entirely beyond the environment edge at the start
+// (left or top, if far is false) or end (right or bottom) of axis (0 for the sides, 1 for the top and bottom).
+func beyondEdge(p *Particle, axis int, far bool) bool {
+	if far {
+		return p.Position()[axis]-float64(p.Radius) > Engine.environmentExtent(axis)
 	}
+	return p.Position()[axis]+float64(p.Radius) < 0
 }
@@ -0,0 +1,1109 @@
+package physics
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/atedja/go-vector"
+)
+
+// resetEngine reinitializes the Engine (with its default settings) and sets its particles to those provided.
+func resetEngine(particles ...*Particle) {
+	Engine = EngineData{}
+	Engine.Initialize()
+	SetParticles(particles)
+}
+
+// randomParticles creates n particles with random masses, charges, and positions within the default environment, and
+// small random velocities. The same seed always creates the same particles (other than their IDs).
+func randomParticles(n int, seed int64) []*Particle {
+	r := rand.New(rand.NewSource(seed))
+	particles := make([]*Particle, n)
+	for i := range particles {
+		p := NewParticle(1+r.Float64()*49, r.Float64()*2-1, r.Float64(), 20+r.Float64()*760, 20+r.Float64()*760)
+		p.Velocity()[0], p.Velocity()[1] = r.Float64()-0.5, r.Float64()-0.5
+		particles[i] = p
+	}
+	return particles
+}
+
+// BenchmarkUpdateParticles benchmarks UpdateParticles with the forces summed directly (comparing every pair of
+// particles) and with the Barnes-Hut approximation, each serially and in parallel, for several numbers of particles.
+// Merging is disabled so the number of particles stays the same throughout.
+func BenchmarkUpdateParticles(b *testing.B) {
+	modes := []struct {
+		name      string
+		barnesHut bool
+		workers   int
+	}{{"Direct", false, 1}, {"BarnesHut", true, 1}, {"DirectParallel", false, 4}, {"BarnesHutParallel", true, 4}}
+	for _, mode := range modes {
+		for _, n := range []int{100, 500, 1000} {
+			b.Run(fmt.Sprintf("%s/%d", mode.name, n), func(b *testing.B) {
+				resetEngine(randomParticles(n, 1)...)
+				Engine.AllowMerge = false
+				Engine.UseBarnesHut = mode.barnesHut
+				Engine.Workers = mode.workers
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					UpdateParticles()
+				}
+			})
+		}
+	}
+}
+
+// TestLoneParticleForces checks that a particle without any other particles to exert forces on it isn't accelerated
+// (rather than its velocity becoming NaN from averaging over zero particles), with each integrator.
+func TestLoneParticleForces(t *testing.T) {
+	for _, integrator := range []Integrator{SemiImplicitEuler, Euler, VelocityVerlet} {
+		p := NewParticle(10, 0.5, 0.5, 400, 400)
+		p.Velocity()[0] = 1
+		resetEngine(p)
+		Engine.Integrator = integrator
+		UpdateParticles()
+		if !finite(p.Velocity()) || !finite(p.Position()) {
+			t.Errorf("integrator %d: got velocity %v and position %v, want finite", integrator, p.Velocity(),
+				p.Position())
+			continue
+		}
+		if p.Velocity()[0] != 1 || p.Velocity()[1] != 0 {
+			t.Errorf("integrator %d: got velocity %v, want [1 0]", integrator, p.Velocity())
+		}
+		if p.Position()[0] != 401 || p.Position()[1] != 400 {
+			t.Errorf("integrator %d: got position %v, want [401 400]", integrator, p.Position())
+		}
+	}
+}
+
+// TestBouncingPairForces checks that two particles bouncing against each other (so that neither has any other particle
+// to exert forces on it) aren't accelerated, and stay finite, with each integrator.
+func TestBouncingPairForces(t *testing.T) {
+	for _, integrator := range []Integrator{SemiImplicitEuler, Euler, VelocityVerlet} {
+		p, o := NewParticle(100, 0.5, 0.5, 400, 400), NewParticle(100, -0.5, 0.5, 404, 400)
+		resetEngine(p, o)
+		Engine.Integrator = integrator
+		p.Velocity()[0], o.Velocity()[0] = -0.5, 0.5
+		p.bouncing, p.bouncingAgainst = true, o
+		o.bouncing, o.bouncingAgainst = true, p
+		for step := 1; step <= 3; step++ {
+			UpdateParticles()
+			for _, q := range []*Particle{p, o} {
+				if !finite(q.Velocity()) || !finite(q.Position()) {
+					t.Fatalf("integrator %d, step %d: got velocity %v and position %v, want finite", integrator,
+						step, q.Velocity(), q.Position())
+				}
+			}
+			if p.Velocity()[0] != -0.5 || o.Velocity()[0] != 0.5 || p.Velocity()[1] != 0 || o.Velocity()[1] != 0 {
+				t.Errorf("integrator %d, step %d: got velocities %v and %v, want [-0.5 0] and [0.5 0]", integrator,
+					step, p.Velocity(), o.Velocity())
+			}
+		}
+		if len(Engine.Particles) != 2 {
+			t.Errorf("integrator %d: got %d particles, want 2", integrator, len(Engine.Particles))
+		}
+	}
+}
+
+// TestMergeConservesMomentum checks that particles merging conserve their total mass and momentum.
+func TestMergeConservesMomentum(t *testing.T) {
+	tests := []struct {
+		name      string
+		particles func() []*Particle
+	}{
+		{"pair", func() []*Particle {
+			a, b := NewParticle(100, 0.5, 0.5, 400, 400), NewParticle(10, -0.5, 0.5, 403, 400)
+			a.SetVelocity(vector.NewWithValues([]float64{1, 0.5}))
+			b.SetVelocity(vector.NewWithValues([]float64{-2, 3}))
+			return []*Particle{a, b}
+		}},
+		{"three", func() []*Particle {
+			a, b, c := NewParticle(200, 0.5, 0.5, 400, 400), NewParticle(10, -0.5, 0.5, 404, 400),
+				NewParticle(20, -0.5, 0.5, 400, 396)
+			a.SetVelocity(vector.NewWithValues([]float64{0, -1}))
+			b.SetVelocity(vector.NewWithValues([]float64{-3, 0}))
+			c.SetVelocity(vector.NewWithValues([]float64{2, 2}))
+			return []*Particle{a, b, c}
+		}},
+	}
+	for _, test := range tests {
+		resetEngine(test.particles()...)
+		mass, momentum := Summarize().TotalMass, TotalMomentum()
+		merged, _, _, _ := UpdateParticles()
+		if !merged || len(Engine.Particles) != 1 {
+			t.Errorf("%s: got %d particles (merged: %v), want 1", test.name, len(Engine.Particles), merged)
+			continue
+		}
+		if got := Engine.Particles[0].Mass(); got != mass {
+			t.Errorf("%s: got mass %g, want %g", test.name, got, mass)
+		}
+		if got := TotalMomentum(); !closeTo(got[0], momentum[0]) || !closeTo(got[1], momentum[1]) {
+			t.Errorf("%s: got momentum %v, want %v", test.name, got, momentum)
+		}
+	}
+}
+
+// closeTo indicates whether a and b are equal, to within a small relative tolerance (for comparing values which may
+// differ in their last bits due to rounding).
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// TestLimitSpeed checks that particles faster than Engine.MaxSpeed are slowed to it, keeping their direction.
+func TestLimitSpeed(t *testing.T) {
+	tests := []struct {
+		maxSpeed float64
+		velocity []float64
+		want     []float64
+	}{
+		{0, []float64{30, 40}, []float64{30, 40}},
+		{100, []float64{30, 40}, []float64{30, 40}},
+		{10, []float64{30, 40}, []float64{6, 8}},
+		{10, []float64{-30, 40}, []float64{-6, 8}},
+	}
+	for _, test := range tests {
+		resetEngine()
+		Engine.MaxSpeed = test.maxSpeed
+		p := NewParticle(1, 0, 0, 0, 0)
+		p.SetVelocity(vector.NewWithValues(test.velocity))
+		limitSpeed(p)
+		if v := p.Velocity(); !closeTo(v[0], test.want[0]) || !closeTo(v[1], test.want[1]) {
+			t.Errorf("MaxSpeed %g, velocity %v: got %v, want %v", test.maxSpeed, test.velocity, v, test.want)
+		}
+	}
+}
+
+// TestSweptCollision checks that particles which would pass through each other during a step are detected, but those
+// which miss each other, or would still be colliding at the end of the step, aren't.
+func TestSweptCollision(t *testing.T) {
+	tests := []struct {
+		name   string
+		o      []float64
+		vp, vo []float64
+		want   bool
+	}{
+		{"pass through", []float64{420, 400}, []float64{30, 0}, []float64{-30, 0}, true},
+		{"still colliding", []float64{420, 400}, []float64{10, 0}, []float64{-10, 0}, false},
+		{"miss", []float64{420, 430}, []float64{30, 0}, []float64{-30, 0}, false},
+		{"separating", []float64{420, 400}, []float64{-30, 0}, []float64{30, 0}, false},
+		{"stationary", []float64{420, 400}, []float64{0, 0}, []float64{0, 0}, false},
+	}
+	for _, test := range tests {
+		resetEngine()
+		p, o := NewParticle(100, 0, 0, 400, 400), NewParticle(100, 0, 0, test.o[0], test.o[1])
+		p.SetVelocity(vector.NewWithValues(test.vp))
+		o.SetVelocity(vector.NewWithValues(test.vo))
+		if got := sweptCollision(p, o, vector.Subtract(p.Position(), o.Position()), 1); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestSoftenedDistance checks the distances used in the force denominators, with and without softening.
+func TestSoftenedDistance(t *testing.T) {
+	tests := []struct {
+		softening, mag, want float64
+	}{
+		{0, 5, 5},
+		{0, 0, 0},
+		{3, 4, 5},
+		{3, 0, 3},
+	}
+	for _, test := range tests {
+		resetEngine()
+		Engine.SofteningLength = test.softening
+		if got := softenedDistance(test.mag); !closeTo(got, test.want) {
+			t.Errorf("SofteningLength %g, distance %g: got %g, want %g", test.softening, test.mag, got, test.want)
+		}
+	}
+}
+
+// TestSofteningBoundsGravity checks that softening reduces the gravity between close particles (to that of particles
+// the softened distance apart), and keeps the accelerations of near-coincident particles finite and bounded.
+func TestSofteningBoundsGravity(t *testing.T) {
+	p, o := NewParticle(100, 0, 0, 400, 400), NewParticle(100, 0, 0, 410, 400)
+	resetEngine(p, o)
+	Engine.GravityOnly = true
+	hard, _, _ := ForcesOn(p)
+	Engine.SofteningLength = 10
+	soft, _, _ := ForcesOn(p)
+	// With the softened distance sqrt(2) times the actual distance, the force is divided by 2*sqrt(2)
+	if want := hard[0] / (2 * math.Sqrt2); !closeTo(soft[0], want) || soft[1] != 0 {
+		t.Errorf("got softened gravity %v, want [%g 0]", soft, want)
+	}
+
+	// Near-coincident particles (which would otherwise be accelerated ~1e12 times harder than those above). The
+	// softened accelerations are greatest at distances of s/sqrt(2) for gravity, G*m/(d^2+s^2)^(3/2)*d, and s/sqrt(3)
+	// for the close charge, C*c1*c2/(m*(d^2+s^2)^2)*d
+	p, o = NewParticle(100, 0.5, 0.5, 400, 400), NewParticle(100, 0.5, 0.5, 400+1e-6, 400)
+	resetEngine(p, o)
+	s := 10.0
+	Engine.SofteningLength = s
+	maxGravity := 2 * Engine.GravityStrength * o.Mass() / (3 * math.Sqrt(3) * s * s)
+	maxClose := 9 * Engine.CloseChargeStrength * p.CloseCharge() * o.CloseCharge() /
+		(16 * math.Sqrt(3) * p.Mass() * s * s * s)
+	g, c, f := ForcesOn(p)
+	if !finite(g) || !finite(c) || !finite(f) {
+		t.Fatalf("near-coincident: got accelerations %v, %v, and %v, want finite", g, c, f)
+	}
+	if g.Magnitude() > maxGravity || c.Magnitude() > maxClose {
+		t.Errorf("near-coincident: got gravity %g and close charge %g, want at most %g and %g", g.Magnitude(),
+			c.Magnitude(), maxGravity, maxClose)
+	}
+	if g.Magnitude() == 0 || c.Magnitude() == 0 {
+		t.Errorf("near-coincident: got gravity %v and close charge %v, want nonzero", g, c)
+	}
+}
+
+// TestDragAcceleration checks the linear and quadratic drag accelerations, and that drag stops a particle rather than
+// reversing it.
+func TestDragAcceleration(t *testing.T) {
+	tests := []struct {
+		coefficient float64
+		quadratic   bool
+		velocity    []float64
+		want        []float64
+		// wantVelocity is the velocity of a lone particle after an update
+		wantVelocity []float64
+	}{
+		{0, false, []float64{3, 4}, []float64{0, 0}, []float64{3, 4}},
+		{0.1, false, []float64{3, 4}, []float64{-0.3, -0.4}, []float64{2.7, 3.6}},
+		{0.1, true, []float64{3, 4}, []float64{-1.5, -2}, []float64{1.5, 2}},
+		{2, false, []float64{3, 4}, []float64{-6, -8}, []float64{0, 0}},
+	}
+	for _, test := range tests {
+		p := NewParticle(10, 0, 0, 400, 400)
+		resetEngine(p)
+		Engine.DragCoefficient, Engine.QuadraticDrag = test.coefficient, test.quadratic
+		p.SetVelocity(vector.NewWithValues(test.velocity))
+		if got := dragAcceleration(p); !closeTo(got[0], test.want[0]) || !closeTo(got[1], test.want[1]) {
+			t.Errorf("coefficient %g, quadratic %v: got drag %v, want %v", test.coefficient, test.quadratic, got,
+				test.want)
+		}
+		UpdateParticles()
+		if v := p.Velocity(); !closeTo(v[0], test.wantVelocity[0]) || !closeTo(v[1], test.wantVelocity[1]) {
+			t.Errorf("coefficient %g, quadratic %v: got velocity %v, want %v", test.coefficient, test.quadratic, v,
+				test.wantVelocity)
+		}
+	}
+}
+
+// TestDragDecay checks that a lone particle's speed decays geometrically (exponentially) toward zero with linear drag,
+// by the same factor each step.
+func TestDragDecay(t *testing.T) {
+	p := NewParticle(10, 0, 0, 400, 400)
+	resetEngine(p)
+	Engine.DragCoefficient = 0.05
+	p.SetVelocity(vector.NewWithValues([]float64{0.3, 0.4}))
+	initial, speed := p.Velocity().Magnitude(), p.Velocity().Magnitude()
+	const steps = 20
+	for step := 1; step <= steps; step++ {
+		UpdateParticles()
+		got := p.Velocity().Magnitude()
+		if !closeTo(got/speed, 1-Engine.DragCoefficient) {
+			t.Errorf("step %d: got speed %g (%g times the previous), want %g times the previous", step, got,
+				got/speed, 1-Engine.DragCoefficient)
+		}
+		speed = got
+	}
+	if want := initial * math.Pow(1-Engine.DragCoefficient, steps); !closeTo(speed, want) {
+		t.Errorf("got final speed %g, want %g", speed, want)
+	}
+	if v := p.Velocity(); !closeTo(v[0]/v[1], 0.75) {
+		t.Errorf("got final velocity %v, want it in the initial direction", v)
+	}
+}
+
+// TestBounceOffWall checks that particles reaching each wall are moved back inside the environment with their velocity
+// into the wall reflected, while those inside it are unchanged.
+func TestBounceOffWall(t *testing.T) {
+	tests := []struct {
+		axis         int
+		far          bool
+		position     []float64
+		velocity     []float64
+		wantPosition []float64
+		wantVelocity []float64
+	}{
+		{0, false, []float64{-2, 400}, []float64{-3, 1}, []float64{3, 400}, []float64{3, 1}},
+		{0, true, []float64{801, 400}, []float64{3, 1}, []float64{796, 400}, []float64{-3, 1}},
+		{1, false, []float64{400, 1}, []float64{1, -3}, []float64{400, 3}, []float64{1, 3}},
+		{1, true, []float64{400, 799}, []float64{1, 3}, []float64{400, 796}, []float64{1, -3}},
+		{0, false, []float64{400, 400}, []float64{-3, 1}, []float64{400, 400}, []float64{-3, 1}},
+	}
+	for _, test := range tests {
+		resetEngine()
+		p := NewParticle(100, 0, 0, test.position[0], test.position[1])
+		p.SetVelocity(vector.NewWithValues(test.velocity))
+		bounceOffWall(p, test.axis, test.far)
+		if pos, v := p.Position(), p.Velocity(); !closeTo(pos[0], test.wantPosition[0]) ||
+			!closeTo(pos[1], test.wantPosition[1]) || !closeTo(v[0], test.wantVelocity[0]) ||
+			!closeTo(v[1], test.wantVelocity[1]) {
+			t.Errorf("axis %d, far %v, position %v, velocity %v: got position %v and velocity %v, want %v and %v",
+				test.axis, test.far, test.position, test.velocity, pos, v, test.wantPosition, test.wantVelocity)
+		}
+	}
+}
+
+// TestBounceOffCorner checks that a particle moving diagonally into a corner bounces off both walls, having both
+// components of its velocity reflected.
+func TestBounceOffCorner(t *testing.T) {
+	tests := []struct {
+		name         string
+		position     []float64
+		velocity     []float64
+		wantPosition []float64
+		wantVelocity []float64
+	}{
+		{"top left", []float64{4, 5}, []float64{-3, -4}, []float64{3, 3}, []float64{3, 4}},
+		{"bottom right", []float64{795, 794}, []float64{3, 4}, []float64{796, 796}, []float64{-3, -4}},
+		{"top right", []float64{795, 5}, []float64{3, -4}, []float64{796, 3}, []float64{-3, 4}},
+	}
+	for _, test := range tests {
+		p := NewParticle(100, 0, 0, test.position[0], test.position[1])
+		resetEngine(p)
+		p.SetVelocity(vector.NewWithValues(test.velocity))
+		UpdateParticles()
+		if pos, v := p.Position(), p.Velocity(); !closeTo(pos[0], test.wantPosition[0]) ||
+			!closeTo(pos[1], test.wantPosition[1]) || !closeTo(v[0], test.wantVelocity[0]) ||
+			!closeTo(v[1], test.wantVelocity[1]) {
+			t.Errorf("%s: got position %v and velocity %v, want %v and %v", test.name, pos, v, test.wantPosition,
+				test.wantVelocity)
+		}
+	}
+}
+
+// TestSplit checks that splitting a particle conserves its mass, momentum, and charges, and gives the fragments new
+// IDs.
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		velocity []float64
+	}{
+		{"stationary", []float64{0, 0}},
+		{"moving", []float64{3, -4}},
+	}
+	for _, test := range tests {
+		resetEngine()
+		p := NewParticle(6000, -0.4, 0.7, 400, 400)
+		p.SetVelocity(vector.NewWithValues(test.velocity))
+		a, b := p.split()
+		if got := a.Mass() + b.Mass(); got != p.Mass() {
+			t.Errorf("%s: got mass %g, want %g", test.name, got, p.Mass())
+		}
+		for i := 0; i < 2; i++ {
+			got := a.Mass()*a.Velocity()[i] + b.Mass()*b.Velocity()[i]
+			if want := p.Mass() * p.Velocity()[i]; !closeTo(got, want) {
+				t.Errorf("%s: got momentum[%d] %g, want %g", test.name, i, got, want)
+			}
+		}
+		for _, f := range []*Particle{a, b} {
+			if f.CloseCharge() != p.CloseCharge() || f.FarCharge() != p.FarCharge() {
+				t.Errorf("%s: got charges %g and %g, want %g and %g", test.name, f.CloseCharge(), f.FarCharge(),
+					p.CloseCharge(), p.FarCharge())
+			}
+		}
+		if a.ID() == p.ID() || b.ID() == p.ID() || a.ID() == b.ID() {
+			t.Errorf("%s: got IDs %d and %d splitting %d, want new IDs", test.name, a.ID(), b.ID(), p.ID())
+		}
+		if d := vector.Subtract(a.Position(), b.Position()).Magnitude(); d < float64(a.Radius+b.Radius) {
+			t.Errorf("%s: got fragments %g apart, want at least %d", test.name, d, a.Radius+b.Radius)
+		}
+	}
+}
+
+// TestFission checks that particles more massive than Engine.MaxMass split when fission is allowed, unless fixed or
+// at the particle limit.
+func TestFission(t *testing.T) {
+	tests := []struct {
+		name         string
+		allow, fixed bool
+		maxParticles int
+		want         int
+	}{
+		{"allowed", true, false, 0, 2},
+		{"disallowed", false, false, 0, 1},
+		{"fixed", true, true, 0, 1},
+		{"limited", true, false, 1, 1},
+	}
+	for _, test := range tests {
+		p := NewParticle(6000, 0, 0.5, 400, 400)
+		p.SetFixed(test.fixed)
+		resetEngine(p)
+		Engine.AllowFission, Engine.MaxParticles = test.allow, test.maxParticles
+		UpdateParticles()
+		if len(Engine.Particles) != test.want {
+			t.Errorf("%s: got %d particles, want %d", test.name, len(Engine.Particles), test.want)
+		}
+	}
+}
+
+// TestExternalField checks that the external field accelerates particles (other than fixed ones) uniformly, with each
+// integrator.
+func TestExternalField(t *testing.T) {
+	tests := []struct {
+		integrator Integrator
+		fixed      bool
+		want       float64
+	}{
+		{SemiImplicitEuler, false, 0.5},
+		{Euler, false, 0.5},
+		{VelocityVerlet, false, 0.5},
+		{SemiImplicitEuler, true, 0},
+	}
+	for _, test := range tests {
+		p := NewParticle(10, 0, 0, 400, 400)
+		p.SetFixed(test.fixed)
+		resetEngine(p)
+		Engine.Integrator = test.integrator
+		Engine.SetExternalFieldPolar(0.5, 90)
+		// VelocityVerlet applies the average of the previous and new accelerations, so needs a step to settle
+		UpdateParticles()
+		v := p.Velocity()[1]
+		UpdateParticles()
+		if got := p.Velocity()[1] - v; !closeTo(got, test.want) || math.Abs(p.Velocity()[0]) > 1e-9 {
+			t.Errorf("integrator %d, fixed %v: got velocity change %g (velocity %v), want %g", test.integrator,
+				test.fixed, got, p.Velocity(), test.want)
+		}
+	}
+}
+
+// TestLimitParticles checks that the most recently added particles beyond Engine.MaxParticles are removed.
+func TestLimitParticles(t *testing.T) {
+	tests := []struct {
+		maxParticles, particles int
+		want                    int
+		wantAtLimit             bool
+	}{
+		{0, 5, 5, false},
+		{10, 5, 5, false},
+		{5, 5, 5, true},
+		{3, 5, 3, true},
+	}
+	for _, test := range tests {
+		particles := randomParticles(test.particles, 3)
+		resetEngine(particles...)
+		Engine.MaxParticles = test.maxParticles
+		limitParticles()
+		if len(Engine.Particles) != test.want {
+			t.Errorf("MaxParticles %d, %d particles: got %d particles, want %d", test.maxParticles, test.particles,
+				len(Engine.Particles), test.want)
+			continue
+		}
+		for i, p := range Engine.Particles {
+			if p != particles[i] {
+				t.Errorf("MaxParticles %d, %d particles: got particle %d at %d, want %d", test.maxParticles,
+					test.particles, p.ID(), i, particles[i].ID())
+			}
+		}
+		if got := AtParticleLimit(); got != test.wantAtLimit {
+			t.Errorf("MaxParticles %d, %d particles: got AtParticleLimit %v, want %v", test.maxParticles,
+				test.particles, got, test.wantAtLimit)
+		}
+	}
+}
+
+// regionParticles creates particles at (100, 100), (200, 100), (100, 300), and (790, 400), in that order.
+func regionParticles() []*Particle {
+	return []*Particle{NewParticle(10, 0, 0, 100, 100), NewParticle(10, 0, 0, 200, 100),
+		NewParticle(10, 0, 0, 100, 300), NewParticle(10, 0, 0, 790, 400)}
+}
+
+// TestParticlesInRect checks that the particles within a rectangle (including its edges, with its corners in either
+// order) are found, in order.
+func TestParticlesInRect(t *testing.T) {
+	tests := []struct {
+		x0, y0, x1, y1 float64
+		want           []int
+	}{
+		{0, 0, 800, 800, []int{0, 1, 2, 3}},
+		{50, 50, 150, 350, []int{0, 2}},
+		{200, 300, 100, 100, []int{0, 1, 2}},
+		{101, 101, 199, 299, nil},
+	}
+	for _, test := range tests {
+		particles := regionParticles()
+		resetEngine(particles...)
+		checkParticles(t, fmt.Sprintf("rect (%g, %g)-(%g, %g)", test.x0, test.y0, test.x1, test.y1),
+			ParticlesInRect(test.x0, test.y0, test.x1, test.y1), particles, test.want)
+	}
+}
+
+// TestParticlesInRadius checks that the particles within a distance of a point (including those exactly that far
+// away, and across the edges if the environment wraps around) are found, in order.
+func TestParticlesInRadius(t *testing.T) {
+	tests := []struct {
+		cx, cy, r float64
+		wrap      bool
+		want      []int
+	}{
+		{100, 100, 100, false, []int{0, 1}},
+		{100, 100, 99, false, []int{0}},
+		{150, 200, 120, false, []int{0, 1, 2}},
+		{10, 400, 30, false, nil},
+		{10, 400, 30, true, []int{3}},
+	}
+	for _, test := range tests {
+		particles := regionParticles()
+		resetEngine(particles...)
+		Engine.WallBounce, Engine.WrapBoundary = !test.wrap, test.wrap
+		checkParticles(t, fmt.Sprintf("radius %g of (%g, %g), wrap %v", test.r, test.cx, test.cy, test.wrap),
+			ParticlesInRadius(test.cx, test.cy, test.r), particles, test.want)
+	}
+}
+
+// checkParticles checks that got holds the particles (at the indexes want) in order, reporting any difference as an
+// error for the named test case.
+func checkParticles(t *testing.T, name string, got, particles []*Particle, want []int) {
+	t.Helper()
+	ok := len(got) == len(want)
+	for i := 0; ok && i < len(got); i++ {
+		ok = got[i] == particles[want[i]]
+	}
+	if !ok {
+		t.Errorf("%s: got %v, want particles %v", name, got, want)
+	}
+}
+
+// TestForcesOn checks the direction of each force acting on a particle, that disabled forces are zero, and that the
+// particles aren't changed.
+func TestForcesOn(t *testing.T) {
+	tests := []struct {
+		name                            string
+		gravity, close, far             bool
+		wantGravity, wantClose, wantFar float64
+	}{
+		// o is to the right of p, so forces toward it are positive, and those away from it negative
+		{"all", true, true, true, 1, -1, 1},
+		{"gravity", true, false, false, 1, 0, 0},
+		{"close charge", false, true, false, 0, -1, 0},
+		{"far charge", false, false, true, 0, 0, 1},
+	}
+	sign := func(v float64) float64 {
+		if v == 0 {
+			return 0
+		}
+		return math.Copysign(1, v)
+	}
+	for _, test := range tests {
+		p, o := NewParticle(100, 0.5, 0.5, 400, 400), NewParticle(100, 0.5, 0.5, 420, 400)
+		resetEngine(p, o)
+		Engine.EnableGravity, Engine.EnableCloseCharge, Engine.EnableFarCharge = test.gravity, test.close, test.far
+		g, c, f := ForcesOn(p)
+		if sign(g[0]) != test.wantGravity || sign(c[0]) != test.wantClose || sign(f[0]) != test.wantFar ||
+			g[1] != 0 || c[1] != 0 || f[1] != 0 {
+			t.Errorf("%s: got gravity %v, close charge %v, far charge %v, want x signs %g, %g, %g", test.name, g, c,
+				f, test.wantGravity, test.wantClose, test.wantFar)
+		}
+		if p.Velocity().Magnitude() != 0 || p.bouncing || p.merging {
+			t.Errorf("%s: particle changed: %v", test.name, p)
+		}
+	}
+}
+
+// TestMergedColors checks that the colors of merged particles are derived from their (clamped) charges, under each
+// charge merge rule and color scheme.
+func TestMergedColors(t *testing.T) {
+	for _, rule := range []ChargeMergeRule{ChargeMergeAverage, ChargeMergeSum, ChargeMergeMax} {
+		for scheme := range ColorSchemes {
+			a, b := NewParticle(100, 0.8, 0.9, 400, 400), NewParticle(10, -0.1, 0.6, 403, 400)
+			resetEngine(a, b)
+			Engine.ChargeMergeRule, Engine.ColorScheme = rule, ColorScheme(scheme)
+			UpdateParticles()
+			if len(Engine.Particles) != 1 {
+				t.Fatalf("rule %d, scheme %d: got %d particles, want 1", rule, scheme, len(Engine.Particles))
+			}
+			p := Engine.Particles[0]
+			if p.CloseCharge() < -1 || p.CloseCharge() > 1 || p.FarCharge() < 0 || p.FarCharge() > 1 {
+				t.Errorf("rule %d, scheme %d: got unclamped charges %g and %g", rule, scheme, p.CloseCharge(),
+					p.FarCharge())
+			}
+			want := p.Clone()
+			want.SetCloseCharge(p.CloseCharge())
+			want.SetFarCharge(p.FarCharge())
+			if p.R != want.R || p.G != want.G || p.B != want.B || p.A != want.A {
+				t.Errorf("rule %d, scheme %d: got color %d,%d,%d,%d, want %d,%d,%d,%d", rule, scheme, p.R, p.G, p.B,
+					p.A, want.R, want.G, want.B, want.A)
+			}
+		}
+	}
+}
+
+// TestRestitution checks that bounces between particles, and off walls, reverse the Engine.Restitution fraction of the
+// closing speed, and that bounces between particles conserve momentum.
+func TestRestitution(t *testing.T) {
+	for _, restitution := range []float64{1, 0.5, 0} {
+		resetEngine()
+		Engine.Restitution = restitution
+		p, o := NewParticle(100, 0, 0, 400, 400), NewParticle(50, 0, 0, 405, 400)
+		p.SetVelocity(vector.NewWithValues([]float64{2, 1}))
+		o.SetVelocity(vector.NewWithValues([]float64{-1, 0}))
+		v := vector.Subtract(p.Position(), o.Position())
+		collideParticles(p, o, v, v.Magnitude())
+		if got, want := o.Velocity()[0]-p.Velocity()[0], 3*restitution; !closeTo(got, want) {
+			t.Errorf("restitution %g: got separating speed %g, want %g", restitution, got, want)
+		}
+		if got := p.Mass()*p.Velocity()[0] + o.Mass()*o.Velocity()[0]; !closeTo(got, 150) {
+			t.Errorf("restitution %g: got momentum %g, want 150", restitution, got)
+		}
+		if p.Velocity()[1] != 1 || o.Velocity()[1] != 0 {
+			t.Errorf("restitution %g: got tangential velocities %g and %g, want 1 and 0", restitution,
+				p.Velocity()[1], o.Velocity()[1])
+		}
+
+		w := NewParticle(100, 0, 0, 0, 400)
+		w.SetVelocity(vector.NewWithValues([]float64{-2, 1}))
+		bounceOffWall(w, 0, false)
+		if got, want := w.Velocity()[0], 2*restitution; !closeTo(got, want) || w.Velocity()[1] != 1 {
+			t.Errorf("restitution %g: got wall bounce velocity %v, want [%g 1]", restitution, w.Velocity(), want)
+		}
+	}
+}
+
+// TestSuccessiveWallBounces checks that, with Engine.Restitution 0.5, the normal speed of a particle bouncing back and
+// forth between the walls halves on each bounce.
+func TestSuccessiveWallBounces(t *testing.T) {
+	p := NewParticle(100, 0, 0, 30, 400)
+	resetEngine(p)
+	Engine.Restitution = 0.5
+	Engine.EnvironmentWidth = 60
+	p.SetVelocity(vector.NewWithValues([]float64{8, 0}))
+
+	const wantBounces = 4
+	bounces := 0
+	for step := 0; step < 1000 && bounces < wantBounces; step++ {
+		before := p.Velocity()[0]
+		UpdateParticles()
+		after := p.Velocity()[0]
+		if after == before {
+			continue
+		}
+		bounces++
+		if !closeTo(after, -0.5*before) {
+			t.Errorf("bounce %d: got normal velocity %g after %g, want %g", bounces, after, before, -0.5*before)
+		}
+		if p.Velocity()[1] != 0 {
+			t.Errorf("bounce %d: got tangential velocity %g, want 0", bounces, p.Velocity()[1])
+		}
+	}
+	if bounces != wantBounces {
+		t.Errorf("got %d bounces, want %d", bounces, wantBounces)
+	}
+	if want := 8 / math.Pow(2, wantBounces); !closeTo(math.Abs(p.Velocity()[0]), want) {
+		t.Errorf("got final speed %g, want %g", math.Abs(p.Velocity()[0]), want)
+	}
+}
+
+// TestRun checks the statistics reported by running a fixed number of steps.
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		particles     func() []*Particle
+		steps         int
+		wantMerges    int
+		wantParticles int
+	}{
+		{"none", func() []*Particle { return nil }, 3, 0, 0},
+		{"no steps", func() []*Particle {
+			return []*Particle{NewParticle(100, 0.5, 0.5, 400, 400), NewParticle(10, -0.5, 0.5, 403, 400)}
+		}, 0, 0, 2},
+		{"merge", func() []*Particle {
+			return []*Particle{NewParticle(100, 0.5, 0.5, 400, 400), NewParticle(10, -0.5, 0.5, 403, 400)}
+		}, 5, 1, 1},
+	}
+	for _, test := range tests {
+		resetEngine(test.particles()...)
+		stats := Engine.Run(test.steps)
+		if stats.Steps != test.steps || stats.Merges != test.wantMerges || stats.Particles != test.wantParticles {
+			t.Errorf("%s: got %d steps, %d merges, and %d particles, want %d, %d, and %d", test.name, stats.Steps,
+				stats.Merges, stats.Particles, test.steps, test.wantMerges, test.wantParticles)
+		}
+		if stats.Summary.Particles != test.wantParticles {
+			t.Errorf("%s: got summary of %d particles, want %d", test.name, stats.Summary.Particles,
+				test.wantParticles)
+		}
+	}
+}
+
+// TestCaptureSpeed checks that colliding particles which could merge bounce instead if approaching (along the line of
+// centers) faster than Engine.CaptureSpeed.
+func TestCaptureSpeed(t *testing.T) {
+	tests := []struct {
+		name         string
+		captureSpeed float64
+		velocity     []float64
+		wantMerge    bool
+	}{
+		{"head-on fast", 3, []float64{-5, 0}, false},
+		{"glancing fast", 3, []float64{-4, 6}, false},
+		{"slow", 3, []float64{-2, 0}, true},
+		{"fast tangential", 3, []float64{-1, 8}, true},
+		{"no limit", 0, []float64{-50, 0}, true},
+	}
+	for _, test := range tests {
+		resetEngine()
+		Engine.CaptureSpeed = test.captureSpeed
+		p, o := NewParticle(100, 0.5, 0.5, 400, 400), NewParticle(10, -0.5, 0.5, 403, 400)
+		o.SetVelocity(vector.NewWithValues(test.velocity))
+		v := vector.Subtract(p.Position(), o.Position())
+		collideParticles(p, o, v, v.Magnitude())
+		if p.merging != test.wantMerge || p.bouncing == test.wantMerge {
+			t.Errorf("%s: got merging %v and bouncing %v, want merging %v", test.name, p.merging, p.bouncing,
+				test.wantMerge)
+		}
+	}
+}
+
+// TestMergeOrder checks that particles' merging partners are in ID order, so that merging the same particles gives
+// exactly the same result whatever order they're in.
+func TestMergeOrder(t *testing.T) {
+	orders := [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}}
+	var want *Particle
+	for _, order := range orders {
+		// The particles have the same IDs (and values) each time
+		particles := []*Particle{newParticle(1001, 300, 0.5, 0.5, 400, 400),
+			newParticle(1002, 10, -0.5, 0.3, 403, 401), newParticle(1003, 10, -0.5, 0.7, 398, 397),
+			newParticle(1004, 10, -0.5, 0.1, 401, 396)}
+		particles[1].SetVelocity(vector.NewWithValues([]float64{0.1, 0.7}))
+		particles[2].SetVelocity(vector.NewWithValues([]float64{-0.3, 0.2}))
+		particles[3].SetVelocity(vector.NewWithValues([]float64{0.9, -0.4}))
+		ordered := make([]*Particle, len(particles))
+		for i, j := range order {
+			ordered[i] = particles[j]
+		}
+		resetEngine(ordered...)
+		UpdateParticles()
+		if len(Engine.Particles) != 1 {
+			t.Fatalf("order %v: got %d particles, want 1", order, len(Engine.Particles))
+		}
+		p := Engine.Particles[0]
+		// The first parent is the largest particle, which the others (its partners) merge into
+		if parents := p.ParentIDs(); len(parents) != 4 || !sort.IntsAreSorted(parents[1:]) {
+			t.Errorf("order %v: got parents %v, want partners in ID order", order, parents)
+		}
+		if want == nil {
+			want = p
+		} else if p.Mass() != want.Mass() || p.CloseCharge() != want.CloseCharge() ||
+			p.FarCharge() != want.FarCharge() || p.Position()[0] != want.Position()[0] ||
+			p.Position()[1] != want.Position()[1] || p.Velocity()[0] != want.Velocity()[0] ||
+			p.Velocity()[1] != want.Velocity()[1] {
+			t.Errorf("order %v: got %v, want %v", order, p, want)
+		}
+	}
+}
+
+// TestEdges checks that a particle crossing an edge is bounced, wrapped, removed, or let through, according to the
+// edge's mode.
+func TestEdges(t *testing.T) {
+	tests := []struct {
+		mode        EdgeMode
+		wantRemoved bool
+		// wantX is the particle's x coordinate after crossing the edge, if it isn't removed
+		wantX float64
+	}{
+		{EdgeBounce, false, 3},
+		{EdgeWrap, false, 792},
+		{EdgeOpen, true, 0},
+		{EdgeDefault, false, -8},
+	}
+	for _, test := range tests {
+		p := NewParticle(100, 0, 0, 2, 400)
+		p.SetVelocity(vector.NewWithValues([]float64{-10, 0}))
+		resetEngine(p)
+		Engine.WallBounce = false
+		Engine.LeftEdge = test.mode
+		UpdateParticles()
+		if removed := len(Engine.Particles) == 0; removed != test.wantRemoved {
+			t.Errorf("mode %d: got removed %v, want %v", test.mode, removed, test.wantRemoved)
+		} else if !removed && p.Position()[0] != test.wantX {
+			t.Errorf("mode %d: got x %g, want %g", test.mode, p.Position()[0], test.wantX)
+		}
+	}
+}
+
+// TestGravitySign checks that positive gravity attracts particles toward each other and negative gravity repels them.
+func TestGravitySign(t *testing.T) {
+	tests := []struct {
+		strength float64
+		want     float64
+	}{
+		{15, 1},
+		{-15, -1},
+		{0, 0},
+	}
+	for _, test := range tests {
+		p, o := NewParticle(100, 0, 0, 400, 400), NewParticle(100, 0, 0, 420, 400)
+		resetEngine(p, o)
+		Engine.GravityOnly = true
+		Engine.GravityStrength = test.strength
+		// o is to the right of p, so attraction is positive
+		g, _, _ := ForcesOn(p)
+		got := 0.0
+		if g[0] > 0 {
+			got = 1
+		} else if g[0] < 0 {
+			got = -1
+		}
+		if got != test.want {
+			t.Errorf("strength %g: got gravity %v, want x sign %g", test.strength, g, test.want)
+		}
+	}
+}
+
+// TestFarChargeCutoff checks that particles further apart than Engine.FarChargeCutoff don't exert far charge forces
+// on each other.
+func TestFarChargeCutoff(t *testing.T) {
+	tests := []struct {
+		cutoff    float64
+		wantForce bool
+	}{
+		{0, true},
+		{30, true},
+		{20, true},
+		{10, false},
+	}
+	for _, test := range tests {
+		p, o := NewParticle(100, 0, 0.5, 400, 400), NewParticle(100, 0, 0.5, 420, 400)
+		resetEngine(p, o)
+		Engine.FarChargeCutoff = test.cutoff
+		if _, _, f := ForcesOn(p); (f[0] > 0) != test.wantForce {
+			t.Errorf("cutoff %g: got far charge %v, want force %v", test.cutoff, f, test.wantForce)
+		}
+	}
+}
+
+// TestMergedCharges checks the charges merging particles are combined into under each charge merge rule.
+func TestMergedCharges(t *testing.T) {
+	tests := []struct {
+		rule               ChargeMergeRule
+		wantClose, wantFar float64
+	}{
+		// The mass weighted average
+		{ChargeMergeAverage, (300*0.5 + 100*-0.5) / 400, (300*0.2 + 100*0.6) / 400},
+		// Unclamped; NewParticle clamps them when the merged particle is created
+		{ChargeMergeSum, 0, 0.8},
+		{ChargeMergeMax, 0.5, 0.6},
+	}
+	for _, test := range tests {
+		resetEngine()
+		Engine.ChargeMergeRule = test.rule
+		parents := []*Particle{NewParticle(300, 0.5, 0.2, 0, 0), NewParticle(100, -0.5, 0.6, 0, 0)}
+		if c, f := mergedCharges(parents, 400); !closeTo(c, test.wantClose) || !closeTo(f, test.wantFar) {
+			t.Errorf("rule %d: got charges %g and %g, want %g and %g", test.rule, c, f, test.wantClose, test.wantFar)
+		}
+	}
+}
+
+// TestMassOrder checks that each update sorts the particles by decreasing mass, with equal masses in ID order, whatever
+// order they were in.
+func TestMassOrder(t *testing.T) {
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 4, 0, 3, 1}} {
+		particles := []*Particle{newParticle(2001, 50, 0, 0, 100, 100), newParticle(2002, 20, 0, 0, 200, 100),
+			newParticle(2003, 20, 0, 0, 300, 100), newParticle(2004, 20, 0, 0, 400, 100),
+			newParticle(2005, 10, 0, 0, 500, 100)}
+		ordered := make([]*Particle, len(particles))
+		for i, j := range order {
+			ordered[i] = particles[j]
+		}
+		resetEngine(ordered...)
+		Engine.EnableGravity, Engine.EnableFarCharge = false, false
+		UpdateParticles()
+		checkParticles(t, fmt.Sprintf("order %v", order), Engine.Particles, particles, []int{0, 1, 2, 3, 4})
+	}
+}
+
+// TestAdaptiveSubsteps checks that the steps are divided for the fastest closing pair of particles (compared against
+// checking every pair), and not at all if disabled.
+func TestAdaptiveSubsteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		speed   float64
+		limit   int
+	}{
+		{"disabled", false, 8, 16},
+		{"slow", true, 0.1, 16},
+		{"fast", true, 8, 16},
+		{"limited", true, 8, 2},
+		// Fast enough that some particles' collision candidates are every particle
+		{"very fast", true, 20, 64},
+	}
+	for _, test := range tests {
+		particles := randomParticles(400, 4)
+		for _, p := range particles {
+			p.Velocity().Scale(test.speed)
+		}
+		resetEngine(particles...)
+		Engine.AdaptiveSubstep, Engine.MaxAdaptiveSubsteps = test.enabled, test.limit
+		want := 1
+		if test.enabled {
+			for i, p := range Engine.Particles {
+				for _, o := range Engine.Particles[i+1:] {
+					if n := pairSubsteps(p, o, Engine.TimeStep); n > want {
+						want = n
+					}
+				}
+			}
+			if want > test.limit {
+				want = test.limit
+			}
+		}
+		if got := adaptiveSubsteps(Engine.TimeStep); got != want {
+			t.Errorf("%s: got %d sub-steps, want %d", test.name, got, want)
+		}
+	}
+}
+
+// TestNoTunnelling checks that fast particles approaching each other head-on collide (merging or bouncing), rather than
+// passing through each other, with and without adaptive sub-stepping.
+func TestNoTunnelling(t *testing.T) {
+	tests := []struct {
+		name      string
+		adaptive  bool
+		massB     float64
+		wantMerge bool
+	}{
+		{"bounce", false, 100, false},
+		{"bounce adaptive", true, 100, false},
+		{"merge", false, 10, true},
+		{"merge adaptive", true, 10, true},
+	}
+	for _, test := range tests {
+		a, b := NewParticle(100, 0.5, 0.5, 380, 400), NewParticle(test.massB, -0.5, 0.5, 420, 400)
+		a.SetVelocity(vector.NewWithValues([]float64{60, 0}))
+		b.SetVelocity(vector.NewWithValues([]float64{-60, 0}))
+		resetEngine(a, b)
+		Engine.AdaptiveSubstep = test.adaptive
+		Engine.EnableGravity, Engine.EnableCloseCharge, Engine.EnableFarCharge = false, false, false
+		if test.adaptive && adaptiveSubsteps(Engine.TimeStep) < 2 {
+			t.Errorf("%s: the step isn't divided", test.name)
+		}
+		UpdateParticles()
+		if test.wantMerge {
+			if len(Engine.Particles) != 1 {
+				t.Errorf("%s: got %d particles, want them merged", test.name, len(Engine.Particles))
+			}
+			continue
+		}
+		if len(Engine.Particles) != 2 || a.Position()[0] >= b.Position()[0] || a.Velocity()[0] >= 0 ||
+			b.Velocity()[0] <= 0 {
+			t.Errorf("%s: got %v and %v, want them bounced apart", test.name, a, b)
+		}
+	}
+}
+
+// TestLimitAcceleration checks that the summed acceleration is scaled down to Engine.MaxAcceleration (per time step,
+// with the SemiImplicitEuler integrator), keeping its direction.
+func TestLimitAcceleration(t *testing.T) {
+	tests := []struct {
+		max        float64
+		integrator Integrator
+		dt         float64
+		want       []float64
+	}{
+		{0, SemiImplicitEuler, 1, []float64{6, 8}},
+		{20, SemiImplicitEuler, 1, []float64{6, 8}},
+		{5, SemiImplicitEuler, 1, []float64{3, 4}},
+		{5, SemiImplicitEuler, 0.5, []float64{1.5, 2}},
+		{5, VelocityVerlet, 0.5, []float64{3, 4}},
+	}
+	for _, test := range tests {
+		resetEngine()
+		Engine.MaxAcceleration, Engine.Integrator = test.max, test.integrator
+		a, b := vector.NewWithValues([]float64{6, 0}), vector.NewWithValues([]float64{0, 8})
+		limitAcceleration(test.dt, a, b)
+		if sum := vector.Add(a, b); !closeTo(sum[0], test.want[0]) || !closeTo(sum[1], test.want[1]) {
+			t.Errorf("max %g, integrator %d, dt %g: got %v, want %v", test.max, test.integrator, test.dt, sum,
+				test.want)
+		}
+	}
+}
+
+// TestAccelerationCap checks that the velocities of a near-coincident, highly charged pair of particles (just out of
+// contact, so they repel rather than colliding) change by no more than Engine.MaxAcceleration per unit time each step.
+func TestAccelerationCap(t *testing.T) {
+	for _, integrator := range []Integrator{SemiImplicitEuler, VelocityVerlet} {
+		p, o := NewParticle(100, 1, 0, 400, 400), NewParticle(100, 1, 0, 406.5, 400)
+		resetEngine(p, o)
+		Engine.Integrator = integrator
+		Engine.TimeStep = 0.5
+		Engine.MaxAcceleration = 0.2
+		limit := Engine.MaxAcceleration * Engine.TimeStep
+		// Without the cap, the close charge repulsion would change the velocities thousands of times more
+		if _, c, _ := ForcesOn(p); c.Magnitude() < 1000*Engine.MaxAcceleration {
+			t.Fatalf("integrator %d: got close charge acceleration %g, want a much larger one", integrator,
+				c.Magnitude())
+		}
+		for step := 1; step <= 5; step++ {
+			before := []vector.Vector{p.Velocity().Clone(), o.Velocity().Clone()}
+			UpdateParticles()
+			for i, q := range []*Particle{p, o} {
+				if change := vector.Subtract(q.Velocity(), before[i]).Magnitude(); change > limit*(1+1e-9) {
+					t.Errorf("integrator %d, step %d: got velocity change %g, want at most %g", integrator, step,
+						change, limit)
+				}
+			}
+		}
+		if len(Engine.Particles) != 2 || p.Velocity()[0] >= 0 || o.Velocity()[0] <= 0 {
+			t.Errorf("integrator %d: got velocities %v and %v, want the particles repelled", integrator,
+				p.Velocity(), o.Velocity())
+		}
+	}
+}
+
+// TestFrameAcceleration checks the centrifugal and Coriolis accelerations of the rotating frame.
+func TestFrameAcceleration(t *testing.T) {
+	tests := []struct {
+		name     string
+		w        float64
+		position []float64
+		velocity []float64
+		want     []float64
+	}{
+		{"none", 0, []float64{500, 400}, []float64{1, 0}, []float64{0, 0}},
+		{"centrifugal", 0.1, []float64{500, 400}, []float64{0, 0}, []float64{1, 0}},
+		{"center", 0.1, []float64{400, 400}, []float64{0, 0}, []float64{0, 0}},
+		{"coriolis", 0.1, []float64{400, 400}, []float64{1, 0}, []float64{0, -0.2}},
+		{"both", -0.1, []float64{400, 300}, []float64{0, 2}, []float64{-0.4, -1}},
+	}
+	for _, test := range tests {
+		resetEngine()
+		Engine.FrameAngularVelocity = test.w
+		p := NewParticle(10, 0, 0, test.position[0], test.position[1])
+		p.SetVelocity(vector.NewWithValues(test.velocity))
+		if got := frameAcceleration(p, 1); !closeTo(got[0], test.want[0]) || !closeTo(got[1], test.want[1]) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestRotatingFrameOrbit checks that, in the rotating frame, a lone particle at rest in the (inertial) environment
+// follows a circular path about the center, at the frame's angular velocity in the opposite direction.
+func TestRotatingFrameOrbit(t *testing.T) {
+	const w, radius = 0.01, 100.0
+	for _, integrator := range []Integrator{SemiImplicitEuler, VelocityVerlet} {
+		p := NewParticle(10, 0, 0, 400+radius, 400)
+		resetEngine(p)
+		Engine.Integrator = integrator
+		Engine.FrameAngularVelocity = w
+		// At rest in the environment, so moving at -w x r in the rotating frame
+		p.SetVelocity(vector.NewWithValues([]float64{0, -w * radius}))
+		// A quarter turn, checked every 10 steps
+		for step := 1; step <= 157; step++ {
+			UpdateParticles()
+			if step%10 != 0 && step != 157 {
+				continue
+			}
+			x, y := p.Position()[0]-400, p.Position()[1]-400
+			if r := math.Hypot(x, y); math.Abs(r-radius) > 0.01*radius {
+				t.Errorf("integrator %d, step %d: got distance %g from the center, want %g", integrator, step, r,
+					radius)
+			}
+			if angle, want := math.Atan2(y, x), -w*float64(step); math.Abs(angle-want) > 0.05 {
+				t.Errorf("integrator %d, step %d: got angle %g, want %g", integrator, step, angle, want)
+			}
+		}
+	}
+}
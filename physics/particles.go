@@ -6,6 +6,7 @@ import (
 	"math"
 	"regexp"
 	"strings"
+	"sync/atomic"
 
 	"github.com/atedja/go-vector"
 )
@@ -26,8 +27,11 @@ import (
 // The main struct then needs to implement the json.Marshaler and json.Unmarshaler interfaces by simply returning the
 // results of json.Marshal/Unmarshal on this struct.
 type particleData struct {
+	// ID is the particle's identifier, unique among the particles created (or loaded) while the app runs (see
+	// NewParticle). It is 0 if loaded from a file saved before IDs were added, in which case a new ID is assigned.
+	ID int `json:"id"`
 	// Gravity is inversely proportional to distance^2.
-	// It is always positive and therefore attractive.
+	// It is always positive and therefore attractive (unless Engine.GravityStrength is negative).
 	// Masses add. Radius is proxy.
 	Mass float64 `json:"mass"`
 	// closeCharge is inversely proportional to distance^3.
@@ -36,19 +40,31 @@ type particleData struct {
 	// with charge min/max +/- 1.
 	CloseCharge float64 `json:"close_charge"`
 	// farCharge is *proportional* to distance.
-	// It is always positive and therefore attractive.
-	// Charges average. Alpha is proxy with charge range  0-1.
-	FarCharge float64       `json:"far_charge"`
-	Position  vector.Vector `json:"position"`
-	Velocity  vector.Vector `json:"velocity"`
-
-	// trackHistory indicates whether the previous position should be stored in positionHistory
+	// It is always positive and therefore attractive (unless Engine.SignedFarCharge is set, allowing negative charges,
+	// which repel positive ones).
+	// Charges average. Alpha is proxy (of its magnitude) with charge range  0-1 (or -1 to 1).
+	FarCharge float64 `json:"far_charge"`
+	// Species is the particle's species, which (if Engine.SpeciesMatrix is set) scales the close charge forces between
+	// it and other particles. It is 0 (the first species) if loaded from a file saved before species were added.
+	Species  int           `json:"species"`
+	Position vector.Vector `json:"position"`
+	Velocity vector.Vector `json:"velocity"`
+	// Fixed indicates whether the particle is pinned in place: it exerts forces on other particles, but doesn't move
+	// (its velocity remains zero).
+	Fixed bool `json:"fixed"`
+
+	// TrackHistory indicates whether the previous position should be stored in PositionHistory
 	// during Particle.UpdatePosition.
-	trackHistory bool
-	// historySize is the FIFO length of positionHistory
-	historySize int
-	// positionHistory is the slice of previous positions of the Particle.
-	positionHistory []vector.Vector
+	TrackHistory bool `json:"track_history"`
+	// HistorySize is the FIFO length of PositionHistory. It is 0 if loaded from a file saved before position history
+	// was serialized.
+	HistorySize int `json:"history_size"`
+	// HistoryStride is the number of position updates between the positions stored in PositionHistory (so trails of
+	// the same HistorySize span more time). It is 0 if loaded from a file saved before trail sampling was added, which
+	// is treated as 1 (every position is stored).
+	HistoryStride int `json:"history_stride"`
+	// PositionHistory is the slice of previous positions of the Particle.
+	PositionHistory []vector.Vector `json:"position_history"`
 }
 
 // The Particle struct holds particle data. It is composed of the particleData struct which holds the more fundamental
@@ -60,10 +76,10 @@ type Particle struct {
 	particleData particleData
 	// Radius is a proxy for mass. Updated with SetMass.
 	Radius int
-	// R (red) & G (green) are proxies for closeCharge. Updated with SetCloseCharge.
-	R, G uint8
-	// A (alpha) is proxy for farCharge (min 64). Updated with SetFarCharge.
-	A uint8
+	// R (red), G (green), B (blue), and A (alpha) are the display color, calculated using the Engine.ColorScheme (see
+	// ColorSchemes). By default, R & G are proxies for closeCharge and A is proxy for farCharge (min 48). Updated with
+	// SetCloseCharge and SetFarCharge (and, as some schemes depend on velocity, with each UpdateParticles).
+	R, G, B, A uint8
 
 	// merging indicates whether the particle is currently merging with one or more other particle(s)
 	merging bool
@@ -72,17 +88,53 @@ type Particle struct {
 	// whether a particle is in the list.
 	MergingWith map[*Particle]struct{}
 	// bouncing indicates whether the particle is currently bouncing against another (application of forces is suspended
-	// until the bounce completes, as determined by EngineData.bounceCompleteDistFactor).
+	// until the bounce completes, as determined by EngineData.BounceCompleteDistFactor).
 	bouncing bool
 	// bouncingAgainst is the particle which this particle is currently bouncing against (if any / if bouncing is true).
 	bouncingAgainst *Particle
+	// parentIDs holds the IDs of the particles which merged to form this particle, if any.
+	parentIDs []int
+	// historySteps counts the position updates since a position was last stored in the PositionHistory (see
+	// HistoryStride).
+	historySteps int
+
+	// acceleration is the (summed force) acceleration most recently calculated for the particle. It is only stored
+	// when the Engine.Integrator applies accelerations separately from calculating them (Euler, VelocityVerlet).
+	acceleration vector.Vector
+	// previousAcceleration is the acceleration from the previous step, retained by the VelocityVerlet integrator.
+	previousAcceleration vector.Vector
 }
 
 //region Creation & Initialization
 
-// NewParticle is a factory for creating a new, basic Particle (without a velocity, history info, etc.).
+// lastParticleID is the most recently assigned particle ID (see nextParticleID). It is accessed atomically.
+var lastParticleID int64
+
+// nextParticleID gets a new (not yet assigned) particle ID.
+func nextParticleID() int {
+	return int(atomic.AddInt64(&lastParticleID, 1))
+}
+
+// reserveParticleID ensures particle ID id (e.g. loaded from file) isn't assigned to any particles created later.
+func reserveParticleID(id int) {
+	for {
+		last := atomic.LoadInt64(&lastParticleID)
+		if int64(id) <= last || atomic.CompareAndSwapInt64(&lastParticleID, last, int64(id)) {
+			return
+		}
+	}
+}
+
+// NewParticle is a factory for creating a new, basic Particle (without a velocity, history info, etc.). The particle is
+// assigned the next ID.
 func NewParticle(mass, closeCharge, farCharge, x, y float64) *Particle {
+	return newParticle(nextParticleID(), mass, closeCharge, farCharge, x, y)
+}
+
+// newParticle creates a new, basic Particle with the provided ID (see NewParticle).
+func newParticle(id int, mass, closeCharge, farCharge, x, y float64) *Particle {
 	p := &Particle{particleData: particleData{
+		ID:       id,
 		Position: vector.NewWithValues([]float64{x, y}),
 		Velocity: vector.New(2)}}
 
@@ -91,31 +143,45 @@ func NewParticle(mass, closeCharge, farCharge, x, y float64) *Particle {
 	return p
 }
 
-// Clone creates a copy of Particle p.
+// Clone creates a copy of Particle p (with the same ID).
 func (p *Particle) Clone() *Particle {
-	// NewParticle is used to ensure the copy is properly created and initialized (and so that non-exported values,
+	// newParticle is used to ensure the copy is properly created and initialized (and so that non-exported values,
 	// such as Radius, are copied).
-	c := NewParticle(p.Mass(), p.CloseCharge(), p.FarCharge(), p.Position()[0], p.Position()[1])
-	// Velocity is not set by NewParticle, so we set it here to complete the copy.
+	c := newParticle(p.ID(), p.Mass(), p.CloseCharge(), p.FarCharge(), p.Position()[0], p.Position()[1])
+	// Velocity, Fixed, and Species are not set by newParticle, so we set them here to complete the copy (and update the
+	// color, in case the color scheme depends on velocity or species).
 	c.SetVelocity(p.Velocity())
+	c.SetFixed(p.Fixed())
+	c.particleData.Species = p.Species()
+	c.updateColor()
 	return c
 }
 
-// initialize is used to initialize a particle; see initializeWithValues.
+// initialize is used to initialize a particle; see initializeWithValues. Particles without an ID (e.g. loaded from a
+// file saved before IDs were added) are assigned one, and the IDs of other particles are reserved.
 func (p *Particle) initialize() {
+	if p.particleData.ID == 0 {
+		p.particleData.ID = nextParticleID()
+	} else {
+		reserveParticleID(p.particleData.ID)
+	}
 	// Assumes the particle already has properties set (but needs proxies set) - e.g. because created by deserialization
 	p.initializeWithValues(p.Mass(), p.CloseCharge(), p.FarCharge())
 }
 
 // initializeWithValues is used to initialize particles, that is to calculate proxy values (e.g. radius)
-// and make the (empty) particleData.positionHistory and MergingWith "lists"
+// and make the (empty) particleData.PositionHistory (unless it already exists, e.g. because restored from file) and
+// MergingWith "lists"
 func (p *Particle) initializeWithValues(mass, closeCharge, farCharge float64) {
-	// Use setters so the proxies get initialized
+	// Use setters so the proxies get initialized. The setters clamp the charges before the colors are derived from them;
+	// the colors depend on both charges, so they're final once SetFarCharge (the last to be set) recalculates them.
 	p.SetMass(mass)
 	p.SetCloseCharge(closeCharge)
 	p.SetFarCharge(farCharge)
 
-	p.particleData.positionHistory = make([]vector.Vector, 0, 0)
+	if p.particleData.PositionHistory == nil {
+		p.particleData.PositionHistory = make([]vector.Vector, 0, 0)
+	}
 
 	p.MergingWith = make(map[*Particle]struct{})
 }
@@ -137,8 +203,8 @@ func (p *Particle) UnmarshalJSON(b []byte) error {
 // String gets a string representation of the Particle, which is more verbose / plain English than string(particle) but
 // does not include every field.
 func (p *Particle) String() string {
-	return fmt.Sprintf("{Position: %v; Velocity: %v, Mass: %f, Close Charge: %f, Far Charge: %f}",
-		p.Position(), p.Velocity(), p.Mass(), p.CloseCharge(), p.FarCharge())
+	return fmt.Sprintf("{ID: %d; Position: %v; Velocity: %v, Mass: %f, Close Charge: %f, Far Charge: %f}",
+		p.ID(), p.Position(), p.Velocity(), p.Mass(), p.CloseCharge(), p.FarCharge())
 }
 
 // ShortString gets a compact string representation of the most relevant Particle fields, without any labels and with
@@ -152,6 +218,20 @@ func (p *Particle) ShortString() string {
 
 //endregion Serialization and Stringification
 
+//region ID
+
+// ID gets the ID.
+func (p *Particle) ID() int {
+	return p.particleData.ID
+}
+
+// ParentIDs gets the IDs of the particles which merged to form this particle (nil if it wasn't formed by a merger).
+func (p *Particle) ParentIDs() []int {
+	return p.parentIDs
+}
+
+//endregion ID
+
 //region Mass (gravity)
 
 // Mass gets the mass.
@@ -175,22 +255,12 @@ func (p *Particle) CloseCharge() float64 {
 	return p.particleData.CloseCharge
 }
 
-// SetCloseCharge sets the closeCharge and updates the proxies R (red) & G (green).
+// SetCloseCharge sets the closeCharge and updates the display color proxies (see updateColor).
 func (p *Particle) SetCloseCharge(closeCharge float64) {
 	closeCharge = math.Max(-1, math.Min(closeCharge, 1))
 	p.particleData.CloseCharge = closeCharge
 
-	// Negative charge is red, the closer to -1 the more red (0 is black)
-	if closeCharge < 0 {
-		// We don't want to round here, it's expensive and unnecessary
-		p.R = uint8(255.0 * math.Abs(closeCharge))
-		p.G = 0
-		// Positive charge is green, the closer to 1 the more green (0 is black)
-	} else {
-		p.R = 0
-		// We don't want to round here, it's expensive and unnecessary
-		p.G = uint8(255.0 * math.Abs(closeCharge))
-	}
+	p.updateColor()
 }
 
 //endregion CloseCharge
@@ -202,17 +272,40 @@ func (p *Particle) FarCharge() float64 {
 	return p.particleData.FarCharge
 }
 
-// SetFarCharge sets the farCharge.
+// SetFarCharge sets the farCharge, clamped to 0 to 1 (or -1 to 1 if Engine.SignedFarCharge is set), and updates the
+// display color proxies (see updateColor).
 func (p *Particle) SetFarCharge(farCharge float64) {
-	farCharge = math.Max(0, math.Min(farCharge, 1))
+	farCharge = math.Max(minFarCharge(), math.Min(farCharge, 1))
 	p.particleData.FarCharge = farCharge
 
-	// Alpha range 64 - 255 (we don't want 0 charge to be fully transparent, we want to always be able to see particles)
-	p.A = uint8(207*math.Abs(farCharge)) + 48
+	p.updateColor()
+}
+
+// minFarCharge gets the smallest far charge allowed (see Engine.SignedFarCharge).
+func minFarCharge() float64 {
+	if Engine.SignedFarCharge {
+		return -1
+	}
+	return 0
 }
 
 //endregion FarCharge
 
+//region Species
+
+// Species gets the Species
+func (p *Particle) Species() int {
+	return p.particleData.Species
+}
+
+// SetSpecies sets the Species and updates the color proxies (in case the color scheme depends on species).
+func (p *Particle) SetSpecies(species int) {
+	p.particleData.Species = species
+	p.updateColor()
+}
+
+//endregion Species
+
 //region Position
 
 // Position gets the Position
@@ -227,14 +320,24 @@ func (p *Particle) SetPosition(position vector.Vector) {
 
 // UpdatePosition adds the velocity to the current position
 func (p *Particle) UpdatePosition() {
-	if p.particleData.trackHistory {
-		p.particleData.positionHistory = append(p.particleData.positionHistory, p.Position())
-		// If longer than historySize, truncate it (remove from end since it's FIFO)
-		if len(p.particleData.positionHistory) > p.particleData.historySize {
-			p.particleData.positionHistory = p.particleData.positionHistory[1:]
+	p.movePosition(p.Velocity())
+}
+
+// movePosition adds the displacement d to the current position (storing the previous position in the history if
+// enabled).
+func (p *Particle) movePosition(d vector.Vector) {
+	if p.particleData.TrackHistory {
+		// Only every HistoryStride-th position is stored
+		if p.historySteps == 0 {
+			p.particleData.PositionHistory = append(p.particleData.PositionHistory, p.Position())
+			// If longer than HistorySize, truncate it (remove from end since it's FIFO)
+			if len(p.particleData.PositionHistory) > p.particleData.HistorySize {
+				p.particleData.PositionHistory = p.particleData.PositionHistory[1:]
+			}
 		}
+		p.historySteps = (p.historySteps + 1) % int(math.Max(float64(p.particleData.HistoryStride), 1))
 	}
-	p.SetPosition(vector.Add(p.Position(), p.Velocity()))
+	p.SetPosition(vector.Add(p.Position(), d))
 }
 
 //endregion Position
@@ -253,44 +356,117 @@ func (p *Particle) SetVelocity(velocity vector.Vector) {
 
 //endregion Velocity
 
-//region trackHistory
+//region Fixed
+
+// Fixed gets Fixed
+func (p *Particle) Fixed() bool {
+	return p.particleData.Fixed
+}
+
+// SetFixed sets Fixed. Fixed particles are stopped (their velocity set to zero).
+func (p *Particle) SetFixed(fixed bool) {
+	p.particleData.Fixed = fixed
+	if fixed {
+		p.SetVelocity(vector.New(2))
+		p.acceleration = nil
+		p.previousAcceleration = nil
+	}
+}
+
+//endregion Fixed
 
-// TrackHistory gets the trackHistory
+//region TrackHistory
+
+// TrackHistory gets the TrackHistory
 func (p *Particle) TrackHistory() bool {
-	return p.particleData.trackHistory
+	return p.particleData.TrackHistory
 }
 
-// SetTrackHistory sets the trackHistory
+// SetTrackHistory sets the TrackHistory
 func (p *Particle) SetTrackHistory(trackHistory bool) {
-	p.particleData.trackHistory = trackHistory
+	p.particleData.TrackHistory = trackHistory
 }
 
-//endregion trackHistory
+//endregion TrackHistory
 
-//region historySize
+//region HistorySize
 
-// HistorySize gets the historySize
+// HistorySize gets the HistorySize
 func (p *Particle) HistorySize() int {
-	return p.particleData.historySize
+	return p.particleData.HistorySize
 }
 
-// SetHistorySize sets the historySize
+// SetHistorySize sets the HistorySize, immediately truncating the PositionHistory (dropping the oldest positions) if
+// it's longer than historySize, so the two are always consistent.
 func (p *Particle) SetHistorySize(historySize int) {
-	p.particleData.historySize = historySize
+	p.particleData.HistorySize = historySize
+	if excess := len(p.particleData.PositionHistory) - int(math.Max(float64(historySize), 0)); excess > 0 {
+		p.particleData.PositionHistory = p.particleData.PositionHistory[excess:]
+	}
+}
+
+// setHistory sets TrackHistory, HistorySize (truncating the PositionHistory; see SetHistorySize), and HistoryStride.
+// If tracking, an empty PositionHistory is pre-filled according to the Engine's history prefill (see
+// SetHistoryPrefill).
+func (p *Particle) setHistory(trackHistory bool, historySize, historyStride int) {
+	p.particleData.TrackHistory = trackHistory
+	p.SetHistorySize(historySize)
+	p.particleData.HistoryStride = historyStride
+	if trackHistory && len(p.particleData.PositionHistory) == 0 {
+		p.prefillHistory(Engine.historyPrefill)
+	}
+}
+
+// prefillHistory fills the (empty) PositionHistory with HistorySize positions, according to prefill. With
+// PrefillExtrapolate, the positions are stepped back from the current position by the distance the particle moves (at
+// its current velocity) between stored positions - HistoryStride steps of Engine.TimeStep / Engine.SubSteps - so the
+// trail continues smoothly once the particle moves.
+func (p *Particle) prefillHistory(prefill HistoryPrefill) {
+	if (prefill != PrefillRepeat && prefill != PrefillExtrapolate) || p.particleData.HistorySize <= 0 {
+		return
+	}
+	// The spacing of the stored positions, in steps of the velocity
+	spacing := 0.0
+	if prefill == PrefillExtrapolate {
+		spacing = math.Max(float64(p.particleData.HistoryStride), 1) * Engine.TimeStep /
+			math.Max(float64(Engine.SubSteps), 1)
+	}
+	pos, vel := p.Position(), p.Velocity()
+	history := make([]vector.Vector, p.particleData.HistorySize)
+	// The oldest position is first
+	for i := range history {
+		back := spacing * float64(len(history)-i)
+		history[i] = vector.NewWithValues([]float64{pos[0] - vel[0]*back, pos[1] - vel[1]*back})
+	}
+	p.particleData.PositionHistory = history
+}
+
+//endregion HistorySize
+
+//region HistoryStride
+
+// HistoryStride gets the HistoryStride
+func (p *Particle) HistoryStride() int {
+	return p.particleData.HistoryStride
+}
+
+// SetHistoryStride sets the HistoryStride
+func (p *Particle) SetHistoryStride(historyStride int) {
+	p.particleData.HistoryStride = historyStride
 }
 
-//endregion historySize
+//endregion HistoryStride
 
-//region positionHistory
+//region PositionHistory
 
-// PositionHistory gets the positionHistory
+// PositionHistory gets the PositionHistory
 func (p *Particle) PositionHistory() []vector.Vector {
-	return p.particleData.positionHistory
+	return p.particleData.PositionHistory
 }
 
-// SetPositionHistory sets the (entire) positionHistory
+// SetPositionHistory sets the (entire) PositionHistory
 func (p *Particle) SetPositionHistory(positionHistory []vector.Vector) {
-	p.particleData.positionHistory = positionHistory
+	p.particleData.PositionHistory = positionHistory
 }
 
-//endregion positionHistory
+//endregion PositionHistory
@@ -0,0 +1,40 @@
+package physics
+
+import (
+	"testing"
+
+	"github.com/atedja/go-vector"
+)
+
+// TestSetHistorySize checks that SetHistorySize truncates the position history to the new size, keeping the newest
+// positions.
+func TestSetHistorySize(t *testing.T) {
+	tests := []struct {
+		size int
+		want []float64
+	}{
+		{10, []float64{0, 1, 2, 3, 4}},
+		{5, []float64{0, 1, 2, 3, 4}},
+		{3, []float64{2, 3, 4}},
+		{0, []float64{}},
+		{-1, []float64{}},
+	}
+	for _, test := range tests {
+		p := NewParticle(10, 0, 0, 0, 0)
+		for i := 0; i < 5; i++ {
+			p.particleData.PositionHistory = append(p.PositionHistory(), vector.NewWithValues([]float64{float64(i), 0}))
+		}
+		p.SetHistorySize(test.size)
+		history := p.PositionHistory()
+		ok := len(history) == len(test.want)
+		for i := 0; ok && i < len(history); i++ {
+			ok = history[i][0] == test.want[i]
+		}
+		if !ok {
+			t.Errorf("size %d: got history %v, want x coordinates %v", test.size, history, test.want)
+		}
+		if p.HistorySize() != test.size {
+			t.Errorf("size %d: got HistorySize %d", test.size, p.HistorySize())
+		}
+	}
+}
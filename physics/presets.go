@@ -0,0 +1,115 @@
+package physics
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/atedja/go-vector"
+)
+
+// Preset describes a built-in scenario: a set of Engine settings and particles (see Presets).
+type Preset struct {
+	// Name is the display name of the preset.
+	Name string
+	// Setup sets the Engine fields the scenario depends on (they have just been reset to their defaults) and creates
+	// the scenario's particles.
+	Setup func() []*Particle
+}
+
+// Presets holds the built-in scenarios, in the order they are presented to the user. Additional presets may be
+// appended.
+var Presets = []Preset{
+	{Name: "Binary Orbit", Setup: binaryOrbitPreset},
+	{Name: "Gas Cloud", Setup: gasCloudPreset},
+	{Name: "Lattice", Setup: latticePreset},
+}
+
+// FindPreset gets the preset named name from Presets, or nil if there is no such preset.
+func FindPreset(name string) *Preset {
+	for i := range Presets {
+		if Presets[i].Name == name {
+			return &Presets[i]
+		}
+	}
+	return nil
+}
+
+// Load replaces the Engine settings and particles with those of the preset. The settings are first reset to their
+// defaults (see EngineData.Initialize), except the ColorScheme and Workers, which only affect how the simulation is
+// displayed and calculated. The particles are then replaced (see SetParticles).
+// It must only be called while the simulation is paused.
+func (p *Preset) Load() {
+	colorScheme, workers := Engine.ColorScheme, Engine.Workers
+	Engine.Initialize()
+	Engine.ColorScheme, Engine.Workers = colorScheme, workers
+
+	SetParticles(p.Setup())
+}
+
+// binaryOrbitPreset sets up two equal, gravity only particles in a circular orbit about their common center of mass.
+func binaryOrbitPreset() []*Particle {
+	Engine.CloseChargeStrength = 0
+	Engine.FarChargeStrength = 0
+	Engine.AllowMerge = false
+	// Conserves the orbit's energy much better than the Euler integrators
+	Engine.Integrator = VelocityVerlet
+
+	const mass, separation = 400.0, 200.0
+	cx, cy := float64(Engine.EnvironmentWidth)/2, float64(Engine.EnvironmentHeight)/2
+	// Each particle orbits the center at separation/2, so the gravity between them (G*m/separation^2) must equal the
+	// centripetal acceleration v^2/(separation/2)
+	speed := math.Sqrt(Engine.GravityStrength * mass / (2 * separation))
+
+	// The charges only affect the colors, as the charge strengths are 0
+	a := NewParticle(mass, 0.75, 1, cx-separation/2, cy)
+	a.SetVelocity(vector.NewWithValues([]float64{0, -speed}))
+	b := NewParticle(mass, -0.75, 1, cx+separation/2, cy)
+	b.SetVelocity(vector.NewWithValues([]float64{0, speed}))
+	return []*Particle{a, b}
+}
+
+// gasCloudPreset sets up a disc of many small, slowly and randomly moving particles in the center of the environment,
+// which collapses under its own gravity.
+func gasCloudPreset() []*Particle {
+	Engine.FarChargeStrength = 0
+
+	const n, radius = 400, 250.0
+	cx, cy := float64(Engine.EnvironmentWidth)/2, float64(Engine.EnvironmentHeight)/2
+	particles := make([]*Particle, n)
+	for i := range particles {
+		// Uniformly distributed within the disc (the square root keeps the density from peaking at the center)
+		r := radius * math.Sqrt(rand.Float64())
+		sin, cos := math.Sincos(2 * math.Pi * rand.Float64())
+		mass := math.Max(rand.NormFloat64()*15+60, 10)
+		p := NewParticle(mass, rand.Float64()*2-1, rand.Float64(), cx+r*cos, cy+r*sin)
+		p.SetVelocity(vector.NewWithValues([]float64{rand.NormFloat64() * 0.5, rand.NormFloat64() * 0.5}))
+		particles[i] = p
+	}
+	return particles
+}
+
+// latticePreset sets up a square grid of particles with alternating (checkerboard) close charges, filling an
+// environment which wraps around (so the grid has no edges). The particles are displaced very slightly from the grid
+// points, so the symmetry eventually breaks.
+func latticePreset() []*Particle {
+	Engine.FarChargeStrength = 0
+	Engine.WallBounce = false
+	Engine.WrapBoundary = true
+
+	const cells, mass, jitter = 16, 100.0, 0.5
+	spacingX := float64(Engine.EnvironmentWidth) / cells
+	spacingY := float64(Engine.EnvironmentHeight) / cells
+	particles := make([]*Particle, 0, cells*cells)
+	for i := 0; i < cells; i++ {
+		for j := 0; j < cells; j++ {
+			closeCharge := 0.5
+			if (i+j)%2 == 1 {
+				closeCharge = -0.5
+			}
+			x := (float64(i)+0.5)*spacingX + (rand.Float64()*2-1)*jitter
+			y := (float64(j)+0.5)*spacingY + (rand.Float64()*2-1)*jitter
+			particles = append(particles, NewParticle(mass, closeCharge, 1, x, y))
+		}
+	}
+	return particles
+}
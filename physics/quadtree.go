@@ -0,0 +1,289 @@
+package physics
+
+import (
+	"math"
+
+	"github.com/atedja/go-vector"
+)
+
+// quadTreeMaxDepth is the maximum depth of the quadtree. Nodes at this depth are not subdivided further and instead
+// hold all the particles inserted into them (so particles at the same, or nearly the same, position don't cause
+// endless subdivision).
+const quadTreeMaxDepth = 32
+
+// quadTree is a Barnes-Hut quadtree built over a set of particles. Each node stores aggregate information (total mass,
+// center of mass, summed charges, etc.) about the particles within it, so that the forces exerted by a distant group of
+// particles can be approximated as the forces from a single particle.
+// See https://en.wikipedia.org/wiki/Barnes%E2%80%93Hut_simulation.
+type quadTree struct {
+	// root is the node covering the bounding square of all the particles.
+	root *quadTreeNode
+}
+
+// quadTreeNode is a (square) region of a quadTree. Leaf nodes hold particles, other nodes hold their four quadrants
+// (children).
+type quadTreeNode struct {
+	// x and y are the top left corner of the region covered by the node.
+	x, y float64
+	// size is the width (and height) of the region covered by the node.
+	size float64
+
+	// divided indicates whether the node has been subdivided into children.
+	divided bool
+	// children are the four quadrants of a divided node (top left, top right, bottom left, bottom right). Children
+	// are created as needed, so some may be nil.
+	children [4]*quadTreeNode
+	// particles are the particles held by a leaf node (only more than one if quadTreeMaxDepth was reached).
+	particles []*Particle
+
+	// count is the number of particles within the node (including within its children).
+	count int
+	// mass is the total mass of the particles within the node.
+	mass float64
+	// massX and massY are the sums of the particle positions, weighted by mass (divide by mass for the center of
+	// mass).
+	massX, massY float64
+	// closeCharge is the sum of the close charges of the particles within the node.
+	closeCharge float64
+	// speciesCloseCharge is the sum of the close charges of the particles of each species (index) within the node,
+	// only tracked if Engine.SpeciesMatrix is set. Particles of species outside the matrix aren't included (see
+	// otherCloseCharge).
+	speciesCloseCharge []float64
+	// otherCloseCharge is the sum of the close charges of the particles within the node whose species are outside the
+	// Engine.SpeciesMatrix (only tracked if it is set).
+	otherCloseCharge float64
+	// farCharge is the sum of the far charges of the particles within the node.
+	farCharge float64
+	// farChargeX and farChargeY are the sums of the particle positions, weighted by far charge. Because far charge is
+	// proportional to distance, these allow the far charge forces from all the particles within the node to be
+	// calculated exactly.
+	farChargeX, farChargeY float64
+	// maxRadius is the largest Radius of the particles within the node.
+	maxRadius int
+	// maxSpeed is the largest speed (velocity magnitude) of the particles within the node.
+	maxSpeed float64
+}
+
+// newQuadTree builds a quadTree over the provided particles, with the root node covering the bounding square of all
+// their positions.
+func newQuadTree(particles []*Particle) *quadTree {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range particles {
+		minX = math.Min(minX, p.Position()[0])
+		minY = math.Min(minY, p.Position()[1])
+		maxX = math.Max(maxX, p.Position()[0])
+		maxY = math.Max(maxY, p.Position()[1])
+	}
+
+	// Make sure the root has a (positive) size even if all the particles are at the same position, and pad it slightly
+	// so particles on the max edges fall within it.
+	size := math.Max(math.Max(maxX-minX, maxY-minY), 1) + 1
+	t := &quadTree{root: &quadTreeNode{x: minX, y: minY, size: size}}
+	for _, p := range particles {
+		t.root.insert(p, 0)
+	}
+
+	return t
+}
+
+// insert adds Particle p to the node (and its aggregate information), subdividing the node as necessary.
+func (n *quadTreeNode) insert(p *Particle, depth int) {
+	n.count++
+	n.mass += p.Mass()
+	n.massX += p.Mass() * p.Position()[0]
+	n.massY += p.Mass() * p.Position()[1]
+	n.closeCharge += p.CloseCharge()
+	if Engine.SpeciesMatrix != nil {
+		if s := p.Species(); s >= 0 && s < len(Engine.SpeciesMatrix) {
+			if n.speciesCloseCharge == nil {
+				n.speciesCloseCharge = make([]float64, len(Engine.SpeciesMatrix))
+			}
+			n.speciesCloseCharge[s] += p.CloseCharge()
+		} else {
+			n.otherCloseCharge += p.CloseCharge()
+		}
+	}
+	n.farCharge += p.FarCharge()
+	n.farChargeX += p.FarCharge() * p.Position()[0]
+	n.farChargeY += p.FarCharge() * p.Position()[1]
+	if p.Radius > n.maxRadius {
+		n.maxRadius = p.Radius
+	}
+	n.maxSpeed = math.Max(n.maxSpeed, p.Velocity().Magnitude())
+
+	if !n.divided {
+		// Empty leaves (or leaves which can't be subdivided further) simply hold the particle
+		if len(n.particles) == 0 || depth >= quadTreeMaxDepth {
+			n.particles = append(n.particles, p)
+			return
+		}
+		// Otherwise, subdivide and move the existing particle(s) down into the children
+		n.divided = true
+		existing := n.particles
+		n.particles = nil
+		for _, e := range existing {
+			n.child(e).insert(e, depth+1)
+		}
+	}
+
+	n.child(p).insert(p, depth+1)
+}
+
+// child gets (creating it if necessary) the child node (quadrant) which Particle p falls within.
+func (n *quadTreeNode) child(p *Particle) *quadTreeNode {
+	half := n.size / 2
+	i := 0
+	x, y := n.x, n.y
+	if p.Position()[0] >= n.x+half {
+		i |= 1
+		x += half
+	}
+	if p.Position()[1] >= n.y+half {
+		i |= 2
+		y += half
+	}
+
+	if n.children[i] == nil {
+		n.children[i] = &quadTreeNode{x: x, y: y, size: half}
+	}
+	return n.children[i]
+}
+
+// accumulateForces adds the gravity, close charge, and far charge acceleration vectors the particles in the tree exert
+// on Particle p to g, c, and f (in place), and handles collisions with nearby particles (see interactParticles). dt
+// is the time step, and deferred is passed to interactParticles (for deferring collision handling when calculating in
+// parallel; nil otherwise).
+// Returns the number of particles for which forces were added (for averaging).
+func (t *quadTree) accumulateForces(p *Particle, dt float64, g, c, f vector.Vector, deferred *velocityUpdate) int {
+	return t.root.accumulateForces(p, dt, g, c, f, deferred)
+}
+
+// accumulateForces adds the acceleration vectors the particles within the node exert on Particle p (see
+// quadTree.accumulateForces). Leaf particles are compared with p exactly, and other nodes are either approximated (if
+// sufficiently distant, as determined by Engine.Theta) or opened and their children visited.
+func (n *quadTreeNode) accumulateForces(p *Particle, dt float64, g, c, f vector.Vector,
+	deferred *velocityUpdate) int {
+	if n == nil || n.count == 0 {
+		return 0
+	}
+
+	ct := 0
+	if !n.divided {
+		for _, o := range n.particles {
+			if interactParticles(p, o, dt, g, c, f, deferred, true) {
+				ct++
+			}
+		}
+		return ct
+	}
+
+	if n.canApproximate(p, dt) {
+		n.addApproximateForces(p, g, c, f)
+		return n.count
+	}
+
+	for _, child := range n.children {
+		ct += child.accumulateForces(p, dt, g, c, f, deferred)
+	}
+	return ct
+}
+
+// canApproximate determines whether the forces from the particles within the node can be approximated for Particle p.
+// Nodes which might contain p itself, or a particle p may be colliding with (including during the next step of time dt)
+// or bouncing against, are never approximated (so that collision handling remains exact).
+func (n *quadTreeNode) canApproximate(p *Particle, dt float64) bool {
+	px, py := p.Position()[0], p.Position()[1]
+
+	nearest, farthest := n.distanceRange(p)
+	if nearest <= math.Max(Engine.BounceCompleteDistFactor, 1)*float64(p.Radius+n.maxRadius)+
+		(p.Velocity().Magnitude()+n.maxSpeed)*math.Abs(dt) {
+		return false
+	}
+
+	// The far charge of the node's particles can only be summed if they're all within, or all beyond, the far charge
+	// cutoff (if set)
+	if beyondFarChargeCutoff(farthest) && !beyondFarChargeCutoff(nearest) {
+		return false
+	}
+
+	// The bounce state is only cleared when the particle p is bouncing against is compared exactly (see
+	// interactParticles), so don't approximate a node containing it.
+	if p.bouncing && p.bouncingAgainst != nil {
+		bx, by := p.bouncingAgainst.Position()[0], p.bouncingAgainst.Position()[1]
+		if bx >= n.x && bx <= n.x+n.size && by >= n.y && by <= n.y+n.size {
+			return false
+		}
+	}
+
+	// The Barnes-Hut criterion: the node is far enough away, relative to its size, to be treated as a single particle
+	d := math.Hypot(px-n.massX/n.mass, py-n.massY/n.mass)
+	return n.size/d < Engine.Theta
+}
+
+// distanceRange gets the distances from Particle p to the nearest point of the node's region (0 if p is within it)
+// and to the farthest point of it.
+func (n *quadTreeNode) distanceRange(p *Particle) (nearest, farthest float64) {
+	px, py := p.Position()[0], p.Position()[1]
+	dx := math.Max(math.Max(n.x-px, 0), px-(n.x+n.size))
+	dy := math.Max(math.Max(n.y-py, 0), py-(n.y+n.size))
+	fx := math.Max(math.Abs(px-n.x), math.Abs(px-(n.x+n.size)))
+	fy := math.Max(math.Abs(py-n.y), math.Abs(py-(n.y+n.size)))
+	return math.Hypot(dx, dy), math.Hypot(fx, fy)
+}
+
+// addApproximateForces adds the acceleration vectors the particles within the node exert on Particle p, treating them
+// as a single particle at their center of mass (see interactParticles for the individual force formulas).
+func (n *quadTreeNode) addApproximateForces(p *Particle, g, c, f vector.Vector) {
+	// Vector between p and the node's center of mass
+	v := vector.Subtract(p.Position(), vector.NewWithValues([]float64{n.massX / n.mass, n.massY / n.mass}))
+	soft := softenedDistance(v.Magnitude())
+	vc := v.Clone()
+
+	// Gravity acts on the total mass, at the center of mass
+	if Engine.EnableGravity {
+		v.Scale((Engine.GravityStrength * n.mass * -1) / math.Pow(soft, 3))
+		addInPlace(g, v)
+	}
+
+	if Engine.GravityOnly {
+		return
+	}
+
+	// Close charge is approximated as the summed close charge acting from the center of mass (with the charge of each
+	// species multiplied by its Engine.SpeciesMatrix entry, if set)
+	if Engine.EnableCloseCharge {
+		closeCharge := n.closeCharge
+		if Engine.SpeciesMatrix != nil {
+			closeCharge = n.otherCloseCharge
+			for s, sc := range n.speciesCloseCharge {
+				closeCharge += sc * speciesPairFactor(p.Species(), s)
+			}
+		}
+		vc.Scale((Engine.CloseChargeStrength * p.CloseCharge() * closeCharge) / (p.Mass() * math.Pow(soft, 4)))
+		addInPlace(c, vc)
+	}
+
+	// Far charge is proportional to distance (with the default Engine.FarChargeExponent), so its sum is exact: the sum
+	// over o of (p - o) * o.FarCharge is p * (summed far charge) - (far charge weighted sum of positions). That is
+	// the summed far charge times the vector from the far charge weighted center, so for other exponents the summed
+	// far charge is approximated as acting from that center (which, if Engine.SignedFarCharge is set and the node holds
+	// charges of both signs, may be well away from the particles, so the approximation is rougher). Nodes entirely
+	// beyond Engine.FarChargeCutoff (if set) exert no far charge force (nodes straddling it aren't approximated; see
+	// canApproximate).
+	if !Engine.EnableFarCharge {
+		return
+	}
+	if nearest, _ := n.distanceRange(p); beyondFarChargeCutoff(nearest) {
+		return
+	}
+	vf := vector.NewWithValues([]float64{
+		p.Position()[0]*n.farCharge - n.farChargeX,
+		p.Position()[1]*n.farCharge - n.farChargeY})
+	scale := (Engine.FarChargeStrength * p.FarCharge() * -1) / p.Mass()
+	if Engine.FarChargeExponent != 1 && n.farCharge != 0 {
+		scale *= math.Pow(vf.Magnitude()/math.Abs(n.farCharge), Engine.FarChargeExponent-1)
+	}
+	vf.Scale(scale)
+	addInPlace(f, vf)
+}
@@ -0,0 +1,117 @@
+package physics
+
+import (
+	"math"
+	"sort"
+)
+
+// spatialGrid is a uniform grid of (roughly square) cells over the particle positions, used to find the particles which
+// might be colliding with a particle (collision candidates) without testing every pair of particles for collisions.
+// It is built from the particle positions at the start of each velocity update (see updateParticleVelocities), during
+// which positions don't change. Velocities may change (e.g. when particles bounce), so the fastest speed is tracked to
+// keep the candidates a superset of the particles which could collide during the step.
+type spatialGrid struct {
+	// cellSize is the width and height of each cell.
+	cellSize [2]float64
+	// wrapCells is the number of cells along each axis if the environment wraps around along it (the cells are then
+	// sized to divide the environment exactly, so cells can be wrapped like positions), or 0 if it doesn't.
+	wrapCells [2]int
+	// cells holds the indexes (into the particles the grid was built from, in ascending order) of the particles within
+	// each (non-empty) cell.
+	cells map[[2]int][]int
+	// maxRadius is the largest Radius of the particles.
+	maxRadius int
+	// maxSpeed is the largest speed (velocity magnitude) of the particles (see updateMaxSpeed).
+	maxSpeed float64
+}
+
+// newSpatialGrid builds a spatialGrid over the provided particles. The cell size is based on the largest particle
+// radius (two particles can only be colliding if within the combined radii of the two largest particles).
+func newSpatialGrid(particles []*Particle) *spatialGrid {
+	g := &spatialGrid{cells: make(map[[2]int][]int)}
+	for _, p := range particles {
+		if p.Radius > g.maxRadius {
+			g.maxRadius = p.Radius
+		}
+		g.updateMaxSpeed(p)
+	}
+
+	for axis := range g.cellSize {
+		g.cellSize[axis] = math.Max(2*float64(g.maxRadius), 1)
+		if Engine.wrapsAxis(axis) {
+			size := Engine.environmentExtent(axis)
+			g.wrapCells[axis] = int(math.Max(math.Floor(size/g.cellSize[axis]), 1))
+			g.cellSize[axis] = size / float64(g.wrapCells[axis])
+		}
+	}
+
+	for i, p := range particles {
+		k := [2]int{g.cell(p.Position()[0], 0), g.cell(p.Position()[1], 1)}
+		g.cells[k] = append(g.cells[k], i)
+	}
+
+	return g
+}
+
+// cell gets the (wrapped, if the environment wraps around) cell coordinate of position coordinate x along axis.
+func (g *spatialGrid) cell(x float64, axis int) int {
+	return g.wrap(int(math.Floor(x/g.cellSize[axis])), axis)
+}
+
+// wrap wraps cell coordinate c along axis into the grid, if the environment wraps around.
+func (g *spatialGrid) wrap(c int, axis int) int {
+	if g.wrapCells[axis] == 0 {
+		return c
+	}
+	c %= g.wrapCells[axis]
+	if c < 0 {
+		c += g.wrapCells[axis]
+	}
+	return c
+}
+
+// updateMaxSpeed updates the tracked fastest speed, such as after Particle p's velocity changes.
+func (g *spatialGrid) updateMaxSpeed(p *Particle) {
+	g.maxSpeed = math.Max(g.maxSpeed, p.Velocity().Magnitude())
+}
+
+// candidates finds the particles which might be colliding with Particle p, or would pass through it during a step of
+// time dt (see interactParticles). Returns their indexes (in ascending order), or true if every particle is a
+// candidate (when p's reach covers more cells than are occupied, it's quicker to test every particle).
+func (g *spatialGrid) candidates(p *Particle, dt float64) ([]int, bool) {
+	// The furthest another particle can be from p and still collide with it during the step (the closest approach
+	// of two particles during the step is at least their starting distance minus their relative speed times dt)
+	reach := float64(p.Radius+g.maxRadius) + (p.Velocity().Magnitude()+g.maxSpeed)*math.Abs(dt)
+
+	// The number of cells along each axis within reach (checked before converting to cell coordinates, which might
+	// overflow for very fast particles)
+	var span [2]float64
+	for axis := range span {
+		span[axis] = 2*reach/g.cellSize[axis] + 2
+		if g.wrapCells[axis] > 0 {
+			span[axis] = math.Min(span[axis], float64(g.wrapCells[axis]))
+		}
+	}
+	if span[0]*span[1] > float64(len(g.cells)) {
+		return nil, true
+	}
+
+	var lo, hi [2]int
+	for axis := range lo {
+		lo[axis] = int(math.Floor((p.Position()[axis] - reach) / g.cellSize[axis]))
+		hi[axis] = int(math.Floor((p.Position()[axis] + reach) / g.cellSize[axis]))
+		// Don't visit any (wrapped) cell twice
+		if g.wrapCells[axis] > 0 && hi[axis]-lo[axis] >= g.wrapCells[axis] {
+			lo[axis], hi[axis] = 0, g.wrapCells[axis]-1
+		}
+	}
+
+	var c []int
+	for x := lo[0]; x <= hi[0]; x++ {
+		for y := lo[1]; y <= hi[1]; y++ {
+			c = append(c, g.cells[[2]int{g.wrap(x, 0), g.wrap(y, 1)}]...)
+		}
+	}
+	sort.Ints(c)
+	return c, false
+}
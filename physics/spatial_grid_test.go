@@ -0,0 +1,54 @@
+package physics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/atedja/go-vector"
+)
+
+// TestSpatialGridCandidates checks that the collision candidates of each particle include every particle which could
+// collide with it during a step (compared against testing every pair), with and without a wrapping boundary.
+func TestSpatialGridCandidates(t *testing.T) {
+	const dt = 1
+	for _, wrap := range []bool{false, true} {
+		resetEngine(randomParticles(300, 2)...)
+		Engine.WallBounce, Engine.WrapBoundary = !wrap, wrap
+		grid := newSpatialGrid(Engine.Particles)
+		for _, p := range Engine.Particles {
+			candidates, all := grid.candidates(p, dt)
+			if all {
+				continue
+			}
+			in := make(map[int]bool)
+			for _, i := range candidates {
+				in[i] = true
+			}
+			for i, o := range Engine.Particles {
+				v := minimumImage(vector.Subtract(p.Position(), o.Position()))
+				reach := float64(p.Radius+o.Radius) + (p.Velocity().Magnitude()+o.Velocity().Magnitude())*dt
+				if v.Magnitude() < reach && !in[i] {
+					t.Errorf("wrap %v: particle %d (%v) isn't a candidate of particle %d (%v)", wrap, o.ID(),
+						o.Position(), p.ID(), p.Position())
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkSpatialGrid benchmarks building a spatialGrid and finding the collision candidates of every particle, for
+// several numbers of particles.
+func BenchmarkSpatialGrid(b *testing.B) {
+	for _, n := range []int{100, 500, 1000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			resetEngine(randomParticles(n, 1)...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				grid := newSpatialGrid(Engine.Particles)
+				for _, p := range Engine.Particles {
+					grid.candidates(p, Engine.TimeStep)
+				}
+			}
+		})
+	}
+}
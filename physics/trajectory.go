@@ -0,0 +1,121 @@
+package physics
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/atedja/go-vector"
+	log "github.com/sirupsen/logrus"
+)
+
+// trajectoryRecorder writes the particles' trajectories to a file as JSON Lines (see StartTrajectory).
+type trajectoryRecorder struct {
+	file   *os.File
+	writer *bufio.Writer
+	// step is the number of the next step written (the steps are counted from the start of the recording).
+	step int
+	// err is the first error writing the file, after which nothing more is written.
+	err error
+}
+
+// trajectoryStep is a line of a trajectory recording: the particles after a step.
+type trajectoryStep struct {
+	Step      int                  `json:"step"`
+	Particles []trajectoryParticle `json:"particles"`
+}
+
+// trajectoryParticle is a particle in a trajectoryStep.
+type trajectoryParticle struct {
+	ID       int           `json:"id"`
+	Position vector.Vector `json:"position"`
+	Velocity vector.Vector `json:"velocity"`
+}
+
+var (
+	// trajectory is the current trajectory recording, or nil if not recording.
+	trajectory *trajectoryRecorder
+	// trajectoryLock guards trajectory, which is started and stopped by the GUI while the particles are updated.
+	trajectoryLock sync.Mutex
+)
+
+// StartTrajectory starts recording the particles' trajectories to file (replacing it), stopping any current recording.
+// Each step (each call to UpdateParticles) is appended as a line of JSON with the step number and each particle's ID,
+// position, and velocity; the particles as they are when recording starts are written first, as step 0. Lines are
+// written as the steps happen (through a buffer), so recordings needn't fit in memory. It holds the particles lock for
+// reading (see RLockParticles) while writing the starting particles.
+func StartTrajectory(file string) error {
+	if err := StopTrajectory(); err != nil {
+		log.Warnln("Error finishing the previous trajectory recording: " + err.Error())
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	// The particles lock is acquired first (see particlesLock), as it is when the particles are updated and recorded
+	particlesLock.RLock()
+	defer particlesLock.RUnlock()
+	trajectoryLock.Lock()
+	defer trajectoryLock.Unlock()
+	trajectory = &trajectoryRecorder{file: f, writer: bufio.NewWriter(f)}
+	trajectory.record(Engine.Particles)
+	return nil
+}
+
+// StopTrajectory stops the current trajectory recording, if any, flushing and closing the file. Returns the first
+// error writing, flushing, or closing the file, if any.
+func StopTrajectory() error {
+	trajectoryLock.Lock()
+	defer trajectoryLock.Unlock()
+	if trajectory == nil {
+		return nil
+	}
+	t := trajectory
+	trajectory = nil
+
+	err := t.err
+	if ferr := t.writer.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := t.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// RecordingTrajectory indicates whether the particles' trajectories are being recorded (see StartTrajectory).
+func RecordingTrajectory() bool {
+	trajectoryLock.Lock()
+	defer trajectoryLock.Unlock()
+	return trajectory != nil
+}
+
+// recordTrajectory appends the current particles to the trajectory recording, if recording.
+func recordTrajectory() {
+	trajectoryLock.Lock()
+	defer trajectoryLock.Unlock()
+	if trajectory != nil {
+		trajectory.record(Engine.Particles)
+	}
+}
+
+// record writes the particles as the next step, unless writing has already failed (in which case the error is returned
+// by StopTrajectory).
+func (t *trajectoryRecorder) record(particles []*Particle) {
+	if t.err != nil {
+		return
+	}
+	line := trajectoryStep{Step: t.step, Particles: make([]trajectoryParticle, len(particles))}
+	for i, p := range particles {
+		line.Particles[i] = trajectoryParticle{ID: p.ID(), Position: p.Position(), Velocity: p.Velocity()}
+	}
+	// Encode appends a newline, so each step is a line
+	if err := json.NewEncoder(t.writer).Encode(line); err != nil {
+		t.err = err
+		log.Warnln("Unable to write trajectory: " + err.Error())
+		return
+	}
+	t.step++
+}
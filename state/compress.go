@@ -0,0 +1,46 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+)
+
+// GzipExtension is the extension of files saved (see Compress) as gzipped json, such as "state.json.gz".
+const GzipExtension = ".gz"
+
+// gzipMagic is the bytes gzipped data starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Compress returns a writer which writes to w, gzipping the data if file (the name of the file w writes to) ends with
+// GzipExtension. The writer must be closed to finish writing (which doesn't close w).
+func Compress(w io.Writer, file string) io.WriteCloser {
+	if strings.HasSuffix(file, GzipExtension) {
+		return gzip.NewWriter(w)
+	}
+	return nopWriteCloser{w}
+}
+
+// Decompress returns a reader of the data read from r, which is gunzipped if it's gzipped (detected by the data
+// starting with gzipMagic, whatever the file is named). Saved Data and Settings are decompressed this way when decoded
+// (see Decode and DecodeSettings), so they may be read whether they were saved compressed or not.
+func Decompress(r io.Reader) (io.Reader, error) {
+	b := bufio.NewReader(r)
+	// Fewer bytes (e.g. an empty file) can't be gzipped; the error reading them, if any, is left to the reader
+	if magic, err := b.Peek(len(gzipMagic)); err != nil || !bytes.Equal(magic, gzipMagic) {
+		return b, nil
+	}
+	return gzip.NewReader(b)
+}
+
+// nopWriteCloser is an io.WriteCloser whose Close does nothing (see Compress).
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer.Close, doing nothing.
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package state
+
+import (
+	"GoGoGadgetGravity/physics"
+)
+
+// History is a bounded undo/redo history of Data snapshots. A snapshot of the current Data is recorded (with Record)
+// before each change which should be undoable, and Undo / Redo then step back and forth through the snapshots.
+type History struct {
+	// limit is the maximum number of snapshots kept on each of the undo and redo stacks (the oldest are discarded).
+	limit int
+	// undo is the stack of snapshots to return to with Undo (most recent last).
+	undo []*Data
+	// redo is the stack of snapshots to return to with Redo (most recent last). It is cleared when a new change is
+	// recorded.
+	redo []*Data
+	// lastChange is the change most recently recorded (see Record).
+	lastChange string
+}
+
+// NewHistory is a factory for creating a new (empty) History, keeping up to limit snapshots.
+func NewHistory(limit int) *History {
+	return &History{limit: limit}
+}
+
+// Clone creates a copy of Data d, including a copy of its PhysicsEngine (see physics.EngineData.Clone).
+func (d *Data) Clone() *Data {
+	c := *d
+	c.PhysicsEngine = d.PhysicsEngine.Clone()
+	return &c
+}
+
+// Record records a snapshot of current (which is about to be changed) so that the change may be undone, and clears the
+// redo stack.
+// change identifies what is being changed (e.g. the name of the setting). Consecutive changes with the same non-empty
+// change (such as the many values a slider passes through as it's dragged) are recorded as a single change, so that
+// they are undone together.
+func (h *History) Record(current *Data, change string) {
+	if change != "" && change == h.lastChange {
+		return
+	}
+	h.lastChange = change
+	h.undo = h.push(h.undo, snapshot(current))
+	h.redo = nil
+}
+
+// Undo returns the most recently recorded snapshot, and records current on the redo stack (so that Redo may return to
+// it). Returns false (and a nil snapshot) if there is nothing to undo.
+func (h *History) Undo(current *Data) (*Data, bool) {
+	if len(h.undo) == 0 {
+		return nil, false
+	}
+	d := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = h.push(h.redo, snapshot(current))
+	h.lastChange = ""
+	return d, true
+}
+
+// Redo returns the snapshot most recently undone, and records current on the undo stack. Returns false (and a nil
+// snapshot) if there is nothing to redo.
+func (h *History) Redo(current *Data) (*Data, bool) {
+	if len(h.redo) == 0 {
+		return nil, false
+	}
+	d := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = h.push(h.undo, snapshot(current))
+	h.lastChange = ""
+	return d, true
+}
+
+// snapshot clones current (see Data.Clone) holding the particles lock for reading (see physics.RLockParticles), as the
+// physics loop may be updating the particles meanwhile. It must not be called while the lock is held.
+func snapshot(current *Data) *Data {
+	physics.RLockParticles()
+	defer physics.RUnlockParticles()
+	return current.Clone()
+}
+
+// push adds snapshot d to the stack, discarding the oldest snapshot if the stack is full, and returns the stack.
+func (h *History) push(stack []*Data, d *Data) []*Data {
+	stack = append(stack, d)
+	if len(stack) > h.limit {
+		stack = stack[len(stack)-h.limit:]
+	}
+	return stack
+}
@@ -0,0 +1,95 @@
+package state
+
+import (
+	"encoding/json"
+	"io"
+
+	"GoGoGadgetGravity/physics"
+)
+
+// SettingsKind is the kind of saved Settings, which distinguishes them from saved Data (which has no kind).
+const SettingsKind = "settings"
+
+// Settings is a shareable set of simulation parameters: the physics engine settings (without the particles), the
+// particle generation settings, and the physics loop speed. It is saved in the same (versioned) format as Data, with
+// the Kind SettingsKind and no particles.
+type Settings struct {
+	// Kind is SettingsKind
+	Kind string `json:"kind"`
+	// Version is the version of the saved (json) format of the settings (see CurrentVersion)
+	Version int `json:"version"`
+	// See Data. Its Particles are always empty.
+	PhysicsEngine       *physics.EngineData `json:"physics_engine"`
+	NumberOfParticles   int                 `json:"number_of_particles"`
+	AverageMass         int                 `json:"average_mass"`
+	InitialVelocityMode InitialVelocityMode `json:"initial_velocity_mode"`
+	InitialSpeed        float64             `json:"initial_speed"`
+	ChargeDistribution  ChargeDistribution  `json:"charge_distribution"`
+	GenerationRegions   []GenerationRegion  `json:"generation_regions"`
+	PhysicsLoopSpeed    int                 `json:"physics_loop_speed"`
+}
+
+// Settings gets the Settings of d. Its PhysicsEngine is a copy of d's, with no particles.
+func (d *Data) Settings() *Settings {
+	engine := *d.PhysicsEngine
+	engine.Particles = nil
+	return &Settings{
+		Kind:                SettingsKind,
+		Version:             CurrentVersion,
+		PhysicsEngine:       &engine,
+		NumberOfParticles:   d.NumberOfParticles,
+		AverageMass:         d.AverageMass,
+		InitialVelocityMode: d.InitialVelocityMode,
+		InitialSpeed:        d.InitialSpeed,
+		ChargeDistribution:  d.ChargeDistribution,
+		GenerationRegions:   d.GenerationRegions,
+		PhysicsLoopSpeed:    d.PhysicsLoopSpeed,
+	}
+}
+
+// ApplySettings sets d's settings to s. d's PhysicsEngine keeps its particles (and isn't replaced; the settings are
+// copied into it).
+func (d *Data) ApplySettings(s *Settings) {
+	particles := d.PhysicsEngine.Particles
+	*d.PhysicsEngine = *s.PhysicsEngine
+	d.PhysicsEngine.Particles = particles
+	d.NumberOfParticles = s.NumberOfParticles
+	d.AverageMass = s.AverageMass
+	d.InitialVelocityMode = s.InitialVelocityMode
+	d.InitialSpeed = s.InitialSpeed
+	d.ChargeDistribution = s.ChargeDistribution
+	d.GenerationRegions = s.GenerationRegions
+	d.PhysicsLoopSpeed = s.PhysicsLoopSpeed
+}
+
+// DecodeSettings decodes saved (json) Settings read from r into s, first upgrading settings saved in older versions of
+// the format to the CurrentVersion (as Decode does). Saved Data may also be decoded as Settings, in which case its
+// particles (and other state) are ignored. Values not in the data keep their values in s (so s may be initialized with
+// the current settings first). Returns an error if the data can't be decoded or was saved in a newer (unknown) version.
+func DecodeSettings(r io.Reader, s *Settings) error {
+	fields, err := upgrade(r)
+	if err != nil {
+		return err
+	}
+	if raw, ok := fields["physics_engine"]; ok && string(raw) != "null" {
+		var engine map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &engine); err != nil {
+			return err
+		}
+		delete(engine, "particles")
+		if fields["physics_engine"], err = json.Marshal(engine); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, s); err != nil {
+		return err
+	}
+	s.Kind = SettingsKind
+	s.Version = CurrentVersion
+	return nil
+}
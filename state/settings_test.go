@@ -0,0 +1,95 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"GoGoGadgetGravity/physics"
+)
+
+// newData creates Data with an initialized (default) PhysicsEngine, not the physics.Engine.
+func newData() *Data {
+	d := &Data{PhysicsEngine: &physics.EngineData{}}
+	d.PhysicsEngine.Initialize()
+	return d
+}
+
+// newSettings creates Settings with an initialized (default) PhysicsEngine, to decode saved settings into.
+func newSettings() *Settings {
+	return newData().Settings()
+}
+
+// TestSettingsRoundTrip checks that saved Settings decode to the same settings.
+func TestSettingsRoundTrip(t *testing.T) {
+	d := newData()
+	d.PhysicsEngine.GravityStrength = -3
+	d.PhysicsEngine.EnvironmentWidth, d.PhysicsEngine.EnvironmentHeight = 640, 480
+	d.PhysicsEngine.SpeciesMatrix = [][]float64{{1, -1}, {0.5, 1}}
+	d.PhysicsEngine.Particles = []*physics.Particle{physics.NewParticle(10, 0, 0, 5, 5)}
+	d.NumberOfParticles = 42
+	d.ChargeDistribution = ChargesBimodal
+	d.GenerationRegions = []GenerationRegion{{Shape: RegionRectangle, X: 10, Y: 20, Width: 30, Height: 40}}
+	d.PhysicsLoopSpeed = 7
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(d.Settings()); err != nil {
+		t.Fatal(err)
+	}
+	s := newSettings()
+	if err := DecodeSettings(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	if want := d.Settings(); !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+	if len(s.PhysicsEngine.Particles) != 0 {
+		t.Errorf("got %d particles, want none", len(s.PhysicsEngine.Particles))
+	}
+}
+
+// TestDecodeSettingsVersions checks that settings saved in older versions of the format are upgraded, and that those
+// saved in newer versions aren't decoded.
+func TestDecodeSettingsVersions(t *testing.T) {
+	tests := []struct {
+		name          string
+		saved         string
+		width, height int
+		err           string
+	}{
+		{"unversioned", `{"physics_engine": {"environment_size": 300}}`, 300, 300, ""},
+		{"version 1", `{"version": 1, "physics_engine": {"environment_size": 500}}`, 500, 500, ""},
+		{"current", `{"version": 2, "physics_engine": {"environment_width": 300, "environment_height": 200}}`, 300,
+			200, ""},
+		{"data", `{"version": 2, "physics_engine": {"environment_width": 300, "particles": [{"mass": 1}]}}`, 300, 800,
+			""},
+		{"newer", `{"version": 3, "physics_engine": {"environment_width": 300}}`, 800, 800, "newer version"},
+	}
+	for _, test := range tests {
+		s := newSettings()
+		err := DecodeSettings(strings.NewReader(test.saved), s)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: got error %v", test.name, err)
+			continue
+		}
+		if s.Version != CurrentVersion || s.Kind != SettingsKind {
+			t.Errorf("%s: got version %d and kind %q, want %d and %q", test.name, s.Version, s.Kind, CurrentVersion,
+				SettingsKind)
+		}
+		if e := s.PhysicsEngine; e.EnvironmentWidth != test.width || e.EnvironmentHeight != test.height {
+			t.Errorf("%s: got environment %dx%d, want %dx%d", test.name, e.EnvironmentWidth, e.EnvironmentHeight,
+				test.width, test.height)
+		}
+		if len(s.PhysicsEngine.Particles) != 0 {
+			t.Errorf("%s: got %d particles, want none", test.name, len(s.PhysicsEngine.Particles))
+		}
+	}
+}
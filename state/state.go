@@ -5,19 +5,177 @@ import (
 	"GoGoGadgetGravity/physics"
 )
 
+// TrailStyle is the type for the styles in which physics.Particle position history trails may be drawn (see
+// Data.TrailStyle).
+type TrailStyle int
+
+const (
+	// TrailCircles draws a progressively smaller and fainter circle at each historical position. This is the default
+	// (zero value) style.
+	TrailCircles TrailStyle = iota
+	// TrailLines draws lines connecting the historical positions, fading along the trail.
+	TrailLines
+)
+
+// TrailColoring is the type for the ways in which physics.Particle position history trails may be colored (see
+// Data.TrailColoring).
+type TrailColoring int
+
+const (
+	// TrailColorParticle colors trails like their particles (see physics.ColorScheme). This is the default (zero value)
+	// coloring.
+	TrailColorParticle TrailColoring = iota
+	// TrailColorAge colors trails along a cold-to-hot gradient by the age of each historical position (the oldest
+	// blue, the newest red), regardless of the particle, to emphasize motion over identity.
+	TrailColorAge
+)
+
+// InitialVelocityMode is the type for the ways in which generated physics.Particle velocities may be initialized (see
+// Data.InitialVelocityMode).
+type InitialVelocityMode int
+
+const (
+	// VelocityZero generates particles at rest. This is the default (zero value) mode.
+	VelocityZero InitialVelocityMode = iota
+	// VelocityThermal generates particles moving in random directions, with each velocity component normally
+	// distributed (so speeds follow the 2D Maxwell-Boltzmann, i.e. Rayleigh, distribution). Data.InitialSpeed is the
+	// standard deviation of each component.
+	VelocityThermal
+	// VelocityTangential generates particles moving perpendicular to the line from the center of the environment (as
+	// if the environment were rotating as a rigid disc). Speeds are proportional to the distance from the center, with
+	// Data.InitialSpeed the speed at the nearest edge.
+	VelocityTangential
+)
+
+// ChargeDistribution is the type for the distributions generated physics.Particle close charges may be drawn from (see
+// Data.ChargeDistribution). Far charges are always drawn uniformly from their range.
+type ChargeDistribution int
+
+const (
+	// ChargesUniform draws close charges uniformly from [-1, 1]. This is the default (zero value) distribution.
+	ChargesUniform ChargeDistribution = iota
+	// ChargesBimodal draws close charges near -1 or 1 (with equal probability), so particles strongly attract or repel
+	// each other.
+	ChargesBimodal
+	// ChargesNormal draws close charges from a (clamped) normal distribution around zero, so most particles are weakly
+	// charged.
+	ChargesNormal
+)
+
+// RegionShape is the type for the shapes of the regions physics.Particles may be generated within (see
+// GenerationRegion).
+type RegionShape int
+
+const (
+	// RegionCircle is a circle, of GenerationRegion.Radius. This is the default (zero value) shape.
+	RegionCircle RegionShape = iota
+	// RegionRectangle is a rectangle, of GenerationRegion.Width and GenerationRegion.Height.
+	RegionRectangle
+)
+
+// Color is an RGBA color (with straight, not premultiplied, alpha), such as Data.BackgroundColor.
+type Color struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+	A uint8 `json:"a"`
+}
+
+// DefaultWallColor is the default Data.WallColor (opaque blue). The default Data.BackgroundColor is the zero value
+// (transparent).
+var DefaultWallColor = Color{B: 255, A: 255}
+
+// GenerationRegion is a region of the environment physics.Particles may be generated within (see
+// Data.GenerationRegions).
+type GenerationRegion struct {
+	// Shape is the shape of the region
+	Shape RegionShape `json:"shape"`
+	// X and Y are the center of the region
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	// Radius is the radius of a RegionCircle region
+	Radius float64 `json:"radius"`
+	// Width and Height are the size of a RegionRectangle region
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
 // Data is the primary struct for GGGG, used by the main app and the guis package to hold state information.
 type Data struct {
+	// Version is the version of the saved (json) format of the data (see CurrentVersion)
+	Version int `json:"version"`
 	// PhysicsEngine is a pointer to the physics.Engine variable (single physics.EngineData instance)
 	PhysicsEngine *physics.EngineData `json:"physics_engine"`
 	// NumberOfParticles is the (desired) number of physics.Engine.Particles
 	NumberOfParticles int `json:"number_of_particles"`
 	// AverageMass is the desired average mass of physics.Engine.Particles to be generated
 	AverageMass int `json:"average_mass"`
+	// InitialVelocityMode is the way the velocities of physics.Engine.Particles to be generated are initialized
+	InitialVelocityMode InitialVelocityMode `json:"initial_velocity_mode"`
+	// InitialSpeed scales the initial velocities of physics.Engine.Particles to be generated (see InitialVelocityMode)
+	InitialSpeed float64 `json:"initial_speed"`
+	// ChargeDistribution is the distribution the close charges of physics.Engine.Particles to be generated are drawn
+	// from
+	ChargeDistribution ChargeDistribution `json:"charge_distribution"`
+	// GenerationRegions are the regions physics.Engine.Particles to be generated are placed within: the particles are
+	// divided evenly between the regions (in turn), each placed uniformly at random within its region. If empty (the
+	// default), particles are placed uniformly at random across the whole environment. Several regions (e.g. two
+	// separate clumps) can be set up, such as to collide clusters.
+	GenerationRegions []GenerationRegion `json:"generation_regions"`
 	// HistoryTrail indicates whether physics.Particle position histories are being tracked/displayed
 	HistoryTrail bool `json:"history_trail"`
 	// HistoryLength is the number of previous physics.Particle positions stored/displayed
 	HistoryLength int `json:"history_length"`
+	// HistoryStride is the number of physics.Particle position updates between the positions stored (so longer strides
+	// give trails spanning more time)
+	HistoryStride int `json:"history_stride"`
+	// TrailStyle is the style in which position history trails are drawn
+	TrailStyle TrailStyle `json:"trail_style"`
+	// TrailColoring is the way in which position history trails are colored
+	TrailColoring TrailColoring `json:"trail_coloring"`
+	// HistoryPrefill is the way empty physics.Particle position histories are pre-filled when tracking starts, so that
+	// trails appear at full length immediately (see physics.SetHistoryPrefill)
+	HistoryPrefill physics.HistoryPrefill `json:"history_prefill"`
+	// DrawRadiusScale is the multiplier applied to physics.Particle radii when they are drawn. It only affects the
+	// display; collisions etc. use the unscaled Radius.
+	DrawRadiusScale float64 `json:"draw_radius_scale"`
+	// MinDrawRadius is the smallest radius physics.Particles are drawn with (after scaling by DrawRadiusScale), so that
+	// tiny particles are visible (and clickable) even when the view is scaled down. Like DrawRadiusScale, it only
+	// affects the display.
+	MinDrawRadius int `json:"min_draw_radius"`
+	// ShowVelocityVectors indicates whether an arrow along each physics.Particle's velocity is drawn over it.
+	ShowVelocityVectors bool `json:"show_velocity_vectors"`
+	// ShowParticleIDs indicates whether each physics.Particle's ID is drawn next to it.
+	ShowParticleIDs bool `json:"show_particle_ids"`
+	// DensityHeatmap indicates whether the particles are drawn as a (mass) density heatmap rather than as circles.
+	DensityHeatmap bool `json:"density_heatmap"`
+	// CenterOfMassFrame indicates whether the particles are drawn in their center of mass frame (shifted so that their
+	// center of mass is at the center of the environment, and with its velocity subtracted from the velocity vectors).
+	// It only affects drawing; the particles' positions and velocities are unchanged.
+	CenterOfMassFrame bool `json:"center_of_mass_frame"`
+	// SmoothRendering indicates whether the particles are drawn as anti-aliased circles (with their edges blended into
+	// what's behind them) rather than hard-edged ones.
+	SmoothRendering bool `json:"smooth_rendering"`
+	// BackgroundColor is the color the environment is filled with (behind the particles) when drawn. The default (zero
+	// value) is transparent, so whatever the GUI shows behind the environment shows through.
+	BackgroundColor Color `json:"background_color"`
+	// WallColor is the color of the box drawn around the environment, at its walls (see DefaultWallColor).
+	WallColor Color `json:"wall_color"`
+	// PauseOnMerge indicates whether the simulation automatically pauses when particles merge.
+	PauseOnMerge bool `json:"pause_on_merge"`
+	// PauseOnSpeed indicates whether the simulation automatically pauses when a particle's speed exceeds
+	// PauseSpeedThreshold (such as when the simulation "explodes").
+	PauseOnSpeed bool `json:"pause_on_speed"`
+	// PauseSpeedThreshold is the particle speed above which the simulation automatically pauses, if PauseOnSpeed is set.
+	PauseSpeedThreshold float64 `json:"pause_speed_threshold"`
 	// PhysicsLoopSpeed is the frequency with which the simulation is updated, in milliseconds. Essentially, how often
 	// physics.UpdateParticles is called.
 	PhysicsLoopSpeed int `json:"physics_loop_speed"`
+	// StepsPerFrame is the number of times physics.UpdateParticles is called each PhysicsLoopSpeed interval, between
+	// drawing the particles. Drawing is much slower than updating, so values above 1 (a "turbo" mode) fast-forward the
+	// simulation. Values below 1 are treated as 1 (the default, drawing every update).
+	StepsPerFrame int `json:"steps_per_frame"`
+	// AutoSlowdown indicates whether PhysicsLoopSpeed is automatically increased when the simulation can't keep up with
+	// it (rather than the updates running back-to-back, as fast as they can, at whatever speed that is).
+	AutoSlowdown bool `json:"auto_slowdown"`
 }
@@ -0,0 +1,124 @@
+package state
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"GoGoGadgetGravity/physics"
+)
+
+// TestStateRoundTrip checks that Data saved to plain and gzipped files decodes to the same settings and particles.
+func TestStateRoundTrip(t *testing.T) {
+	tests := []struct {
+		file     string
+		wantGzip bool
+	}{
+		{"state.json", false},
+		{"state.json.gz", true},
+	}
+	for _, test := range tests {
+		d := newData()
+		d.PhysicsEngine.GravityStrength = 7
+		d.PhysicsEngine.EnvironmentWidth = 640
+		p := physics.NewParticle(25, -0.5, 0.25, 10, 20)
+		p.SetVelocity([]float64{1, -2})
+		d.PhysicsEngine.Particles = []*physics.Particle{p, physics.NewParticle(9, 0.5, 1, 300, 200)}
+		d.NumberOfParticles = 2
+		d.TrailStyle = TrailLines
+		d.WallColor = Color{R: 1, G: 2, B: 3, A: 4}
+
+		var buf bytes.Buffer
+		w := Compress(&buf, test.file)
+		if err := json.NewEncoder(w).Encode(d); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if gzipped := bytes.HasPrefix(buf.Bytes(), gzipMagic); gzipped != test.wantGzip {
+			t.Errorf("%s: got gzipped %v, want %v", test.file, gzipped, test.wantGzip)
+		}
+
+		got := newData()
+		if err := Decode(&buf, got); err != nil {
+			t.Errorf("%s: got error %v", test.file, err)
+			continue
+		}
+		if got.Version != CurrentVersion || got.NumberOfParticles != 2 || got.TrailStyle != TrailLines ||
+			got.WallColor != d.WallColor || got.PhysicsEngine.GravityStrength != 7 ||
+			got.PhysicsEngine.EnvironmentWidth != 640 {
+			t.Errorf("%s: got %+v (engine %+v), want %+v (engine %+v)", test.file, got, got.PhysicsEngine, d,
+				d.PhysicsEngine)
+		}
+		if len(got.PhysicsEngine.Particles) != 2 {
+			t.Errorf("%s: got %d particles, want 2", test.file, len(got.PhysicsEngine.Particles))
+			continue
+		}
+		for i, p := range got.PhysicsEngine.Particles {
+			want := d.PhysicsEngine.Particles[i]
+			if p.String() != want.String() {
+				t.Errorf("%s: got particle %v, want %v", test.file, p, want)
+			}
+		}
+	}
+}
+
+// TestDecodeVersions checks that Data saved in older versions of the format (plain or gzipped) is upgraded, and that
+// Data saved in newer versions, and saved Settings, aren't decoded.
+func TestDecodeVersions(t *testing.T) {
+	tests := []struct {
+		name          string
+		saved         string
+		width, height int
+		err           string
+	}{
+		{"unversioned", `{"physics_engine": {"environment_size": 300}, "number_of_particles": 5}`, 300, 300, ""},
+		{"version 1", `{"version": 1, "physics_engine": {"environment_size": 500}}`, 500, 500, ""},
+		{"current", `{"version": 2, "physics_engine": {"environment_width": 300, "environment_height": 200}}`, 300,
+			200, ""},
+		{"newer", `{"version": 3, "physics_engine": {"environment_width": 300}}`, 800, 800, "newer version"},
+		{"settings", `{"kind": "settings", "version": 2, "physics_engine": {"environment_width": 300}}`, 800, 800,
+			"only settings"},
+	}
+	for _, test := range tests {
+		for _, gzipped := range []bool{false, true} {
+			var buf bytes.Buffer
+			if gzipped {
+				w := gzip.NewWriter(&buf)
+				if _, err := w.Write([]byte(test.saved)); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				buf.WriteString(test.saved)
+			}
+
+			d := newData()
+			err := Decode(&buf, d)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("%s (gzipped %v): got error %v, want %q", test.name, gzipped, err, test.err)
+				}
+				if d.PhysicsEngine.EnvironmentWidth != test.width {
+					t.Errorf("%s (gzipped %v): data changed", test.name, gzipped)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s (gzipped %v): got error %v", test.name, gzipped, err)
+				continue
+			}
+			if e := d.PhysicsEngine; e.EnvironmentWidth != test.width || e.EnvironmentHeight != test.height ||
+				d.Version != CurrentVersion {
+				t.Errorf("%s (gzipped %v): got environment %dx%d (version %d), want %dx%d (version %d)", test.name,
+					gzipped, e.EnvironmentWidth, e.EnvironmentHeight, d.Version, test.width, test.height,
+					CurrentVersion)
+			}
+		}
+	}
+}
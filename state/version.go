@@ -0,0 +1,104 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CurrentVersion is the current version of the saved (json) Data format. It is increased (and a migration added to
+// migrations) whenever a change to Data or physics.EngineData means older saved data would be misread.
+const CurrentVersion = 2
+
+// migrations holds the functions which upgrade saved Data from each version to the next (migrations[v] upgrades
+// version v to v+1), by transforming the top-level json fields in place.
+var migrations = []func(fields map[string]json.RawMessage) error{
+	// Version 0 (saved before the version was added) has the same layout as version 1
+	func(fields map[string]json.RawMessage) error { return nil },
+	// Version 2 replaced the (square) environment size with its width and height
+	splitEnvironmentSize,
+}
+
+// splitEnvironmentSize upgrades version 1 Data to version 2, setting both the environment width and height to the
+// (square) environment size.
+func splitEnvironmentSize(fields map[string]json.RawMessage) error {
+	raw, ok := fields["physics_engine"]
+	if !ok || string(raw) == "null" {
+		return nil
+	}
+	var engine map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &engine); err != nil {
+		return err
+	}
+	if size, ok := engine["environment_size"]; ok {
+		engine["environment_width"] = size
+		engine["environment_height"] = size
+		delete(engine, "environment_size")
+	}
+	raw, err := json.Marshal(engine)
+	if err != nil {
+		return err
+	}
+	fields["physics_engine"] = raw
+	return nil
+}
+
+// Decode decodes saved (json) Data read from r into d, first upgrading data saved in older versions of the format to
+// the CurrentVersion. Values not in the data keep their values in d (so d may be initialized with defaults first).
+// Returns an error if the data can't be decoded, was saved in a newer (unknown) version, or is saved Settings rather
+// than Data (in which case d is left unchanged, rather than partially loaded).
+func Decode(r io.Reader, d *Data) error {
+	fields, err := upgrade(r)
+	if err != nil {
+		return err
+	}
+	if kind, ok := fields["kind"]; ok && string(kind) == `"`+SettingsKind+`"` {
+		return fmt.Errorf("the file contains only settings (no particles); load it as settings instead")
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, d); err != nil {
+		return err
+	}
+	d.Version = CurrentVersion
+	return nil
+}
+
+// upgrade reads saved (json, optionally gzipped; see Decompress) Data or Settings from r, and upgrades it from the
+// version it was saved in to the CurrentVersion. Returns the upgraded top-level json fields, or an error if the data
+// can't be read or was saved in a newer (unknown) version.
+func upgrade(r io.Reader) (map[string]json.RawMessage, error) {
+	r, err := Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	// Data saved before the version was added doesn't include it, and is version 0
+	version := 0
+	if v, ok := fields["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, fmt.Errorf("invalid version: %v", err)
+		}
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("saved by a newer version of the app (format version %d; this version supports up to %d)",
+			version, CurrentVersion)
+	}
+	if version < 0 {
+		return nil, fmt.Errorf("invalid version: %d", version)
+	}
+
+	for ; version < CurrentVersion; version++ {
+		if err := migrations[version](fields); err != nil {
+			return nil, fmt.Errorf("unable to upgrade from format version %d: %v", version, err)
+		}
+	}
+	return fields, nil
+}